@@ -9,6 +9,9 @@ export const errors = Object.freeze({
   UserDoesNotExist: "user-does-not-exist",
   EventNotFound: "event-not-found",
   InvalidCredentials: "invalid-credentials",
+  ValidationFailed: "validation-failed",
+  RateLimited: "rate-limited",
+  EmailLimitExceeded: "email-limit-exceeded",
 })
 
 // Auth types
@@ -0,0 +1,122 @@
+package chatcommands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+var activeUsers = Command{
+	Name:        "active_users",
+	Description: "Gets the number of active users in the database, based on last sign in date",
+	Args: []Arg{
+		{Name: "list", Type: BoolArg, Default: "false", Description: "Whether to list the name/email of all users, instead of showing a bar graph"},
+		{Name: "days", Type: IntArg, Default: "7", Description: "The amount of days since last sign in"},
+	},
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		list := args.Bool("list")
+		days := args.Int("days")
+
+		// Query for daily user logs starting from `days` days before the current date
+		startDate := time.Now().AddDate(0, 0, -days)
+		startDate = utils.GetDateAtTime(startDate, "00:00:00")
+		logs, err := db.DailyUserLogs.GetSince(ctx, startDate, list)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add empty days
+		curDate := startDate
+		for i := len(logs) - 1; i >= 0; i-- {
+			// Add all dates up to the current log date
+			for !logs[i].Date.Time().Equal(curDate) && curDate.Before(time.Now()) {
+				// Insert curDate into logs, with an empty users array
+				logs, err = utils.Insert(logs, i+1, models.DailyUserLog{
+					Date:  primitive.NewDateTimeFromTime(curDate),
+					Users: make([]models.User, 0),
+				})
+				if err != nil {
+					return nil, err
+				}
+				curDate = curDate.AddDate(0, 0, 1)
+			}
+
+			// Increase curDate by a day
+			curDate = curDate.AddDate(0, 0, 1)
+		}
+
+		// Add all dates up to the current date
+		for curDate.Before(time.Now()) {
+			logs, err = utils.Insert(logs, 0, models.DailyUserLog{
+				Date:  primitive.NewDateTimeFromTime(curDate),
+				Users: make([]models.User, 0),
+			})
+			if err != nil {
+				return nil, err
+			}
+			curDate = curDate.AddDate(0, 0, 1)
+		}
+
+		if list {
+			// Display a list of all active users
+			message := ""
+			for _, log := range logs {
+				date := log.Date.Time()
+				message += date.Format("Mon") + " "
+				message += utils.GetDateString(date) + " | "
+				message += fmt.Sprintf("Count: %d\n", len(log.Users))
+
+				for _, user := range log.Users {
+					message += fmt.Sprintf("\t- %s %s (%s)\n", user.FirstName, user.LastName, user.Email)
+				}
+			}
+
+			return &Message{Title: "Active Users", Code: message}, nil
+		}
+
+		// Display a bar graph of active users over time
+
+		// Generate labels and data based on logs
+		labels := make([]string, 0)
+		data := make([]int, 0)
+		for i := len(logs) - 1; i >= 0; i-- {
+			labels = append(labels, utils.GetDateString(logs[i].Date.Time()))
+			data = append(data, len(logs[i].UserIds))
+		}
+
+		// Generate chart using QuickChart API
+		chart := bson.M{
+			"type": "bar",
+			"data": bson.M{
+				"labels": labels,
+				"datasets": bson.A{bson.M{
+					"label": "Active Users",
+					"data":  data,
+				}},
+			},
+			"options": bson.M{
+				"scales": bson.M{
+					"yAxes": bson.A{bson.M{
+						"ticks": bson.M{
+							"stepSize": 1,
+						},
+					}},
+				},
+			},
+		}
+		jsonStr, _ := json.Marshal(chart)
+
+		encodedChart := url.PathEscape(string(jsonStr))
+		chartUrl := fmt.Sprintf(`https://quickchart.io/chart?c=%s&backgroundColor=white`, encodedChart)
+
+		return &Message{Title: "Active Users", ImageUrl: chartUrl}, nil
+	},
+}
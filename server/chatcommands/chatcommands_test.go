@@ -0,0 +1,138 @@
+package chatcommands
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+)
+
+func TestParse(t *testing.T) {
+	command := &Command{Name: "test", Args: []Arg{
+		{Name: "id", Type: StringArg, Required: true},
+		{Name: "list", Type: BoolArg, Default: "false"},
+		{Name: "days", Type: IntArg, Default: "7"},
+	}}
+
+	tests := []struct {
+		raw  []string
+		args Args
+		err  string
+	}{
+		{raw: []string{"abc"}, args: Args{"id": "abc", "list": false, "days": 7}},
+		{raw: []string{"abc", "true", "3"}, args: Args{"id": "abc", "list": true, "days": 3}},
+		{raw: []string{"abc", "DAYS=3"}, args: Args{"id": "abc", "list": false, "days": 3}},
+		{raw: []string{"a=b"}, args: Args{"id": "a=b", "list": false, "days": 7}},
+		{raw: []string{}, err: "ID is required!"},
+		{raw: []string{"abc", "yes"}, err: "LIST=yes is not a valid boolean!"},
+		{raw: []string{"abc", "days=x"}, err: "DAYS=x is not a valid number!"},
+		{raw: []string{"abc", "true", "3", "4"}, err: "Too many arguments!"},
+	}
+	for _, test := range tests {
+		args, err := command.Parse(test.raw)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Parse(%q) error = %v, want %q", test.raw, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", test.raw, err)
+			continue
+		}
+		for name, value := range test.args {
+			if args[name] != value {
+				t.Errorf("Parse(%q)[%s] = %v, want %v", test.raw, name, args[name], value)
+			}
+		}
+	}
+
+	if usage := command.Usage("!"); usage != "!test ID [LIST=false] [DAYS=7]" {
+		t.Errorf("unexpected usage %q", usage)
+	}
+	if args := SplitArgs(`event_info “Game night” 'a b' c`); strings.Join(args, "|") != "event_info|Game night|a b|c" {
+		t.Errorf("unexpected args %q", args)
+	}
+}
+
+func TestSplitLongMessage(t *testing.T) {
+	message := strings.Repeat(strings.Repeat("a", 99)+"\n", 30)
+	messages := splitLongMessage(message, "```")
+	if len(messages) != 2 || len(messages[0]) > charLimit || !strings.HasSuffix(messages[0], "a```") {
+		t.Fatalf("expected 2 messages split at a newline, got %d", len(messages))
+	}
+	if strings.ReplaceAll(strings.Join(messages, "\n"), "```", "") != message {
+		t.Errorf("expected the messages to contain the whole message")
+	}
+}
+
+func TestRender(t *testing.T) {
+	message := &Message{Title: "<Game night>", Url: "https://schej.it/e/1", Fields: []Field{{Name: "Responses", Value: "3"}, {Name: "Calendars"}}, Code: "a\nb"}
+
+	slack := message.Slack()
+	if len(slack) != 2 || len(slack[0].Blocks) != 2 || slack[1].Text != "```a\nb```" {
+		t.Fatalf("unexpected Slack messages %+v", slack)
+	}
+	if text := slack[0].Blocks[0]["text"].(bson.M)["text"]; text != "*<https://schej.it/e/1|&lt;Game night&gt;>*" {
+		t.Errorf("unexpected Slack title %q", text)
+	}
+
+	discord := message.Discord()
+	if len(discord) != 2 || discord[0].Embeds[0].URL != message.Url || discord[0].Embeds[0].Fields[1].Value != "-" || discord[1].Content != "```a\nb```" {
+		t.Errorf("unexpected Discord messages %+v", discord)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	logger.Init(io.Discard)
+	db.InitMemory("")
+	ctx := context.Background()
+
+	owner := models.User{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", TimezoneOffset: 240}
+	db.Users.Insert(ctx, &owner)
+	shortId := "abc12"
+	quiet := models.Event{Id: primitive.NewObjectID(), OwnerId: owner.Id, Name: "Quiet", Type: models.SPECIFIC_DATES, Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(owner.Id.Timestamp())}}
+	busy := models.Event{Id: primitive.NewObjectID(), OwnerId: owner.Id, Name: "Busy", Type: models.SPECIFIC_DATES, ShortId: &shortId, Dates: quiet.Dates}
+	db.Events.Insert(ctx, &quiet)
+	db.Events.Insert(ctx, &busy)
+	for i := 0; i < 2; i++ {
+		userId := primitive.NewObjectID()
+		db.Responses.Upsert(ctx, busy.Id, userId.Hex(), &models.Response{UserId: userId, Availability: busy.Dates})
+	}
+
+	run := func(name string, raw ...string) *Message {
+		t.Helper()
+		return Run(ctx, Find(name), "/", raw)
+	}
+
+	message := run("event_info", "https://schej.it/e/"+shortId)
+	if message.Title != "Busy" || message.Fields[0].Value != "Jane Doe (jane@example.com)" || message.Fields[4].Value != "2" {
+		t.Errorf("unexpected event info %+v", message)
+	}
+	if message := run("event_info", "nope"); message.Text != "There's no event with the id nope." {
+		t.Errorf("unexpected event info %+v", message)
+	}
+	if message := run("event_info"); message.Text != "ID is required!\nUsage: /event_info ID" {
+		t.Errorf("unexpected event info %+v", message)
+	}
+
+	message = run("user_info", "jane@example.com")
+	if message.Fields[2].Value != "UTC-04:00" || message.Fields[3].Value != "2" || message.Fields[4].Value != "0" {
+		t.Errorf("unexpected user info %+v", message.Fields)
+	}
+
+	message = run("top_events", "limit=1")
+	if !strings.HasPrefix(message.Code, "1. Busy | 2 responses |") || strings.Contains(message.Code, "Quiet") {
+		t.Errorf("unexpected top events %q", message.Code)
+	}
+
+	if message := run("signups_today"); message.Title != "1 user signed up today" || !strings.Contains(message.Code, "jane@example.com") {
+		t.Errorf("unexpected signups %+v", message)
+	}
+}
@@ -0,0 +1,205 @@
+/* Admin commands that run in both the Slack and Discord bots, e.g. /num_users in Slack or !num_users in Discord */
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"schej.it/server/logger"
+)
+
+// The type an argument is parsed as
+type ArgType string
+
+const (
+	StringArg ArgType = "text"
+	IntArg    ArgType = "number"
+	BoolArg   ArgType = "boolean"
+)
+
+type Arg struct {
+	// Lower case, it's shown in upper case in usages
+	Name        string
+	Type        ArgType
+	Description string
+
+	// Value of an optional argument that isn't given, parsed like a given value. Required arguments don't have one
+	Default  string
+	Required bool
+}
+
+type Command struct {
+	// Name without the platform's prefix, e.g. "num_users"
+	Name        string
+	Description string
+	Args        []Arg
+	Execute     func(ctx context.Context, args Args) (*Message, error)
+}
+
+// Parsed arguments, by name
+type Args map[string]interface{}
+
+func (a Args) String(name string) string {
+	value, _ := a[name].(string)
+	return value
+}
+
+func (a Args) Int(name string) int {
+	value, _ := a[name].(int)
+	return value
+}
+
+func (a Args) Bool(name string) bool {
+	value, _ := a[name].(bool)
+	return value
+}
+
+var Commands = []Command{activeUsers, numUsers, eventInfo, userInfo, topEvents, signupsToday}
+
+// Returns the command with the name, or nil if there isn't one
+func Find(name string) *Command {
+	for i := range Commands {
+		if Commands[i].Name == name {
+			return &Commands[i]
+		}
+	}
+	return nil
+}
+
+// Runs the command with the raw arguments and returns the message to reply with. "help" as the only argument
+// replies with the command's help instead. prefix is the platform's command prefix, for the usage in errors
+func Run(ctx context.Context, command *Command, prefix string, raw []string) *Message {
+	if len(raw) == 1 && raw[0] == "help" {
+		return command.Help(prefix)
+	}
+
+	args, err := command.Parse(raw)
+	if err != nil {
+		return &Message{Text: fmt.Sprintf("%v\nUsage: %s", err, command.Usage(prefix)), Ephemeral: true}
+	}
+
+	message, err := command.Execute(ctx, args)
+	if err != nil {
+		logger.FromContext(ctx).Error("Chat command failed", "command", command.Name, "error", err)
+		return &Message{Text: fmt.Sprintf("%s failed, please try again.", prefix+command.Name), Ephemeral: true}
+	}
+	return message
+}
+
+// Parses the raw arguments, which are either in the order of the command's arguments or NAME=VALUE pairs
+func (c *Command) Parse(raw []string) (Args, error) {
+	values := make(map[string]string)
+	for i, value := range raw {
+		var arg *Arg
+		if name, namedValue, ok := strings.Cut(value, "="); ok {
+			if arg = c.arg(strings.ToLower(name)); arg != nil {
+				value = namedValue
+			}
+		}
+		if arg == nil {
+			if i >= len(c.Args) {
+				return nil, fmt.Errorf("Too many arguments!")
+			}
+			arg = &c.Args[i]
+		}
+		values[arg.Name] = value
+	}
+
+	args := make(Args)
+	for _, arg := range c.Args {
+		value, ok := values[arg.Name]
+		if !ok {
+			if arg.Required {
+				return nil, fmt.Errorf("%s is required!", strings.ToUpper(arg.Name))
+			}
+			value = arg.Default
+		}
+
+		switch arg.Type {
+		case IntArg:
+			if len(value) == 0 {
+				args[arg.Name] = 0
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s=%s is not a valid %s!", strings.ToUpper(arg.Name), value, arg.Type)
+			}
+			args[arg.Name] = n
+		case BoolArg:
+			b, err := strconv.ParseBool(value)
+			if err != nil && len(value) > 0 {
+				return nil, fmt.Errorf("%s=%s is not a valid %s!", strings.ToUpper(arg.Name), value, arg.Type)
+			}
+			args[arg.Name] = b
+		default:
+			args[arg.Name] = value
+		}
+	}
+
+	return args, nil
+}
+
+func (c *Command) arg(name string) *Arg {
+	for i := range c.Args {
+		if c.Args[i].Name == name {
+			return &c.Args[i]
+		}
+	}
+	return nil
+}
+
+// Returns how the command is used, e.g. "/active_users [LIST=false] [DAYS=7]"
+func (c *Command) Usage(prefix string) string {
+	usage := prefix + c.Name
+	for _, arg := range c.Args {
+		switch {
+		case arg.Required:
+			usage += " " + strings.ToUpper(arg.Name)
+		case len(arg.Default) > 0:
+			usage += fmt.Sprintf(" [%s=%s]", strings.ToUpper(arg.Name), arg.Default)
+		default:
+			usage += fmt.Sprintf(" [%s]", strings.ToUpper(arg.Name))
+		}
+	}
+	return usage
+}
+
+// Returns the help of the command, with its usage and what each argument is
+func (c *Command) Help(prefix string) *Message {
+	message := &Message{Title: c.Usage(prefix), Text: c.Description, Ephemeral: true}
+	for _, arg := range c.Args {
+		message.Fields = append(message.Fields, Field{Name: strings.ToUpper(arg.Name), Value: fmt.Sprintf("%s (%s)", arg.Description, arg.Type)})
+	}
+	return message
+}
+
+// Returns the help of every command
+func Help(prefix string) *Message {
+	message := &Message{Title: "Commands", Text: fmt.Sprintf("Run a command with help, e.g. %snum_users help, to see its arguments.", prefix), Ephemeral: true}
+	for _, command := range Commands {
+		message.Fields = append(message.Fields, Field{Name: command.Usage(prefix), Value: command.Description})
+	}
+	return message
+}
+
+var argsRegex = regexp.MustCompile(`([^\s"']+)|"([^"]*)"|'([^']*)'`)
+
+// Splits the text of a command into arguments. Arguments are separated by spaces, unless they're quoted
+func SplitArgs(text string) []string {
+	// Chat clients can turn quotes into smart quotes
+	text = strings.NewReplacer("“", `"`, "”", `"`).Replace(text)
+
+	args := make([]string, 0)
+	for _, match := range argsRegex.FindAllStringSubmatch(text, -1) {
+		for _, el := range match[1:] {
+			if len(el) > 0 {
+				args = append(args, el)
+			}
+		}
+	}
+	return args
+}
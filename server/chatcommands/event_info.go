@@ -0,0 +1,75 @@
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
+	"schej.it/server/utils"
+)
+
+// Times are shown in UTC, since admins can be in any timezone
+const timeLayout = "Mon, Jan 2 2006 at 3:04 PM UTC"
+
+var eventInfo = Command{
+	Name:        "event_info",
+	Description: "Shows an event's owner, dates, responses and best time",
+	Args: []Arg{
+		{Name: "id", Type: StringArg, Required: true, Description: "The event's id, short id or link"},
+	},
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		event, err := chat.GetEvent(ctx, args.String("id"))
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			return &Message{Text: fmt.Sprintf("There's no event with the id %s.", args.String("id")), Ephemeral: true}, nil
+		}
+
+		owner := "Guest"
+		if !event.OwnerId.IsZero() {
+			user, err := db.Users.GetById(ctx, event.OwnerId)
+			if err != nil {
+				return nil, err
+			}
+			if user != nil {
+				owner = fmt.Sprintf("%s %s (%s)", user.FirstName, user.LastName, user.Email)
+			}
+		}
+
+		eventType := string(event.Type)
+		if utils.Coalesce(event.IsSignUpForm) {
+			eventType += ", sign up form"
+		}
+		if utils.Coalesce(event.DaysOnly) {
+			eventType += ", days only"
+		}
+
+		fields := []Field{
+			{Name: "Owner", Value: owner},
+			{Name: "Created", Value: event.Id.Timestamp().UTC().Format(timeLayout)},
+			{Name: "Type", Value: eventType},
+			{Name: "Num days", Value: fmt.Sprint(len(event.Dates))},
+			{Name: "Responses", Value: fmt.Sprint(chat.NumResponses(event))},
+		}
+		if event.Type == models.GROUP {
+			fields = append(fields, Field{Name: "Num attendees", Value: fmt.Sprint(len(utils.Coalesce(event.Attendees)))})
+		}
+		if best := event.BestTimes(1); len(best) > 0 {
+			fields = append(fields, Field{
+				Name:  "Best time",
+				Value: fmt.Sprintf("%s UTC (%d of %d available)", event.FormatTime(best[0].Time.Time().UTC()), best[0].Count, len(event.ResponsesList)),
+			})
+		}
+		if event.ScheduledEvent != nil {
+			fields = append(fields, Field{Name: "Scheduled for", Value: event.ScheduledEvent.StartDate.Time().UTC().Format(timeLayout)})
+		}
+		if event.ShortId != nil {
+			fields = append(fields, Field{Name: "Short url", Value: fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), *event.ShortId)})
+		}
+
+		return &Message{Title: event.Name, Url: chat.EventUrl(event), Fields: fields}, nil
+	},
+}
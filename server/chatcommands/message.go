@@ -0,0 +1,148 @@
+package chatcommands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The reply of a command, which each platform renders in its own format. Text is plain text, since Slack and
+// Discord format text differently
+type Message struct {
+	Title string
+
+	// Link of the title
+	Url string
+
+	Text string
+
+	// Name and value pairs, e.g. the fields of an event. Discord shows at most 25
+	Fields []Field
+
+	// Preformatted text, e.g. a list of users. It's sent as separate messages if it's too long for one
+	Code string
+
+	ImageUrl string
+
+	// Whether only the user that ran the command should see the reply, where the platform supports it
+	Ephemeral bool
+}
+
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Messages longer than this are split, since Discord doesn't allow longer messages
+const charLimit = 2000
+
+// schej green
+const discordColor = 0x00994c
+
+// A message rendered with Slack blocks. Text is shown in notifications
+type SlackMessage struct {
+	Text   string
+	Blocks []bson.M
+}
+
+// Renders the message as Slack messages. Blocks documentation: https://api.slack.com/reference/block-kit/blocks
+func (m *Message) Slack() []SlackMessage {
+	blocks := make([]bson.M, 0)
+	if len(m.Title) > 0 {
+		title := slackEscape(m.Title)
+		if len(m.Url) > 0 {
+			title = fmt.Sprintf("<%s|%s>", m.Url, title)
+		}
+		blocks = append(blocks, slackSection("*"+title+"*"))
+	}
+	if len(m.Text) > 0 {
+		blocks = append(blocks, slackSection(slackEscape(m.Text)))
+	}
+
+	// Sections have at most 10 fields
+	for i := 0; i < len(m.Fields); i += 10 {
+		fields := bson.A{}
+		for _, field := range m.Fields[i:min(i+10, len(m.Fields))] {
+			fields = append(fields, bson.M{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", slackEscape(field.Name), slackEscape(field.Value))})
+		}
+		blocks = append(blocks, bson.M{"type": "section", "fields": fields})
+	}
+
+	if len(m.ImageUrl) > 0 {
+		blocks = append(blocks, bson.M{"type": "image", "image_url": m.ImageUrl, "alt_text": orDefault(m.Title, "Chart")})
+	}
+
+	messages := make([]SlackMessage, 0)
+	if len(blocks) > 0 {
+		messages = append(messages, SlackMessage{Text: orDefault(m.Title, m.Text), Blocks: blocks})
+	}
+	for _, code := range splitLongMessage(m.Code, "```") {
+		messages = append(messages, SlackMessage{Text: code})
+	}
+	return messages
+}
+
+// Renders the message as Discord messages, with an embed for everything but the preformatted text
+func (m *Message) Discord() []*discordgo.MessageSend {
+	messages := make([]*discordgo.MessageSend, 0)
+	if len(m.Title) > 0 || len(m.Text) > 0 || len(m.Fields) > 0 || len(m.ImageUrl) > 0 {
+		embed := &discordgo.MessageEmbed{Title: m.Title, URL: m.Url, Description: m.Text, Color: discordColor}
+		for _, field := range m.Fields {
+			// Discord rejects empty values
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: field.Name, Value: orDefault(field.Value, "-"), Inline: true})
+		}
+		if len(m.ImageUrl) > 0 {
+			embed.Image = &discordgo.MessageEmbedImage{URL: m.ImageUrl}
+		}
+		messages = append(messages, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+	}
+	for _, code := range splitLongMessage(m.Code, "```") {
+		messages = append(messages, &discordgo.MessageSend{Content: code})
+	}
+	return messages
+}
+
+func slackSection(text string) bson.M {
+	return bson.M{"type": "section", "text": bson.M{"type": "mrkdwn", "text": text}}
+}
+
+// Escapes the characters that Slack uses for links and mentions: https://api.slack.com/reference/surfaces/formatting#escaping
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func orDefault(value string, fallback string) string {
+	if len(value) == 0 {
+		return fallback
+	}
+	return value
+}
+
+// Returns the separate messages to send when a single message is too long, split at newlines where possible. Each
+// message is surrounded by surroundString (e.g. "```")
+func splitLongMessage(message string, surroundString string) []string {
+	limit := charLimit - len(surroundString)*2
+	messageArray := make([]string, 0)
+
+	// Go through message and keep substringing it in chunks, and add the chunks to the messageArray
+	for len(message) > limit {
+		// Split at the last newline before the character limit, or the character limit if newline was not found
+		splitIndex := strings.LastIndex(message[:limit], "\n")
+		if splitIndex == -1 {
+			splitIndex = limit
+		}
+
+		messageArray = append(messageArray, surroundString+message[:splitIndex]+surroundString)
+		if message[splitIndex:splitIndex+1] == "\n" {
+			splitIndex++
+		}
+		message = message[splitIndex:]
+	}
+	if len(message) > 0 {
+		messageArray = append(messageArray, surroundString+message+surroundString)
+	}
+
+	return messageArray
+}
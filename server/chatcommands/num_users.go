@@ -0,0 +1,21 @@
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+
+	"schej.it/server/db"
+)
+
+var numUsers = Command{
+	Name:        "num_users",
+	Description: "Returns the number of signed up users",
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		n, err := db.Users.Count(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Message{Text: fmt.Sprintf("Number of currently signed up users: %v", n)}, nil
+	},
+}
@@ -0,0 +1,32 @@
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"schej.it/server/db"
+	"schej.it/server/services/chat"
+	"schej.it/server/utils"
+)
+
+var signupsToday = Command{
+	Name:        "signups_today",
+	Description: "Lists the users that signed up today, in UTC",
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		users, err := db.Users.GetCreatedSince(ctx, utils.GetDateAtTime(time.Now(), "00:00:00"))
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			return &Message{Text: "Nobody has signed up today yet."}, nil
+		}
+
+		list := ""
+		for _, user := range users {
+			list += fmt.Sprintf("%s - %s %s (%s)\n", user.Id.Timestamp().UTC().Format("15:04"), user.FirstName, user.LastName, user.Email)
+		}
+
+		return &Message{Title: fmt.Sprintf("%d %s signed up today", len(users), chat.Plural(len(users), "user", "users")), Code: list}, nil
+	},
+}
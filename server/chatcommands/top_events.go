@@ -0,0 +1,65 @@
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/services/chat"
+	"schej.it/server/utils"
+)
+
+var topEvents = Command{
+	Name:        "top_events",
+	Description: "Lists the recently created events with the most responses",
+	Args: []Arg{
+		{Name: "days", Type: IntArg, Default: "7", Description: "How many days back to look for events"},
+		{Name: "limit", Type: IntArg, Default: "10", Description: "How many events to list"},
+	},
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		days, limit := args.Int("days"), args.Int("limit")
+		if days < 1 || limit < 1 {
+			return &Message{Text: "DAYS and LIMIT must be at least 1!", Ephemeral: true}, nil
+		}
+
+		events, err := db.Events.GetCreatedSince(ctx, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			return &Message{Text: fmt.Sprintf("No events were created in the last %d %s.", days, chat.Plural(days, "day", "days"))}, nil
+		}
+
+		eventIds := make([]primitive.ObjectID, 0)
+		for _, event := range events {
+			eventIds = append(eventIds, event.Id)
+		}
+		respondents, err := db.Responses.GetUserIdsByEvents(ctx, eventIds)
+		if err != nil {
+			return nil, err
+		}
+		counts := make(map[primitive.ObjectID]int)
+		for _, event := range events {
+			counts[event.Id] = len(respondents[event.Id])
+			if utils.Coalesce(event.IsSignUpForm) {
+				counts[event.Id] = len(event.SignUpResponses)
+			}
+		}
+
+		// Ties go to the newest event
+		sort.SliceStable(events, func(i, j int) bool { return counts[events[i].Id] > counts[events[j].Id] })
+		if len(events) > limit {
+			events = events[:limit]
+		}
+
+		list := ""
+		for i, event := range events {
+			list += fmt.Sprintf("%d. %s | %d %s | %s\n", i+1, event.Name, counts[event.Id], chat.Plural(counts[event.Id], "response", "responses"), chat.EventUrl(&event))
+		}
+
+		return &Message{Title: fmt.Sprintf("Top events created in the last %d %s", days, chat.Plural(days, "day", "days")), Code: list}, nil
+	},
+}
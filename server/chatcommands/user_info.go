@@ -0,0 +1,62 @@
+package chatcommands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"schej.it/server/db"
+)
+
+var userInfo = Command{
+	Name:        "user_info",
+	Description: "Shows when a user signed up, their calendars and how many events they have",
+	Args: []Arg{
+		{Name: "email", Type: StringArg, Required: true, Description: "The user's email address"},
+	},
+	Execute: func(ctx context.Context, args Args) (*Message, error) {
+		user, err := db.Users.GetByEmail(ctx, args.String("email"))
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return &Message{Text: fmt.Sprintf("There's no user with the email %s.", args.String("email")), Ephemeral: true}, nil
+		}
+
+		events, err := db.Events.GetByUser(ctx, user.Id, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		owned := 0
+		for _, event := range events {
+			if event.OwnerId == user.Id {
+				owned++
+			}
+		}
+
+		calendars := make([]string, 0)
+		for _, account := range user.CalendarAccounts {
+			calendars = append(calendars, fmt.Sprintf("%s (%s)", account.Email, account.CalendarType))
+		}
+
+		// The offset is in minutes behind UTC, like javascript's Date.getTimezoneOffset
+		offset := -user.TimezoneOffset
+		sign := "+"
+		if offset < 0 {
+			sign, offset = "-", -offset
+		}
+
+		return &Message{
+			Title: strings.TrimSpace(user.FirstName + " " + user.LastName),
+			Text:  user.Email,
+			Fields: []Field{
+				{Name: "Id", Value: user.Id.Hex()},
+				{Name: "Signed up", Value: user.Id.Timestamp().UTC().Format(timeLayout)},
+				{Name: "Timezone", Value: fmt.Sprintf("UTC%s%02d:%02d", sign, offset/60, offset%60)},
+				{Name: "Events created", Value: fmt.Sprint(owned)},
+				{Name: "Events joined", Value: fmt.Sprint(len(events) - owned)},
+				{Name: "Calendars", Value: strings.Join(calendars, "\n")},
+			},
+		}, nil
+	},
+}
@@ -0,0 +1,639 @@
+package config
+
+// The server's configuration is read from (in increasing order of precedence) the defaults below, an optional YAML
+// or TOML config file, and environment variables. Load is called on startup and fails if any value is invalid;
+// everything else reads the loaded config with Get.
+//
+// Secrets that are only used by a single service (OAuth client secrets, encryption keys, bot tokens) are still
+// read from environment variables by that service.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"schej.it/server/logger"
+)
+
+type Config struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Storage    Storage    `yaml:"storage" toml:"storage"`
+	Email      Email      `yaml:"email" toml:"email"`
+	Listmonk   Listmonk   `yaml:"listmonk" toml:"listmonk"`
+	Tasks      Tasks      `yaml:"tasks" toml:"tasks"`
+	Timeouts   Timeouts   `yaml:"timeouts" toml:"timeouts"`
+	RateLimits RateLimits `yaml:"rateLimits" toml:"rateLimits"`
+}
+
+type Server struct {
+	Port int `yaml:"port" toml:"port"` // PORT
+
+	// Url of the frontend, used for links in emails and messages
+	BaseUrl string `yaml:"baseUrl" toml:"baseUrl"` // BASE_URL
+
+	// Origins allowed to make cross origin requests with credentials
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowedOrigins"` // ALLOWED_ORIGINS, comma separated
+
+	// Secret used to sign session cookies
+	SessionSecret string `yaml:"sessionSecret" toml:"sessionSecret"` // SESSION_SECRET
+
+	// How long to wait for in flight requests and background emails to finish when shutting down
+	ShutdownTimeoutSeconds int `yaml:"shutdownTimeoutSeconds" toml:"shutdownTimeoutSeconds"` // SHUTDOWN_TIMEOUT_SECONDS
+
+	// If set, /metrics requires it as a bearer token
+	MetricsToken string `yaml:"metricsToken" toml:"metricsToken"` // METRICS_TOKEN
+
+	// Addresses or CIDR ranges of the proxies in front of the server, whose X-Forwarded-For headers are used to get
+	// the client's ip for rate limiting. Every proxy is trusted if unset, which lets clients spoof their ip
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trustedProxies"` // TRUSTED_PROXIES, comma separated
+}
+
+type Storage struct {
+	// "mongo" or "memory", memory runs without mongo
+	Backend string `yaml:"backend" toml:"backend"` // STORAGE_BACKEND
+
+	MongoUri      string `yaml:"mongoUri" toml:"mongoUri"`           // MONGODB_URI
+	MongoDatabase string `yaml:"mongoDatabase" toml:"mongoDatabase"` // MONGODB_DATABASE
+
+	// File the memory backend writes its data to after every change, data is lost on restart if unset
+	MemoryPath string `yaml:"memoryPath" toml:"memoryPath"` // MEMORY_STORAGE_PATH
+
+	// Whether to run pending migrations when the server starts
+	MigrateOnStartup bool `yaml:"migrateOnStartup" toml:"migrateOnStartup"` // MIGRATE_ON_STARTUP
+}
+
+// How notification emails are delivered
+type Email struct {
+	// "listmonk" sends emails with listmonk's transactional templates, "smtp" renders the templates in
+	// services/notifications/templates and sends them with an SMTP server, "file" writes the rendered emails to
+	// MailboxPath instead of sending them, and "none" doesn't send emails
+	Backend string `yaml:"backend" toml:"backend"` // EMAIL_BACKEND
+
+	// Sender address of rendered emails, e.g. "schej <noreply@example.com>"
+	From string `yaml:"from" toml:"from"` // EMAIL_FROM
+
+	SmtpHost     string `yaml:"smtpHost" toml:"smtpHost"`         // SMTP_HOST
+	SmtpPort     int    `yaml:"smtpPort" toml:"smtpPort"`         // SMTP_PORT
+	SmtpUsername string `yaml:"smtpUsername" toml:"smtpUsername"` // SMTP_USERNAME
+	SmtpPassword string `yaml:"smtpPassword" toml:"smtpPassword"` // SMTP_PASSWORD
+
+	// Directory the file backend writes emails to, as .eml files
+	MailboxPath string `yaml:"mailboxPath" toml:"mailboxPath"` // EMAIL_MAILBOX_PATH
+}
+
+type Listmonk struct {
+	Enabled  bool   `yaml:"enabled" toml:"enabled"`   // LISTMONK_ENABLED
+	Url      string `yaml:"url" toml:"url"`           // LISTMONK_URL
+	Username string `yaml:"username" toml:"username"` // LISTMONK_USERNAME
+	Password string `yaml:"password" toml:"password"` // LISTMONK_PASSWORD
+	ListId   int    `yaml:"listId" toml:"listId"`     // LISTMONK_LIST_ID
+
+	Templates ListmonkTemplates `yaml:"templates" toml:"templates"`
+}
+
+// Ids of the listmonk transactional email templates, 0 if the email isn't configured
+type ListmonkTemplates struct {
+	EveryoneResponded       int `yaml:"everyoneResponded" toml:"everyoneResponded"`             // LISTMONK_EVERYONE_RESPONDED_EMAIL_ID
+	AvailabilityGroupInvite int `yaml:"availabilityGroupInvite" toml:"availabilityGroupInvite"` // LISTMONK_AVAILABILITY_GROUP_INVITE_EMAIL_ID
+	SomeoneResponded        int `yaml:"someoneResponded" toml:"someoneResponded"`               // LISTMONK_SOMEONE_RESPONDED_EMAIL_ID
+	AddedAttendee           int `yaml:"addedAttendee" toml:"addedAttendee"`                     // LISTMONK_ADDED_ATTENDEE_EMAIL_ID
+	SomeoneRespondedGroup   int `yaml:"someoneRespondedGroup" toml:"someoneRespondedGroup"`     // LISTMONK_SOMEONE_RESPONDED_GROUP_EMAIL_ID
+	ResponsesThreshold      int `yaml:"responsesThreshold" toml:"responsesThreshold"`           // LISTMONK_RESPONSES_THRESHOLD_EMAIL_ID
+	InitialReminder         int `yaml:"initialReminder" toml:"initialReminder"`                 // LISTMONK_INITIAL_EMAIL_REMINDER_ID
+	SecondReminder          int `yaml:"secondReminder" toml:"secondReminder"`                   // LISTMONK_SECOND_EMAIL_REMINDER_ID
+	FinalReminder           int `yaml:"finalReminder" toml:"finalReminder"`                     // LISTMONK_FINAL_EMAIL_REMINDER_ID
+	ReconnectCalendar       int `yaml:"reconnectCalendar" toml:"reconnectCalendar"`             // LISTMONK_RECONNECT_CALENDAR_EMAIL_ID
+	Digest                  int `yaml:"digest" toml:"digest"`                                   // LISTMONK_DIGEST_EMAIL_ID
+	Nudge                   int `yaml:"nudge" toml:"nudge"`                                     // LISTMONK_NUDGE_EMAIL_ID
+}
+
+// Background jobs, e.g. reminder emails. The local scheduler runs jobs stored in the storage backend on every
+// server, Google Cloud Tasks calls back the server at RunUrl to run each job
+type Tasks struct {
+	// "local" or "cloudtasks"
+	Backend string `yaml:"backend" toml:"backend"` // TASKS_BACKEND
+
+	// How often the local scheduler checks for due jobs, how long a job may run before another server retries it,
+	// and how many times a failing job is tried
+	PollIntervalSeconds int `yaml:"pollIntervalSeconds" toml:"pollIntervalSeconds"` // TASKS_POLL_INTERVAL_SECONDS
+	LeaseSeconds        int `yaml:"leaseSeconds" toml:"leaseSeconds"`               // TASKS_LEASE_SECONDS
+	MaxAttempts         int `yaml:"maxAttempts" toml:"maxAttempts"`                 // TASKS_MAX_ATTEMPTS
+
+	// Cloud Tasks. The service account key is also used by the local scheduler to cancel tasks scheduled before
+	// switching to it
+	ServiceAccountKeyPath string `yaml:"serviceAccountKeyPath" toml:"serviceAccountKeyPath"` // SERVICE_ACCOUNT_KEY_PATH
+	Project               string `yaml:"project" toml:"project"`                             // TASKS_PROJECT
+	Location              string `yaml:"location" toml:"location"`                           // TASKS_LOCATION
+	ReminderQueue         string `yaml:"reminderQueue" toml:"reminderQueue"`                 // TASKS_REMINDER_QUEUE, the queue every job is scheduled on
+
+	// Url that Cloud Tasks posts jobs to, defaults to <baseUrl>/api/jobs/run, and the secret it authenticates with
+	RunUrl string `yaml:"runUrl" toml:"runUrl"` // TASKS_RUN_URL
+	Secret string `yaml:"secret" toml:"secret"` // TASKS_SECRET
+}
+
+// Returns the full name of the Cloud Tasks queue that jobs are scheduled on
+func (t Tasks) ReminderQueuePath() string {
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", t.Project, t.Location, t.ReminderQueue)
+}
+
+func (t Tasks) PollInterval() time.Duration { return seconds(t.PollIntervalSeconds) }
+func (t Tasks) Lease() time.Duration        { return seconds(t.LeaseSeconds) }
+
+// How long a single call to each external dependency may take before it's cancelled, in seconds. Calls are also
+// cancelled when the request that made them is
+type Timeouts struct {
+	DatabaseSeconds int `yaml:"databaseSeconds" toml:"databaseSeconds"` // DATABASE_TIMEOUT_SECONDS
+	CalendarSeconds int `yaml:"calendarSeconds" toml:"calendarSeconds"` // CALENDAR_TIMEOUT_SECONDS, google, outlook, and apple calendar requests
+	AuthSeconds     int `yaml:"authSeconds" toml:"authSeconds"`         // AUTH_TIMEOUT_SECONDS, OAuth token and user info requests
+	ContactsSeconds int `yaml:"contactsSeconds" toml:"contactsSeconds"` // CONTACTS_TIMEOUT_SECONDS
+	EmailSeconds    int `yaml:"emailSeconds" toml:"emailSeconds"`       // EMAIL_TIMEOUT_SECONDS, listmonk requests
+}
+
+func (t Timeouts) Database() time.Duration { return seconds(t.DatabaseSeconds) }
+func (t Timeouts) Calendar() time.Duration { return seconds(t.CalendarSeconds) }
+func (t Timeouts) Auth() time.Duration     { return seconds(t.AuthSeconds) }
+func (t Timeouts) Contacts() time.Duration { return seconds(t.ContactsSeconds) }
+func (t Timeouts) Email() time.Duration    { return seconds(t.EmailSeconds) }
+
+// Limits on how often clients can call the public endpoints and how many emails event owners can send to other
+// people. A limit of 0 requests or emails is disabled
+type RateLimits struct {
+	// "memory" counts requests in each server process, "mongo" shares the counts between servers. Defaults to the
+	// storage backend
+	Store string `yaml:"store" toml:"store"` // RATE_LIMIT_STORE
+
+	CreateEvent    RateLimit `yaml:"createEvent" toml:"createEvent"`       // RATE_LIMIT_CREATE_EVENT_*, per user or per ip when signed out
+	Respond        RateLimit `yaml:"respond" toml:"respond"`               // RATE_LIMIT_RESPOND_*, per ip
+	RespondToEvent RateLimit `yaml:"respondToEvent" toml:"respondToEvent"` // RATE_LIMIT_RESPOND_TO_EVENT_*, per event
+	SearchUsers    RateLimit `yaml:"searchUsers" toml:"searchUsers"`       // RATE_LIMIT_SEARCH_USERS_*, per user or per ip when signed out
+	Analytics      RateLimit `yaml:"analytics" toml:"analytics"`           // RATE_LIMIT_ANALYTICS_*, per ip
+
+	// Invite emails to availability group attendees and reminder emails to remindees each owner can send per day,
+	// counted per ip for events without an owner
+	InviteEmailsPerDay   int `yaml:"inviteEmailsPerDay" toml:"inviteEmailsPerDay"`     // INVITE_EMAILS_PER_DAY
+	ReminderEmailsPerDay int `yaml:"reminderEmailsPerDay" toml:"reminderEmailsPerDay"` // REMINDER_EMAILS_PER_DAY
+
+	// How long after an owner nudges someone about an event before they can be nudged about it again, 0 to not limit
+	// nudges. Nudges also count against the owner's reminder emails
+	NudgeCooldownHours int `yaml:"nudgeCooldownHours" toml:"nudgeCooldownHours"` // NUDGE_COOLDOWN_HOURS
+}
+
+func (r RateLimits) NudgeCooldown() time.Duration {
+	return time.Duration(r.NudgeCooldownHours) * time.Hour
+}
+
+// At most Requests requests every WindowSeconds
+type RateLimit struct {
+	Requests      int `yaml:"requests" toml:"requests"`           // *_REQUESTS
+	WindowSeconds int `yaml:"windowSeconds" toml:"windowSeconds"` // *_WINDOW_SECONDS
+}
+
+func (r RateLimit) Window() time.Duration { return seconds(r.WindowSeconds) }
+
+func seconds(s int) time.Duration {
+	return time.Duration(s) * time.Second
+}
+
+var (
+	current *Config
+	mutex   sync.Mutex
+)
+
+// Loads and validates the config from the defaults, the config file at the given path (or CONFIG_FILE if path is
+// empty, no file is read if both are empty), and environment variables. The loaded config is returned by Get
+func Load(path string) (*Config, error) {
+	if len(path) == 0 {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	c := defaults()
+	if len(path) > 0 {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.readEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	current = c
+
+	return c, nil
+}
+
+// Returns the config loaded by Load. If Load hasn't been called (e.g. in tests and scripts), the config is loaded
+// from the defaults and environment variables
+func Get() *Config {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if current == nil {
+		c := defaults()
+		if err := c.readEnv(); err != nil {
+			logger.StdErr.Panicln(err)
+		}
+		current = c
+	}
+
+	return current
+}
+
+func defaults() *Config {
+	baseUrl := "http://localhost:8080"
+	if os.Getenv("GIN_MODE") == "release" {
+		baseUrl = "https://schej.it"
+	}
+
+	return &Config{
+		Server: Server{
+			Port:           3002,
+			BaseUrl:        baseUrl,
+			AllowedOrigins: []string{"http://localhost:8080", "https://www.schej.it", "https://schej.it"},
+			SessionSecret:  "secret",
+
+			ShutdownTimeoutSeconds: 30,
+		},
+		Storage: Storage{
+			Backend:       "mongo",
+			MongoUri:      "mongodb://localhost",
+			MongoDatabase: "schej-it",
+		},
+		Email: Email{
+			Backend:     "listmonk",
+			From:        "schej <noreply@schej.it>",
+			SmtpPort:    587,
+			MailboxPath: "mailbox",
+		},
+		Listmonk: Listmonk{
+			Enabled: true,
+			Templates: ListmonkTemplates{
+				EveryoneResponded:       8,
+				AvailabilityGroupInvite: 9,
+				SomeoneResponded:        10,
+				AddedAttendee:           11,
+				SomeoneRespondedGroup:   13,
+				ResponsesThreshold:      14,
+			},
+		},
+		Tasks: Tasks{
+			Backend:             "local",
+			PollIntervalSeconds: 5,
+			LeaseSeconds:        60,
+			MaxAttempts:         5,
+
+			Project:       "schej-it",
+			Location:      "us-central1",
+			ReminderQueue: "SendReminderEmail",
+		},
+		Timeouts: Timeouts{
+			DatabaseSeconds: 10,
+			CalendarSeconds: 15,
+			AuthSeconds:     10,
+			ContactsSeconds: 10,
+			EmailSeconds:    10,
+		},
+		RateLimits: RateLimits{
+			CreateEvent:    RateLimit{Requests: 30, WindowSeconds: 60 * 60},
+			Respond:        RateLimit{Requests: 60, WindowSeconds: 60},
+			RespondToEvent: RateLimit{Requests: 600, WindowSeconds: 60},
+			SearchUsers:    RateLimit{Requests: 60, WindowSeconds: 60},
+			Analytics:      RateLimit{Requests: 10, WindowSeconds: 60},
+
+			InviteEmailsPerDay:   200,
+			ReminderEmailsPerDay: 200,
+			NudgeCooldownHours:   24,
+		},
+	}
+}
+
+// Reads the YAML or TOML file at path (based on its extension) into the config. Values that aren't in the file
+// are left unchanged
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); errors.Is(err, io.EOF) {
+			// The file is empty
+			err = nil
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		return fmt.Errorf("config file %s must be a .yaml, .yml, or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Overrides the config with the environment variables that are set
+func (c *Config) readEnv() error {
+	var errs []error
+
+	errs = append(errs, envInt("PORT", &c.Server.Port))
+	envString("BASE_URL", &c.Server.BaseUrl)
+	envList("ALLOWED_ORIGINS", &c.Server.AllowedOrigins)
+	envString("SESSION_SECRET", &c.Server.SessionSecret)
+	errs = append(errs, envInt("SHUTDOWN_TIMEOUT_SECONDS", &c.Server.ShutdownTimeoutSeconds))
+	envString("METRICS_TOKEN", &c.Server.MetricsToken)
+	envList("TRUSTED_PROXIES", &c.Server.TrustedProxies)
+
+	envString("STORAGE_BACKEND", &c.Storage.Backend)
+	envString("MONGODB_URI", &c.Storage.MongoUri)
+	envString("MONGODB_DATABASE", &c.Storage.MongoDatabase)
+	envString("MEMORY_STORAGE_PATH", &c.Storage.MemoryPath)
+	errs = append(errs, envBool("MIGRATE_ON_STARTUP", &c.Storage.MigrateOnStartup))
+
+	envString("EMAIL_BACKEND", &c.Email.Backend)
+	envString("EMAIL_FROM", &c.Email.From)
+	envString("SMTP_HOST", &c.Email.SmtpHost)
+	errs = append(errs, envInt("SMTP_PORT", &c.Email.SmtpPort))
+	envString("SMTP_USERNAME", &c.Email.SmtpUsername)
+	envString("SMTP_PASSWORD", &c.Email.SmtpPassword)
+	envString("EMAIL_MAILBOX_PATH", &c.Email.MailboxPath)
+
+	errs = append(errs, envBool("LISTMONK_ENABLED", &c.Listmonk.Enabled))
+	envString("LISTMONK_URL", &c.Listmonk.Url)
+	envString("LISTMONK_USERNAME", &c.Listmonk.Username)
+	envString("LISTMONK_PASSWORD", &c.Listmonk.Password)
+	errs = append(errs, envInt("LISTMONK_LIST_ID", &c.Listmonk.ListId))
+
+	templates := &c.Listmonk.Templates
+	errs = append(errs,
+		envInt("LISTMONK_EVERYONE_RESPONDED_EMAIL_ID", &templates.EveryoneResponded),
+		envInt("LISTMONK_AVAILABILITY_GROUP_INVITE_EMAIL_ID", &templates.AvailabilityGroupInvite),
+		envInt("LISTMONK_SOMEONE_RESPONDED_EMAIL_ID", &templates.SomeoneResponded),
+		envInt("LISTMONK_ADDED_ATTENDEE_EMAIL_ID", &templates.AddedAttendee),
+		envInt("LISTMONK_SOMEONE_RESPONDED_GROUP_EMAIL_ID", &templates.SomeoneRespondedGroup),
+		envInt("LISTMONK_RESPONSES_THRESHOLD_EMAIL_ID", &templates.ResponsesThreshold),
+		envInt("LISTMONK_INITIAL_EMAIL_REMINDER_ID", &templates.InitialReminder),
+		envInt("LISTMONK_SECOND_EMAIL_REMINDER_ID", &templates.SecondReminder),
+		envInt("LISTMONK_FINAL_EMAIL_REMINDER_ID", &templates.FinalReminder),
+		envInt("LISTMONK_RECONNECT_CALENDAR_EMAIL_ID", &templates.ReconnectCalendar),
+		envInt("LISTMONK_DIGEST_EMAIL_ID", &templates.Digest),
+		envInt("LISTMONK_NUDGE_EMAIL_ID", &templates.Nudge),
+	)
+
+	envString("TASKS_BACKEND", &c.Tasks.Backend)
+	errs = append(errs,
+		envInt("TASKS_POLL_INTERVAL_SECONDS", &c.Tasks.PollIntervalSeconds),
+		envInt("TASKS_LEASE_SECONDS", &c.Tasks.LeaseSeconds),
+		envInt("TASKS_MAX_ATTEMPTS", &c.Tasks.MaxAttempts),
+	)
+	envString("SERVICE_ACCOUNT_KEY_PATH", &c.Tasks.ServiceAccountKeyPath)
+	envString("TASKS_PROJECT", &c.Tasks.Project)
+	envString("TASKS_LOCATION", &c.Tasks.Location)
+	envString("TASKS_REMINDER_QUEUE", &c.Tasks.ReminderQueue)
+	envString("TASKS_RUN_URL", &c.Tasks.RunUrl)
+	envString("TASKS_SECRET", &c.Tasks.Secret)
+
+	errs = append(errs,
+		envInt("DATABASE_TIMEOUT_SECONDS", &c.Timeouts.DatabaseSeconds),
+		envInt("CALENDAR_TIMEOUT_SECONDS", &c.Timeouts.CalendarSeconds),
+		envInt("AUTH_TIMEOUT_SECONDS", &c.Timeouts.AuthSeconds),
+		envInt("CONTACTS_TIMEOUT_SECONDS", &c.Timeouts.ContactsSeconds),
+		envInt("EMAIL_TIMEOUT_SECONDS", &c.Timeouts.EmailSeconds),
+	)
+
+	envString("RATE_LIMIT_STORE", &c.RateLimits.Store)
+	errs = append(errs,
+		envRateLimit("RATE_LIMIT_CREATE_EVENT", &c.RateLimits.CreateEvent),
+		envRateLimit("RATE_LIMIT_RESPOND", &c.RateLimits.Respond),
+		envRateLimit("RATE_LIMIT_RESPOND_TO_EVENT", &c.RateLimits.RespondToEvent),
+		envRateLimit("RATE_LIMIT_SEARCH_USERS", &c.RateLimits.SearchUsers),
+		envRateLimit("RATE_LIMIT_ANALYTICS", &c.RateLimits.Analytics),
+		envInt("INVITE_EMAILS_PER_DAY", &c.RateLimits.InviteEmailsPerDay),
+		envInt("REMINDER_EMAILS_PER_DAY", &c.RateLimits.ReminderEmailsPerDay),
+		envInt("NUDGE_COOLDOWN_HOURS", &c.RateLimits.NudgeCooldownHours),
+	)
+
+	return errors.Join(errs...)
+}
+
+// Returns an error describing every invalid value in the config
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if err := validateUrl(c.Server.BaseUrl); err != nil {
+		errs = append(errs, fmt.Errorf("server.baseUrl: %w", err))
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		if err := validateUrl(origin); err != nil {
+			errs = append(errs, fmt.Errorf("server.allowedOrigins: %w", err))
+		}
+	}
+	if len(c.Server.SessionSecret) == 0 {
+		errs = append(errs, errors.New("server.sessionSecret must not be empty"))
+	}
+	if c.Server.ShutdownTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownTimeoutSeconds must not be negative, got %d", c.Server.ShutdownTimeoutSeconds))
+	}
+
+	switch c.Storage.Backend {
+	case "mongo":
+		if len(c.Storage.MongoUri) == 0 || len(c.Storage.MongoDatabase) == 0 {
+			errs = append(errs, errors.New("storage.mongoUri and storage.mongoDatabase are required for the mongo backend"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend must be \"mongo\" or \"memory\", got %q", c.Storage.Backend))
+	}
+
+	switch c.Email.Backend {
+	case "listmonk", "none":
+	case "smtp":
+		if len(c.Email.SmtpHost) == 0 || len(c.Email.From) == 0 {
+			errs = append(errs, errors.New("email.smtpHost and email.from are required for the smtp backend"))
+		}
+		if c.Email.SmtpPort <= 0 || c.Email.SmtpPort > 65535 {
+			errs = append(errs, fmt.Errorf("email.smtpPort must be between 1 and 65535, got %d", c.Email.SmtpPort))
+		}
+	case "file":
+		if len(c.Email.MailboxPath) == 0 {
+			errs = append(errs, errors.New("email.mailboxPath is required for the file backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("email.backend must be \"listmonk\", \"smtp\", \"file\", or \"none\", got %q", c.Email.Backend))
+	}
+
+	if c.Listmonk.Enabled && len(c.Listmonk.Url) > 0 {
+		if err := validateUrl(c.Listmonk.Url); err != nil {
+			errs = append(errs, fmt.Errorf("listmonk.url: %w", err))
+		}
+	}
+
+	switch c.Tasks.Backend {
+	case "local":
+		if c.Tasks.PollIntervalSeconds <= 0 || c.Tasks.LeaseSeconds <= 0 || c.Tasks.MaxAttempts <= 0 {
+			errs = append(errs, errors.New("tasks.pollIntervalSeconds, tasks.leaseSeconds, and tasks.maxAttempts must be positive"))
+		}
+	case "cloudtasks":
+		if len(c.Tasks.Project) == 0 || len(c.Tasks.Location) == 0 || len(c.Tasks.ReminderQueue) == 0 {
+			errs = append(errs, errors.New("tasks.project, tasks.location, and tasks.reminderQueue must not be empty"))
+		}
+		if len(c.Tasks.Secret) == 0 {
+			errs = append(errs, errors.New("tasks.secret is required for the cloudtasks backend"))
+		}
+		if len(c.Tasks.RunUrl) > 0 {
+			if err := validateUrl(c.Tasks.RunUrl); err != nil {
+				errs = append(errs, fmt.Errorf("tasks.runUrl: %w", err))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tasks.backend must be \"local\" or \"cloudtasks\", got %q", c.Tasks.Backend))
+	}
+
+	timeouts := []struct {
+		name  string
+		value int
+	}{
+		{"databaseSeconds", c.Timeouts.DatabaseSeconds},
+		{"calendarSeconds", c.Timeouts.CalendarSeconds},
+		{"authSeconds", c.Timeouts.AuthSeconds},
+		{"contactsSeconds", c.Timeouts.ContactsSeconds},
+		{"emailSeconds", c.Timeouts.EmailSeconds},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must be positive, got %d", timeout.name, timeout.value))
+		}
+	}
+
+	switch c.RateLimits.Store {
+	case "", "memory":
+	case "mongo":
+		if c.Storage.Backend != "mongo" {
+			errs = append(errs, errors.New("rateLimits.store can only be \"mongo\" with the mongo storage backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rateLimits.store must be \"mongo\" or \"memory\", got %q", c.RateLimits.Store))
+	}
+	rateLimits := []struct {
+		name  string
+		value RateLimit
+	}{
+		{"createEvent", c.RateLimits.CreateEvent},
+		{"respond", c.RateLimits.Respond},
+		{"respondToEvent", c.RateLimits.RespondToEvent},
+		{"searchUsers", c.RateLimits.SearchUsers},
+		{"analytics", c.RateLimits.Analytics},
+	}
+	for _, rateLimit := range rateLimits {
+		if rateLimit.value.Requests < 0 {
+			errs = append(errs, fmt.Errorf("rateLimits.%s.requests must not be negative, got %d", rateLimit.name, rateLimit.value.Requests))
+		} else if rateLimit.value.Requests > 0 && rateLimit.value.WindowSeconds <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimits.%s.windowSeconds must be positive, got %d", rateLimit.name, rateLimit.value.WindowSeconds))
+		}
+	}
+	if c.RateLimits.InviteEmailsPerDay < 0 || c.RateLimits.ReminderEmailsPerDay < 0 {
+		errs = append(errs, errors.New("rateLimits.inviteEmailsPerDay and rateLimits.reminderEmailsPerDay must not be negative"))
+	}
+	if c.RateLimits.NudgeCooldownHours < 0 {
+		errs = append(errs, fmt.Errorf("rateLimits.nudgeCooldownHours must not be negative, got %d", c.RateLimits.NudgeCooldownHours))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateUrl(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("%q is not an http(s) url", value)
+	}
+	if strings.HasSuffix(value, "/") {
+		return fmt.Errorf("%q must not end with a slash", value)
+	}
+
+	return nil
+}
+
+func envString(name string, value *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v
+	}
+}
+
+// Sets value to the comma separated list in the environment variable, ignoring whitespace around each item
+func envList(name string, value *[]string) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+
+	list := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	*value = list
+}
+
+func envInt(name string, value *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || len(v) == 0 {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer, got %q", name, v)
+	}
+	*value = parsed
+
+	return nil
+}
+
+// Reads the limit from the environment variables prefix_REQUESTS and prefix_WINDOW_SECONDS
+func envRateLimit(prefix string, value *RateLimit) error {
+	return errors.Join(
+		envInt(prefix+"_REQUESTS", &value.Requests),
+		envInt(prefix+"_WINDOW_SECONDS", &value.WindowSeconds),
+	)
+}
+
+func envBool(name string, value *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok || len(v) == 0 {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false, got %q", name, v)
+	}
+	*value = parsed
+
+	return nil
+}
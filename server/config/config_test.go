@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Setenv("GIN_MODE", "debug")
+	t.Setenv("PORT", "4000")
+	t.Setenv("ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	files := map[string]string{
+		"config.yaml": "server:\n  baseUrl: https://schej.example.com\n  port: 5000\nlistmonk:\n  templates:\n    everyoneResponded: 20\n",
+		"config.toml": "[server]\nbaseUrl = \"https://schej.example.com\"\nport = 5000\n\n[listmonk.templates]\neveryoneResponded = 20\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Environment variables take precedence over the file, which takes precedence over the defaults
+		if c.Server.Port != 4000 {
+			t.Errorf("%s: expected port from env, got %d", name, c.Server.Port)
+		}
+		if c.Server.BaseUrl != "https://schej.example.com" || c.Listmonk.Templates.EveryoneResponded != 20 {
+			t.Errorf("%s: expected values from file, got %+v", name, c)
+		}
+		if c.Listmonk.Templates.SomeoneResponded != 10 || c.Storage.MongoDatabase != "schej-it" {
+			t.Errorf("%s: expected defaults for values not in the file, got %+v", name, c)
+		}
+		if len(c.Server.AllowedOrigins) != 2 || c.Server.AllowedOrigins[1] != "https://b.example.com" {
+			t.Errorf("%s: unexpected allowed origins %v", name, c.Server.AllowedOrigins)
+		}
+		if Get() != c {
+			t.Errorf("%s: expected Get to return the loaded config", name)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Setenv("BASE_URL", "schej.example.com")
+	t.Setenv("STORAGE_BACKEND", "postgres")
+	t.Setenv("CALENDAR_TIMEOUT_SECONDS", "0")
+	t.Setenv("RATE_LIMIT_RESPOND_WINDOW_SECONDS", "0")
+	t.Setenv("EMAIL_BACKEND", "smtp")
+
+	_, err := Load("")
+	if err == nil || !strings.Contains(err.Error(), "server.baseUrl") || !strings.Contains(err.Error(), "storage.backend") {
+		t.Errorf("expected base url and storage backend errors, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "timeouts.calendarSeconds") {
+		t.Errorf("expected calendar timeout error, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "rateLimits.respond.windowSeconds") {
+		t.Errorf("expected rate limit window error, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "email.smtpHost") {
+		t.Errorf("expected smtp host error, got %v", err)
+	}
+
+	t.Setenv("PORT", "abc")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("expected invalid port error, got %v", err)
+	}
+
+	// Unknown fields are most likely typos
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  baseURL: https://schej.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected unknown field error")
+	}
+}
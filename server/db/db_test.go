@@ -1,21 +1,22 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestGetDailyUserLogByDate(t *testing.T) {
-	GetDailyUserLogByDate(time.Now(), 7)
+	GetDailyUserLogByDate(context.Background(), time.Now(), 7)
 }
 
 func TestGenerateShortEventId(t *testing.T) {
 	Init()
 
-	objectId, _ := primitive.ObjectIDFromHex("6607d6409f96021811c0a55f")
-	id := GenerateShortEventId(objectId)
+	id, err := GenerateShortEventId(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(id)
 }
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/logger"
+)
+
+// An index that should exist on a collection
+type index struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+
+	// Only documents matching the filter are indexed, e.g. so that documents without the field don't conflict
+	// in a unique index
+	partialFilter bson.M
+
+	// If set, documents are deleted this many seconds after the date in the index's only key
+	expireAfterSeconds *int32
+}
+
+// Every index the server relies on. EnsureIndexes creates the ones that are missing on startup, so new
+// environments get the right indexes without running migrations. Names match the ones created by migrations
+var indexes = []index{
+	// Events
+	{
+		collection:    eventsCollectionName,
+		name:          "shortId_1",
+		keys:          bson.D{{Key: "shortId", Value: 1}},
+		unique:        true,
+		partialFilter: bson.M{"shortId": bson.M{"$type": "string"}},
+	},
+	{
+		collection: eventsCollectionName,
+		name:       "ownerId_id_1",
+		keys:       bson.D{{Key: "ownerId", Value: 1}, {Key: "_id", Value: -1}},
+	},
+	{
+		collection: eventsCollectionName,
+		name:       "attendees_email_declined_id_1",
+		keys:       bson.D{{Key: "attendees.email", Value: 1}, {Key: "attendees.declined", Value: 1}, {Key: "_id", Value: -1}},
+	},
+
+	// Users
+	{
+		collection:    usersCollectionName,
+		name:          "email_1",
+		keys:          bson.D{{Key: "email", Value: 1}},
+		unique:        true,
+		partialFilter: bson.M{"email": bson.M{"$type": "string"}},
+	},
+
+	// Responses
+	{
+		collection: responsesCollectionName,
+		name:       "eventId_1_userId_1",
+		keys:       bson.D{{Key: "eventId", Value: 1}, {Key: "userId", Value: 1}},
+		unique:     true,
+	},
+	{
+		collection: responsesCollectionName,
+		name:       "userId_1",
+		keys:       bson.D{{Key: "userId", Value: 1}},
+	},
+
+	// Daily user logs
+	{
+		collection: dailyUserLogsCollectionName,
+		name:       "date_1",
+		keys:       bson.D{{Key: "date", Value: 1}},
+	},
+
+	// Jobs
+	{
+		collection: jobsCollectionName,
+		name:       "status_runAt_1",
+		keys:       bson.D{{Key: "status", Value: 1}, {Key: "runAt", Value: 1}},
+	},
+	{
+		collection:         jobsCollectionName,
+		name:               "finishedAt_1",
+		keys:               bson.D{{Key: "finishedAt", Value: 1}},
+		expireAfterSeconds: &finishedJobsExpireAfterSeconds,
+	},
+
+	// Email preferences
+	{
+		collection: emailPreferencesCollectionName,
+		name:       "email_1",
+		keys:       bson.D{{Key: "email", Value: 1}},
+		unique:     true,
+	},
+
+	// Digest items
+	{
+		collection: digestItemsCollectionName,
+		name:       "userId_1_createdAt_1",
+		keys:       bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}},
+	},
+
+	// Webhooks
+	{
+		collection: webhooksCollectionName,
+		name:       "ownerId_1",
+		keys:       bson.D{{Key: "ownerId", Value: 1}},
+	},
+	{
+		collection: webhookDeliveriesCollectionName,
+		name:       "webhookId_1_createdAt_-1",
+		keys:       bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}},
+	},
+
+	// Chat accounts
+	{
+		collection: chatAccountsCollectionName,
+		name:       "platform_1_teamId_1_externalId_1",
+		keys:       bson.D{{Key: "platform", Value: 1}, {Key: "teamId", Value: 1}, {Key: "externalId", Value: 1}},
+		unique:     true,
+	},
+	{
+		collection: chatAccountsCollectionName,
+		name:       "userId_1",
+		keys:       bson.D{{Key: "userId", Value: 1}},
+	},
+	{
+		collection: chatMessagesCollectionName,
+		name:       "eventId_1",
+		keys:       bson.D{{Key: "eventId", Value: 1}},
+	},
+
+	// Discord guilds
+	{
+		collection: discordGuildsCollectionName,
+		name:       "guildId_1",
+		keys:       bson.D{{Key: "guildId", Value: 1}},
+		unique:     true,
+	},
+
+	// Rate limits
+	{
+		collection:         rateLimitsCollectionName,
+		name:               "expiresAt_1",
+		keys:               bson.D{{Key: "expiresAt", Value: 1}},
+		expireAfterSeconds: new(int32),
+	},
+}
+
+// Finished jobs are kept for a week so that failures can be looked into
+var finishedJobsExpireAfterSeconds = int32(7 * 24 * 60 * 60)
+
+// Creates the indexes that are missing, and logs indexes that differ from the registry or aren't in it.
+// Existing indexes are never dropped, since an unexpected index may still be in use by an older server
+func EnsureIndexes() {
+	collectionNames := make([]string, 0)
+	indexesByCollection := make(map[string][]index)
+	for _, i := range indexes {
+		if _, ok := indexesByCollection[i.collection]; !ok {
+			collectionNames = append(collectionNames, i.collection)
+		}
+		indexesByCollection[i.collection] = append(indexesByCollection[i.collection], i)
+	}
+
+	for _, collectionName := range collectionNames {
+		collection := Db.Collection(collectionName)
+		existing, err := collection.Indexes().ListSpecifications(context.Background())
+		if err != nil {
+			logger.StdErr.Printf("Failed to list indexes on %s: %v\n", collectionName, err)
+			continue
+		}
+
+		missing, mismatched, unexpected := diffIndexes(indexesByCollection[collectionName], existing)
+		for _, name := range mismatched {
+			logger.StdErr.Printf("Index %s on %s doesn't match its definition, drop it so that it can be recreated\n", name, collectionName)
+		}
+		for _, name := range unexpected {
+			logger.StdOut.Printf("Unexpected index %s on %s\n", name, collectionName)
+		}
+
+		// Create indexes one at a time so that one failing (e.g. a unique index on duplicate data) doesn't
+		// prevent the others from being created
+		for _, i := range missing {
+			if _, err := collection.Indexes().CreateOne(context.Background(), i.model()); err != nil {
+				logger.StdErr.Printf("Failed to create index %s on %s: %v\n", i.name, collectionName, err)
+				continue
+			}
+			logger.StdOut.Printf("Created index %s on %s\n", i.name, collectionName)
+		}
+	}
+}
+
+// Compares the wanted indexes of a collection with the existing ones. Returns the wanted indexes that don't exist,
+// and the names of the existing indexes that have different keys than wanted or aren't wanted at all
+func diffIndexes(wanted []index, existing []*mongo.IndexSpecification) (missing []index, mismatched []string, unexpected []string) {
+	existingByName := make(map[string]*mongo.IndexSpecification)
+	for _, spec := range existing {
+		existingByName[spec.Name] = spec
+	}
+
+	wantedNames := make(map[string]bool)
+	for _, i := range wanted {
+		wantedNames[i.name] = true
+
+		spec, ok := existingByName[i.name]
+		if !ok {
+			missing = append(missing, i)
+		} else if !i.matches(spec) {
+			mismatched = append(mismatched, i.name)
+		}
+	}
+
+	for _, spec := range existing {
+		if spec.Name != "_id_" && !wantedNames[spec.Name] {
+			unexpected = append(unexpected, spec.Name)
+		}
+	}
+
+	return missing, mismatched, unexpected
+}
+
+// Returns whether the existing index has the same keys, uniqueness, and expiry as the index
+func (i index) matches(spec *mongo.IndexSpecification) bool {
+	unique := spec.Unique != nil && *spec.Unique
+	if unique != i.unique {
+		return false
+	}
+	if (spec.ExpireAfterSeconds == nil) != (i.expireAfterSeconds == nil) ||
+		(i.expireAfterSeconds != nil && *spec.ExpireAfterSeconds != *i.expireAfterSeconds) {
+		return false
+	}
+
+	elements, err := spec.KeysDocument.Elements()
+	if err != nil || len(elements) != len(i.keys) {
+		return false
+	}
+	for j, element := range elements {
+		direction, ok := element.Value().AsInt64OK()
+		if !ok || element.Key() != i.keys[j].Key || direction != int64(i.keys[j].Value.(int)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i index) model() mongo.IndexModel {
+	indexOptions := options.Index().SetName(i.name)
+	if i.unique {
+		indexOptions.SetUnique(true)
+	}
+	if i.partialFilter != nil {
+		indexOptions.SetPartialFilterExpression(i.partialFilter)
+	}
+	if i.expireAfterSeconds != nil {
+		indexOptions.SetExpireAfterSeconds(*i.expireAfterSeconds)
+	}
+
+	return mongo.IndexModel{Keys: i.keys, Options: indexOptions}
+}
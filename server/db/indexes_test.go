@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	wanted := []index{
+		{collection: usersCollectionName, name: "email_1", keys: bson.D{{Key: "email", Value: 1}}, unique: true},
+		{collection: usersCollectionName, name: "firstName_1", keys: bson.D{{Key: "firstName", Value: 1}}},
+		{collection: usersCollectionName, name: "lastName_id_1", keys: bson.D{{Key: "lastName", Value: 1}, {Key: "_id", Value: -1}}},
+	}
+
+	keys := func(d bson.D) bson.Raw {
+		raw, _ := bson.Marshal(d)
+		return raw
+	}
+	notUnique := false
+	existing := []*mongo.IndexSpecification{
+		{Name: "_id_", KeysDocument: keys(bson.D{{Key: "_id", Value: 1}})},
+		// Same keys but not unique
+		{Name: "email_1", KeysDocument: keys(bson.D{{Key: "email", Value: int32(1)}}), Unique: &notUnique},
+		// Directions are doubles when created from the shell
+		{Name: "lastName_id_1", KeysDocument: keys(bson.D{{Key: "lastName", Value: 1.0}, {Key: "_id", Value: -1.0}})},
+		{Name: "picture_1", KeysDocument: keys(bson.D{{Key: "picture", Value: 1}})},
+	}
+
+	missing, mismatched, unexpected := diffIndexes(wanted, existing)
+	if len(missing) != 1 || missing[0].name != "firstName_1" {
+		t.Errorf("expected firstName_1 to be missing, got %v", missing)
+	}
+	if len(mismatched) != 1 || mismatched[0] != "email_1" {
+		t.Errorf("expected email_1 to be mismatched, got %v", mismatched)
+	}
+	if len(unexpected) != 1 || unexpected[0] != "picture_1" {
+		t.Errorf("expected picture_1 to be unexpected, got %v", unexpected)
+	}
+}
@@ -4,9 +4,32 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"schej.it/server/config"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
+)
+
+// Collection names, shared by every storage backend
+const (
+	eventsCollectionName            = "events"
+	usersCollectionName             = "users"
+	dailyUserLogsCollectionName     = "dailyuserlogs"
+	friendRequestsCollectionName    = "friendrequests"
+	responsesCollectionName         = "responses"
+	migrationsCollectionName        = "migrations"
+	rateLimitsCollectionName        = "ratelimits"
+	jobsCollectionName              = "jobs"
+	emailPreferencesCollectionName  = "emailpreferences"
+	digestItemsCollectionName       = "digestitems"
+	webhooksCollectionName          = "webhooks"
+	webhookDeliveriesCollectionName = "webhookdeliveries"
+	chatAccountsCollectionName      = "chataccounts"
+	chatMessagesCollectionName      = "chatmessages"
+	discordGuildsCollectionName     = "discordguilds"
 )
 
 var Client *mongo.Client
@@ -15,25 +38,86 @@ var EventsCollection *mongo.Collection
 var UsersCollection *mongo.Collection
 var DailyUserLogCollection *mongo.Collection
 var FriendRequestsCollection *mongo.Collection
+var ResponsesCollection *mongo.Collection
+var MigrationsCollection *mongo.Collection
+var RateLimitsCollection *mongo.Collection
+var JobsCollection *mongo.Collection
+var EmailPreferencesCollection *mongo.Collection
+var DigestItemsCollection *mongo.Collection
+var WebhooksCollection *mongo.Collection
+var WebhookDeliveriesCollection *mongo.Collection
+var ChatAccountsCollection *mongo.Collection
+var ChatMessagesCollection *mongo.Collection
+var DiscordGuildsCollection *mongo.Collection
 
 func Init() func() {
 	// Establish mongodb connection
 	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	Client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost"))
+	storageConfig := config.Get().Storage
+	var err error
+	Client, err = mongo.Connect(ctx, options.Client().ApplyURI(storageConfig.MongoUri).SetMonitor(commandMonitor))
 	if err != nil {
 		logger.StdErr.Panicln(err)
 	}
 
 	// Define mongodb database + collections
-	Db = Client.Database("schej-it")
-	EventsCollection = Db.Collection("events")
-	UsersCollection = Db.Collection("users")
-	DailyUserLogCollection = Db.Collection("dailyuserlogs")
-	FriendRequestsCollection = Db.Collection("friendrequests")
+	Db = Client.Database(storageConfig.MongoDatabase)
+	EventsCollection = Db.Collection(eventsCollectionName)
+	UsersCollection = Db.Collection(usersCollectionName)
+	DailyUserLogCollection = Db.Collection(dailyUserLogsCollectionName)
+	FriendRequestsCollection = Db.Collection(friendRequestsCollectionName)
+	ResponsesCollection = Db.Collection(responsesCollectionName)
+	MigrationsCollection = Db.Collection(migrationsCollectionName)
+	RateLimitsCollection = Db.Collection(rateLimitsCollectionName)
+	JobsCollection = Db.Collection(jobsCollectionName)
+	EmailPreferencesCollection = Db.Collection(emailPreferencesCollectionName)
+	DigestItemsCollection = Db.Collection(digestItemsCollectionName)
+	WebhooksCollection = Db.Collection(webhooksCollectionName)
+	WebhookDeliveriesCollection = Db.Collection(webhookDeliveriesCollectionName)
+	ChatAccountsCollection = Db.Collection(chatAccountsCollectionName)
+	ChatMessagesCollection = Db.Collection(chatMessagesCollectionName)
+	DiscordGuildsCollection = Db.Collection(discordGuildsCollectionName)
+
+	// Use the mongo implementations of the repositories
+	Events = mongoEventRepository{}
+	Users = mongoUserRepository{}
+	DailyUserLogs = mongoDailyUserLogRepository{}
+	FriendRequests = mongoFriendRequestRepository{}
+	Responses = mongoResponseRepository{}
+	RateLimits = mongoRateLimitRepository{}
+	Jobs = mongoJobRepository{}
+	EmailPreferences = mongoEmailPreferencesRepository{}
+	DigestItems = mongoDigestItemRepository{}
+	Webhooks = mongoWebhookRepository{}
+	WebhookDeliveries = mongoWebhookDeliveryRepository{}
+	ChatAccounts = mongoChatAccountRepository{}
+	ChatMessages = mongoChatMessageRepository{}
+	DiscordGuilds = mongoDiscordGuildRepository{}
 
-	// Return a function to close the connection
+	// Return a function to close the connection. The connection context has expired by the time it's called
 	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 		Client.Disconnect(ctx)
 	}
 }
+
+// Records the latency of every command sent to mongo
+var commandMonitor = &event.CommandMonitor{
+	Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+		metrics.MongoCommandDuration.WithLabelValues(e.CommandName, metrics.Success).Observe(e.Duration.Seconds())
+	},
+	Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+		metrics.MongoCommandDuration.WithLabelValues(e.CommandName, metrics.Error).Observe(e.Duration.Seconds())
+	},
+}
+
+// Returns an error if the database can't be reached. Always succeeds for the memory backend
+func Ping(ctx context.Context) error {
+	if Client == nil {
+		return nil
+	}
+
+	return Client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,1143 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+)
+
+// In-memory implementations of the repositories, used for tests and small self-hosted deployments that don't
+// want to run MongoDB.
+//
+// Documents are stored as BSON so that reads, inserts, and "$set" updates behave exactly like they do in mongo
+// (same omitempty handling, same encryption of calendar credentials, and callers never share memory with the
+// store). If a file path is given, every collection is written to that file after each write and loaded from it
+// on startup.
+//
+// Contexts are ignored, since no operation blocks on anything but the store's lock.
+
+var errDuplicateId = errors.New("a document with the same _id already exists")
+
+type memoryStore struct {
+	mutex       sync.RWMutex
+	collections map[string][]bson.M
+	path        string
+}
+
+type memoryEventRepository struct{ store *memoryStore }
+type memoryUserRepository struct{ store *memoryStore }
+type memoryDailyUserLogRepository struct{ store *memoryStore }
+type memoryFriendRequestRepository struct{ store *memoryStore }
+type memoryResponseRepository struct{ store *memoryStore }
+type memoryJobRepository struct{ store *memoryStore }
+type memoryEmailPreferencesRepository struct{ store *memoryStore }
+type memoryDigestItemRepository struct{ store *memoryStore }
+type memoryWebhookRepository struct{ store *memoryStore }
+type memoryWebhookDeliveryRepository struct{ store *memoryStore }
+type memoryChatAccountRepository struct{ store *memoryStore }
+type memoryChatMessageRepository struct{ store *memoryStore }
+type memoryDiscordGuildRepository struct{ store *memoryStore }
+
+// Rate limit counters are only kept in memory, since they're worthless after a restart
+type memoryRateLimitRepository struct {
+	mutex    sync.Mutex
+	counters map[string]*rateLimitCounter
+	sweptAt  time.Time
+}
+
+type rateLimitCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// Sets the repositories to in-memory implementations. If path is not empty, data is persisted to that file
+func InitMemory(path string) {
+	store := &memoryStore{collections: make(map[string][]bson.M), path: path}
+	if err := store.load(); err != nil {
+		logger.StdErr.Panicln(err)
+	}
+
+	Events = memoryEventRepository{store}
+	Users = memoryUserRepository{store}
+	DailyUserLogs = memoryDailyUserLogRepository{store}
+	FriendRequests = memoryFriendRequestRepository{store}
+	Responses = memoryResponseRepository{store}
+	Jobs = memoryJobRepository{store}
+	EmailPreferences = memoryEmailPreferencesRepository{store}
+	DigestItems = memoryDigestItemRepository{store}
+	Webhooks = memoryWebhookRepository{store}
+	WebhookDeliveries = memoryWebhookDeliveryRepository{store}
+	ChatAccounts = memoryChatAccountRepository{store}
+	ChatMessages = memoryChatMessageRepository{store}
+	DiscordGuilds = memoryDiscordGuildRepository{store}
+	UseMemoryRateLimits()
+}
+
+// Counts rate limits in this process instead of the storage backend
+func UseMemoryRateLimits() {
+	RateLimits = &memoryRateLimitRepository{counters: make(map[string]*rateLimitCounter), sweptAt: time.Now()}
+}
+
+// Loads the collections from the store's file, if it exists
+func (s *memoryStore) load() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var snapshot struct {
+		Collections map[string][]bson.M `bson:"collections"`
+	}
+	if err := bson.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	if snapshot.Collections != nil {
+		s.collections = snapshot.Collections
+	}
+
+	return nil
+}
+
+// Writes the collections to the store's file. Must be called with the lock held
+func (s *memoryStore) save() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	data, err := bson.Marshal(bson.M{"collections": s.collections})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash can't leave a partially written file behind
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// Decodes every document in the collection into a new element of results, which must be a pointer to a slice
+func (s *memoryStore) all(collection string, results interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// Decode via a single array so that any type of slice can be filled in
+	data, err := bson.Marshal(bson.M{"docs": s.collections[collection]})
+	if err != nil {
+		return err
+	}
+	wrapper := bson.Raw(data)
+	return wrapper.Lookup("docs").Unmarshal(results)
+}
+
+// Inserts the given object, generating an _id if it doesn't have one, and returns its _id
+func (s *memoryStore) insert(collection string, v interface{}) (primitive.ObjectID, error) {
+	doc, err := toDocument(v)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	id, ok := doc["_id"].(primitive.ObjectID)
+	if !ok || id.IsZero() {
+		id = primitive.NewObjectID()
+		doc["_id"] = id
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.indexOf(collection, id) >= 0 {
+		return primitive.NilObjectID, errDuplicateId
+	}
+	s.collections[collection] = append(s.collections[collection], doc)
+
+	return id, s.save()
+}
+
+// Sets the top level fields of the given object on the document with the given _id, like mongo's "$set"
+func (s *memoryStore) set(collection string, id primitive.ObjectID, v interface{}) error {
+	fields, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+
+	return s.update(collection, id, func(doc bson.M) {
+		for key, value := range fields {
+			doc[key] = value
+		}
+	})
+}
+
+// Calls updateFunc with the document with the given _id, if it exists
+func (s *memoryStore) update(collection string, id primitive.ObjectID, updateFunc func(doc bson.M)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i := s.indexOf(collection, id)
+	if i < 0 {
+		return nil
+	}
+	updateFunc(s.collections[collection][i])
+
+	return s.save()
+}
+
+// Deletes the document with the given _id, if it exists
+func (s *memoryStore) delete(collection string, id primitive.ObjectID) error {
+	return s.deleteWhere(collection, func(doc bson.M) bool { return doc["_id"] == id })
+}
+
+// Deletes every document that matches the filter
+func (s *memoryStore) deleteWhere(collection string, filter func(doc bson.M) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	docs := make([]bson.M, 0)
+	for _, doc := range s.collections[collection] {
+		if !filter(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == len(s.collections[collection]) {
+		return nil
+	}
+	s.collections[collection] = docs
+
+	return s.save()
+}
+
+// Returns the _id of the first document that matches the filter, or false if there is none
+func (s *memoryStore) findId(collection string, filter func(doc bson.M) bool) (primitive.ObjectID, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, doc := range s.collections[collection] {
+		if filter(doc) {
+			return doc["_id"].(primitive.ObjectID), true
+		}
+	}
+
+	return primitive.NilObjectID, false
+}
+
+// Returns the index of the document with the given _id, or -1. Must be called with the lock held
+func (s *memoryStore) indexOf(collection string, id primitive.ObjectID) int {
+	for i, doc := range s.collections[collection] {
+		if doc["_id"] == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Converts the given object to a document, the same way the mongo driver does
+func toDocument(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func (r memoryEventRepository) find(filter func(event *models.Event) bool) ([]models.Event, error) {
+	var events []models.Event
+	if err := r.store.all(eventsCollectionName, &events); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.Event, 0)
+	for i := range events {
+		if filter(&events[i]) {
+			results = append(results, events[i])
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryEventRepository) GetById(ctx context.Context, eventId primitive.ObjectID) (*models.Event, error) {
+	events, err := r.find(func(event *models.Event) bool { return event.Id == eventId })
+	if len(events) == 0 {
+		return nil, err
+	}
+
+	return &events[0], nil
+}
+
+func (r memoryEventRepository) GetByShortId(ctx context.Context, shortId string) (*models.Event, error) {
+	events, err := r.find(func(event *models.Event) bool { return event.ShortId != nil && *event.ShortId == shortId })
+	if len(events) == 0 {
+		return nil, err
+	}
+
+	return &events[0], nil
+}
+
+func (r memoryEventRepository) GetByUser(ctx context.Context, userId primitive.ObjectID, email string) ([]models.Event, error) {
+	eventIds, err := Responses.GetEventIdsByUser(ctx, userId.Hex())
+	if err != nil {
+		return nil, err
+	}
+	respondedEventIds := make(map[primitive.ObjectID]bool)
+	for _, eventId := range eventIds {
+		respondedEventIds[eventId] = true
+	}
+
+	events, err := r.find(func(event *models.Event) bool {
+		if event.OwnerId == userId || respondedEventIds[event.Id] {
+			return true
+		}
+		if event.Attendees != nil {
+			for _, attendee := range *event.Attendees {
+				if attendee.Email == email && attendee.Declined != nil && !*attendee.Declined {
+					return true
+				}
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Id.Hex() > events[j].Id.Hex()
+	})
+
+	return events, nil
+}
+
+func (r memoryEventRepository) GetCreatedSince(ctx context.Context, since time.Time) ([]models.Event, error) {
+	events, err := r.find(func(event *models.Event) bool { return !event.Id.Timestamp().Before(since.Truncate(time.Second)) })
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Id.Hex() > events[j].Id.Hex()
+	})
+
+	return events, nil
+}
+
+func (r memoryEventRepository) Insert(ctx context.Context, event *models.Event) error {
+	// Like the unique shortId index
+	if event.ShortId != nil {
+		existing, err := r.GetByShortId(ctx, *event.ShortId)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key error: shortId_1"}}}
+		}
+	}
+
+	id, err := r.store.insert(eventsCollectionName, event)
+	if err != nil {
+		return err
+	}
+
+	event.Id = id
+	return nil
+}
+
+func (r memoryEventRepository) Update(ctx context.Context, event *models.Event) error {
+	return r.store.set(eventsCollectionName, event.Id, event)
+}
+
+func (r memoryEventRepository) Delete(ctx context.Context, eventId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	event, err := r.GetById(ctx, eventId)
+	if err != nil || event == nil || event.OwnerId != ownerId {
+		return err
+	}
+
+	if err := r.store.delete(eventsCollectionName, eventId); err != nil {
+		return err
+	}
+	return Responses.DeleteByEvent(ctx, eventId)
+}
+
+func (r memoryUserRepository) find(filter func(user *models.User) bool) ([]models.User, error) {
+	var users []models.User
+	if err := r.store.all(usersCollectionName, &users); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.User, 0)
+	for i := range users {
+		if filter(&users[i]) {
+			results = append(results, users[i])
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryUserRepository) GetById(ctx context.Context, userId primitive.ObjectID) (*models.User, error) {
+	users, err := r.find(func(user *models.User) bool { return user.Id == userId })
+	if len(users) == 0 {
+		return nil, err
+	}
+
+	return &users[0], nil
+}
+
+func (r memoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	users, err := r.find(func(user *models.User) bool { return user.Email == email })
+	if len(users) == 0 {
+		return nil, err
+	}
+
+	return &users[0], nil
+}
+
+func (r memoryUserRepository) Search(ctx context.Context, terms []string) ([]models.User, error) {
+	return r.find(func(user *models.User) bool {
+		searchString := strings.ToLower(user.FirstName + " " + user.LastName + " " + user.Email)
+		for _, term := range terms {
+			if !strings.Contains(searchString, strings.ToLower(term)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (r memoryUserRepository) Count(ctx context.Context) (int64, error) {
+	r.store.mutex.RLock()
+	defer r.store.mutex.RUnlock()
+
+	return int64(len(r.store.collections[usersCollectionName])), nil
+}
+
+func (r memoryUserRepository) GetCreatedSince(ctx context.Context, since time.Time) ([]models.User, error) {
+	users, err := r.find(func(user *models.User) bool { return !user.Id.Timestamp().Before(since.Truncate(time.Second)) })
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Id.Hex() > users[j].Id.Hex()
+	})
+
+	return users, nil
+}
+
+func (r memoryUserRepository) Insert(ctx context.Context, user *models.User) error {
+	id, err := r.store.insert(usersCollectionName, user)
+	if err != nil {
+		return err
+	}
+
+	user.Id = id
+	return nil
+}
+
+func (r memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	return r.store.set(usersCollectionName, user.Id, user)
+}
+
+func (r memoryUserRepository) SetFields(ctx context.Context, userId primitive.ObjectID, fields bson.M) error {
+	return r.store.set(usersCollectionName, userId, fields)
+}
+
+func (r memoryUserRepository) RemoveCalendarAccount(ctx context.Context, userId primitive.ObjectID, calendarAccountKey string) error {
+	return r.store.update(usersCollectionName, userId, func(doc bson.M) {
+		if calendarAccounts, ok := doc["calendarAccounts"].(bson.M); ok {
+			delete(calendarAccounts, calendarAccountKey)
+		}
+	})
+}
+
+func (r memoryUserRepository) ClaimDigest(ctx context.Context, userId primitive.ObjectID, at time.Time, staleBefore time.Time) (bool, error) {
+	claimed := false
+	err := r.store.update(usersCollectionName, userId, func(doc bson.M) {
+		if scheduledFor, ok := doc["digestScheduledFor"].(primitive.DateTime); ok && !scheduledFor.Time().Before(staleBefore) {
+			return
+		}
+		doc["digestScheduledFor"] = primitive.NewDateTimeFromTime(at)
+		claimed = true
+	})
+
+	return claimed, err
+}
+
+func (r memoryUserRepository) Delete(ctx context.Context, userId primitive.ObjectID) error {
+	return r.store.delete(usersCollectionName, userId)
+}
+
+func (r memoryDailyUserLogRepository) find(filter func(log *models.DailyUserLog) bool) ([]models.DailyUserLog, error) {
+	var logs []models.DailyUserLog
+	if err := r.store.all(dailyUserLogsCollectionName, &logs); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.DailyUserLog, 0)
+	for i := range logs {
+		if filter(&logs[i]) {
+			results = append(results, logs[i])
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryDailyUserLogRepository) GetByDateRange(ctx context.Context, start time.Time, end time.Time) (*models.DailyUserLog, error) {
+	logs, err := r.find(func(log *models.DailyUserLog) bool {
+		date := log.Date.Time()
+		return !date.Before(start) && !date.After(end)
+	})
+	if len(logs) == 0 {
+		return nil, err
+	}
+
+	return &logs[0], nil
+}
+
+func (r memoryDailyUserLogRepository) GetSince(ctx context.Context, start time.Time, populateUsers bool) ([]models.DailyUserLog, error) {
+	logs, err := r.find(func(log *models.DailyUserLog) bool {
+		return !log.Date.Time().Before(start)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Date > logs[j].Date
+	})
+
+	if populateUsers {
+		for i := range logs {
+			logs[i].Users = make([]models.User, 0)
+			for _, userId := range logs[i].UserIds {
+				user, err := Users.GetById(ctx, userId)
+				if err != nil {
+					return nil, err
+				}
+				if user != nil {
+					logs[i].Users = append(logs[i].Users, models.User{
+						Id:        user.Id,
+						FirstName: user.FirstName,
+						LastName:  user.LastName,
+						Email:     user.Email,
+					})
+				}
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+func (r memoryDailyUserLogRepository) Insert(ctx context.Context, log *models.DailyUserLog) error {
+	id, err := r.store.insert(dailyUserLogsCollectionName, log)
+	if err != nil {
+		return err
+	}
+
+	log.Id = id
+	return nil
+}
+
+func (r memoryDailyUserLogRepository) Update(ctx context.Context, log *models.DailyUserLog) error {
+	return r.store.set(dailyUserLogsCollectionName, log.Id, log)
+}
+
+func (r memoryFriendRequestRepository) GetById(ctx context.Context, friendRequestId primitive.ObjectID) (*models.FriendRequest, error) {
+	var friendRequests []models.FriendRequest
+	if err := r.store.all(friendRequestsCollectionName, &friendRequests); err != nil {
+		return nil, err
+	}
+
+	for i := range friendRequests {
+		if friendRequests[i].Id == friendRequestId {
+			return &friendRequests[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryFriendRequestRepository) Insert(ctx context.Context, friendRequest *models.FriendRequest) error {
+	id, err := r.store.insert(friendRequestsCollectionName, friendRequest)
+	if err != nil {
+		return err
+	}
+
+	friendRequest.Id = id
+	return nil
+}
+
+func (r memoryFriendRequestRepository) Delete(ctx context.Context, friendRequestId primitive.ObjectID) error {
+	return r.store.delete(friendRequestsCollectionName, friendRequestId)
+}
+
+func (r memoryResponseRepository) find(filter func(response *models.EventResponse) bool) ([]models.EventResponse, error) {
+	var responses []models.EventResponse
+	if err := r.store.all(responsesCollectionName, &responses); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.EventResponse, 0)
+	for i := range responses {
+		if filter(&responses[i]) {
+			results = append(results, responses[i])
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryResponseRepository) GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.EventResponse, error) {
+	return r.find(func(response *models.EventResponse) bool { return response.EventId == eventId })
+}
+
+func (r memoryResponseRepository) GetUserIdsByEvents(ctx context.Context, eventIds []primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	eventIdsSet := make(map[primitive.ObjectID]bool)
+	for _, eventId := range eventIds {
+		eventIdsSet[eventId] = true
+	}
+
+	responses, err := r.find(func(response *models.EventResponse) bool { return eventIdsSet[response.EventId] })
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make(map[primitive.ObjectID][]string)
+	for _, response := range responses {
+		userIds[response.EventId] = append(userIds[response.EventId], response.UserId)
+	}
+
+	return userIds, nil
+}
+
+func (r memoryResponseRepository) GetEventIdsByUser(ctx context.Context, userId string) ([]primitive.ObjectID, error) {
+	responses, err := r.find(func(response *models.EventResponse) bool { return response.UserId == userId })
+	if err != nil {
+		return nil, err
+	}
+
+	eventIds := make([]primitive.ObjectID, 0)
+	for _, response := range responses {
+		eventIds = append(eventIds, response.EventId)
+	}
+
+	return eventIds, nil
+}
+
+func (r memoryResponseRepository) Upsert(ctx context.Context, eventId primitive.ObjectID, userId string, response *models.Response) error {
+	id, exists := r.store.findId(responsesCollectionName, responseFilter(eventId, userId))
+	if exists {
+		return r.store.set(responsesCollectionName, id, bson.M{"response": response})
+	}
+
+	_, err := r.store.insert(responsesCollectionName, models.EventResponse{
+		EventId:  eventId,
+		UserId:   userId,
+		Response: response,
+	})
+	return err
+}
+
+func (r memoryResponseRepository) Delete(ctx context.Context, eventId primitive.ObjectID, userId string) error {
+	return r.store.deleteWhere(responsesCollectionName, responseFilter(eventId, userId))
+}
+
+func (r memoryResponseRepository) DeleteByEvent(ctx context.Context, eventId primitive.ObjectID) error {
+	return r.store.deleteWhere(responsesCollectionName, func(doc bson.M) bool { return doc["eventId"] == eventId })
+}
+
+// Returns a filter matching the user's response document for the event
+func responseFilter(eventId primitive.ObjectID, userId string) func(doc bson.M) bool {
+	return func(doc bson.M) bool {
+		return doc["eventId"] == eventId && doc["userId"] == userId
+	}
+}
+
+func (r memoryJobRepository) GetById(ctx context.Context, jobId primitive.ObjectID) (*models.Job, error) {
+	var jobs []models.Job
+	if err := r.store.all(jobsCollectionName, &jobs); err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if jobs[i].Id == jobId {
+			return &jobs[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryJobRepository) Insert(ctx context.Context, job *models.Job) error {
+	id, err := r.store.insert(jobsCollectionName, job)
+	if err != nil {
+		return err
+	}
+
+	job.Id = id
+	return nil
+}
+
+func (r memoryJobRepository) Lease(ctx context.Context, worker string, now time.Time, leasedUntil time.Time) (*models.Job, error) {
+	var leased *models.Job
+	err := r.modify(func(jobs []models.Job) (int, error) {
+		due := -1
+		for i, job := range jobs {
+			if job.Status != models.JobPending || job.RunAt.After(now) || (job.LeasedUntil != nil && job.LeasedUntil.After(now)) {
+				continue
+			}
+			if due < 0 || job.RunAt.Before(jobs[due].RunAt) {
+				due = i
+			}
+		}
+		if due < 0 {
+			return -1, nil
+		}
+
+		jobs[due].LeasedBy = worker
+		jobs[due].LeasedUntil = &leasedUntil
+		jobs[due].Attempts++
+		leased = &jobs[due]
+		return due, nil
+	})
+
+	return leased, err
+}
+
+func (r memoryJobRepository) Complete(ctx context.Context, jobId primitive.ObjectID, worker string) error {
+	return r.modifyById(jobId, func(job *models.Job) bool {
+		if job.Status != models.JobPending || job.LeasedBy != worker {
+			return false
+		}
+
+		now := time.Now()
+		job.Status = models.JobDone
+		job.FinishedAt = &now
+		job.LeasedUntil = nil
+		return true
+	})
+}
+
+func (r memoryJobRepository) Fail(ctx context.Context, jobId primitive.ObjectID, worker string, message string, retryAt *time.Time) error {
+	return r.modifyById(jobId, func(job *models.Job) bool {
+		if job.Status != models.JobPending || job.LeasedBy != worker {
+			return false
+		}
+
+		job.LastError = message
+		job.LeasedUntil = nil
+		if retryAt != nil {
+			job.RunAt = *retryAt
+		} else {
+			now := time.Now()
+			job.Status = models.JobFailed
+			job.FinishedAt = &now
+		}
+		return true
+	})
+}
+
+func (r memoryJobRepository) Cancel(ctx context.Context, jobId primitive.ObjectID) error {
+	return r.modifyById(jobId, func(job *models.Job) bool {
+		if job.Status != models.JobPending {
+			return false
+		}
+
+		now := time.Now()
+		job.Status = models.JobCanceled
+		job.FinishedAt = &now
+		return true
+	})
+}
+
+// Calls modifyFunc with the job with the given id, and saves it if modifyFunc returns true
+func (r memoryJobRepository) modifyById(jobId primitive.ObjectID, modifyFunc func(job *models.Job) bool) error {
+	return r.modify(func(jobs []models.Job) (int, error) {
+		for i := range jobs {
+			if jobs[i].Id == jobId && modifyFunc(&jobs[i]) {
+				return i, nil
+			}
+		}
+		return -1, nil
+	})
+}
+
+// Calls modifyFunc with every job while holding the lock, so that finding and updating a job is atomic like in
+// mongo. modifyFunc returns the index of the job it modified, or -1 if it didn't modify any
+func (r memoryJobRepository) modify(modifyFunc func(jobs []models.Job) (int, error)) error {
+	s := r.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var jobs []models.Job
+	data, err := bson.Marshal(bson.M{"docs": s.collections[jobsCollectionName]})
+	if err != nil {
+		return err
+	}
+	if err := bson.Raw(data).Lookup("docs").Unmarshal(&jobs); err != nil {
+		return err
+	}
+
+	i, err := modifyFunc(jobs)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	doc, err := toDocument(jobs[i])
+	if err != nil {
+		return err
+	}
+	s.collections[jobsCollectionName][i] = doc
+
+	return s.save()
+}
+
+func (r memoryEmailPreferencesRepository) GetByEmail(ctx context.Context, email string) (*models.EmailPreferences, error) {
+	var prefs []models.EmailPreferences
+	if err := r.store.all(emailPreferencesCollectionName, &prefs); err != nil {
+		return nil, err
+	}
+
+	for i := range prefs {
+		if prefs[i].Email == email {
+			return &prefs[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryEmailPreferencesRepository) Upsert(ctx context.Context, prefs *models.EmailPreferences) error {
+	replacement := models.EmailPreferences{Email: prefs.Email, Preferences: prefs.Preferences}
+	if id, ok := r.store.findId(emailPreferencesCollectionName, func(doc bson.M) bool { return doc["email"] == prefs.Email }); ok {
+		return r.store.set(emailPreferencesCollectionName, id, replacement)
+	}
+
+	_, err := r.store.insert(emailPreferencesCollectionName, replacement)
+	return err
+}
+
+func (r memoryDigestItemRepository) GetByUser(ctx context.Context, userId primitive.ObjectID) ([]models.DigestItem, error) {
+	var items []models.DigestItem
+	if err := r.store.all(digestItemsCollectionName, &items); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.DigestItem, 0)
+	for _, item := range items {
+		if item.UserId == userId {
+			results = append(results, item)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].CreatedAt.Before(results[j].CreatedAt) })
+
+	return results, nil
+}
+
+func (r memoryDigestItemRepository) Insert(ctx context.Context, item *models.DigestItem) error {
+	id, err := r.store.insert(digestItemsCollectionName, item)
+	if err != nil {
+		return err
+	}
+
+	item.Id = id
+	return nil
+}
+
+func (r memoryDigestItemRepository) Delete(ctx context.Context, itemIds []primitive.ObjectID) error {
+	return r.store.deleteWhere(digestItemsCollectionName, func(doc bson.M) bool {
+		id, _ := doc["_id"].(primitive.ObjectID)
+		return slices.Contains(itemIds, id)
+	})
+}
+
+func (r memoryWebhookRepository) GetById(ctx context.Context, webhookId primitive.ObjectID) (*models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := r.store.all(webhooksCollectionName, &webhooks); err != nil {
+		return nil, err
+	}
+
+	for i := range webhooks {
+		if webhooks[i].Id == webhookId {
+			return &webhooks[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryWebhookRepository) GetByOwner(ctx context.Context, ownerId primitive.ObjectID) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := r.store.all(webhooksCollectionName, &webhooks); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.Webhook, 0)
+	for _, webhook := range webhooks {
+		if webhook.OwnerId == ownerId {
+			results = append(results, webhook)
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryWebhookRepository) Insert(ctx context.Context, webhook *models.Webhook) error {
+	id, err := r.store.insert(webhooksCollectionName, webhook)
+	if err != nil {
+		return err
+	}
+
+	webhook.Id = id
+	return nil
+}
+
+func (r memoryWebhookRepository) Delete(ctx context.Context, webhookId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	webhook, err := r.GetById(ctx, webhookId)
+	if err != nil || webhook == nil || webhook.OwnerId != ownerId {
+		return err
+	}
+
+	if err := r.store.delete(webhooksCollectionName, webhookId); err != nil {
+		return err
+	}
+	return r.store.deleteWhere(webhookDeliveriesCollectionName, func(doc bson.M) bool {
+		id, _ := doc["webhookId"].(primitive.ObjectID)
+		return id == webhookId
+	})
+}
+
+func (r memoryWebhookDeliveryRepository) GetById(ctx context.Context, deliveryId primitive.ObjectID) (*models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.store.all(webhookDeliveriesCollectionName, &deliveries); err != nil {
+		return nil, err
+	}
+
+	for i := range deliveries {
+		if deliveries[i].Id == deliveryId {
+			return &deliveries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryWebhookDeliveryRepository) GetByWebhook(ctx context.Context, webhookId primitive.ObjectID, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.store.all(webhookDeliveriesCollectionName, &deliveries); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.WebhookDelivery, 0)
+	for _, delivery := range deliveries {
+		if delivery.WebhookId == webhookId {
+			results = append(results, delivery)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].CreatedAt.After(results[j].CreatedAt) })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (r memoryWebhookDeliveryRepository) Insert(ctx context.Context, delivery *models.WebhookDelivery) error {
+	id, err := r.store.insert(webhookDeliveriesCollectionName, delivery)
+	if err != nil {
+		return err
+	}
+
+	delivery.Id = id
+	return nil
+}
+
+func (r memoryWebhookDeliveryRepository) Update(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.store.set(webhookDeliveriesCollectionName, delivery.Id, delivery)
+}
+
+func (r memoryChatAccountRepository) Get(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) (*models.ChatAccount, error) {
+	var accounts []models.ChatAccount
+	if err := r.store.all(chatAccountsCollectionName, &accounts); err != nil {
+		return nil, err
+	}
+
+	for i := range accounts {
+		if accounts[i].Platform == platform && accounts[i].TeamId == teamId && accounts[i].ExternalId == externalId {
+			return &accounts[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryChatAccountRepository) Upsert(ctx context.Context, account *models.ChatAccount) error {
+	existing, err := r.Get(ctx, account.Platform, account.TeamId, account.ExternalId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		account.Id = existing.Id
+		return r.store.set(chatAccountsCollectionName, account.Id, account)
+	}
+
+	id, err := r.store.insert(chatAccountsCollectionName, account)
+	if err != nil {
+		return err
+	}
+
+	account.Id = id
+	return nil
+}
+
+func (r memoryChatAccountRepository) Delete(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) error {
+	return r.store.deleteWhere(chatAccountsCollectionName, func(doc bson.M) bool {
+		return doc["platform"] == string(platform) && doc["teamId"] == teamId && doc["externalId"] == externalId
+	})
+}
+
+func (r memoryChatAccountRepository) GetByUsers(ctx context.Context, platform models.ChatPlatform, userIds []primitive.ObjectID) ([]models.ChatAccount, error) {
+	var accounts []models.ChatAccount
+	if err := r.store.all(chatAccountsCollectionName, &accounts); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ChatAccount, 0)
+	for _, account := range accounts {
+		if account.Platform == platform && slices.Contains(userIds, account.UserId) {
+			results = append(results, account)
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryChatMessageRepository) GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	if err := r.store.all(chatMessagesCollectionName, &messages); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ChatMessage, 0)
+	for _, message := range messages {
+		if message.EventId == eventId {
+			results = append(results, message)
+		}
+	}
+
+	return results, nil
+}
+
+func (r memoryChatMessageRepository) Insert(ctx context.Context, message *models.ChatMessage) error {
+	id, err := r.store.insert(chatMessagesCollectionName, message)
+	if err != nil {
+		return err
+	}
+
+	message.Id = id
+	return nil
+}
+
+func (r memoryChatMessageRepository) Delete(ctx context.Context, messageId primitive.ObjectID) error {
+	return r.store.delete(chatMessagesCollectionName, messageId)
+}
+
+func (r memoryDiscordGuildRepository) Get(ctx context.Context, guildId string) (*models.DiscordGuild, error) {
+	var guilds []models.DiscordGuild
+	if err := r.store.all(discordGuildsCollectionName, &guilds); err != nil {
+		return nil, err
+	}
+
+	for i := range guilds {
+		if guilds[i].GuildId == guildId {
+			return &guilds[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r memoryDiscordGuildRepository) Upsert(ctx context.Context, guild *models.DiscordGuild) error {
+	existing, err := r.Get(ctx, guild.GuildId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		guild.Id = existing.Id
+		return r.store.set(discordGuildsCollectionName, guild.Id, guild)
+	}
+
+	id, err := r.store.insert(discordGuildsCollectionName, guild)
+	if err != nil {
+		return err
+	}
+
+	guild.Id = id
+	return nil
+}
+
+func (r memoryDiscordGuildRepository) Delete(ctx context.Context, guildId string) error {
+	return r.store.deleteWhere(discordGuildsCollectionName, func(doc bson.M) bool {
+		return doc["guildId"] == guildId
+	})
+}
+
+func (r *memoryRateLimitRepository) Increment(ctx context.Context, key string, n int, window time.Duration) (int, time.Time, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	counter, ok := r.counters[key]
+	if !ok || !counter.expiresAt.After(now) {
+		counter = &rateLimitCounter{expiresAt: now.Add(window)}
+		r.counters[key] = counter
+	}
+	counter.count += n
+
+	return counter.count, counter.expiresAt, nil
+}
+
+// Deletes expired counters at most once a minute, so that clients that stop making requests don't use memory
+// forever. Must be called with the lock held
+func (r *memoryRateLimitRepository) sweep(now time.Time) {
+	if now.Sub(r.sweptAt) < time.Minute {
+		return
+	}
+
+	for key, counter := range r.counters {
+		if !counter.expiresAt.After(now) {
+			delete(r.counters, key)
+		}
+	}
+	r.sweptAt = now
+}
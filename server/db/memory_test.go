@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data.bson")
+	InitMemory(path)
+
+	user := models.User{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		CalendarAccounts: map[string]models.CalendarAccount{
+			"ada@example.com_google": {Email: "ada@example.com", CalendarType: models.GoogleCalendarType},
+			"ada@example.com_apple":  {Email: "ada@example.com", CalendarType: models.AppleCalendarType},
+		},
+	}
+	if err := Users.Insert(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates only set the fields that are marshalled, like mongo's $set
+	if err := Users.Update(ctx, &models.User{Id: user.Id, FirstName: "Augusta"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Users.SetFields(ctx, user.Id, bson.M{"hasCustomName": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Users.RemoveCalendarAccount(ctx, user.Id, "ada@example.com_apple"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload from disk
+	InitMemory(path)
+
+	got, err := Users.GetByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("expected user to be persisted")
+	}
+	if got.FirstName != "Augusta" || got.LastName != "Lovelace" || !utils.Coalesce(got.HasCustomName) {
+		t.Errorf("unexpected user after updates: %+v", got)
+	}
+	if _, ok := got.CalendarAccounts["ada@example.com_apple"]; ok || len(got.CalendarAccounts) != 1 {
+		t.Errorf("expected only the google calendar account to remain, got %v", got.CalendarAccounts)
+	}
+	if users, err := Users.Search(ctx, []string{"AUGUSTA", "example"}); err != nil || len(users) != 1 {
+		t.Errorf("expected search to match the user, got %v", users)
+	}
+
+	if err := Users.Delete(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, _ := Users.GetById(ctx, user.Id); deleted != nil {
+		t.Error("expected user to be deleted")
+	}
+	if count, _ := Users.Count(ctx); count != 0 {
+		t.Error("expected user to be deleted")
+	}
+}
+
+func TestMemoryRateLimitRepository(t *testing.T) {
+	ctx := context.Background()
+	InitMemory("")
+
+	if count, _, _ := RateLimits.Increment(ctx, "a", 1, time.Hour); count != 1 {
+		t.Errorf("expected count 1, got %d", count)
+	}
+	count, resetAt, err := RateLimits.Increment(ctx, "a", 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || time.Until(resetAt) < 59*time.Minute {
+		t.Errorf("expected count 3 in the first window, got %d resetting at %v", count, resetAt)
+	}
+	if count, _, _ := RateLimits.Increment(ctx, "b", 1, time.Hour); count != 1 {
+		t.Errorf("expected counters to be separate per key, got %d", count)
+	}
+
+	// The counter starts over once its window has passed
+	RateLimits.Increment(ctx, "c", 5, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if count, _, _ := RateLimits.Increment(ctx, "c", 1, time.Hour); count != 1 {
+		t.Errorf("expected the expired counter to reset, got %d", count)
+	}
+}
+
+func TestInsertEventWithShortId(t *testing.T) {
+	ctx := context.Background()
+	InitMemory("")
+
+	first := models.Event{Name: "First"}
+	if err := InsertEventWithShortId(ctx, &first); err != nil || first.ShortId == nil {
+		t.Fatalf("expected the event to get a short id, got %v", err)
+	}
+
+	// Taken short ids are rejected like by the unique index
+	second := models.Event{Name: "Second", ShortId: first.ShortId}
+	if err := Events.Insert(ctx, &second); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+	if err := InsertEventWithShortId(ctx, &second); err != nil || *second.ShortId == *first.ShortId {
+		t.Errorf("expected the event to get another short id, got %v", err)
+	}
+}
@@ -0,0 +1,907 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/config"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// MongoDB implementations of the repositories, backed by the collections set in Init
+
+type mongoEventRepository struct{}
+type mongoUserRepository struct{}
+type mongoDailyUserLogRepository struct{}
+type mongoFriendRequestRepository struct{}
+type mongoResponseRepository struct{}
+type mongoRateLimitRepository struct{}
+type mongoJobRepository struct{}
+type mongoEmailPreferencesRepository struct{}
+type mongoDigestItemRepository struct{}
+type mongoWebhookRepository struct{}
+type mongoWebhookDeliveryRepository struct{}
+type mongoChatAccountRepository struct{}
+type mongoChatMessageRepository struct{}
+type mongoDiscordGuildRepository struct{}
+
+// Bounds ctx by the database timeout, so that a hung query doesn't outlive the request that made it
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Get().Timeouts.Database())
+}
+
+// Decodes the result of a FindOne into v, returning false if no document was found
+func decodeOne(result *mongo.SingleResult, v interface{}) (bool, error) {
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return false, nil
+	}
+
+	if err := result.Decode(v); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Decodes all the documents in the cursor into results
+func decodeAll(ctx context.Context, cursor *mongo.Cursor, err error, results interface{}) error {
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func (mongoEventRepository) GetById(ctx context.Context, eventId primitive.ObjectID) (*models.Event, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var event models.Event
+	if found, err := decodeOne(EventsCollection.FindOne(ctx, bson.M{"_id": eventId}), &event); !found {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (mongoEventRepository) GetByShortId(ctx context.Context, shortId string) (*models.Event, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var event models.Event
+	if found, err := decodeOne(EventsCollection.FindOne(ctx, bson.M{"shortId": shortId}), &event); !found {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (mongoEventRepository) GetByUser(ctx context.Context, userId primitive.ObjectID, email string) ([]models.Event, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	respondedEventIds, err := Responses.GetEventIdsByUser(ctx, userId.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0)
+	cursor, err := EventsCollection.Find(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"ownerId": userId},
+			bson.M{"_id": bson.M{"$in": respondedEventIds}},
+			bson.M{"attendees": bson.M{"email": email, "declined": false}},
+		},
+	}, options.Find().SetSort(bson.M{"_id": -1}))
+	if err := decodeAll(ctx, cursor, err, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (mongoEventRepository) GetCreatedSince(ctx context.Context, since time.Time) ([]models.Event, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	events := make([]models.Event, 0)
+	filter := bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(since)}}
+	cursor, err := EventsCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+	if err := decodeAll(ctx, cursor, err, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (mongoEventRepository) Insert(ctx context.Context, event *models.Event) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := EventsCollection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	event.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoEventRepository) Update(ctx context.Context, event *models.Event) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := EventsCollection.UpdateByID(ctx, event.Id, bson.M{"$set": event})
+	return err
+}
+
+func (mongoEventRepository) Delete(ctx context.Context, eventId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := EventsCollection.DeleteOne(ctx, bson.M{
+		"_id":     eventId,
+		"ownerId": ownerId,
+	})
+	if err != nil || result.DeletedCount == 0 {
+		return err
+	}
+
+	return Responses.DeleteByEvent(ctx, eventId)
+}
+
+func (mongoUserRepository) GetById(ctx context.Context, userId primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	if found, err := decodeOne(UsersCollection.FindOne(ctx, bson.M{"_id": userId}), &user); !found {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (mongoUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	if found, err := decodeOne(UsersCollection.FindOne(ctx, bson.M{"email": email}), &user); !found {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (mongoUserRepository) Search(ctx context.Context, terms []string) ([]models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	termsRegex := make([]primitive.Regex, 0)
+	for _, term := range terms {
+		termsRegex = append(termsRegex, primitive.Regex{Pattern: utils.EscapeRegExp(term), Options: "i"})
+	}
+
+	users := make([]models.User, 0)
+	cursor, err := UsersCollection.Find(ctx, bson.M{
+		"$expr": bson.M{
+			"$reduce": bson.M{
+				"input":        termsRegex,
+				"initialValue": true,
+				"in": bson.M{
+					"$and": bson.A{
+						"$$value",
+						bson.M{
+							"$regexMatch": bson.M{
+								"input": bson.M{
+									"$concat": bson.A{
+										"$firstName", " ", "$lastName", " ", "$email",
+									},
+								},
+								"regex": "$$this",
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err := decodeAll(ctx, cursor, err, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (mongoUserRepository) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return UsersCollection.CountDocuments(ctx, bson.M{})
+}
+
+func (mongoUserRepository) GetCreatedSince(ctx context.Context, since time.Time) ([]models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	users := make([]models.User, 0)
+	filter := bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(since)}}
+	cursor, err := UsersCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+	if err := decodeAll(ctx, cursor, err, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (mongoUserRepository) Insert(ctx context.Context, user *models.User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := UsersCollection.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	user.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoUserRepository) Update(ctx context.Context, user *models.User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := UsersCollection.UpdateByID(ctx, user.Id, bson.M{"$set": user})
+	return err
+}
+
+func (mongoUserRepository) SetFields(ctx context.Context, userId primitive.ObjectID, fields bson.M) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := UsersCollection.UpdateByID(ctx, userId, bson.M{"$set": fields})
+	return err
+}
+
+func (mongoUserRepository) RemoveCalendarAccount(ctx context.Context, userId primitive.ObjectID, calendarAccountKey string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// Calendar account keys contain periods, so the field has to be removed with $setField
+	_, err := UsersCollection.UpdateByID(ctx, userId, bson.A{
+		bson.M{"$set": bson.M{
+			"calendarAccounts": bson.M{
+				"$setField": bson.M{
+					"field": calendarAccountKey,
+					"input": "$$ROOT.calendarAccounts",
+					"value": "$$REMOVE",
+				},
+			},
+		}},
+	})
+	return err
+}
+
+func (mongoUserRepository) ClaimDigest(ctx context.Context, userId primitive.ObjectID, at time.Time, staleBefore time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := UsersCollection.UpdateOne(ctx, bson.M{
+		"_id": userId,
+		"$or": bson.A{
+			bson.M{"digestScheduledFor": nil},
+			bson.M{"digestScheduledFor": bson.M{"$lt": primitive.NewDateTimeFromTime(staleBefore)}},
+		},
+	}, bson.M{"$set": bson.M{"digestScheduledFor": primitive.NewDateTimeFromTime(at)}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (mongoUserRepository) Delete(ctx context.Context, userId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := UsersCollection.DeleteOne(ctx, bson.M{"_id": userId})
+	return err
+}
+
+func (mongoDailyUserLogRepository) GetByDateRange(ctx context.Context, start time.Time, end time.Time) (*models.DailyUserLog, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var log models.DailyUserLog
+	if found, err := decodeOne(DailyUserLogCollection.FindOne(ctx, bson.M{
+		"date": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(start),
+			"$lte": primitive.NewDateTimeFromTime(end),
+		},
+	}), &log); !found {
+		return nil, err
+	}
+
+	return &log, nil
+}
+
+func (mongoDailyUserLogRepository) GetSince(ctx context.Context, start time.Time, populateUsers bool) ([]models.DailyUserLog, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := bson.M{"date": bson.M{"$gte": primitive.NewDateTimeFromTime(start)}}
+	sort := bson.M{"date": -1}
+
+	logs := make([]models.DailyUserLog, 0)
+	if populateUsers {
+		cursor, err := DailyUserLogCollection.Aggregate(ctx, []bson.M{
+			{"$match": query},
+			{"$sort": sort},
+			{"$lookup": bson.M{
+				"from":         "users",
+				"localField":   "userIds",
+				"foreignField": "_id",
+				"as":           "users",
+			}},
+			{"$project": bson.M{
+				"date":            1,
+				"userIds":         1,
+				"users._id":       1,
+				"users.firstName": 1,
+				"users.lastName":  1,
+				"users.email":     1,
+			}},
+		})
+		if err := decodeAll(ctx, cursor, err, &logs); err != nil {
+			return nil, err
+		}
+	} else {
+		cursor, err := DailyUserLogCollection.Find(ctx, query, options.Find().SetSort(sort))
+		if err := decodeAll(ctx, cursor, err, &logs); err != nil {
+			return nil, err
+		}
+	}
+
+	return logs, nil
+}
+
+func (mongoDailyUserLogRepository) Insert(ctx context.Context, log *models.DailyUserLog) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := DailyUserLogCollection.InsertOne(ctx, log)
+	if err != nil {
+		return err
+	}
+
+	log.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoDailyUserLogRepository) Update(ctx context.Context, log *models.DailyUserLog) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := DailyUserLogCollection.UpdateByID(ctx, log.Id, bson.M{"$set": log})
+	return err
+}
+
+func (mongoFriendRequestRepository) GetById(ctx context.Context, friendRequestId primitive.ObjectID) (*models.FriendRequest, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var friendRequest models.FriendRequest
+	if found, err := decodeOne(FriendRequestsCollection.FindOne(ctx, bson.M{"_id": friendRequestId}), &friendRequest); !found {
+		return nil, err
+	}
+
+	return &friendRequest, nil
+}
+
+func (mongoFriendRequestRepository) Insert(ctx context.Context, friendRequest *models.FriendRequest) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := FriendRequestsCollection.InsertOne(ctx, friendRequest)
+	if err != nil {
+		return err
+	}
+
+	friendRequest.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoFriendRequestRepository) Delete(ctx context.Context, friendRequestId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := FriendRequestsCollection.DeleteOne(ctx, bson.M{"_id": friendRequestId})
+	return err
+}
+
+func (mongoResponseRepository) GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.EventResponse, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	responses := make([]models.EventResponse, 0)
+	cursor, err := ResponsesCollection.Find(ctx, bson.M{"eventId": eventId})
+	if err := decodeAll(ctx, cursor, err, &responses); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+func (mongoResponseRepository) GetUserIdsByEvents(ctx context.Context, eventIds []primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var responses []models.EventResponse
+	cursor, err := ResponsesCollection.Find(
+		ctx,
+		bson.M{"eventId": bson.M{"$in": eventIds}},
+		options.Find().SetProjection(bson.M{"eventId": 1, "userId": 1}),
+	)
+	if err := decodeAll(ctx, cursor, err, &responses); err != nil {
+		return nil, err
+	}
+
+	userIds := make(map[primitive.ObjectID][]string)
+	for _, response := range responses {
+		userIds[response.EventId] = append(userIds[response.EventId], response.UserId)
+	}
+
+	return userIds, nil
+}
+
+func (mongoResponseRepository) GetEventIdsByUser(ctx context.Context, userId string) ([]primitive.ObjectID, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var responses []models.EventResponse
+	cursor, err := ResponsesCollection.Find(
+		ctx,
+		bson.M{"userId": userId},
+		options.Find().SetProjection(bson.M{"eventId": 1}),
+	)
+	if err := decodeAll(ctx, cursor, err, &responses); err != nil {
+		return nil, err
+	}
+
+	eventIds := make([]primitive.ObjectID, 0)
+	for _, response := range responses {
+		eventIds = append(eventIds, response.EventId)
+	}
+
+	return eventIds, nil
+}
+
+func (mongoResponseRepository) Upsert(ctx context.Context, eventId primitive.ObjectID, userId string, response *models.Response) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := ResponsesCollection.UpdateOne(
+		ctx,
+		bson.M{"eventId": eventId, "userId": userId},
+		bson.M{"$set": bson.M{"response": response}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (mongoResponseRepository) Delete(ctx context.Context, eventId primitive.ObjectID, userId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := ResponsesCollection.DeleteOne(ctx, bson.M{"eventId": eventId, "userId": userId})
+	return err
+}
+
+func (mongoResponseRepository) DeleteByEvent(ctx context.Context, eventId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := ResponsesCollection.DeleteMany(ctx, bson.M{"eventId": eventId})
+	return err
+}
+
+// Increments the counter in a single update so that concurrent requests on different servers can't both start a
+// new window. Expired counters are removed by the TTL index on expiresAt
+func (mongoRateLimitRepository) Increment(ctx context.Context, key string, n int, window time.Duration) (int, time.Time, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	active := bson.M{"$gt": bson.A{"$expiresAt", now}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"count":     bson.M{"$cond": bson.A{active, bson.M{"$add": bson.A{"$count", n}}, n}},
+		"expiresAt": bson.M{"$cond": bson.A{active, "$expiresAt", now.Add(window)}},
+	}}}}
+
+	var counter struct {
+		Count     int                `bson:"count"`
+		ExpiresAt primitive.DateTime `bson:"expiresAt"`
+	}
+	result := RateLimitsCollection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if err := result.Decode(&counter); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return counter.Count, counter.ExpiresAt.Time(), nil
+}
+
+func (mongoJobRepository) GetById(ctx context.Context, jobId primitive.ObjectID) (*models.Job, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var job models.Job
+	if found, err := decodeOne(JobsCollection.FindOne(ctx, bson.M{"_id": jobId}), &job); !found {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (mongoJobRepository) Insert(ctx context.Context, job *models.Job) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := JobsCollection.InsertOne(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	job.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoJobRepository) Lease(ctx context.Context, worker string, now time.Time, leasedUntil time.Time) (*models.Job, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	filter := bson.M{
+		"status": models.JobPending,
+		"runAt":  bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"leasedUntil": bson.M{"$exists": false}},
+			bson.M{"leasedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"leasedBy": worker, "leasedUntil": leasedUntil},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"runAt": 1}).SetReturnDocument(options.After)
+
+	var job models.Job
+	if found, err := decodeOne(JobsCollection.FindOneAndUpdate(ctx, filter, update, opts), &job); !found {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (mongoJobRepository) Complete(ctx context.Context, jobId primitive.ObjectID, worker string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := JobsCollection.UpdateOne(ctx, bson.M{"_id": jobId, "status": models.JobPending, "leasedBy": worker}, bson.M{
+		"$set":   bson.M{"status": models.JobDone, "finishedAt": time.Now()},
+		"$unset": bson.M{"leasedUntil": ""},
+	})
+	return err
+}
+
+func (mongoJobRepository) Fail(ctx context.Context, jobId primitive.ObjectID, worker string, message string, retryAt *time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	set := bson.M{"lastError": message}
+	if retryAt != nil {
+		set["runAt"] = *retryAt
+	} else {
+		set["status"] = models.JobFailed
+		set["finishedAt"] = time.Now()
+	}
+	_, err := JobsCollection.UpdateOne(ctx, bson.M{"_id": jobId, "status": models.JobPending, "leasedBy": worker}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"leasedUntil": ""},
+	})
+	return err
+}
+
+func (mongoJobRepository) Cancel(ctx context.Context, jobId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := JobsCollection.UpdateOne(ctx, bson.M{"_id": jobId, "status": models.JobPending}, bson.M{
+		"$set": bson.M{"status": models.JobCanceled, "finishedAt": time.Now()},
+	})
+	return err
+}
+
+func (mongoEmailPreferencesRepository) GetByEmail(ctx context.Context, email string) (*models.EmailPreferences, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var prefs models.EmailPreferences
+	if found, err := decodeOne(EmailPreferencesCollection.FindOne(ctx, bson.M{"email": email}), &prefs); !found {
+		return nil, err
+	}
+
+	return &prefs, nil
+}
+
+func (mongoEmailPreferencesRepository) Upsert(ctx context.Context, prefs *models.EmailPreferences) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := EmailPreferencesCollection.ReplaceOne(ctx, bson.M{"email": prefs.Email}, bson.M{
+		"email":       prefs.Email,
+		"preferences": prefs.Preferences,
+	}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (mongoDigestItemRepository) GetByUser(ctx context.Context, userId primitive.ObjectID) ([]models.DigestItem, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := DigestItemsCollection.Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	items := make([]models.DigestItem, 0)
+	if err := decodeAll(ctx, cursor, err, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (mongoDigestItemRepository) Insert(ctx context.Context, item *models.DigestItem) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := DigestItemsCollection.InsertOne(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	item.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoDigestItemRepository) Delete(ctx context.Context, itemIds []primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := DigestItemsCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": itemIds}})
+	return err
+}
+
+func (mongoWebhookRepository) GetById(ctx context.Context, webhookId primitive.ObjectID) (*models.Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var webhook models.Webhook
+	if found, err := decodeOne(WebhooksCollection.FindOne(ctx, bson.M{"_id": webhookId}), &webhook); !found {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (mongoWebhookRepository) GetByOwner(ctx context.Context, ownerId primitive.ObjectID) ([]models.Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := WebhooksCollection.Find(ctx, bson.M{"ownerId": ownerId}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	webhooks := make([]models.Webhook, 0)
+	if err := decodeAll(ctx, cursor, err, &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (mongoWebhookRepository) Insert(ctx context.Context, webhook *models.Webhook) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := WebhooksCollection.InsertOne(ctx, webhook)
+	if err != nil {
+		return err
+	}
+
+	webhook.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoWebhookRepository) Delete(ctx context.Context, webhookId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := WebhooksCollection.DeleteOne(ctx, bson.M{"_id": webhookId, "ownerId": ownerId})
+	if err != nil || result.DeletedCount == 0 {
+		return err
+	}
+
+	_, err = WebhookDeliveriesCollection.DeleteMany(ctx, bson.M{"webhookId": webhookId})
+	return err
+}
+
+func (mongoWebhookDeliveryRepository) GetById(ctx context.Context, deliveryId primitive.ObjectID) (*models.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var delivery models.WebhookDelivery
+	if found, err := decodeOne(WebhookDeliveriesCollection.FindOne(ctx, bson.M{"_id": deliveryId}), &delivery); !found {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (mongoWebhookDeliveryRepository) GetByWebhook(ctx context.Context, webhookId primitive.ObjectID, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := WebhookDeliveriesCollection.Find(ctx, bson.M{"webhookId": webhookId}, opts)
+	deliveries := make([]models.WebhookDelivery, 0)
+	if err := decodeAll(ctx, cursor, err, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (mongoWebhookDeliveryRepository) Insert(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := WebhookDeliveriesCollection.InsertOne(ctx, delivery)
+	if err != nil {
+		return err
+	}
+
+	delivery.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoWebhookDeliveryRepository) Update(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := WebhookDeliveriesCollection.ReplaceOne(ctx, bson.M{"_id": delivery.Id}, delivery)
+	return err
+}
+
+func (mongoChatAccountRepository) Get(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) (*models.ChatAccount, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var account models.ChatAccount
+	filter := bson.M{"platform": platform, "teamId": teamId, "externalId": externalId}
+	if found, err := decodeOne(ChatAccountsCollection.FindOne(ctx, filter), &account); !found {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (mongoChatAccountRepository) Upsert(ctx context.Context, account *models.ChatAccount) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	filter := bson.M{"platform": account.Platform, "teamId": account.TeamId, "externalId": account.ExternalId}
+	update := bson.M{"$set": bson.M{"userId": account.UserId, "linkedAt": account.LinkedAt}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var updated models.ChatAccount
+	if err := ChatAccountsCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+		return err
+	}
+
+	account.Id = updated.Id
+	return nil
+}
+
+func (mongoChatAccountRepository) Delete(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := ChatAccountsCollection.DeleteOne(ctx, bson.M{"platform": platform, "teamId": teamId, "externalId": externalId})
+	return err
+}
+
+func (mongoChatAccountRepository) GetByUsers(ctx context.Context, platform models.ChatPlatform, userIds []primitive.ObjectID) ([]models.ChatAccount, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := ChatAccountsCollection.Find(ctx, bson.M{"platform": platform, "userId": bson.M{"$in": userIds}})
+	accounts := make([]models.ChatAccount, 0)
+	if err := decodeAll(ctx, cursor, err, &accounts); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (mongoChatMessageRepository) GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.ChatMessage, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := ChatMessagesCollection.Find(ctx, bson.M{"eventId": eventId})
+	messages := make([]models.ChatMessage, 0)
+	if err := decodeAll(ctx, cursor, err, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (mongoChatMessageRepository) Insert(ctx context.Context, message *models.ChatMessage) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := ChatMessagesCollection.InsertOne(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	message.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoChatMessageRepository) Delete(ctx context.Context, messageId primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := ChatMessagesCollection.DeleteOne(ctx, bson.M{"_id": messageId})
+	return err
+}
+
+func (mongoDiscordGuildRepository) Get(ctx context.Context, guildId string) (*models.DiscordGuild, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var guild models.DiscordGuild
+	if found, err := decodeOne(DiscordGuildsCollection.FindOne(ctx, bson.M{"guildId": guildId}), &guild); !found {
+		return nil, err
+	}
+
+	return &guild, nil
+}
+
+func (mongoDiscordGuildRepository) Upsert(ctx context.Context, guild *models.DiscordGuild) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"eventsChannelId": guild.EventsChannelId,
+		"pingRoleId":      guild.PingRoleId,
+		"updatedAt":       guild.UpdatedAt,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var updated models.DiscordGuild
+	if err := DiscordGuildsCollection.FindOneAndUpdate(ctx, bson.M{"guildId": guild.GuildId}, update, opts).Decode(&updated); err != nil {
+		return err
+	}
+
+	guild.Id = updated.Id
+	return nil
+}
+
+func (mongoDiscordGuildRepository) Delete(ctx context.Context, guildId string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := DiscordGuildsCollection.DeleteOne(ctx, bson.M{"guildId": guildId})
+	return err
+}
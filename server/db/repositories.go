@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Repositories are the only way routes and services should read or write data, so that the storage backend
+// can be swapped out. Init sets them to the MongoDB implementation and InitMemory to the in-memory one.
+//
+// Reads return nil and no error if the document does not exist. Updates behave like a mongo "$set" of the given
+// object, i.e. fields that are omitted when marshalling (nil pointers, empty omitempty fields) are left unchanged.
+// Every method takes the context of the request that made it, so that work stops when the client goes away.
+var Events EventRepository
+var Users UserRepository
+var DailyUserLogs DailyUserLogRepository
+var FriendRequests FriendRequestRepository
+var Responses ResponseRepository
+var RateLimits RateLimitRepository
+var Jobs JobRepository
+var EmailPreferences EmailPreferencesRepository
+var DigestItems DigestItemRepository
+var Webhooks WebhookRepository
+var WebhookDeliveries WebhookDeliveryRepository
+var ChatAccounts ChatAccountRepository
+var ChatMessages ChatMessageRepository
+var DiscordGuilds DiscordGuildRepository
+
+type EventRepository interface {
+	GetById(ctx context.Context, eventId primitive.ObjectID) (*models.Event, error)
+	GetByShortId(ctx context.Context, shortId string) (*models.Event, error)
+
+	// Returns the events the user owns, has responded to, or is an attendee of, newest first
+	GetByUser(ctx context.Context, userId primitive.ObjectID, email string) ([]models.Event, error)
+
+	// Returns the events created on or after since, newest first. Creation times are read from the events' ids
+	GetCreatedSince(ctx context.Context, since time.Time) ([]models.Event, error)
+
+	// Inserts the event, generating an id if it doesn't have one
+	Insert(ctx context.Context, event *models.Event) error
+	Update(ctx context.Context, event *models.Event) error
+
+	// Deletes the event and its responses if it is owned by ownerId
+	Delete(ctx context.Context, eventId primitive.ObjectID, ownerId primitive.ObjectID) error
+}
+
+type UserRepository interface {
+	GetById(ctx context.Context, userId primitive.ObjectID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+
+	// Returns the users whose name or email contain every one of the given terms, case insensitive
+	Search(ctx context.Context, terms []string) ([]models.User, error)
+	Count(ctx context.Context) (int64, error)
+
+	// Returns the users that signed up on or after since, newest first. Sign up times are read from the users' ids
+	GetCreatedSince(ctx context.Context, since time.Time) ([]models.User, error)
+
+	// Inserts the user, generating an id if it doesn't have one
+	Insert(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, user *models.User) error
+
+	// Sets the given top level fields on the user
+	SetFields(ctx context.Context, userId primitive.ObjectID, fields bson.M) error
+	RemoveCalendarAccount(ctx context.Context, userId primitive.ObjectID, calendarAccountKey string) error
+
+	// Sets digestScheduledFor to at if it isn't set or is before staleBefore (i.e. that digest was never sent), and
+	// returns whether it was set. Only one caller can claim the user's next digest
+	ClaimDigest(ctx context.Context, userId primitive.ObjectID, at time.Time, staleBefore time.Time) (bool, error)
+	Delete(ctx context.Context, userId primitive.ObjectID) error
+}
+
+type DailyUserLogRepository interface {
+	// Returns the first log whose date is within [start, end]
+	GetByDateRange(ctx context.Context, start time.Time, end time.Time) (*models.DailyUserLog, error)
+
+	// Returns all logs on or after the given date, newest first. If populateUsers is true, the Users
+	// field of each log is populated with the id, name, and email of each user
+	GetSince(ctx context.Context, start time.Time, populateUsers bool) ([]models.DailyUserLog, error)
+
+	// Inserts the log, generating an id if it doesn't have one
+	Insert(ctx context.Context, log *models.DailyUserLog) error
+	Update(ctx context.Context, log *models.DailyUserLog) error
+}
+
+type FriendRequestRepository interface {
+	GetById(ctx context.Context, friendRequestId primitive.ObjectID) (*models.FriendRequest, error)
+
+	// Inserts the friend request, generating an id if it doesn't have one
+	Insert(ctx context.Context, friendRequest *models.FriendRequest) error
+	Delete(ctx context.Context, friendRequestId primitive.ObjectID) error
+}
+
+type ResponseRepository interface {
+	GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.EventResponse, error)
+
+	// Returns the ids of the users that responded to each of the given events, without fetching their availability
+	GetUserIdsByEvents(ctx context.Context, eventIds []primitive.ObjectID) (map[primitive.ObjectID][]string, error)
+
+	// Returns the ids of the events the user has responded to
+	GetEventIdsByUser(ctx context.Context, userId string) ([]primitive.ObjectID, error)
+
+	// Creates or replaces the user's response to the event
+	Upsert(ctx context.Context, eventId primitive.ObjectID, userId string, response *models.Response) error
+	Delete(ctx context.Context, eventId primitive.ObjectID, userId string) error
+	DeleteByEvent(ctx context.Context, eventId primitive.ObjectID) error
+}
+
+// Background jobs run by the local scheduler. Jobs that are leased by a worker aren't leased by any other worker
+// until the lease expires, so that several servers can run jobs from the same collection
+type JobRepository interface {
+	GetById(ctx context.Context, jobId primitive.ObjectID) (*models.Job, error)
+
+	// Inserts the job, generating an id if it doesn't have one
+	Insert(ctx context.Context, job *models.Job) error
+
+	// Leases the pending job that is due soonest and isn't leased to worker until leasedUntil, and counts the
+	// attempt. Returns nil if no job is due
+	Lease(ctx context.Context, worker string, now time.Time, leasedUntil time.Time) (*models.Job, error)
+
+	// Marks the job as done if it's still leased to worker
+	Complete(ctx context.Context, jobId primitive.ObjectID, worker string) error
+
+	// Releases the job leased to worker after a failed attempt so that it's retried at retryAt, or marks it as
+	// failed if retryAt is nil
+	Fail(ctx context.Context, jobId primitive.ObjectID, worker string, message string, retryAt *time.Time) error
+
+	// Marks the job as canceled if it's pending. A job that is already running still finishes
+	Cancel(ctx context.Context, jobId primitive.ObjectID) error
+}
+
+// Notification preferences of email addresses that aren't users'. Users' preferences are stored on the user
+type EmailPreferencesRepository interface {
+	GetByEmail(ctx context.Context, email string) (*models.EmailPreferences, error)
+
+	// Creates or replaces the preferences of prefs.Email
+	Upsert(ctx context.Context, prefs *models.EmailPreferences) error
+}
+
+type DigestItemRepository interface {
+	// Returns the user's digest items, oldest first
+	GetByUser(ctx context.Context, userId primitive.ObjectID) ([]models.DigestItem, error)
+
+	// Inserts the item, generating an id if it doesn't have one
+	Insert(ctx context.Context, item *models.DigestItem) error
+	Delete(ctx context.Context, itemIds []primitive.ObjectID) error
+}
+
+type WebhookRepository interface {
+	GetById(ctx context.Context, webhookId primitive.ObjectID) (*models.Webhook, error)
+
+	// Returns the owner's webhooks, oldest first
+	GetByOwner(ctx context.Context, ownerId primitive.ObjectID) ([]models.Webhook, error)
+
+	// Inserts the webhook, generating an id if it doesn't have one
+	Insert(ctx context.Context, webhook *models.Webhook) error
+
+	// Deletes the webhook and its deliveries if it is owned by ownerId
+	Delete(ctx context.Context, webhookId primitive.ObjectID, ownerId primitive.ObjectID) error
+}
+
+type WebhookDeliveryRepository interface {
+	GetById(ctx context.Context, deliveryId primitive.ObjectID) (*models.WebhookDelivery, error)
+
+	// Returns the webhook's most recent deliveries, newest first
+	GetByWebhook(ctx context.Context, webhookId primitive.ObjectID, limit int) ([]models.WebhookDelivery, error)
+
+	// Inserts the delivery, generating an id if it doesn't have one
+	Insert(ctx context.Context, delivery *models.WebhookDelivery) error
+	Update(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+// Links between chat users and schej accounts
+type ChatAccountRepository interface {
+	// Returns the link of the chat user in the team, or nil if they haven't linked an account
+	Get(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) (*models.ChatAccount, error)
+
+	// Creates the link of account's chat user, or replaces the link they have
+	Upsert(ctx context.Context, account *models.ChatAccount) error
+	Delete(ctx context.Context, platform models.ChatPlatform, teamId string, externalId string) error
+
+	// Returns the links of the schej users on the platform
+	GetByUsers(ctx context.Context, platform models.ChatPlatform, userIds []primitive.ObjectID) ([]models.ChatAccount, error)
+}
+
+// Messages that chat bots posted about events
+type ChatMessageRepository interface {
+	GetByEvent(ctx context.Context, eventId primitive.ObjectID) ([]models.ChatMessage, error)
+
+	// Inserts the message, generating an id if it doesn't have one
+	Insert(ctx context.Context, message *models.ChatMessage) error
+	Delete(ctx context.Context, messageId primitive.ObjectID) error
+}
+
+// Settings of the Discord servers that the bot was added to
+type DiscordGuildRepository interface {
+	// Returns the server's settings, or nil if they haven't been set
+	Get(ctx context.Context, guildId string) (*models.DiscordGuild, error)
+
+	// Creates the server's settings, or replaces the ones it has
+	Upsert(ctx context.Context, guild *models.DiscordGuild) error
+	Delete(ctx context.Context, guildId string) error
+}
+
+// Fixed window counters, used to rate limit requests and emails
+type RateLimitRepository interface {
+	// Adds n to the counter with the given key and returns its new value and when it resets. The counter resets to 0
+	// once window has passed since the first increment after the last reset
+	Increment(ctx context.Context, key string, n int, window time.Duration) (count int, resetAt time.Time, err error)
+}
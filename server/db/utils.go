@@ -2,147 +2,93 @@ package db
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math/big"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 	"schej.it/server/utils"
 )
 
 // Returns a user based on their _id
-func GetUserById(userId string) *models.User {
+func GetUserById(ctx context.Context, userId string) (*models.User, error) {
 	objectId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		// userId is malformatted
-		return nil
-	}
-	result := UsersCollection.FindOne(context.Background(), bson.M{
-		"_id": objectId,
-	})
-	if result.Err() == mongo.ErrNoDocuments {
-		// User does not exist!
-		return nil
-	}
-
-	// Decode result
-	var user models.User
-	if err := result.Decode(&user); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, nil
 	}
 
-	return &user
+	return Users.GetById(ctx, objectId)
 }
 
-func GetUserByEmail(email string) *models.User {
-	result := UsersCollection.FindOne(context.Background(), bson.M{
-		"email": email,
-	})
-	if result.Err() == mongo.ErrNoDocuments {
-		// User does not exist!
-		return nil
-	}
-
-	// Decode result
-	var user models.User
-	if err := result.Decode(&user); err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
-	return &user
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return Users.GetByEmail(ctx, email)
 }
 
 // Returns an event based on its _id
-func GetEventById(eventId string) *models.Event {
+func GetEventById(ctx context.Context, eventId string) (*models.Event, error) {
 	objectId, err := primitive.ObjectIDFromHex(eventId)
 	if err != nil {
 		// eventId is malformatted
-		return nil
-	}
-	result := EventsCollection.FindOne(context.Background(), bson.M{
-		"_id": objectId,
-	})
-	if result.Err() == mongo.ErrNoDocuments {
-		// Event does not exist!
-		return nil
-	}
-
-	// Decode result
-	var event models.Event
-	if err := result.Decode(&event); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, nil
 	}
 
-	return &event
+	return Events.GetById(ctx, objectId)
 }
 
 // Returns an event based on its shortId
-func GetEventByShortId(shortEventId string) *models.Event {
-	result := EventsCollection.FindOne(context.Background(), bson.M{
-		"shortId": shortEventId,
-	})
-	if result.Err() == mongo.ErrNoDocuments {
-		// Event does not exist!
-		return nil
-	}
-
-	// Decode result
-	var event models.Event
-	if err := result.Decode(&event); err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
-	return &event
+func GetEventByShortId(ctx context.Context, shortEventId string) (*models.Event, error) {
+	return Events.GetByShortId(ctx, shortEventId)
 }
 
 // Returns an event by either its _id or shortId
-func GetEventByEitherId(id string) *models.Event {
+func GetEventByEitherId(ctx context.Context, id string) (*models.Event, error) {
 	if len(id) <= 10 {
-		return GetEventByShortId(id)
+		return GetEventByShortId(ctx, id)
 	}
 
-	return GetEventById(id)
+	return GetEventById(ctx, id)
 }
 
-func GetFriendRequestById(friendRequestId string) *models.FriendRequest {
-	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
+// Sets the event's ResponsesList to its responses from the responses collection, with their availability decoded
+func PopulateEventResponses(ctx context.Context, event *models.Event) error {
+	responsesList, err := Responses.GetByEvent(ctx, event.Id)
 	if err != nil {
-		// friendRequestId is malformatted
-		return nil
-	}
-	result := FriendRequestsCollection.FindOne(context.Background(), bson.M{
-		"_id": objectId,
-	})
-	if result.Err() == mongo.ErrNoDocuments {
-		// Friend request does not exist!
-		return nil
+		return err
 	}
 
-	// Decode result
-	var friendRequest models.FriendRequest
-	if err := result.Decode(&friendRequest); err != nil {
-		logger.StdErr.Panicln(err)
+	event.ResponsesList = responsesList
+	for _, eventResponse := range event.ResponsesList {
+		if eventResponse.Response != nil {
+			eventResponse.Response.DecodeAvailability()
+		}
 	}
 
-	return &friendRequest
+	return nil
 }
 
-func DeleteFriendRequestById(friendRequestId string) {
+func GetFriendRequestById(ctx context.Context, friendRequestId string) (*models.FriendRequest, error) {
 	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
 	if err != nil {
 		// friendRequestId is malformatted
-		logger.StdErr.Panicln(err)
+		return nil, nil
 	}
-	_, err = FriendRequestsCollection.DeleteOne(context.Background(), bson.M{
-		"_id": objectId,
-	})
+
+	return FriendRequests.GetById(ctx, objectId)
+}
+
+func DeleteFriendRequestById(ctx context.Context, friendRequestId string) error {
+	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		// friendRequestId is malformatted
+		return err
 	}
+
+	return FriendRequests.Delete(ctx, objectId)
 }
 
 /*
@@ -154,84 +100,107 @@ Note: we find the log localized to the user's month/day/year in order to track i
 own timezone, rather than the server's timezone. For example, if a user signed in at 11pm on Monday, then signed in at 8am on Tuesday,
 it could theoretically count as the same day if we were to use server time
 */
-func GetDailyUserLogByDate(date time.Time, timezoneOffset int) *models.DailyUserLog {
+func GetDailyUserLogByDate(ctx context.Context, date time.Time, timezoneOffset int) (*models.DailyUserLog, error) {
 	timezoneOffsetDuration, _ := time.ParseDuration(fmt.Sprintf("%dm", timezoneOffset))
 	adjustedDate := date.Add(timezoneOffsetDuration)
 	startDate := utils.GetDateAtTime(adjustedDate, "00:00:00")
 	endDate := utils.GetDateAtTime(adjustedDate, "23:59:59")
 
 	// Find a log for the current date
-	result := DailyUserLogCollection.FindOne(context.Background(), bson.M{
-		"date": bson.M{
-			"$gte": primitive.NewDateTimeFromTime(startDate),
-			"$lte": primitive.NewDateTimeFromTime(endDate),
-		},
-	})
-
-	var log models.DailyUserLog
+	log, err := DailyUserLogs.GetByDateRange(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create a new log if it doesn't exist already
-	if result.Err() == mongo.ErrNoDocuments {
-		log = models.DailyUserLog{
+	if log == nil {
+		log = &models.DailyUserLog{
 			Date: primitive.NewDateTimeFromTime(startDate),
 		}
-		result, err := DailyUserLogCollection.InsertOne(context.Background(), log)
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
-		log.Id = result.InsertedID.(primitive.ObjectID)
-	} else {
-		// Parse daily user log object
-		if err := result.Decode(&log); err != nil {
-			logger.StdErr.Panicln(err)
+		if err := DailyUserLogs.Insert(ctx, log); err != nil {
+			return nil, err
 		}
 	}
 
-	return &log
+	return log, nil
 }
 
-func UpdateDailyUserLog(user *models.User) {
-	log := GetDailyUserLogByDate(time.Now(), user.TimezoneOffset)
+func UpdateDailyUserLog(ctx context.Context, user *models.User) error {
+	log, err := GetDailyUserLogByDate(ctx, time.Now(), user.TimezoneOffset)
+	if err != nil {
+		return err
+	}
 	for _, id := range log.UserIds {
 		if id == user.Id {
-			return
+			return nil
 		}
 	}
 
 	log.UserIds = append(log.UserIds, user.Id)
-	_, err := DailyUserLogCollection.UpdateByID(context.Background(), log.Id, bson.M{"$set": log})
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
+	return DailyUserLogs.Update(ctx, log)
 }
 
-// Returns a random unique short event id seeded by the actual event id
-func GenerateShortEventId(eventId primitive.ObjectID) string {
-	r := rand.New(rand.NewSource(eventId.Timestamp().Unix()))
-
+// Returns a random short event id that isn't taken yet. Another event can still take it before it's inserted, so
+// new events should be inserted with InsertEventWithShortId
+func GenerateShortEventId(ctx context.Context) (string, error) {
 	id := ""
 
 	letters := "23456789ABCDEFabcdef"
 	for i := 0; i < 5; i++ {
-		index := r.Intn(len(letters))
+		index, err := randomIndex(len(letters))
+		if err != nil {
+			return "", err
+		}
 		letter := letters[index : index+1]
 		id += letter
 	}
 
 	i := 0
-	event := GetEventByShortId(id)
-	for event != nil && i < 5 {
+	event, err := GetEventByShortId(ctx, id)
+	for err == nil && event != nil && i < 5 {
 		// Event exists, keep on adding letters until event doesn't exist anymore, max of 5 more letters
-		index := r.Intn(len(letters))
+		var index int
+		index, err = randomIndex(len(letters))
+		if err != nil {
+			return "", err
+		}
 		letter := letters[index : index+1]
 		id += letter
-		event = GetEventByShortId(id)
+		event, err = GetEventByShortId(ctx, id)
 		i++
 	}
 
+	if err != nil {
+		return "", err
+	}
 	if event != nil {
-		logger.StdErr.Panicln("Couldn't generate unique id")
+		return "", errors.New("couldn't generate unique id")
+	}
+
+	return id, nil
+}
+
+// Returns a random number in [0, n), from crypto/rand so that events created at the same time get different ids
+func randomIndex(n int) (int, error) {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
 	}
+	return int(index.Int64()), nil
+}
+
+// Inserts the new event with a unique short id, generating another one if an event created at the same time took it
+func InsertEventWithShortId(ctx context.Context, event *models.Event) error {
+	for attempt := 1; ; attempt++ {
+		shortId, err := GenerateShortEventId(ctx)
+		if err != nil {
+			return err
+		}
+		event.ShortId = &shortId
 
-	return id
+		err = Events.Insert(ctx, event)
+		if !mongo.IsDuplicateKeyError(err) || attempt == 3 {
+			return err
+		}
+	}
 }
@@ -0,0 +1,125 @@
+package discord_bot
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
+)
+
+// Records the messages that would be sent to Discord
+type fakeMessenger struct {
+	mutex sync.Mutex
+	sent  []*discordgo.MessageSend
+	edits []*discordgo.MessageEdit
+}
+
+func (f *fakeMessenger) ChannelMessageSendComplex(channelId string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sent = append(f.sent, data)
+	return &discordgo.Message{ID: primitive.NewObjectID().Hex(), ChannelID: channelId}, nil
+}
+
+func (f *fakeMessenger) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.edits = append(f.edits, m)
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func TestSchej(t *testing.T) {
+	logger.Init(io.Discard)
+	db.InitMemory("")
+	t.Setenv("EMAIL_BACKEND", "none")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	fake := &fakeMessenger{}
+	messages = fake
+	defer func() { messages = nil }()
+
+	owner := models.User{FirstName: "Jane", Email: "jane@example.com", TimezoneOffset: 240}
+	friend := models.User{FirstName: "Sam", Email: "sam@example.com"}
+	db.Users.Insert(ctx, &owner)
+	db.Users.Insert(ctx, &friend)
+
+	// Runs the command as the Discord user with the given options
+	run := func(name string, userId string, options map[string]interface{}) *discordgo.InteractionResponseData {
+		r := schejRequest{GuildId: "G1", ChannelId: "C1", UserId: userId, Options: make(map[string]*discordgo.ApplicationCommandInteractionDataOption)}
+		for key, value := range options {
+			r.Options[key] = &discordgo.ApplicationCommandInteractionDataOption{Name: key, Value: value}
+		}
+		return runSchejCommand(ctx, name, r)
+	}
+
+	// Commands that act as a schej user need a linked account
+	reply := run("new", "U1", map[string]interface{}{"name": "Game night", "days": "next week", "times": "7pm-11pm"})
+	if len(reply.Components) != 1 {
+		t.Fatalf("expected a link prompt, got %+v", reply)
+	}
+	linkUrl, _ := url.Parse(reply.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.Button).URL)
+	l, err := chat.ParseLinkToken(models.DiscordPlatform, linkUrl.Query().Get("token"))
+	if err != nil || !strings.HasSuffix(linkUrl.Path, "/api/discord/link") {
+		t.Fatalf("expected a Discord link, got %s: %v", linkUrl, err)
+	}
+	if _, err := chat.ParseLinkToken(models.SlackPlatform, linkUrl.Query().Get("token")); err == nil {
+		t.Errorf("expected a Discord link to be rejected by Slack")
+	}
+	l.Apply(ctx, &owner)
+	chat.NewLinkRequest(models.DiscordPlatform, "", "U2").Apply(ctx, &friend)
+
+	reply = run("schej-settings", "U1", map[string]interface{}{"events_channel": "C2", "ping_role": "R1"})
+	if !strings.Contains(reply.Content, "<#C2>") || !strings.Contains(reply.Content, "<@&R1>") {
+		t.Errorf("unexpected settings reply %q", reply.Content)
+	}
+
+	reply = run("new", "U1", map[string]interface{}{"name": "Game night", "days": "next week", "times": "7pm-11pm"})
+	events, _ := db.Events.GetByUser(ctx, owner.Id, owner.Email)
+	if len(events) != 1 || len(events[0].Dates) != 5 || *events[0].Duration != 4 || !strings.Contains(reply.Content, "posted it in <#C2>") {
+		t.Fatalf("expected a 5 day event posted in the events channel, got %+v and reply %q", events, reply.Content)
+	}
+	event := events[0]
+	posts, _ := db.ChatMessages.GetByEvent(ctx, event.Id)
+	if len(fake.sent) != 1 || len(posts) != 1 || posts[0].ChannelId != "C2" || len(fake.sent[0].AllowedMentions.Users) != 0 {
+		t.Fatalf("expected the event to be posted without pinging anyone, got %+v", posts)
+	}
+
+	// The post is updated with the best time as people respond
+	db.Responses.Upsert(ctx, event.Id, friend.Id.Hex(), &models.Response{UserId: friend.Id, Availability: event.Dates[1:2]})
+	if _, _, err := updatePosts(ctx, event.Id); err != nil {
+		t.Fatal(err)
+	}
+	embed := fake.edits[0].Embeds[0]
+	if len(fake.edits) != 1 || embed.Fields[0].Value != "1" || !strings.Contains(embed.Fields[1].Value, "1 of 1 available") {
+		t.Fatalf("expected the post to show the response, got %+v", embed.Fields)
+	}
+
+	// Scheduling pings the role and the respondents that linked their account
+	event.ScheduledEvent = &models.CalendarEvent{StartDate: event.Dates[1], EndDate: primitive.NewDateTimeFromTime(event.Dates[1].Time().Add(time.Hour))}
+	db.Events.Update(ctx, &event)
+	if err := pingScheduled(ctx, event.Id); err != nil {
+		t.Fatal(err)
+	}
+	ping := fake.sent[len(fake.sent)-1]
+	if len(fake.sent) != 2 || !strings.HasPrefix(ping.Content, "<@&R1> <@U2> **Game night** is scheduled") || ping.Reference.MessageID != posts[0].MessageId {
+		t.Errorf("unexpected ping %+v", ping)
+	}
+
+	reply = run("remind", "U2", map[string]interface{}{"event": *event.ShortId})
+	if reply.Content != "Only the event's owner can send reminders." {
+		t.Errorf("unexpected remind reply %q", reply.Content)
+	}
+}
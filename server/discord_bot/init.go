@@ -1,25 +1,47 @@
 package discord_bot
 
 import (
+	"context"
 	"os"
-	"regexp"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
-	"schej.it/server/discord_bot/commands"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/chatcommands"
+	"schej.it/server/db"
 	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
 	"schej.it/server/utils"
 )
 
 var BotId string
 var bot *discordgo.Session
 var listeningChannel *discordgo.Channel
-var commandMap map[string]commands.Command = make(map[string]commands.Command)
 
-// Initialize the discord bot
-func Init() {
-	var err error
+// The session's calls that post and update event messages, so that tests don't need Discord
+type messenger interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// Posts event messages, nil if the bot isn't running
+var messages messenger
+
+// Initialize the discord bot and its routes, and return a function that closes the bot. The bot only runs if
+// DISCORD_BOT_TOKEN is set. Admin commands are only listened to in the schej-it-bot channel of GUILD_ID, while
+// /schej works in every server the bot is added to
+func Init(router *gin.RouterGroup) func() {
+	discordRouter := router.Group("/discord")
+	discordRouter.GET("/link", chat.GetLinkHandler(models.DiscordPlatform))
+	discordRouter.POST("/link", chat.LinkHandler(models.DiscordPlatform))
+
 	token := os.Getenv("DISCORD_BOT_TOKEN")
-	guildId := os.Getenv("GUILD_ID")
+	if len(token) == 0 {
+		return func() {}
+	}
+
+	var err error
 	bot, err = discordgo.New("Bot " + token)
 	if err != nil {
 		logger.StdErr.Panicln(err)
@@ -35,41 +57,53 @@ func Init() {
 	if err != nil {
 		logger.StdErr.Panicln(err)
 	}
+	messages = bot
 	logger.StdOut.Println("Discord bot initialized")
 
 	// Get the channel object to listen on
-	var listeningChannelName string
-	if utils.IsRelease() {
-		listeningChannelName = "schej-it-bot"
-	} else {
-		listeningChannelName = "schej-it-bot-dev"
-	}
-	channels, _ := bot.GuildChannels(guildId)
-	for _, channel := range channels {
-		if channel.Name == listeningChannelName {
-			listeningChannel = channel
-			break
+	if guildId := os.Getenv("GUILD_ID"); len(guildId) > 0 {
+		var listeningChannelName string
+		if utils.IsRelease() {
+			listeningChannelName = "schej-it-bot"
+		} else {
+			listeningChannelName = "schej-it-bot-dev"
+		}
+		channels, _ := bot.GuildChannels(guildId)
+		for _, channel := range channels {
+			if channel.Name == listeningChannelName {
+				listeningChannel = channel
+				break
+			}
 		}
 	}
 
-	// Construct commandMap
-	commands.Init()
-	for _, command := range commands.Commands {
-		commandMap[command.Name] = command
+	// Register the slash commands in every server
+	if _, err := bot.ApplicationCommandBulkOverwrite(BotId, "", applicationCommands()); err != nil {
+		logger.StdErr.Println("Failed to register Discord commands:", err)
 	}
 
 	// Bot handlers
 	bot.AddHandler(messageHandler)
+	bot.AddHandler(interactionHandler)
+	bot.AddHandler(guildDeleteHandler)
+
+	return func() {
+		messages = nil
+		bot.Close()
+	}
 }
 
 // Send a message to the listening channel
 func SendMessage(message string) {
+	if bot == nil || listeningChannel == nil {
+		return
+	}
 	_, _ = bot.ChannelMessageSend(listeningChannel.ID, message)
 }
 
 // messageHandler is run every time a message is sent in a server we are listening to
 func messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.ChannelID != listeningChannel.ID {
+	if listeningChannel == nil || m.ChannelID != listeningChannel.ID {
 		return
 	}
 	if m.Author.ID == BotId {
@@ -77,13 +111,32 @@ func messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 
 	// Parse the command + arguments
-	re := regexp.MustCompile(" +")
-	args := re.Split(m.Content, -1)
-	commandName := args[0]
-	args = args[1:]
+	args := chatcommands.SplitArgs(m.Content)
+	if len(args) == 0 || !strings.HasPrefix(args[0], "!") {
+		return
+	}
 
 	// Execute command if it exists
-	if command, ok := commandMap[commandName]; ok {
-		command.Execute(s, m, args)
+	var message *chatcommands.Message
+	if args[0] == "!help" {
+		message = chatcommands.Help("!")
+	} else if command := chatcommands.Find(strings.TrimPrefix(args[0], "!")); command != nil {
+		message = chatcommands.Run(context.Background(), command, "!", args[1:])
+	} else {
+		return
+	}
+	for _, discordMessage := range message.Discord() {
+		_, _ = s.ChannelMessageSendComplex(m.ChannelID, discordMessage)
+	}
+}
+
+// guildDeleteHandler deletes a server's settings when the bot is removed from it. Servers that are only
+// unavailable because of an outage keep their settings
+func guildDeleteHandler(s *discordgo.Session, g *discordgo.GuildDelete) {
+	if g.Unavailable {
+		return
+	}
+	if err := db.DiscordGuilds.Delete(context.Background(), g.ID); err != nil {
+		logger.StdErr.Println("Failed to delete Discord server settings:", err)
 	}
 }
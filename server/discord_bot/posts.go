@@ -0,0 +1,238 @@
+package discord_bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
+	"schej.it/server/utils"
+)
+
+// Events posted in Discord are kept up to date by editing their message whenever someone responds, and the
+// people that responded are pinged in the message's channel when the event is scheduled
+
+// schej green
+const embedColor = 0x00994c
+
+// Posts the event in the channel and remembers the message, so that it's updated as people respond
+func postEvent(ctx context.Context, guildId string, channelId string, event *models.Event, content string) error {
+	message, err := messages.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+		Content:    content,
+		Embeds:     []*discordgo.MessageEmbed{eventEmbed(event, 1)},
+		Components: eventComponents(event),
+		// The content mentions the creator without pinging them
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.ChatMessages.Insert(ctx, &models.ChatMessage{
+		Platform:  models.DiscordPlatform,
+		EventId:   event.Id,
+		TeamId:    guildId,
+		ChannelId: message.ChannelID,
+		MessageId: message.ID,
+		CreatedAt: time.Now(),
+	})
+}
+
+// Updates the messages of the event in the background, after its responses changed
+func EventUpdated(ctx context.Context, eventId primitive.ObjectID) {
+	if messages == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	utils.RunInBackground(func() {
+		if _, _, err := updatePosts(ctx, eventId); err != nil {
+			logger.FromContext(ctx).Error("Failed to update Discord events", "error", err)
+		}
+	})
+}
+
+// Updates the messages of the event in the background, and pings the people that responded to it in the
+// messages' channels, after it was scheduled
+func EventScheduled(ctx context.Context, eventId primitive.ObjectID) {
+	if messages == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	utils.RunInBackground(func() {
+		if err := pingScheduled(ctx, eventId); err != nil {
+			logger.FromContext(ctx).Error("Failed to ping Discord event", "error", err)
+		}
+	})
+}
+
+// Edits the event's messages to show its current responses, and returns the event and the messages that still
+// exist. Messages that were deleted in Discord are forgotten
+func updatePosts(ctx context.Context, eventId primitive.ObjectID) (*models.Event, []models.ChatMessage, error) {
+	posts, err := db.ChatMessages.GetByEvent(ctx, eventId)
+	if err != nil || len(posts) == 0 {
+		return nil, nil, err
+	}
+	event, err := chat.GetEvent(ctx, eventId.Hex())
+	if err != nil || event == nil {
+		return nil, nil, err
+	}
+
+	embed := eventEmbed(event, 1)
+	components := eventComponents(event)
+	existing := make([]models.ChatMessage, 0)
+	for _, post := range posts {
+		edit := discordgo.NewMessageEdit(post.ChannelId, post.MessageId).SetEmbed(embed)
+		edit.Components = components
+		if _, err := messages.ChannelMessageEditComplex(edit); isDeleted(err) {
+			if err := db.ChatMessages.Delete(ctx, post.Id); err != nil {
+				return nil, nil, err
+			}
+			continue
+		} else if err != nil {
+			logger.FromContext(ctx).Error("Failed to edit Discord event", "channelId", post.ChannelId, "error", err)
+		}
+		existing = append(existing, post)
+	}
+
+	return event, existing, nil
+}
+
+func pingScheduled(ctx context.Context, eventId primitive.ObjectID) error {
+	event, posts, err := updatePosts(ctx, eventId)
+	if err != nil || event == nil || event.ScheduledEvent == nil {
+		return err
+	}
+
+	// Ping the respondents that linked their Discord account
+	userIds := make([]primitive.ObjectID, 0)
+	for _, eventResponse := range event.ResponsesList {
+		if userId, err := primitive.ObjectIDFromHex(eventResponse.UserId); err == nil {
+			userIds = append(userIds, userId)
+		}
+	}
+	accounts, err := db.ChatAccounts.GetByUsers(ctx, models.DiscordPlatform, userIds)
+	if err != nil {
+		return err
+	}
+	users := make([]string, 0)
+	for _, account := range accounts {
+		users = append(users, account.ExternalId)
+	}
+
+	for _, post := range posts {
+		guild, err := db.DiscordGuilds.Get(ctx, post.TeamId)
+		if err != nil {
+			return err
+		}
+
+		mentions := &discordgo.MessageAllowedMentions{Users: users}
+		pings := make([]string, 0)
+		if guild != nil && len(guild.PingRoleId) > 0 {
+			mentions.Roles = []string{guild.PingRoleId}
+			pings = append(pings, fmt.Sprintf("<@&%s>", guild.PingRoleId))
+		}
+		for _, user := range users {
+			pings = append(pings, fmt.Sprintf("<@%s>", user))
+		}
+
+		content := fmt.Sprintf("**%s** is scheduled for %s!", event.Name, formatScheduled(event.ScheduledEvent))
+		if len(pings) > 0 {
+			content = strings.Join(pings, " ") + " " + content
+		}
+		if _, err := messages.ChannelMessageSendComplex(post.ChannelId, &discordgo.MessageSend{
+			Content:         content,
+			AllowedMentions: mentions,
+			Reference:       &discordgo.MessageReference{MessageID: post.MessageId, ChannelID: post.ChannelId, GuildID: post.TeamId},
+		}); err != nil {
+			logger.FromContext(ctx).Error("Failed to ping Discord event", "channelId", post.ChannelId, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the embed that shows the event's responses and its best numBest times, or when it's scheduled for
+func eventEmbed(event *models.Event, numBest int) *discordgo.MessageEmbed {
+	numResponses := chat.NumResponses(event)
+	embed := &discordgo.MessageEmbed{
+		Title:       event.Name,
+		URL:         chat.EventUrl(event),
+		Color:       embedColor,
+		Description: "Add your availability so we can find a time!",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Responses", Value: fmt.Sprint(numResponses), Inline: true},
+		},
+	}
+
+	if event.ScheduledEvent != nil {
+		embed.Description = "Scheduled for " + formatScheduled(event.ScheduledEvent)
+		return embed
+	}
+
+	if event.Type == models.GROUP || utils.Coalesce(event.IsSignUpForm) {
+		return embed
+	}
+	best := event.BestTimes(numBest)
+	times := make([]string, 0)
+	for _, slot := range best {
+		times = append(times, fmt.Sprintf("%s (%d of %d available)", formatTime(event, slot.Time.Time()), slot.Count, len(event.ResponsesList)))
+	}
+	if len(times) == 0 {
+		times = append(times, "Nobody is available yet")
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:   chat.Plural(numBest, "Best time", "Best times"),
+		Value:  strings.Join(times, "\n"),
+		Inline: true,
+	})
+
+	return embed
+}
+
+func eventComponents(event *models.Event) []discordgo.MessageComponent {
+	label := "Add availability"
+	if event.ScheduledEvent != nil {
+		label = "Open event"
+	}
+	return []discordgo.MessageComponent{linkButton(label, chat.EventUrl(event))}
+}
+
+// Formats the time with a Discord timestamp, which is shown in each viewer's timezone. Days of the week events
+// aren't on real dates, so they're shown in UTC instead
+func formatTime(event *models.Event, t time.Time) string {
+	daysOnly := utils.Coalesce(event.DaysOnly)
+	switch {
+	case event.Type == models.DOW:
+		return event.FormatTime(t.UTC()) + " UTC"
+	case daysOnly:
+		return fmt.Sprintf("<t:%d:D>", t.Unix())
+	default:
+		return fmt.Sprintf("<t:%d:F>", t.Unix())
+	}
+}
+
+func formatScheduled(scheduled *models.CalendarEvent) string {
+	return fmt.Sprintf("<t:%d:F> - <t:%d:t>", scheduled.StartDate.Time().Unix(), scheduled.EndDate.Time().Unix())
+}
+
+// Returns whether the error is from editing a message or channel that was deleted
+func isDeleted(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil && (restErr.Message.Code == discordgo.ErrCodeUnknownMessage || restErr.Message.Code == discordgo.ErrCodeUnknownChannel) {
+		return true
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,444 @@
+package discord_bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
+	"schej.it/server/services/reminders"
+	"schej.it/server/slackbot"
+	"schej.it/server/validation"
+)
+
+// The /schej command lets Discord servers create and follow up on events without leaving Discord, e.g.
+// `/schej new name:Game night days:fri-sun times:7pm-11pm`. Events are posted in the channel as a message that's
+// kept up to date as people respond. Subcommands that act as a schej user need the Discord user to link their
+// schej account, and /schej-settings sets where the server's events are posted and who is pinged when they're
+// scheduled
+
+// A subcommand of /schej, or a top level command
+type schejCommand struct {
+	Name        string
+	Description string
+	Options     []*discordgo.ApplicationCommandOption
+
+	// Whether the Discord user has to link a schej account before running the command
+	NeedsAccount bool
+
+	Execute func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData
+}
+
+type schejRequest struct {
+	// The server, channel and user that ran the command
+	GuildId   string
+	ChannelId string
+	UserId    string
+
+	Options map[string]*discordgo.ApplicationCommandInteractionDataOption
+
+	// The linked schej account, nil if the command doesn't need one
+	User *models.User
+}
+
+// Returns the value of a string, channel or role option, or "" if it wasn't given
+func (r schejRequest) String(name string) string {
+	if option, ok := r.Options[name]; ok {
+		value, _ := option.Value.(string)
+		return value
+	}
+	return ""
+}
+
+func (r schejRequest) Bool(name string) bool {
+	if option, ok := r.Options[name]; ok {
+		return option.BoolValue()
+	}
+	return false
+}
+
+var schejCommands = []schejCommand{newCommand, statusCommand, postCommand, remindCommand, linkCommand, unlinkCommand}
+
+// Returns the commands to register with Discord
+func applicationCommands() []*discordgo.ApplicationCommand {
+	dmPermission := false
+	manageServer := int64(discordgo.PermissionManageServer)
+
+	subcommands := make([]*discordgo.ApplicationCommandOption, 0)
+	for _, command := range schejCommands {
+		subcommands = append(subcommands, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        command.Name,
+			Description: command.Description,
+			Options:     command.Options,
+		})
+	}
+
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:         "schej",
+			Description:  "Find a time that works for everyone",
+			DMPermission: &dmPermission,
+			Options:      subcommands,
+		},
+		{
+			Name:                     settingsCommand.Name,
+			Description:              settingsCommand.Description,
+			DMPermission:             &dmPermission,
+			DefaultMemberPermissions: &manageServer,
+			Options:                  settingsCommand.Options,
+		},
+	}
+}
+
+// interactionHandler runs the slash command of the interaction, and replies to the user that ran it
+func interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	// Replies are only shown to the user that ran the command. Discord only waits 3 seconds for a reply, so it's
+	// deferred until the command is done
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		logger.StdErr.Println("Failed to respond to Discord command:", err)
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	name, options := data.Name, data.Options
+	if name == "schej" && len(options) > 0 {
+		name, options = options[0].Name, options[0].Options
+	}
+
+	r := schejRequest{GuildId: i.GuildID, ChannelId: i.ChannelID, Options: make(map[string]*discordgo.ApplicationCommandInteractionDataOption)}
+	if i.Member != nil {
+		r.UserId = i.Member.User.ID
+	} else if i.User != nil {
+		r.UserId = i.User.ID
+	}
+	for _, option := range options {
+		r.Options[option.Name] = option
+	}
+
+	reply := runSchejCommand(context.Background(), name, r)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    &reply.Content,
+		Embeds:     &reply.Embeds,
+		Components: &reply.Components,
+	}); err != nil {
+		logger.StdErr.Println("Failed to reply to Discord command:", err)
+	}
+}
+
+// Runs the command and returns the message to reply with
+func runSchejCommand(ctx context.Context, name string, r schejRequest) *discordgo.InteractionResponseData {
+	var command *schejCommand
+	for i := range schejCommands {
+		if schejCommands[i].Name == name {
+			command = &schejCommands[i]
+		}
+	}
+	if name == settingsCommand.Name {
+		command = &settingsCommand
+	}
+	if command == nil {
+		return reply("That command doesn't exist anymore.")
+	}
+
+	if command.NeedsAccount {
+		var err error
+		if r.User, err = chat.GetLinkedUser(ctx, models.DiscordPlatform, "", r.UserId); err != nil {
+			return commandFailed(ctx, err)
+		}
+		if r.User == nil {
+			return linkPrompt(r, "Link your schej account first, so that schej knows who you are.")
+		}
+	}
+
+	return command.Execute(ctx, r)
+}
+
+var eventOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "event",
+	Description: "The event's link or id",
+	Required:    true,
+}
+
+var newCommand = schejCommand{
+	Name:        "new",
+	Description: "Create an event and post it in the channel",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "name",
+			Description: "The event's name",
+			Required:    true,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "days",
+			Description: "e.g. today, tomorrow, this week, next week, fri-sun or 10/19",
+			Required:    true,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "times",
+			Description: "e.g. 7pm-11pm, defaults to 9-5",
+		},
+	},
+	NeedsAccount: true,
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		args := []string{r.String("name"), r.String("days")}
+		if times := r.String("times"); len(times) > 0 {
+			args = append(args, times)
+		}
+		parsed, err := chat.ParseNewEvent(args, time.Now().In(chat.UserZone(r.User)))
+		if err != nil {
+			return reply(fmt.Sprintf("Couldn't create the event: %v.", err))
+		}
+
+		event, err := chat.CreateEvent(ctx, r.User, parsed)
+		if errors.Is(err, chat.ErrTooManyEvents) {
+			return reply("You've created too many events recently, try again later.")
+		} else if err != nil {
+			return validationFailed(ctx, err)
+		}
+		slackbot.SendEventCreatedMessage(event.Id.Hex(), fmt.Sprintf("%s %s (%s) via Discord", r.User.FirstName, r.User.LastName, r.User.Email), *event)
+
+		// Post in the server's events channel if it has one
+		channelId := r.ChannelId
+		guild, err := db.DiscordGuilds.Get(ctx, r.GuildId)
+		if err != nil {
+			return commandFailed(ctx, err)
+		}
+		if guild != nil && len(guild.EventsChannelId) > 0 {
+			channelId = guild.EventsChannelId
+		}
+
+		content := fmt.Sprintf("<@%s> created **%s** for %s. Add your availability so we can find a time!", r.UserId, event.Name, parsed.When())
+		if err := postEvent(ctx, r.GuildId, channelId, event, content); err != nil {
+			logger.FromContext(ctx).Error("Failed to post Discord event", "error", err)
+			return reply(fmt.Sprintf("Created **%s**, but couldn't post it in <#%s>. Make sure schej can send messages there, then use `/schej post`.\n%s", event.Name, channelId, chat.EventUrl(event)))
+		}
+
+		return reply(fmt.Sprintf("Created **%s** and posted it in <#%s>.", event.Name, channelId))
+	},
+}
+
+var statusCommand = schejCommand{
+	Name:        "status",
+	Description: "Show how many people responded to an event and its best times",
+	Options:     []*discordgo.ApplicationCommandOption{eventOption},
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		event, response := getEvent(ctx, r)
+		if event == nil {
+			return response
+		}
+
+		return &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{eventEmbed(event, 3)},
+			Components: eventComponents(event),
+		}
+	},
+}
+
+var postCommand = schejCommand{
+	Name:        "post",
+	Description: "Post an event in this channel, with its responses and best time kept up to date",
+	Options:     []*discordgo.ApplicationCommandOption{eventOption},
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		event, response := getEvent(ctx, r)
+		if event == nil {
+			return response
+		}
+
+		content := fmt.Sprintf("<@%s> shared **%s**. Add your availability so we can find a time!", r.UserId, event.Name)
+		if err := postEvent(ctx, r.GuildId, r.ChannelId, event, content); err != nil {
+			logger.FromContext(ctx).Error("Failed to post Discord event", "error", err)
+			return reply("Couldn't post the event here. Make sure schej can send messages in this channel.")
+		}
+
+		return reply(fmt.Sprintf("Posted **%s**.", event.Name))
+	},
+}
+
+var remindCommand = schejCommand{
+	Name:         "remind",
+	Description:  "Email the people who haven't responded to your event yet",
+	Options:      []*discordgo.ApplicationCommandOption{eventOption},
+	NeedsAccount: true,
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		event, response := getEvent(ctx, r)
+		if event == nil {
+			return response
+		}
+		if event.OwnerId != r.User.Id {
+			return reply("Only the event's owner can send reminders.")
+		}
+
+		result, err := reminders.NudgeAll(ctx, event, r.User, false)
+		if errors.Is(err, errs.EmailLimitExceeded) {
+			return reply("You've sent too many reminder emails today, try again tomorrow.")
+		} else if err != nil {
+			return commandFailed(ctx, err)
+		}
+
+		if len(result.Nudged) == 0 && len(result.Skipped) == 0 && len(result.NotSent) == 0 && len(result.Failed) == 0 {
+			return reply(fmt.Sprintf("Everyone has responded to **%s**, there's nobody to remind.", event.Name))
+		}
+		text := fmt.Sprintf("Reminded %d %s about **%s**.", len(result.Nudged), chat.Plural(len(result.Nudged), "person", "people"), event.Name)
+		if len(result.Skipped) > 0 {
+			text += fmt.Sprintf("\nSkipped %d %s who %s reminded recently.", len(result.Skipped), chat.Plural(len(result.Skipped), "person", "people"), chat.Plural(len(result.Skipped), "was", "were"))
+		}
+		if len(result.NotSent) > 0 {
+			text += fmt.Sprintf("\nDidn't email %d %s who turned off reminder emails.", len(result.NotSent), chat.Plural(len(result.NotSent), "person", "people"))
+		}
+		if len(result.Failed) > 0 {
+			text += fmt.Sprintf("\nCouldn't email %d %s, try reminding them again later.", len(result.Failed), chat.Plural(len(result.Failed), "person", "people"))
+		}
+		return reply(text)
+	},
+}
+
+var linkCommand = schejCommand{
+	Name:        "link",
+	Description: "Link your schej account, so that your commands act as you",
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		return linkPrompt(r, "Open this link while you're signed in to schej to link your account.")
+	},
+}
+
+var unlinkCommand = schejCommand{
+	Name:        "unlink",
+	Description: "Unlink your schej account",
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		if err := db.ChatAccounts.Delete(ctx, models.DiscordPlatform, "", r.UserId); err != nil {
+			return commandFailed(ctx, err)
+		}
+		return reply("Your schej account is unlinked.")
+	},
+}
+
+// Only members that can manage the server can run it, unless the server's admins allow others to
+var settingsCommand = schejCommand{
+	Name:        "schej-settings",
+	Description: "Show or change where schej posts events and who it pings when they're scheduled",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "events_channel",
+			Description:  "Channel that new events are posted in, instead of the channel they were created in",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionRole,
+			Name:        "ping_role",
+			Description: "Role that's pinged when an event is scheduled, along with the people that responded",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "reset",
+			Description: "Go back to the default settings",
+		},
+	},
+	Execute: func(ctx context.Context, r schejRequest) *discordgo.InteractionResponseData {
+		guild, err := db.DiscordGuilds.Get(ctx, r.GuildId)
+		if err != nil {
+			return commandFailed(ctx, err)
+		}
+		if guild == nil {
+			guild = &models.DiscordGuild{GuildId: r.GuildId}
+		}
+
+		if len(r.Options) > 0 {
+			if r.Bool("reset") {
+				guild.EventsChannelId, guild.PingRoleId = "", ""
+			}
+			if channelId := r.String("events_channel"); len(channelId) > 0 {
+				guild.EventsChannelId = channelId
+			}
+			if roleId := r.String("ping_role"); len(roleId) > 0 {
+				guild.PingRoleId = roleId
+			}
+			guild.UpdatedAt = time.Now()
+			if err := db.DiscordGuilds.Upsert(ctx, guild); err != nil {
+				return commandFailed(ctx, err)
+			}
+		}
+
+		text := "New events are posted in the channel they're created in."
+		if len(guild.EventsChannelId) > 0 {
+			text = fmt.Sprintf("New events are posted in <#%s>.", guild.EventsChannelId)
+		}
+		if len(guild.PingRoleId) > 0 {
+			text += fmt.Sprintf("\nWhen an event is scheduled, <@&%s> and the people that responded are pinged.", guild.PingRoleId)
+		} else {
+			text += "\nWhen an event is scheduled, the people that responded are pinged."
+		}
+		return reply(text)
+	},
+}
+
+// Returns the event in the event option, with its responses populated, or nil and the message to reply with if
+// there isn't one
+func getEvent(ctx context.Context, r schejRequest) (*models.Event, *discordgo.InteractionResponseData) {
+	event, err := chat.GetEvent(ctx, r.String("event"))
+	if err != nil {
+		return nil, commandFailed(ctx, err)
+	}
+	if event == nil {
+		return nil, reply(fmt.Sprintf("There's no event with the id %s.", r.String("event")))
+	}
+	return event, nil
+}
+
+// Returns the message asking the Discord user to link their schej account
+func linkPrompt(r schejRequest, text string) *discordgo.InteractionResponseData {
+	l := chat.NewLinkRequest(models.DiscordPlatform, "", r.UserId)
+	return &discordgo.InteractionResponseData{
+		Content:    text,
+		Components: []discordgo.MessageComponent{linkButton("Link schej account", l.Url())},
+	}
+}
+
+func validationFailed(ctx context.Context, err error) *discordgo.InteractionResponseData {
+	var apiErr *errs.Error
+	if !errors.As(err, &apiErr) {
+		return commandFailed(ctx, err)
+	}
+	details, ok := apiErr.Details.(validation.Errors)
+	if !ok {
+		return commandFailed(ctx, err)
+	}
+
+	text := "Couldn't create the event:"
+	for _, detail := range details {
+		text += "\n• " + detail.Message
+	}
+	return reply(text)
+}
+
+func commandFailed(ctx context.Context, err error) *discordgo.InteractionResponseData {
+	logger.FromContext(ctx).Error("Discord command failed", "error", err)
+	return reply("Something went wrong, please try again.")
+}
+
+func reply(text string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{Content: text}
+}
+
+func linkButton(label string, url string) discordgo.ActionsRow {
+	return discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: label, Style: discordgo.LinkButton, URL: url},
+	}}
+}
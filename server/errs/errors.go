@@ -1,25 +1,106 @@
 package errs
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 )
 
-// Errors enum
-// TODO: make these an actual type (i.e. Errors.NotSignedIn)
-const (
-	NotSignedIn           string = "not-signed-in"
-	UserDoesNotExist      string = "user-does-not-exist"
-	EventNotFound         string = "event-not-found"
-	FriendRequestNotFound string = "friend-request-not-found"
-	UserNotFriends        string = "user-not-friends"
-	UserNotEventOwner     string = "user-not-event-owner"
-	RemindeeEmailNotFound string = "remindee-email-not-found"
-	AttendeeEmailNotFound string = "attendee-email-not-found"
-	EventNotGroup         string = "event-not-group"
-	InvalidCredentials    string = "invalid-credentials"
+// An error that can be returned to the client. Handlers add it to the request with c.Error and middleware.Errors
+// renders it as responses.Error{Error: Code, Details: Details} with the given Status. Any other error is rendered as
+// InternalError, and its message is only logged
+type Error struct {
+	// Machine readable code, e.g. "event-not-found", which the frontend switches on
+	Code string
+
+	// Http status code of the response
+	Status int
+
+	// Extra information for the client, which must be safe to show to the user
+	Details interface{}
+
+	// Underlying cause, which is logged but never returned to the client
+	Err error
+}
+
+func New(status int, code string) *Error {
+	return &Error{Code: code, Status: status}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.Err)
+	}
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors with the same code are equal, so that errors.Is(err, errs.EventNotFound) matches copies made by
+// WithDetails and Wrap
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Returns a copy of the error with the given details
+func (e *Error) WithDetails(details interface{}) *Error {
+	copy := *e
+	copy.Details = details
+	return &copy
+}
+
+// Returns a copy of the error caused by err
+func (e *Error) Wrap(err error) *Error {
+	copy := *e
+	copy.Err = err
+	return &copy
+}
+
+// Returns err if it is an *Error, Timeout if a deadline was exceeded, or InternalError caused by err otherwise
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout.Wrap(err)
+	}
+	return InternalError.Wrap(err)
+}
+
+var (
+	InternalError          = New(http.StatusInternalServerError, "internal-error")
+	Timeout                = New(http.StatusGatewayTimeout, "timeout")
+	InvalidRequest         = New(http.StatusBadRequest, "invalid-request")
+	ValidationFailed       = New(http.StatusBadRequest, "validation-failed") // Details are []validation.FieldError
+	NotSignedIn            = New(http.StatusUnauthorized, "not-signed-in")
+	UserDoesNotExist       = New(http.StatusUnauthorized, "user-does-not-exist")
+	EventNotFound          = New(http.StatusNotFound, "event-not-found")
+	FriendRequestNotFound  = New(http.StatusNotFound, "friend-request-not-found")
+	UserNotFriends         = New(http.StatusForbidden, "user-not-friends")
+	UserNotEventOwner      = New(http.StatusForbidden, "user-not-event-owner")
+	RemindeeEmailNotFound  = New(http.StatusNotFound, "remindee-email-not-found")
+	AttendeeEmailNotFound  = New(http.StatusNotFound, "attendee-email-not-found")
+	EventNotGroup          = New(http.StatusBadRequest, "event-not-group")
+	InvalidCredentials     = New(http.StatusUnauthorized, "invalid-credentials")
+	RateLimited            = New(http.StatusTooManyRequests, "rate-limited")         // Details are {retryAfter}, in seconds
+	EmailLimitExceeded     = New(http.StatusTooManyRequests, "email-limit-exceeded") // Details are {limit, resetAt}
+	InvalidUnsubscribeLink = New(http.StatusBadRequest, "invalid-unsubscribe-link")
+	WebhookNotFound        = New(http.StatusNotFound, "webhook-not-found")
+	DeliveryNotFound       = New(http.StatusNotFound, "delivery-not-found")
+	InvalidSlackSignature  = New(http.StatusUnauthorized, "invalid-slack-signature")
+	InvalidChatLink        = New(http.StatusBadRequest, "invalid-chat-link")
+	CrossOriginRequest     = New(http.StatusForbidden, "cross-origin-request")
 )
 
+// ErrCalendarUnauthorized is wrapped by calendar provider errors when the provider rejected the account's credentials
+var ErrCalendarUnauthorized = errors.New("calendar provider rejected credentials")
+
 type GoogleAPIError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -36,3 +117,12 @@ func (e *GoogleAPIError) Error() string {
 
 	return fmt.Sprintln("GoogleAPIError: ", string(s))
 }
+
+// Unwrap lets errors.Is match ErrCalendarUnauthorized when google rejected the access token
+func (e *GoogleAPIError) Unwrap() error {
+	if e.Code == http.StatusUnauthorized {
+		return ErrCalendarUnauthorized
+	}
+
+	return nil
+}
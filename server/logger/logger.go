@@ -1,17 +1,75 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 )
 
+// Logs JSON lines to the log file and stdout, or stderr for errors. Use FromContext in request handlers so that
+// the request's id is included
+var Logger *slog.Logger
+
+// Plain loggers for code that doesn't log structured values, they write to Logger at the info and error levels
 var StdOut *log.Logger
 var StdErr *log.Logger
 
+type contextKey struct{}
+
 func Init(logFile io.Writer) {
-	StdOut = log.New(io.MultiWriter(logFile, os.Stdout), "[INFO] ", log.Ldate|log.Ltime|log.Lmsgprefix|log.Llongfile)
-	StdErr = log.New(io.MultiWriter(logFile, os.Stderr), "[ERROR] ", log.Ldate|log.Ltime|log.Lmsgprefix|log.Llongfile)
+	options := &slog.HandlerOptions{AddSource: true}
+	Logger = slog.New(&levelHandler{
+		out: slog.NewJSONHandler(io.MultiWriter(logFile, os.Stdout), options),
+		err: slog.NewJSONHandler(io.MultiWriter(logFile, os.Stderr), options),
+	})
+	slog.SetDefault(Logger)
+
+	StdOut = slog.NewLogLogger(Logger.Handler(), slog.LevelInfo)
+	StdErr = slog.NewLogLogger(Logger.Handler(), slog.LevelError)
+
+	Logger.Info("Server restarted")
+}
+
+// Returns a copy of ctx that FromContext returns l for
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// Returns the logger attached to ctx with NewContext, or Logger if there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return Logger
+}
+
+// Sends errors to the err handler and everything else to the out handler
+type levelHandler struct {
+	out slog.Handler
+	err slog.Handler
+}
+
+func (h *levelHandler) handler(level slog.Level) slog.Handler {
+	if level >= slog.LevelError {
+		return h.err
+	}
+	return h.out
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler(level).Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler(r.Level).Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{out: h.out.WithAttrs(attrs), err: h.err.WithAttrs(attrs)}
+}
 
-	StdOut.Println("######### Server Restarted #########")
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{out: h.out.WithGroup(name), err: h.err.WithGroup(name)}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -8,8 +10,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -17,10 +21,14 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"schej.it/server/config"
 	"schej.it/server/db"
+	"schej.it/server/discord_bot"
 	"schej.it/server/logger"
+	"schej.it/server/middleware"
+	"schej.it/server/migrations"
 	"schej.it/server/routes"
-	"schej.it/server/services/gcloud"
+	"schej.it/server/services/jobs"
 	"schej.it/server/slackbot"
 	"schej.it/server/utils"
 
@@ -39,6 +47,7 @@ import (
 func main() {
 	// Set release flag
 	release := flag.Bool("release", false, "Whether this is the release version of the server")
+	configFile := flag.String("config", "", "Path to a YAML or TOML config file, defaults to CONFIG_FILE")
 	flag.Parse()
 	if *release {
 		os.Setenv("GIN_MODE", "release")
@@ -60,61 +69,90 @@ func main() {
 	// Load .env variables
 	loadDotEnv()
 
-	// Init router
-	router := gin.New()
-	router.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		var statusColor, methodColor, resetColor string
-		if param.IsOutputColor() {
-			statusColor = param.StatusCodeColor()
-			methodColor = param.MethodColor()
-			resetColor = param.ResetColor()
+	// Load config
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		logger.StdErr.Panicln("Invalid config:", err)
+	}
+	if *release && cfg.Server.SessionSecret == "secret" {
+		logger.StdErr.Println("SESSION_SECRET is not set, session cookies are signed with the default secret")
+	}
+
+	// Init database, the memory storage backend runs without mongo
+	if cfg.Storage.Backend == "memory" {
+		db.InitMemory(cfg.Storage.MemoryPath)
+	} else {
+		closeConnection := db.Init()
+		defer closeConnection()
+	}
+
+	// Run migrations, either with the migrate subcommand or on startup if enabled
+	if flag.Arg(0) == "migrate" {
+		runMigrateCommand(flag.Args()[1:])
+		return
+	}
+	if cfg.RateLimits.Store == "memory" {
+		db.UseMemoryRateLimits()
+	}
+	if cfg.Storage.Backend != "memory" {
+		if cfg.Storage.MigrateOnStartup {
+			if err := migrations.Apply(migrations.Options{}); err != nil {
+				logger.StdErr.Panicln(err)
+			}
 		}
 
-		if param.Latency > time.Minute {
-			param.Latency = param.Latency.Truncate(time.Second)
+		// Create missing indexes after migrating, since migrations can replace old indexes
+		db.EnsureIndexes()
+	}
+
+	// Init router
+	router := gin.New()
+	if len(cfg.Server.TrustedProxies) > 0 {
+		if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			logger.StdErr.Panicln("Invalid trusted proxies:", err)
 		}
-		return fmt.Sprintf("%v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
-			param.TimeStamp.Format("2006/01/02 15:04:05"),
-			statusColor, param.StatusCode, resetColor,
-			param.Latency,
-			param.ClientIP,
-			methodColor, param.Method, resetColor,
-			param.Path,
-			param.ErrorMessage,
-		)
-	}))
-	router.Use(gin.Recovery())
+	}
 
 	// Cors
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8080", "https://www.schej.it", "https://schej.it"},
+		AllowOrigins:     cfg.Server.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PATCH", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Content-Type", middleware.RequestIdHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIdHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Init database
-	closeConnection := db.Init()
-	defer closeConnection()
-
-	// Init google cloud stuff
-	closeTasks := gcloud.InitTasks()
-	defer closeTasks()
+	// Start running background jobs
+	closeJobs := jobs.Init()
+	defer closeJobs()
 
 	// Session
-	store := cookie.NewStore([]byte("secret"))
+	store := cookie.NewStore([]byte(cfg.Server.SessionSecret))
+	// Lax so that the cookie isn't sent with forms posted from other sites
+	store.Options(sessions.Options{Path: "/", MaxAge: 30 * 24 * 60 * 60, SameSite: http.SameSiteLaxMode})
 	router.Use(sessions.Sessions("session", store))
 
+	// Logging and metrics, after the session so that requests are logged with the user's id
+	router.Use(middleware.RequestLogger())
+	router.Use(middleware.Metrics())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Errors())
+
 	// Init routes
+	routes.InitHealth(router.Group("/"))
 	apiRouter := router.Group("/api")
 	routes.InitAuth(apiRouter)
 	routes.InitUser(apiRouter)
 	routes.InitEvents(apiRouter)
 	routes.InitUsers(apiRouter)
 	routes.InitAnalytics(apiRouter)
+	routes.InitJobs(apiRouter)
+	routes.InitNotifications(apiRouter)
+	routes.InitWebhooks(apiRouter)
 	slackbot.InitSlackbot(apiRouter)
+	closeDiscordBot := discord_bot.Init(apiRouter)
+	defer closeDiscordBot()
 
 	err = filepath.WalkDir("../frontend/dist", func(path string, d fs.DirEntry, err error) error {
 		if !d.IsDir() && d.Name() != "index.html" {
@@ -135,7 +173,38 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	// Run server
-	router.Run(":3002")
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.StdErr.Panicln(err)
+		}
+	}()
+
+	// Wait for a signal to shut down
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	shutdown(server, time.Duration(cfg.Server.ShutdownTimeoutSeconds)*time.Second)
+}
+
+// Stops accepting requests and waits for in flight requests and background tasks to finish. The db and tasks
+// clients are closed by the deferred functions in main after this returns
+func shutdown(server *http.Server, timeout time.Duration) {
+	logger.StdOut.Println("Shutting down...")
+	routes.SetShuttingDown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.StdErr.Println("Failed to drain requests:", err)
+	}
+	if err := utils.WaitForBackgroundTasks(ctx); err != nil {
+		logger.StdErr.Println("Failed to wait for background tasks:", err)
+	}
 }
 
 // Load .env variables
@@ -156,7 +225,11 @@ func noRouteHandler() gin.HandlerFunc {
 		if match := regexp.MustCompile(`\/e\/(\w+)`).FindStringSubmatchIndex(path); match != nil {
 			// /e/:eventId
 			eventId := path[match[2]:match[3]]
-			event := db.GetEventByEitherId(eventId)
+			// The page is still served without the event's meta tags if it can't be fetched
+			event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+			if err != nil {
+				logger.FromContext(c.Request.Context()).Error("Failed to get event for meta tags", "error", err.Error())
+			}
 
 			if event != nil {
 				title := fmt.Sprintf("%s - Schej", event.Name)
@@ -0,0 +1,73 @@
+/* Prometheus metrics, served at /metrics */
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Values of the result label
+const (
+	Success = "success"
+	Error   = "error"
+)
+
+// Returns the value of the result label for an operation that returned err
+func Result(err error) string {
+	if err != nil {
+		return Error
+	}
+	return Success
+}
+
+// Latency of the requests handled by the server, by gin route. Requests that don't match a route have the route
+// "unmatched" to keep the number of series bounded
+var HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "schej_http_request_duration_seconds",
+	Help:    "Latency of http requests by route and status code",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// Latency of the requests made to calendar providers, by calendar type and operation (list or events)
+var CalendarRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "schej_calendar_request_duration_seconds",
+	Help:    "Latency of calendar provider requests by calendar type, operation, and result",
+	Buckets: prometheus.DefBuckets,
+}, []string{"calendar_type", "operation", "result"})
+
+// Access token refreshes from auth.RefreshUserTokenIfNecessary
+var TokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "schej_token_refreshes_total",
+	Help: "Access token refreshes by calendar type and result",
+}, []string{"calendar_type", "result"})
+
+// Notification emails sent, by template name
+var EmailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "schej_emails_sent_total",
+	Help: "Notification emails sent by template name and result",
+}, []string{"template", "result"})
+
+// Latency of the commands sent to mongo, by command name (find, insert, update, ...)
+var MongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "schej_mongo_command_duration_seconds",
+	Help:    "Latency of mongo commands by command name and result",
+	Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"command", "result"})
+
+// Requests and emails rejected by a rate limit, by the name of the limit
+var RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "schej_rate_limited_total",
+	Help: "Requests and emails rejected by rate limits by limit name",
+}, []string{"limit"})
+
+// Background jobs run by this server, by job type
+var JobsRun = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "schej_jobs_run_total",
+	Help: "Background jobs run by job type and result",
+}, []string{"type", "result"})
+
+// Attempts to deliver webhooks, by activity type
+var WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "schej_webhook_deliveries_total",
+	Help: "Webhook delivery attempts by activity type and result",
+}, []string{"type", "result"})
@@ -1,13 +1,10 @@
 package middleware
 
 import (
-	"net/http"
-
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"schej.it/server/db"
 	"schej.it/server/errs"
-	"schej.it/server/responses"
 )
 
 func AuthRequired() gin.HandlerFunc {
@@ -16,16 +13,20 @@ func AuthRequired() gin.HandlerFunc {
 		session := sessions.Default(c)
 		if session.Get("userId") == nil {
 			// User id is not set, user is not signed in!
-			c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.NotSignedIn})
+			c.Error(errs.NotSignedIn)
 			c.Abort()
 			return
 		}
 
 		// Check if user with user id exists
-		user := db.GetUserById(session.Get("userId").(string))
-
+		user, err := db.GetUserById(c.Request.Context(), session.Get("userId").(string))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
 		if user == nil {
-			c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.UserDoesNotExist})
+			c.Error(errs.UserDoesNotExist)
 			c.Abort()
 			return
 		}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/responses"
+)
+
+// Status of requests that failed because the client closed the connection, which no one sees
+const statusClientClosedRequest = 499
+
+// Renders the last error added with c.Error as a responses.Error, unless the handler already wrote a response.
+// Internal errors are logged with the request's logger and returned without their message
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		if errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil {
+			c.AbortWithStatus(statusClientClosedRequest)
+			return
+		}
+
+		apiErr := errs.From(err)
+		if apiErr.Status >= http.StatusInternalServerError {
+			logger.FromContext(c.Request.Context()).Error("Internal error", "error", err.Error())
+		}
+
+		c.AbortWithStatusJSON(apiErr.Status, responses.Error{Error: apiErr.Code, Details: apiErr.Details})
+	}
+}
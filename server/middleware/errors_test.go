@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+)
+
+func TestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	logger.Init(&logs)
+
+	router := gin.New()
+	router.Use(Errors())
+	router.GET("/not-found", func(c *gin.Context) {
+		c.Error(errs.EventNotFound.WithDetails(gin.H{"eventId": "123"}))
+	})
+	router.GET("/internal", func(c *gin.Context) {
+		c.Error(errors.New("mongo password is hunter2"))
+	})
+	router.GET("/timeout", func(c *gin.Context) {
+		c.Error(fmt.Errorf("failed to get event: %w", context.DeadlineExceeded))
+	})
+	router.GET("/written", func(c *gin.Context) {
+		c.Error(errs.InvalidRequest)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+		logged bool
+	}{
+		{"/not-found", http.StatusNotFound, `{"error":"event-not-found","details":{"eventId":"123"}}`, false},
+		{"/internal", http.StatusInternalServerError, `{"error":"internal-error"}`, true},
+		{"/timeout", http.StatusGatewayTimeout, `{"error":"timeout"}`, false},
+		{"/written", http.StatusOK, `{}`, false},
+	}
+	for _, test := range tests {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+		}
+		if body := w.Body.String(); body != test.body {
+			t.Errorf("%s: expected body %s, got %s", test.path, test.body, body)
+		}
+		if logged := strings.Contains(logs.String(), "hunter2"); logged != test.logged {
+			t.Errorf("%s: expected the error to be logged: %v, got %v", test.path, test.logged, logged)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/responses"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// Request ids passed in by clients or proxies are only kept if they're reasonably short and safe to log
+var validRequestId = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+// Assigns the request an id, or keeps the one in the X-Request-ID header, and returns it in the X-Request-ID response
+// header. Attaches a logger with the request id, user id, and event id to the request's context, which handlers get
+// with logger.FromContext(c.Request.Context()), and logs the request once it's done. Must be used after the sessions
+// middleware
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestId := c.GetHeader(RequestIdHeader)
+		if !validRequestId.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		c.Header(RequestIdHeader, requestId)
+
+		l := logger.Logger.With("requestId", requestId)
+		userId, hasUserId := sessions.Default(c).Get("userId").(string)
+		if hasUserId {
+			l = l.With("userId", userId)
+		}
+		if eventId := c.Param("eventId"); len(eventId) > 0 {
+			l = l.With("eventId", eventId)
+		}
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), l))
+
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start).String(),
+			"clientIp", c.ClientIP(),
+		}
+		// The user might have signed in during the request
+		if userId, ok := sessions.Default(c).Get("userId").(string); ok && !hasUserId {
+			attrs = append(attrs, "userId", userId)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		l.Log(c.Request.Context(), level, "Request", attrs...)
+	}
+}
+
+// Recovers from panics in handlers, logging them with the request's logger and a stack trace and responding with
+// an internal error. Must be used after RequestLogger
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.FromContext(c.Request.Context()).Error("Panic while handling request", "panic", err, "stack", string(debug.Stack()))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, responses.Error{Error: errs.InternalError.Code})
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// Returns a random 16 byte hex string
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/logger"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	logger.Init(&logs)
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("test"))))
+	router.Use(RequestLogger())
+	router.Use(Recovery())
+	router.GET("/events/:eventId", func(c *gin.Context) {
+		logger.FromContext(c.Request.Context()).Info("Handling")
+		panic("oops")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events/123", nil)
+	req.Header.Set(RequestIdHeader, "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a 500 after a panic, got %d", w.Code)
+	}
+	if w.Header().Get(RequestIdHeader) != "abc-123" {
+		t.Errorf("expected the request id to be propagated, got %q", w.Header().Get(RequestIdHeader))
+	}
+
+	// The handler's log, the panic, and the request should all be logged with the request and event ids
+	messages := make(map[string]map[string]any)
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("expected JSON logs, got %q", line)
+		}
+		messages[entry["msg"].(string)] = entry
+	}
+	for _, msg := range []string{"Handling", "Panic while handling request", "Request"} {
+		entry, ok := messages[msg]
+		if !ok {
+			t.Errorf("expected %q to be logged", msg)
+			continue
+		}
+		if entry["requestId"] != "abc-123" || entry["eventId"] != "123" {
+			t.Errorf("expected %q to be logged with the request and event ids, got %v", msg, entry)
+		}
+	}
+	if stack, _ := messages["Panic while handling request"]["stack"].(string); len(stack) == 0 {
+		t.Error("expected the panic to be logged with a stack trace")
+	}
+
+	// Invalid request ids are replaced
+	req = httptest.NewRequest(http.MethodGet, "/events/123", nil)
+	req.Header.Set(RequestIdHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if id := w.Header().Get(RequestIdHeader); len(id) != 32 {
+		t.Errorf("expected a generated request id, got %q", id)
+	}
+}
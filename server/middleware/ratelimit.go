@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+)
+
+// Returns what requests are counted by, e.g. the client's ip
+type RateLimitKey func(c *gin.Context) string
+
+// Counts requests per client ip
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// Counts requests per signed in user, or per client ip when signed out
+func ByUser(c *gin.Context) string {
+	if userId, ok := sessions.Default(c).Get("userId").(string); ok {
+		return "user:" + userId
+	}
+	return ByIP(c)
+}
+
+// Counts requests per event. Events can be requested by id or short id, which are counted separately
+func ByEvent(c *gin.Context) string {
+	return "event:" + c.Param("eventId")
+}
+
+// Rejects requests with errs.RateLimited once more than limit.Requests requests with the same key have been made in
+// limit.Window(). Each name has its own counters, so the same key can be limited differently on different routes.
+// Counts are kept in db.RateLimits, and requests are let through if it fails
+func RateLimit(name string, limit config.RateLimit, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit.Requests <= 0 {
+			return
+		}
+
+		count, resetAt, err := db.RateLimits.Increment(c.Request.Context(), "request:"+name+":"+key(c), 1, limit.Window())
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to count request", "limit", name, "error", err)
+			return
+		}
+
+		if count > limit.Requests {
+			retryAfter := int(math.Ceil(time.Until(resetAt).Seconds()))
+			metrics.RateLimited.WithLabelValues(name).Inc()
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.Error(errs.RateLimited.WithDetails(gin.H{"retryAfter": retryAfter}))
+			c.Abort()
+		}
+	}
+}
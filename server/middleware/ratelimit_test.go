@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+	db.InitMemory("")
+
+	router := gin.New()
+	router.Use(Errors())
+	router.GET("/limited", RateLimit("test", config.RateLimit{Requests: 2, WindowSeconds: 60}, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unlimited", RateLimit("test", config.RateLimit{}, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("/limited", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := request("/limited", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected 429 with Retry-After 60, got %d with %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if body := w.Body.String(); body != `{"error":"rate-limited","details":{"retryAfter":60}}` {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	// Other clients and disabled limits aren't affected
+	if w := request("/limited", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("expected another ip to be allowed, got %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := request("/unlimited", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Errorf("expected a disabled limit to allow every request, got %d", w.Code)
+		}
+	}
+}
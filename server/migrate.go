@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"schej.it/server/logger"
+	"schej.it/server/migrations"
+)
+
+// Runs the migrate subcommand, e.g. `go run . migrate -dry-run`
+func runMigrateCommand(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Run the migrations without writing anything")
+	list := flags.Bool("list", false, "List the migrations and whether they have been applied")
+	baseline := flags.String("baseline", "", "Record every migration up to and including this id as applied, without running them")
+	only := flags.String("run", "", "Only run the migration with this id, even if it has already been applied")
+	batchSize := flags.Int("batch-size", migrations.DefaultBatchSize, "Number of documents to process at a time")
+	flags.Parse(args)
+
+	if *list {
+		statuses, err := migrations.GetStatuses()
+		if err != nil {
+			logger.StdErr.Fatalln(err)
+		}
+		for _, migration := range migrations.All {
+			status := statuses[migration.Id]
+			if status == migrations.Pending {
+				status = "pending"
+			}
+			fmt.Printf("%-45s %-10s %s\n", migration.Id, status, migration.Description)
+		}
+		return
+	}
+
+	if len(*baseline) > 0 {
+		if err := migrations.Baseline(*baseline); err != nil {
+			logger.StdErr.Fatalln(err)
+		}
+		return
+	}
+
+	err := migrations.Apply(migrations.Options{
+		DryRun:    *dryRun,
+		BatchSize: int32(*batchSize),
+		Only:      *only,
+	})
+	if err != nil {
+		logger.StdErr.Fatalln(err)
+	}
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+// Calendar accounts are written in the format used at the time, which 20240723_multiple_calendar_support converts
+var addCalendarAccounts = Migration{
+	Id:          "20230812_add_calendar_accounts",
+	Description: "Move the google tokens of users into a calendar account keyed by their email",
+	Up: func(run *Run) error {
+		filter := bson.M{
+			"$or": bson.A{
+				bson.M{"accessToken": bson.M{"$exists": true}},
+				bson.M{"refreshToken": bson.M{"$exists": true}},
+			},
+		}
+
+		return run.ForEachBatch(db.UsersCollection, filter, nil, func(cursor *mongo.Cursor, writes *Writes) error {
+			var user struct {
+				Id                    primitive.ObjectID `bson:"_id"`
+				Email                 string             `bson:"email,omitempty"`
+				Picture               string             `bson:"picture,omitempty"`
+				CalendarAccounts      bson.M             `bson:"calendarAccounts,omitempty"`
+				AccessToken           string             `bson:"accessToken,omitempty"`
+				AccessTokenExpireDate primitive.DateTime `bson:"accessTokenExpireDate,omitempty"`
+				RefreshToken          string             `bson:"refreshToken,omitempty"`
+			}
+			if err := cursor.Decode(&user); err != nil {
+				run.Logf("Warning: Failed to decode user, skipping: %v", err)
+				return nil
+			}
+
+			if user.CalendarAccounts == nil {
+				user.CalendarAccounts = bson.M{}
+			}
+			if _, ok := user.CalendarAccounts[user.Email]; ok {
+				return nil
+			}
+			user.CalendarAccounts[user.Email] = bson.M{
+				"email":   user.Email,
+				"picture": user.Picture,
+				"enabled": true,
+
+				"accessToken":           user.AccessToken,
+				"accessTokenExpireDate": user.AccessTokenExpireDate,
+				"refreshToken":          user.RefreshToken,
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": user.Id})
+			update.SetUpdate(bson.M{
+				"$set": bson.M{
+					"calendarAccounts": user.CalendarAccounts,
+				},
+				"$unset": bson.M{
+					"accessToken":           "",
+					"accessTokenExpireDate": "",
+					"refreshToken":          "",
+				},
+			})
+			writes.Add(db.UsersCollection, update)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var fifteenMinuteIncrements = Migration{
+	Id:          "20230914_15_minute_increments",
+	Description: "Convert availability from 30 minute RFC3339 strings to 15 minute dates",
+	Up: func(run *Run) error {
+		// Responses were stored as a map from user id to response at the time
+		filter := bson.M{"$expr": bson.M{"$eq": bson.A{bson.M{"$type": "$responses"}, "object"}}}
+		projection := bson.M{"responses": 1}
+
+		return run.ForEachBatch(db.EventsCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var event struct {
+				Id        primitive.ObjectID `bson:"_id"`
+				Responses map[string]bson.M  `bson:"responses"`
+			}
+			if err := cursor.Decode(&event); err != nil {
+				run.Logf("Warning: Failed to decode event, skipping: %v", err)
+				return nil
+			}
+
+			updated := false
+			for _, response := range event.Responses {
+				availability, ok := response["availability"].(bson.A)
+				if !ok {
+					continue
+				}
+
+				newAvailability, changed, err := splitIntoFifteenMinutes(availability)
+				if err != nil {
+					return err
+				}
+				if changed {
+					response["availability"] = newAvailability
+					updated = true
+				}
+			}
+			if !updated {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": event.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"responses": event.Responses}})
+			writes.Add(db.EventsCollection, update)
+			return nil
+		})
+	},
+}
+
+// Replaces each 30 minute RFC3339 string in the availability with the dates of the two 15 minute slots it covers.
+// Returns false if the availability has no strings, i.e. it has already been converted
+func splitIntoFifteenMinutes(availability bson.A) (bson.A, bool, error) {
+	newAvailability := make(bson.A, 0, 2*len(availability))
+	changed := false
+	for _, value := range availability {
+		dateString, ok := value.(string)
+		if !ok {
+			newAvailability = append(newAvailability, value)
+			continue
+		}
+
+		parsedTime, err := time.Parse(time.RFC3339, dateString)
+		if err != nil {
+			return nil, false, err
+		}
+		newAvailability = append(newAvailability,
+			primitive.NewDateTimeFromTime(parsedTime),
+			primitive.NewDateTimeFromTime(parsedTime.Add(15*time.Minute)),
+		)
+		changed = true
+	}
+
+	return newAvailability, changed, nil
+}
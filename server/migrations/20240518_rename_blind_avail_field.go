@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+)
+
+var renameBlindAvailField = Migration{
+	Id:          "20240518_rename_blind_avail_field",
+	Description: "Rename blindavailabilityenabled to blindAvailabilityEnabled on events",
+	Up: func(run *Run) error {
+		return run.UpdateMany(db.EventsCollection, bson.M{"blindavailabilityenabled": bson.M{"$exists": true}}, bson.M{
+			"$rename": bson.M{"blindavailabilityenabled": "blindAvailabilityEnabled"},
+		})
+	},
+}
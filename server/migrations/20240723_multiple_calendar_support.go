@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Calendar accounts are written in the format used at the time, which 20240823_google_calendar_auth_rename converts
+var multipleCalendarSupport = Migration{
+	Id:          "20240723_multiple_calendar_support",
+	Description: "Key calendar accounts by email and calendar type, and move their tokens into googleCalendarAuth",
+	Up: func(run *Run) error {
+		filter := bson.M{"calendarAccounts": bson.M{"$exists": true}}
+		projection := bson.M{"calendarAccounts": 1}
+
+		return run.ForEachBatch(db.UsersCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var user struct {
+				Id               primitive.ObjectID `bson:"_id"`
+				CalendarAccounts map[string]bson.M  `bson:"calendarAccounts"`
+			}
+			if err := cursor.Decode(&user); err != nil {
+				run.Logf("Warning: Failed to decode user, skipping: %v", err)
+				return nil
+			}
+
+			updated := false
+			newCalendarAccounts := make(map[string]bson.M)
+			for key, calendarAccount := range user.CalendarAccounts {
+				// Accounts with a calendar type have already been migrated
+				if _, ok := calendarAccount["calendarType"]; ok {
+					newCalendarAccounts[key] = calendarAccount
+					continue
+				}
+
+				newCalendarAccount := bson.M{
+					"calendarType": models.GoogleCalendarType,
+					"googleCalendarAuth": bson.M{
+						"accessToken":           calendarAccount["accessToken"],
+						"accessTokenExpireDate": calendarAccount["accessTokenExpireDate"],
+						"refreshToken":          calendarAccount["refreshToken"],
+					},
+					"email": calendarAccount["email"],
+				}
+				for _, field := range []string{"picture", "enabled", "subCalendars"} {
+					if value, ok := calendarAccount[field]; ok {
+						newCalendarAccount[field] = value
+					}
+				}
+
+				email, _ := calendarAccount["email"].(string)
+				newCalendarAccounts[utils.GetCalendarAccountKey(email, models.GoogleCalendarType)] = newCalendarAccount
+				updated = true
+			}
+			if !updated {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": user.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"calendarAccounts": newCalendarAccounts}})
+			writes.Add(db.UsersCollection, update)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var googleCalendarAuthRename = Migration{
+	Id:          "20240823_google_calendar_auth_rename",
+	Description: "Rename the googleCalendarAuth of calendar accounts to oAuth2CalendarAuth",
+	Up: func(run *Run) error {
+		filter := bson.M{"calendarAccounts": bson.M{"$exists": true}}
+		projection := bson.M{"calendarAccounts": 1}
+
+		return run.ForEachBatch(db.UsersCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var user struct {
+				Id               primitive.ObjectID `bson:"_id"`
+				CalendarAccounts map[string]bson.M  `bson:"calendarAccounts"`
+			}
+			if err := cursor.Decode(&user); err != nil {
+				run.Logf("Warning: Failed to decode user, skipping: %v", err)
+				return nil
+			}
+
+			// Calendar account keys contain emails, which can't be used in field paths because of the dots,
+			// so the whole calendarAccounts field is rewritten
+			updated := false
+			for _, calendarAccount := range user.CalendarAccounts {
+				if googleCalendarAuth, ok := calendarAccount["googleCalendarAuth"]; ok {
+					calendarAccount["oAuth2CalendarAuth"] = googleCalendarAuth
+					delete(calendarAccount, "googleCalendarAuth")
+					updated = true
+				}
+			}
+			if !updated {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": user.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"calendarAccounts": user.CalendarAccounts}})
+			writes.Add(db.UsersCollection, update)
+			return nil
+		})
+	},
+}
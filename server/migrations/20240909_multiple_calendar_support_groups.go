@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+var multipleCalendarSupportGroups = Migration{
+	Id:          "20240909_multiple_calendar_support_groups",
+	Description: "Key the enabled calendars of availability group responses by calendar account key instead of email",
+	Up: func(run *Run) error {
+		// Responses were stored as a map from user id to response at the time
+		filter := bson.M{
+			"type":  models.GROUP,
+			"$expr": bson.M{"$eq": bson.A{bson.M{"$type": "$responses"}, "object"}},
+		}
+		projection := bson.M{"responses": 1}
+
+		return run.ForEachBatch(db.EventsCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var event struct {
+				Id        primitive.ObjectID `bson:"_id"`
+				Responses map[string]bson.M  `bson:"responses"`
+			}
+			if err := cursor.Decode(&event); err != nil {
+				run.Logf("Warning: Failed to decode event, skipping: %v", err)
+				return nil
+			}
+
+			updated := false
+			for _, response := range event.Responses {
+				enabledCalendars, ok := response["enabledCalendars"].(bson.M)
+				if !ok {
+					continue
+				}
+
+				newEnabledCalendars := make(bson.M)
+				for calendarEmail, calendarIds := range enabledCalendars {
+					if !strings.HasSuffix(calendarEmail, "_google") && !strings.HasSuffix(calendarEmail, "_apple") {
+						calendarEmail += "_google"
+						updated = true
+					}
+					newEnabledCalendars[calendarEmail] = calendarIds
+				}
+				response["enabledCalendars"] = newEnabledCalendars
+			}
+			if !updated {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": event.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"responses": event.Responses}})
+			writes.Add(db.EventsCollection, update)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/db"
+)
+
+var eventResponsesRestructure = Migration{
+	Id:          "20250201_event_responses_restructure",
+	Description: "Convert the responses of events from a map keyed by user id to a list of {userId, response}",
+	Up: func(run *Run) error {
+		filter := bson.M{"$expr": bson.M{"$eq": bson.A{bson.M{"$type": "$responses"}, "object"}}}
+		projection := bson.M{"responses": 1}
+
+		err := run.ForEachBatch(db.EventsCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var event struct {
+				Id        primitive.ObjectID `bson:"_id"`
+				Responses map[string]bson.M  `bson:"responses"`
+			}
+			if err := cursor.Decode(&event); err != nil {
+				run.Logf("Warning: Failed to decode event, skipping: %v", err)
+				return nil
+			}
+
+			responsesList := make(bson.A, 0, len(event.Responses))
+			for userId, response := range event.Responses {
+				responsesList = append(responsesList, bson.M{"userId": userId, "response": response})
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": event.Id})
+			update.SetUpdate(bson.M{
+				"$set": bson.M{
+					"responses": responsesList,
+				},
+				"$unset": bson.M{
+					"responsesMap": "",
+				},
+			})
+			writes.Add(db.EventsCollection, update)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return run.CreateIndexes(db.EventsCollection,
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "responses.userId", Value: 1}},
+				Options: options.Index().SetName("responses_userId_1"),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "attendees.email", Value: 1}},
+				Options: options.Index().SetName("attendees_email_1"),
+			},
+		)
+	},
+}
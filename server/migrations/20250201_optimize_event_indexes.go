@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/db"
+)
+
+var optimizeEventIndexes = Migration{
+	Id:          "20250201_optimize_event_indexes",
+	Description: "Replace the responses and attendees indexes on events with compound indexes that include _id for sorting",
+	Up: func(run *Run) error {
+		run.DropIndex(db.EventsCollection, "responses_userId_1")
+		run.DropIndex(db.EventsCollection, "attendees_email_1")
+
+		return run.CreateIndexes(db.EventsCollection,
+			mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "responses.userId", Value: 1},
+					{Key: "_id", Value: -1},
+				},
+				Options: options.Index().SetName("responses_userId_id_1"),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "attendees.email", Value: 1},
+					{Key: "attendees.declined", Value: 1},
+					{Key: "_id", Value: -1},
+				},
+				Options: options.Index().SetName("attendees_email_declined_id_1"),
+			},
+		)
+	},
+}
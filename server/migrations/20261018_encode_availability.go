@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+// Responses are encoded when they are written, so this only shrinks responses that haven't been updated since
+// encoding was added
+var encodeAvailability = Migration{
+	Id:          "20261018_encode_availability",
+	Description: "Encode the availability of responses as bitsets over their event's slot grid",
+	Up: func(run *Run) error {
+		grids := make(map[primitive.ObjectID]*models.SlotGrid)
+		filter := bson.M{"response.encodedAvailability": bson.M{"$exists": false}}
+
+		return run.ForEachBatch(db.ResponsesCollection, filter, nil, func(cursor *mongo.Cursor, writes *Writes) error {
+			var doc struct {
+				Id       primitive.ObjectID `bson:"_id"`
+				EventId  primitive.ObjectID `bson:"eventId"`
+				Response *models.Response   `bson:"response"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				run.Logf("Warning: Failed to decode response, skipping: %v", err)
+				return nil
+			}
+			if doc.Response == nil {
+				return nil
+			}
+
+			// Get the slot grid of the response's event
+			grid, ok := grids[doc.EventId]
+			if !ok {
+				event, err := db.Events.GetById(context.Background(), doc.EventId)
+				if err != nil {
+					return err
+				}
+				if event != nil {
+					eventGrid := event.SlotGrid()
+					grid = &eventGrid
+				}
+				grids[doc.EventId] = grid
+			}
+			if grid == nil {
+				return nil
+			}
+
+			// Responses with times that aren't on the grid are left as is
+			encodedResponse := doc.Response.EncodeAvailability(*grid)
+			if encodedResponse.EncodedAvailability == nil {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": doc.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"response": encodedResponse}})
+			writes.Add(db.ResponsesCollection, update)
+			return nil
+		})
+	},
+}
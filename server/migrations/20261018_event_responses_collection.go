@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+// Responses that already exist in the responses collection are never overwritten
+var eventResponsesCollection = Migration{
+	Id:          "20261018_event_responses_collection",
+	Description: "Move the responses of events into the responses collection, keyed by (eventId, userId)",
+	Up: func(run *Run) error {
+		// Create indexes first so that upserts can't create duplicate responses
+		err := run.CreateIndexes(db.ResponsesCollection,
+			mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "eventId", Value: 1},
+					{Key: "userId", Value: 1},
+				},
+				Options: options.Index().
+					SetName("eventId_1_userId_1").
+					SetUnique(true),
+			},
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetName("userId_1"),
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		filter := bson.M{"responses": bson.M{"$exists": true}}
+		projection := bson.M{"responses": 1}
+
+		err = run.ForEachBatch(db.EventsCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var event struct {
+				Id            primitive.ObjectID     `bson:"_id"`
+				ResponsesList []models.EventResponse `bson:"responses"`
+			}
+			if err := cursor.Decode(&event); err != nil {
+				run.Logf("Warning: Failed to decode event, skipping: %v", err)
+				return nil
+			}
+
+			// Copy the responses, then remove them from the event
+			for _, eventResponse := range event.ResponsesList {
+				update := mongo.NewUpdateOneModel()
+				update.SetFilter(bson.M{"eventId": event.Id, "userId": eventResponse.UserId})
+				update.SetUpdate(bson.M{"$setOnInsert": bson.M{"response": eventResponse.Response}})
+				update.SetUpsert(true)
+				writes.Add(db.ResponsesCollection, update)
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": event.Id})
+			update.SetUpdate(bson.M{"$unset": bson.M{"responses": ""}})
+			writes.Add(db.EventsCollection, update)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// The index on events.responses.userId is no longer used
+		run.DropIndex(db.EventsCollection, "responses_userId_id_1")
+
+		return nil
+	},
+}
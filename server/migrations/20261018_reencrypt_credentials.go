@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/services/encryption"
+)
+
+// Also used to rotate keys: after adding a new key to ENCRYPTION_KEYS and pointing ENCRYPTION_KEY_ID at it, run
+// this again with `migrate -run 20261018_reencrypt_credentials`. Old keys can be removed from ENCRYPTION_KEYS once
+// it reports that no accounts failed to decrypt
+var reencryptCredentials = Migration{
+	Id:          "20261018_reencrypt_credentials",
+	Description: "Encrypt every user's calendar credentials with the current encryption key",
+	Up: func(run *Run) error {
+		keyring, err := encryption.LoadKeyringFromEnv()
+		if err != nil {
+			return err
+		}
+		run.Logf("Re-encrypting credentials with key %q", keyring.CurrentKeyId())
+
+		totalFailed := 0
+		filter := bson.M{"calendarAccounts": bson.M{"$exists": true}}
+		projection := bson.M{"calendarAccounts": 1}
+
+		err = run.ForEachBatch(db.UsersCollection, filter, projection, func(cursor *mongo.Cursor, writes *Writes) error {
+			var user models.User
+			if err := cursor.Decode(&user); err != nil {
+				run.Logf("Warning: Failed to decode user, skipping: %v", err)
+				return nil
+			}
+
+			needsUpdate := false
+			for calendarAccountKey, account := range user.CalendarAccounts {
+				if err := account.DecryptionError(); err != nil {
+					// Leave the ciphertext alone, it can be recovered by adding its key back to the keyring
+					run.Logf("Warning: Failed to decrypt %s for user %s: %v", calendarAccountKey, user.Id.Hex(), err)
+					totalFailed++
+					continue
+				}
+				if account.NeedsReencryption() {
+					needsUpdate = true
+				}
+			}
+			if !needsUpdate {
+				return nil
+			}
+
+			// Marshalling the calendar accounts encrypts them with the current key
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": user.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"calendarAccounts": user.CalendarAccounts}})
+			writes.Add(db.UsersCollection, update)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		run.Logf("%d accounts could not be decrypted", totalFailed)
+		return nil
+	},
+}
@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+var addEventType = Migration{
+	Id:          "add_event_type",
+	Description: "Set the type of events without one to specific dates",
+	Up: func(run *Run) error {
+		return run.UpdateMany(db.EventsCollection, bson.M{"type": nil}, bson.M{
+			"$set": bson.M{"type": models.SPECIFIC_DATES},
+		})
+	},
+}
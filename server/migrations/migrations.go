@@ -0,0 +1,259 @@
+package migrations
+
+// Migrations are one-off changes to the data in the database, like restructuring documents or creating indexes.
+// Each migration has a unique id and is applied at most once per database: applied migrations are recorded in
+// the migrations collection, so running the migrations only applies the ones that haven't been applied yet.
+//
+// To add a migration, create a file named after its id (date + description, e.g. 20261018_encode_availability)
+// that declares the migration, and append it to All. Migrations should only touch documents that are still in
+// the old format, so that running them against data that is already migrated does nothing.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+)
+
+type Migration struct {
+	Id          string
+	Description string
+
+	// Applies the migration. All writes should go through run so that they are skipped during a dry run
+	Up func(run *Run) error
+}
+
+// Every migration, in the order they are applied
+var All = []Migration{
+	newDateRepresentation,
+	addEventType,
+	addCalendarAccounts,
+	fifteenMinuteIncrements,
+	renameBlindAvailField,
+	multipleCalendarSupport,
+	googleCalendarAuthRename,
+	multipleCalendarSupportGroups,
+	eventResponsesRestructure,
+	optimizeEventIndexes,
+	reencryptCredentials,
+	eventResponsesCollection,
+	encodeAvailability,
+}
+
+const DefaultBatchSize = 500
+
+type Options struct {
+	// Run the migrations without writing anything and without recording them as applied
+	DryRun bool
+
+	// Number of documents to process at a time, defaults to DefaultBatchSize
+	BatchSize int32
+
+	// If set, only run the migration with this id, even if it has already been applied
+	Only string
+}
+
+// Status of a migration in the migrations collection
+type Status string
+
+const (
+	Pending   Status = ""
+	Running   Status = "running"
+	Applied   Status = "applied"
+	Baselined Status = "baselined" // Recorded as applied without running, see Baseline
+)
+
+// A document in the migrations collection
+type record struct {
+	Id        string             `bson:"_id"`
+	Status    Status             `bson:"status"`
+	StartedAt primitive.DateTime `bson:"startedAt,omitempty"`
+	AppliedAt primitive.DateTime `bson:"appliedAt,omitempty"`
+}
+
+// Applies every migration that hasn't been applied yet, in order
+func Apply(opts Options) error {
+	if db.MigrationsCollection == nil {
+		return errors.New("migrations can only be run against mongodb")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	if len(opts.Only) > 0 {
+		migration := find(opts.Only)
+		if migration == nil {
+			return fmt.Errorf("unknown migration %q", opts.Only)
+		}
+		return applyOne(*migration, opts, true)
+	}
+
+	records, err := getRecords()
+	if err != nil {
+		return err
+	}
+
+	// A database that was migrated with the old scripts has data but no records, and would have every
+	// migration applied to it again
+	if len(records) == 0 && !opts.DryRun {
+		count, err := db.EventsCollection.EstimatedDocumentCount(context.Background())
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("the database has data but no applied migrations, record the migrations that have already been run with `migrate -baseline <id>` first")
+		}
+	}
+
+	for _, migration := range All {
+		if records[migration.Id].Status != Pending {
+			continue
+		}
+		if err := applyOne(migration, opts, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records every migration up to and including the migration with the given id as applied, without running them.
+// Used to start tracking migrations on a database that was migrated before migrations were recorded
+func Baseline(throughId string) error {
+	if db.MigrationsCollection == nil {
+		return errors.New("migrations can only be run against mongodb")
+	}
+	if find(throughId) == nil {
+		return fmt.Errorf("unknown migration %q", throughId)
+	}
+
+	for _, migration := range All {
+		_, err := db.MigrationsCollection.UpdateOne(
+			context.Background(),
+			bson.M{"_id": migration.Id},
+			bson.M{"$setOnInsert": record{
+				Id:        migration.Id,
+				Status:    Baselined,
+				AppliedAt: primitive.NewDateTimeFromTime(time.Now()),
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		logger.StdOut.Printf("Recorded migration %s as applied\n", migration.Id)
+
+		if migration.Id == throughId {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Returns the status of every migration, keyed by id
+func GetStatuses() (map[string]Status, error) {
+	if db.MigrationsCollection == nil {
+		return nil, errors.New("migrations can only be run against mongodb")
+	}
+
+	records, err := getRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]Status)
+	for _, migration := range All {
+		statuses[migration.Id] = records[migration.Id].Status
+	}
+
+	return statuses, nil
+}
+
+// Runs the migration and records it as applied. The migration is recorded as running beforehand, so that
+// multiple servers starting at the same time don't apply the same migration
+func applyOne(migration Migration, opts Options, rerun bool) error {
+	startedAt := time.Now()
+	run := &Run{Id: migration.Id, DryRun: opts.DryRun, BatchSize: opts.BatchSize}
+
+	if opts.DryRun {
+		run.Logf("Dry run: %s", migration.Description)
+		if err := migration.Up(run); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.Id, err)
+		}
+		run.Logf("Dry run complete, %d documents would be written", run.written)
+		return nil
+	}
+
+	if rerun {
+		_, err := db.MigrationsCollection.DeleteOne(context.Background(), bson.M{"_id": migration.Id, "status": bson.M{"$ne": Running}})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := db.MigrationsCollection.InsertOne(context.Background(), record{
+		Id:        migration.Id,
+		Status:    Running,
+		StartedAt: primitive.NewDateTimeFromTime(startedAt),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		run.Logf("Skipping, the migration is already running or has been applied")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	run.Logf("Running: %s", migration.Description)
+	if err := migration.Up(run); err != nil {
+		// Remove the record so that the migration is retried next time
+		db.MigrationsCollection.DeleteOne(context.Background(), bson.M{"_id": migration.Id})
+		return fmt.Errorf("migration %s failed: %w", migration.Id, err)
+	}
+
+	_, err = db.MigrationsCollection.UpdateByID(context.Background(), migration.Id, bson.M{
+		"$set": bson.M{"status": Applied, "appliedAt": primitive.NewDateTimeFromTime(time.Now())},
+	})
+	if err != nil {
+		return err
+	}
+	run.Logf("Applied in %v, %d documents written", time.Since(startedAt).Round(time.Millisecond), run.written)
+
+	return nil
+}
+
+// Returns the records in the migrations collection, keyed by migration id
+func getRecords() (map[string]record, error) {
+	cursor, err := db.MigrationsCollection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	if err := cursor.All(context.Background(), &records); err != nil {
+		return nil, err
+	}
+
+	recordsById := make(map[string]record)
+	for _, r := range records {
+		recordsById[r.Id] = r
+	}
+
+	return recordsById, nil
+}
+
+func find(id string) *Migration {
+	for i := range All {
+		if All[i].Id == id {
+			return &All[i]
+		}
+	}
+
+	return nil
+}
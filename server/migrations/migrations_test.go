@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMigrationIdsAreUnique(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, migration := range All {
+		if len(migration.Id) == 0 || migration.Up == nil {
+			t.Errorf("migration %q is missing an id or Up function", migration.Id)
+		}
+		if ids[migration.Id] {
+			t.Errorf("duplicate migration id %q", migration.Id)
+		}
+		ids[migration.Id] = true
+	}
+}
+
+func TestSplitIntoFifteenMinutes(t *testing.T) {
+	start := time.Date(2023, 9, 14, 17, 0, 0, 0, time.UTC)
+	converted := primitive.NewDateTimeFromTime(start.Add(time.Hour))
+
+	availability, changed, err := splitIntoFifteenMinutes(bson.A{start.Format(time.RFC3339), converted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.A{
+		primitive.NewDateTimeFromTime(start),
+		primitive.NewDateTimeFromTime(start.Add(15 * time.Minute)),
+		converted,
+	}
+	if !changed || len(availability) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, availability)
+	}
+	for i := range expected {
+		if availability[i] != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, availability[i])
+		}
+	}
+
+	// Already converted availability is left alone
+	if _, changed, _ := splitIntoFifteenMinutes(bson.A{converted}); changed {
+		t.Error("expected converted availability to be unchanged")
+	}
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var newDateRepresentation = Migration{
+	Id:          "new_date_representation",
+	Description: "Replace the start and end dates/times of events with a list of dates and a duration",
+	Up: func(run *Run) error {
+		filter := bson.M{
+			"$or": bson.A{
+				bson.M{"startDate": bson.M{"$exists": true}},
+				bson.M{"startTime": bson.M{"$exists": true}},
+			},
+			"duration": bson.M{"$exists": false},
+		}
+
+		return run.ForEachBatch(db.EventsCollection, filter, nil, func(cursor *mongo.Cursor, writes *Writes) error {
+			var oldEvent oldDateRepresentationEvent
+			if err := cursor.Decode(&oldEvent); err != nil {
+				run.Logf("Warning: Failed to decode event, skipping: %v", err)
+				return nil
+			}
+
+			dates, duration := oldEvent.datesAndDuration()
+			if dates == nil || duration <= 0 {
+				return nil
+			}
+
+			update := mongo.NewUpdateOneModel()
+			update.SetFilter(bson.M{"_id": oldEvent.Id})
+			update.SetUpdate(bson.M{"$set": bson.M{"dates": dates, "duration": duration}})
+			writes.Add(db.EventsCollection, update)
+			return nil
+		})
+	},
+}
+
+type oldDateRepresentationEvent struct {
+	Id        primitive.ObjectID  `bson:"_id,omitempty"`
+	StartDate *primitive.DateTime `bson:"startDate,omitempty"`
+	EndDate   *primitive.DateTime `bson:"endDate,omitempty"`
+
+	// StartTime and EndTime are UTC hours, dates are an array of utc dates
+	StartTime *float32 `bson:"startTime,omitempty"`
+	EndTime   *float32 `bson:"endTime,omitempty"`
+	Dates     []string `bson:"dates,omitempty"`
+}
+
+// Returns the start of each day of the event and the event's duration in hours
+func (e oldDateRepresentationEvent) datesAndDuration() ([]primitive.DateTime, float32) {
+	var dates []primitive.DateTime
+	var duration float32
+
+	if e.StartDate != nil && e.EndDate != nil {
+		startTime := e.StartDate.Time().UTC().Hour()
+		endTime := e.EndDate.Time().UTC().Hour()
+		duration = float32(endTime - startTime)
+		if duration < 0 {
+			duration += 24
+		}
+
+		curDate := e.StartDate.Time()
+		for curDate.Before(e.EndDate.Time()) {
+			dates = append(dates, primitive.NewDateTimeFromTime(curDate))
+			curDate = curDate.Add(24 * time.Hour)
+		}
+	} else if e.StartTime != nil && e.EndTime != nil {
+		duration = *e.EndTime - *e.StartTime
+		if duration < 0 {
+			duration += 24
+		}
+
+		for _, dateString := range e.Dates {
+			split := strings.Split(dateString, "-")
+			if len(split) != 3 {
+				continue
+			}
+			year, _ := strconv.Atoi(split[0])
+			month, _ := strconv.Atoi(split[1])
+			day, _ := strconv.Atoi(split[2])
+
+			date := time.Date(year, time.Month(month), day, int(*e.StartTime), 0, 0, 0, time.UTC)
+			dates = append(dates, primitive.NewDateTimeFromTime(date))
+		}
+	}
+
+	return dates, duration
+}
@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/logger"
+)
+
+// Run is passed to a migration while it is being applied. Its write methods do nothing during a dry run
+type Run struct {
+	Id        string
+	DryRun    bool
+	BatchSize int32
+
+	// Number of documents written (or that would have been written during a dry run)
+	written int64
+}
+
+// Writes collects the write operations for a batch of documents, see ForEachBatch
+type Writes struct {
+	collections []*mongo.Collection
+	operations  map[*mongo.Collection][]mongo.WriteModel
+}
+
+// Adds write operations to execute on the collection. Collections are written to in the order they are first added
+func (w *Writes) Add(collection *mongo.Collection, operations ...mongo.WriteModel) {
+	if _, ok := w.operations[collection]; !ok {
+		w.collections = append(w.collections, collection)
+	}
+	w.operations[collection] = append(w.operations[collection], operations...)
+}
+
+// Logs a message tagged with the id of the migration
+func (r *Run) Logf(format string, v ...interface{}) {
+	logger.Logger.Info(fmt.Sprintf(format, v...), "migration", r.Id)
+}
+
+// Calls handle with each document in the collection that matches the filter, in batches of BatchSize documents
+// ordered by _id, and executes the writes added by handle after each batch. The filter is evaluated again for each
+// batch, so documents that handle migrates can be excluded by it
+func (r *Run) ForEachBatch(collection *mongo.Collection, filter bson.M, projection bson.M, handle func(cursor *mongo.Cursor, writes *Writes) error) error {
+	var lastId interface{}
+	processed := 0
+
+	for {
+		// Get the next batch of documents
+		batchFilter := filter
+		if lastId != nil {
+			batchFilter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": lastId}}}}
+		}
+		findOptions := options.Find().
+			SetBatchSize(r.BatchSize).
+			SetLimit(int64(r.BatchSize)).
+			SetSort(bson.D{{Key: "_id", Value: 1}})
+		if projection != nil {
+			findOptions.SetProjection(projection)
+		}
+
+		cursor, err := collection.Find(context.Background(), batchFilter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		writes := &Writes{operations: make(map[*mongo.Collection][]mongo.WriteModel)}
+		for cursor.Next(context.Background()) {
+			count++
+			lastId = cursor.Current.Lookup("_id")
+			if err := handle(cursor, writes); err != nil {
+				cursor.Close(context.Background())
+				return err
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			cursor.Close(context.Background())
+			return err
+		}
+		cursor.Close(context.Background())
+		processed += count
+
+		// Execute the writes for the batch
+		written := int64(0)
+		for _, writeCollection := range writes.collections {
+			n, err := r.BulkWrite(writeCollection, writes.operations[writeCollection])
+			if err != nil {
+				return err
+			}
+			written += n
+		}
+		if count > 0 {
+			r.Logf("Processed %d documents in %s, %d written in batch", processed, collection.Name(), written)
+		}
+
+		// Check if we've processed all documents
+		if count < int(r.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// Executes the write operations on the collection, returning the number of documents modified or upserted
+func (r *Run) BulkWrite(collection *mongo.Collection, operations []mongo.WriteModel) (int64, error) {
+	if len(operations) == 0 {
+		return 0, nil
+	}
+
+	if r.DryRun {
+		r.written += int64(len(operations))
+		return int64(len(operations)), nil
+	}
+
+	result, err := collection.BulkWrite(context.Background(), operations)
+	if err != nil {
+		return 0, err
+	}
+
+	written := result.ModifiedCount + result.UpsertedCount
+	r.written += written
+	return written, nil
+}
+
+// Updates every document in the collection that matches the filter
+func (r *Run) UpdateMany(collection *mongo.Collection, filter bson.M, update bson.M) error {
+	if r.DryRun {
+		count, err := collection.CountDocuments(context.Background(), filter)
+		if err != nil {
+			return err
+		}
+		r.written += count
+		r.Logf("Would update %d documents in %s", count, collection.Name())
+		return nil
+	}
+
+	result, err := collection.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	r.written += result.ModifiedCount
+	r.Logf("Updated %d documents in %s", result.ModifiedCount, collection.Name())
+
+	return nil
+}
+
+// Creates the indexes on the collection. Creating an index that already exists with the same options does nothing
+func (r *Run) CreateIndexes(collection *mongo.Collection, indexes ...mongo.IndexModel) error {
+	if r.DryRun {
+		r.Logf("Would create %d indexes on %s", len(indexes), collection.Name())
+		return nil
+	}
+
+	names, err := collection.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		return err
+	}
+	r.Logf("Created indexes %v on %s", names, collection.Name())
+
+	return nil
+}
+
+// Drops the index with the given name from the collection. Failing to drop the index (e.g. because it doesn't
+// exist) is logged instead of failing the migration
+func (r *Run) DropIndex(collection *mongo.Collection, name string) {
+	if r.DryRun {
+		r.Logf("Would drop index %s on %s", name, collection.Name())
+		return
+	}
+
+	if _, err := collection.Indexes().DropOne(context.Background(), name); err != nil {
+		r.Logf("Warning: Failed to drop index %s on %s: %v", name, collection.Name(), err)
+		return
+	}
+	r.Logf("Dropped index %s on %s", name, collection.Name())
+}
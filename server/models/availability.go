@@ -0,0 +1,236 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Availability is stored as a bitset over the event's slot grid instead of an array with one timestamp per
+// available 15 minute slot, which is about an order of magnitude smaller for large events.
+//
+// Bit i of a bitset is set if the response is available for slot i of the grid, where bit i is stored in
+// byte i/8 at position i%8 (least significant bit first).
+
+const SlotDuration = 15 * time.Minute
+
+// The time slots that an event can be responded to: SlotsPerDay slots of SlotDuration starting at each date.
+// Slot i is on Dates[i/SlotsPerDay], SlotDuration*(i%SlotsPerDay) after the start of the date
+type SlotGrid struct {
+	Dates       []primitive.DateTime `json:"dates" bson:"dates"`
+	SlotsPerDay int                  `json:"slotsPerDay" bson:"slotsPerDay"`
+}
+
+// Availability, IfNeeded, and ManualAvailability of a response, encoded as bitsets over Grid
+type EncodedAvailability struct {
+	Grid         SlotGrid `bson:"grid"`
+	Availability []byte   `bson:"availability"`
+	IfNeeded     []byte   `bson:"ifNeeded"`
+
+	// Maps the start date of a day to a bitset over the SlotsPerDay slots starting at that date
+	ManualAvailability map[primitive.DateTime][]byte `bson:"manualAvailability,omitempty"`
+}
+
+// Returns the grid of time slots that responses to the event can be available for
+func (e *Event) SlotGrid() SlotGrid {
+	slotsPerDay := 1
+	if e.Duration != nil && (e.DaysOnly == nil || !*e.DaysOnly) {
+		slotsPerDay = int(math.Round(float64(*e.Duration) * float64(time.Hour/SlotDuration)))
+	}
+
+	return SlotGrid{Dates: e.Dates, SlotsPerDay: slotsPerDay}
+}
+
+// Returns the number of slots in the grid
+func (g SlotGrid) Len() int {
+	return len(g.Dates) * g.SlotsPerDay
+}
+
+// Encodes the given times as a bitset over the grid. Returns false if any of the times isn't the start of a slot,
+// in which case those times are left out of the bitset
+func (g SlotGrid) Encode(times []primitive.DateTime) ([]byte, bool) {
+	return encodeSlots(times, g.Len(), g.index())
+}
+
+// Returns the times of the slots set in the given bitset
+func (g SlotGrid) Decode(bits []byte) []primitive.DateTime {
+	times := make([]primitive.DateTime, 0)
+	for i := 0; i < g.Len() && i/8 < len(bits); i++ {
+		if bits[i/8]&(1<<(i%8)) != 0 {
+			times = append(times, g.slotTime(g.Dates[i/g.SlotsPerDay], i%g.SlotsPerDay))
+		}
+	}
+
+	return times
+}
+
+// Returns the start time of every slot in the grid, in order
+func (g SlotGrid) Times() []primitive.DateTime {
+	times := make([]primitive.DateTime, 0, g.Len())
+	for _, date := range g.Dates {
+		for slot := 0; slot < g.SlotsPerDay; slot++ {
+			times = append(times, g.slotTime(date, slot))
+		}
+	}
+
+	return times
+}
+
+// Returns a map from the time of each slot to its index in the grid
+func (g SlotGrid) index() map[primitive.DateTime]int {
+	index := make(map[primitive.DateTime]int, g.Len())
+	for day, date := range g.Dates {
+		for slot := 0; slot < g.SlotsPerDay; slot++ {
+			index[g.slotTime(date, slot)] = day*g.SlotsPerDay + slot
+		}
+	}
+
+	return index
+}
+
+func (g SlotGrid) slotTime(date primitive.DateTime, slot int) primitive.DateTime {
+	return primitive.NewDateTimeFromTime(date.Time().Add(time.Duration(slot) * SlotDuration))
+}
+
+// Sets the bit of each time's slot in a bitset of the given length, returning false if any time has no slot
+func encodeSlots(times []primitive.DateTime, length int, index map[primitive.DateTime]int) ([]byte, bool) {
+	bits := make([]byte, (length+7)/8)
+	allOnGrid := true
+	for _, t := range times {
+		i, ok := index[t]
+		if !ok {
+			allOnGrid = false
+			continue
+		}
+		bits[i/8] |= 1 << (i % 8)
+	}
+
+	return bits, allOnGrid
+}
+
+// Returns a copy of the response with its availability encoded over the given grid, to be stored in the database.
+// The response is returned unchanged if any of its times are not on the grid
+func (r Response) EncodeAvailability(grid SlotGrid) Response {
+	if r.EncodedAvailability != nil || grid.Len() == 0 {
+		return r
+	}
+
+	availability, ok := grid.Encode(r.Availability)
+	if !ok {
+		return r
+	}
+	ifNeeded, ok := grid.Encode(r.IfNeeded)
+	if !ok {
+		return r
+	}
+
+	encoded := EncodedAvailability{Grid: grid, Availability: availability, IfNeeded: ifNeeded}
+
+	if r.ManualAvailability != nil {
+		encoded.ManualAvailability = make(map[primitive.DateTime][]byte)
+		for date, times := range *r.ManualAvailability {
+			dayGrid := SlotGrid{Dates: []primitive.DateTime{date}, SlotsPerDay: grid.SlotsPerDay}
+			bits, ok := dayGrid.Encode(times)
+			if !ok {
+				return r
+			}
+			encoded.ManualAvailability[date] = bits
+		}
+	}
+
+	r.EncodedAvailability = &encoded
+	r.Availability = nil
+	r.IfNeeded = nil
+	r.ManualAvailability = nil
+	return r
+}
+
+// Replaces the response's encoded availability, if any, with the timestamp arrays it encodes
+func (r *Response) DecodeAvailability() {
+	encoded := r.EncodedAvailability
+	if encoded == nil {
+		return
+	}
+
+	r.Availability = encoded.Grid.Decode(encoded.Availability)
+	r.IfNeeded = encoded.Grid.Decode(encoded.IfNeeded)
+	if encoded.ManualAvailability != nil {
+		manualAvailability := make(map[primitive.DateTime][]primitive.DateTime)
+		for date, bits := range encoded.ManualAvailability {
+			dayGrid := SlotGrid{Dates: []primitive.DateTime{date}, SlotsPerDay: encoded.Grid.SlotsPerDay}
+			manualAvailability[date] = dayGrid.Decode(bits)
+		}
+		r.ManualAvailability = &manualAvailability
+	}
+	r.EncodedAvailability = nil
+}
+
+// Returns the start of the slot that the most of the event's populated responses are available for, and how many
+// are. Ties go to the earliest slot. Returns false if no response is available for any slot of the grid
+func (e *Event) BestTime() (primitive.DateTime, int, bool) {
+	best := e.BestTimes(1)
+	if len(best) == 0 {
+		return 0, 0, false
+	}
+
+	return best[0].Time, best[0].Count, true
+}
+
+// A slot of an event and how many responses are available for it
+type SlotCount struct {
+	Time  primitive.DateTime
+	Count int
+}
+
+// Returns up to n of the slots that the most of the event's populated responses are available for, most available
+// first. Ties go to the earliest slot, and slots that no response is available for aren't returned
+func (e *Event) BestTimes(n int) []SlotCount {
+	grid := e.SlotGrid()
+	index := grid.index()
+	counts := make([]int, grid.Len())
+	for _, eventResponse := range e.ResponsesList {
+		if eventResponse.Response == nil {
+			continue
+		}
+		for _, t := range eventResponse.Response.Availability {
+			if i, ok := index[t]; ok {
+				counts[i]++
+			}
+		}
+	}
+
+	slots := make([]int, 0)
+	for i, count := range counts {
+		if count > 0 {
+			slots = append(slots, i)
+		}
+	}
+	sort.SliceStable(slots, func(a, b int) bool { return counts[slots[a]] > counts[slots[b]] })
+	if len(slots) > n {
+		slots = slots[:n]
+	}
+
+	best := make([]SlotCount, len(slots))
+	for i, slot := range slots {
+		best[i] = SlotCount{grid.slotTime(grid.Dates[slot/grid.SlotsPerDay], slot%grid.SlotsPerDay), counts[slot]}
+	}
+	return best
+}
+
+// Formats a time of the event for emails. Days of the week events are stored on dates in a fixed week, so only the
+// weekday is meaningful
+func (e *Event) FormatTime(t time.Time) string {
+	daysOnly := e.DaysOnly != nil && *e.DaysOnly
+	switch {
+	case e.Type == DOW && daysOnly:
+		return t.Format("Monday")
+	case e.Type == DOW:
+		return t.Format("Monday at 3:04 PM")
+	case daysOnly:
+		return t.Format("Mon, Jan 2")
+	default:
+		return t.Format("Mon, Jan 2 at 3:04 PM")
+	}
+}
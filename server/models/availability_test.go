@@ -0,0 +1,73 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEncodeAvailability(t *testing.T) {
+	day1 := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	duration := float32(2)
+	event := Event{
+		Dates:    []primitive.DateTime{primitive.NewDateTimeFromTime(day1), primitive.NewDateTimeFromTime(day2)},
+		Duration: &duration,
+	}
+	grid := event.SlotGrid()
+	if grid.SlotsPerDay != 8 || grid.Len() != 16 {
+		t.Fatalf("unexpected grid %+v", grid)
+	}
+
+	response := Response{
+		Name: "Alice",
+		Availability: []primitive.DateTime{
+			primitive.NewDateTimeFromTime(day1),
+			primitive.NewDateTimeFromTime(day1.Add(15 * time.Minute)),
+			primitive.NewDateTimeFromTime(day2.Add(105 * time.Minute)),
+		},
+		IfNeeded: []primitive.DateTime{primitive.NewDateTimeFromTime(day2)},
+	}
+
+	encoded := response.EncodeAvailability(grid)
+	if encoded.EncodedAvailability == nil || encoded.Availability != nil {
+		t.Fatal("expected availability to be encoded")
+	}
+	if want := []byte{0b00000011, 0b10000000}; !reflect.DeepEqual(encoded.EncodedAvailability.Availability, want) {
+		t.Errorf("Availability bits = %08b, expected %08b", encoded.EncodedAvailability.Availability, want)
+	}
+
+	// Round trip through BSON like the responses collection does
+	data, err := bson.Marshal(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Response
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.DecodeAvailability()
+
+	if !reflect.DeepEqual(decoded.Availability, response.Availability) || !reflect.DeepEqual(decoded.IfNeeded, response.IfNeeded) {
+		t.Errorf("decoded availability %v, %v, expected %v, %v", decoded.Availability, decoded.IfNeeded, response.Availability, response.IfNeeded)
+	}
+	if decoded.Name != "Alice" || decoded.EncodedAvailability != nil {
+		t.Errorf("unexpected decoded response %+v", decoded)
+	}
+}
+
+func TestEncodeAvailabilityOffGrid(t *testing.T) {
+	day := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	duration := float32(1)
+	event := Event{Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(day)}, Duration: &duration}
+
+	// Times that aren't on the grid can't be encoded, so the response is stored as is
+	response := Response{Availability: []primitive.DateTime{primitive.NewDateTimeFromTime(day.Add(5 * time.Minute))}}
+	encoded := response.EncodeAvailability(event.SlotGrid())
+	if encoded.EncodedAvailability != nil || len(encoded.Availability) != 1 {
+		t.Errorf("expected off grid response to be left unchanged, got %+v", encoded)
+	}
+}
@@ -13,18 +13,28 @@ const (
 	OutlookCalendarType CalendarType = "outlook"
 )
 
-// OAuth2CalendarAuth contains necessary auth info for the user's google calendar account
+// OAuth2CalendarAuth contains necessary auth info for the user's google calendar account.
+// The access and refresh tokens are encrypted when written to the database (see secrets.go)
 type OAuth2CalendarAuth struct {
 	AccessToken           string             `json:"-" bson:"accessToken,omitempty"`
 	AccessTokenExpireDate primitive.DateTime `json:"-" bson:"accessTokenExpireDate,omitempty"`
 	RefreshToken          string             `json:"-" bson:"refreshToken,omitempty"`
 	Scope                 string             `json:"-" bson:"scope,omitempty"`
+
+	// Set if the stored tokens could not be decrypted
+	DecryptionError error `json:"-" bson:"-"`
+	ciphertexts     *oAuth2CalendarAuthFields
 }
 
-// AppleCalendarAuth contains necessary auth info for the user's apple calendar account
+// AppleCalendarAuth contains necessary auth info for the user's apple calendar account.
+// The password is encrypted when written to the database (see secrets.go)
 type AppleCalendarAuth struct {
 	Email    string `json:"-" bson:"email,omitempty"`
 	Password string `json:"-" bson:"password,omitempty"`
+
+	// Set if the stored password could not be decrypted
+	DecryptionError error `json:"-" bson:"-"`
+	ciphertexts     *appleCalendarAuthFields
 }
 
 // CalendarAccount contains info about the user's other signed in calendar accounts
@@ -37,6 +47,40 @@ type CalendarAccount struct {
 	Picture      string                  `json:"picture" bson:"picture,omitempty"`
 	Enabled      *bool                   `json:"enabled" bson:"enabled,omitempty"`
 	SubCalendars *map[string]SubCalendar `json:"subCalendars" bson:"subCalendars,omitempty"`
+
+	// Health of the connection to the calendar provider, nil if the account has never been checked
+	Health *CalendarAccountHealth `json:"health" bson:"health,omitempty"`
+}
+
+// Returns the error encountered while decrypting the account's credentials, if any
+func (account CalendarAccount) DecryptionError() error {
+	if account.OAuth2CalendarAuth != nil && account.OAuth2CalendarAuth.DecryptionError != nil {
+		return account.OAuth2CalendarAuth.DecryptionError
+	}
+	if account.AppleCalendarAuth != nil && account.AppleCalendarAuth.DecryptionError != nil {
+		return account.AppleCalendarAuth.DecryptionError
+	}
+
+	return nil
+}
+
+// CalendarAccountStatus is an enum representing whether we can still fetch events for a calendar account
+type CalendarAccountStatus string
+
+const (
+	CalendarAccountOk          CalendarAccountStatus = "ok"
+	CalendarAccountNeedsReauth CalendarAccountStatus = "needs_reauth" // The user revoked access or the refresh token expired
+	CalendarAccountFailing     CalendarAccountStatus = "failing"      // The provider returned an error that signing in again won't fix
+)
+
+// CalendarAccountHealth contains the last known status of a calendar account's connection
+type CalendarAccountHealth struct {
+	Status    CalendarAccountStatus `json:"status" bson:"status,omitempty"`
+	LastError string                `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	UpdatedAt primitive.DateTime    `json:"updatedAt" bson:"updatedAt,omitempty"`
+
+	// When the user was emailed asking them to reconnect this account, reset once the account is healthy again
+	ReauthEmailSentAt *primitive.DateTime `json:"-" bson:"reauthEmailSentAt,omitempty"`
 }
 
 // SubCalendar represents a calendar within a calendar account
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chat apps that people can use schej from
+type ChatPlatform string
+
+const (
+	SlackPlatform   ChatPlatform = "slack"
+	DiscordPlatform ChatPlatform = "discord"
+)
+
+// A link between a chat user and the schej account their commands act as
+type ChatAccount struct {
+	Id       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Platform ChatPlatform       `json:"platform" bson:"platform"`
+
+	// The Slack workspace of the chat user, and the chat user's id in it. Discord user ids are the same in every
+	// server, so Discord links don't have a team
+	TeamId     string `json:"teamId" bson:"teamId"`
+	ExternalId string `json:"externalId" bson:"externalId"`
+
+	UserId   primitive.ObjectID `json:"userId" bson:"userId"`
+	LinkedAt time.Time          `json:"linkedAt" bson:"linkedAt"`
+}
+
+// A message that a chat bot posted about an event, which is kept up to date as people respond
+type ChatMessage struct {
+	Id       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Platform ChatPlatform       `json:"platform" bson:"platform"`
+	EventId  primitive.ObjectID `json:"eventId" bson:"eventId"`
+
+	// The Discord server, channel and message that the message was posted in
+	TeamId    string `json:"teamId" bson:"teamId"`
+	ChannelId string `json:"channelId" bson:"channelId"`
+	MessageId string `json:"messageId" bson:"messageId"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Settings of a Discord server that the bot was added to, set with /schej-settings
+type DiscordGuild struct {
+	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	GuildId string             `json:"guildId" bson:"guildId"`
+
+	// Channel that events created in the server are posted in, instead of the channel /schej new was run in
+	EventsChannelId string `json:"eventsChannelId,omitempty" bson:"eventsChannelId,omitempty"`
+
+	// Role that's mentioned when an event posted in the server is scheduled
+	PingRoleId string `json:"pingRoleId,omitempty" bson:"pingRoleId,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of activity on an event that daily digests report
+type DigestActivity string
+
+const (
+	ResponseActivity           DigestActivity = "response"
+	SignUpActivity             DigestActivity = "signUp"
+	DeclineActivity            DigestActivity = "decline"            // A group attendee declined
+	ReminderCompletedActivity  DigestActivity = "reminderCompleted"  // A remindee marked that they responded
+	EveryoneRespondedActivity  DigestActivity = "everyoneResponded"  // Every remindee responded
+	ResponsesThresholdActivity DigestActivity = "responsesThreshold" // The event reached sendEmailAfterXResponses
+)
+
+// Returns the type of notification whose preference decides whether the activity goes in the owner's digest
+func (a DigestActivity) NotificationType() NotificationType {
+	switch a {
+	case EveryoneRespondedActivity:
+		return EveryoneRespondedNotification
+	case ResponsesThresholdActivity:
+		return ResponsesThresholdNotification
+	default:
+		return ResponseNotification
+	}
+}
+
+// Activity on one of a user's events, to be sent in their next daily digest
+type DigestItem struct {
+	Id       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	UserId   primitive.ObjectID `json:"userId" bson:"userId"`
+	EventId  primitive.ObjectID `json:"eventId" bson:"eventId"`
+	Activity DigestActivity     `json:"activity" bson:"activity"`
+
+	// Who responded, signed up, declined, or completed a reminder
+	Name string `json:"name" bson:"name,omitempty"`
+
+	// Number of responses when a responsesThreshold activity happened
+	NumResponses int `json:"numResponses" bson:"numResponses,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
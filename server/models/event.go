@@ -29,6 +29,9 @@ type Response struct {
 	// Mapping from the start date of a day to the available times for that day
 	ManualAvailability *map[primitive.DateTime][]primitive.DateTime `json:"manualAvailability" bson:"manualAvailability,omitempty"`
 
+	// Compact encoding of the availability fields above, stored in the database instead of them when possible
+	EncodedAvailability *EncodedAvailability `json:"-" bson:"encodedAvailability,omitempty"`
+
 	// Calendar availability variables for Availability Groups feature
 	UseCalendarAvailability *bool                `json:"useCalendarAvailability" bson:"useCalendarAvailability,omitempty"`
 	EnabledCalendars        *map[string][]string `json:"enabledCalendars" bson:"enabledCalendars,omitempty"` // Maps email to an array of sub calendar ids
@@ -40,6 +43,49 @@ type Remindee struct {
 	Email     string   `json:"email" bson:"email,omitempty"`
 	TaskIds   []string `json:"-" bson:"taskIds,omitempty"` // Task IDs of the scheduled emails
 	Responded *bool    `json:"responded" bson:"responded,omitempty"`
+
+	// When the remindee was added, nil for remindees added before it was stored
+	AddedAt *primitive.DateTime `json:"-" bson:"addedAt,omitempty"`
+}
+
+// When and how an event's remindees are reminded
+type ReminderSettings struct {
+	// Hours after the remindee is added that each reminder is sent, or hours before the deadline if there is one.
+	// Defaults to DefaultReminderOffsets
+	Offsets  []float64           `json:"offsets" bson:"offsets,omitempty"`
+	Deadline *primitive.DateTime `json:"deadline" bson:"deadline,omitempty"`
+
+	// Included in every reminder
+	Message string `json:"message" bson:"message,omitempty"`
+
+	// Reminders that would be sent during quiet hours in the remindee's timezone are sent when they end instead
+	QuietHours *QuietHours `json:"quietHours" bson:"quietHours,omitempty"`
+}
+
+// Hours of the day from Start until End, which wraps around midnight if End is before Start
+type QuietHours struct {
+	Start int `json:"start" bson:"start"`
+	End   int `json:"end" bson:"end"`
+}
+
+var DefaultReminderOffsets = []float64{0, 24, 72}
+
+func (s *ReminderSettings) GetOffsets() []float64 {
+	if s == nil || len(s.Offsets) == 0 {
+		return DefaultReminderOffsets
+	}
+	return s.Offsets
+}
+
+// Returns whether the hour of the day is within the quiet hours
+func (q *QuietHours) Contains(hour int) bool {
+	if q == nil {
+		return false
+	}
+	if q.Start <= q.End {
+		return hour >= q.Start && hour < q.End
+	}
+	return hour >= q.Start || hour < q.End
 }
 
 type Attendee struct {
@@ -68,9 +114,11 @@ type SignUpResponse struct {
 	User   *User              `json:"user" bson:",omitempty"`
 }
 
+// A user's response to an event, stored in the responses collection keyed by (eventId, userId)
 type EventResponse struct {
-	UserId   string    `json:"userId" bson:"userId"`
-	Response *Response `json:"response" bson:"response"`
+	EventId  primitive.ObjectID `json:"-" bson:"eventId,omitempty"`
+	UserId   string             `json:"userId" bson:"userId"`
+	Response *Response          `json:"response" bson:"response"`
 }
 
 // Representation of an Event in the mongoDB database
@@ -104,8 +152,8 @@ type Event struct {
 	// Whether to only poll for days, not times
 	DaysOnly *bool `json:"daysOnly" bson:"daysOnly,omitempty"`
 
-	// Availability responses - new format for indexed queries
-	ResponsesList []EventResponse `json:"-" bson:"responses"`
+	// Availability responses - stored in the responses collection, populated by db.PopulateEventResponses
+	ResponsesList []EventResponse `json:"-" bson:"-"`
 	// Availability responses - old format for backward compatibility
 	ResponsesMap map[string]*Response `json:"responses" bson:"responsesMap"`
 
@@ -114,7 +162,8 @@ type Event struct {
 	CalendarEventId string         `json:"calendarEventId" bson:"calendarEventId,omitempty"`
 
 	// Remindees
-	Remindees *[]Remindee `json:"remindees" bson:"remindees,omitempty"`
+	Remindees        *[]Remindee       `json:"remindees" bson:"remindees,omitempty"`
+	ReminderSettings *ReminderSettings `json:"reminderSettings" bson:"reminderSettings,omitempty"`
 
 	// Attendees for an availability group
 	Attendees *[]Attendee `json:"attendees" bson:"attendees,omitempty"`
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JobStatus string
+
+const (
+	JobPending  JobStatus = "pending"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// A background job run by the local scheduler, see the jobs package
+type Job struct {
+	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Type    string             `json:"type" bson:"type"`
+	Payload bson.M             `json:"payload" bson:"payload"`
+	Status  JobStatus          `json:"status" bson:"status"`
+
+	// The job isn't run before RunAt, which is moved back after each failed attempt
+	RunAt       time.Time `json:"runAt" bson:"runAt"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+	MaxAttempts int       `json:"maxAttempts" bson:"maxAttempts"`
+	LastError   string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+
+	// The server instance running the job, which no other instance runs until LeasedUntil has passed
+	LeasedBy    string     `json:"leasedBy,omitempty" bson:"leasedBy,omitempty"`
+	LeasedUntil *time.Time `json:"leasedUntil,omitempty" bson:"leasedUntil,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+
+	// Set once the job is done, failed, or canceled. Finished jobs are deleted a week later
+	FinishedAt *time.Time `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of notification emails, which can each be turned off or (for event owners) batched into a daily digest
+type NotificationType string
+
+const (
+	GroupInviteNotification        NotificationType = "groupInvite"        // Invites to availability groups, and who else was added
+	ResponseNotification           NotificationType = "response"           // Someone responded to your event
+	ResponsesThresholdNotification NotificationType = "responsesThreshold" // Your event reached sendEmailAfterXResponses
+	EveryoneRespondedNotification  NotificationType = "everyoneResponded"  // Every remindee responded to your event
+	ReminderNotification           NotificationType = "reminder"           // Reminders to respond to an event
+	CalendarNotification           NotificationType = "calendar"           // A calendar account needs to be reconnected
+	DigestNotification             NotificationType = "digest"             // The daily digest of the types in digest mode
+)
+
+var NotificationTypes = []NotificationType{
+	GroupInviteNotification,
+	ResponseNotification,
+	ResponsesThresholdNotification,
+	EveryoneRespondedNotification,
+	ReminderNotification,
+	CalendarNotification,
+	DigestNotification,
+}
+
+// Returns whether notifications of this type can be batched into a daily digest. Invites, reminders, and calendar
+// notifications are only useful right away
+func (t NotificationType) SupportsDigest() bool {
+	return t == ResponseNotification || t == ResponsesThresholdNotification || t == EveryoneRespondedNotification
+}
+
+type NotificationMode string
+
+const (
+	NotifyInstant NotificationMode = "instant"
+	NotifyDigest  NotificationMode = "digest"
+	NotifyOff     NotificationMode = "off"
+)
+
+// Which notification emails someone gets
+type NotificationPreferences struct {
+	// Mode of each type of notification, instant if it isn't set
+	Types map[NotificationType]NotificationMode `json:"types" bson:"types,omitempty"`
+
+	// Events that no notifications are sent about
+	MutedEventIds []primitive.ObjectID `json:"mutedEventIds" bson:"mutedEventIds,omitempty"`
+
+	// Local hour (0-23, in the user's timezone) that the daily digest is sent at, DefaultDigestHour if it isn't set
+	DigestHour *int `json:"digestHour" bson:"digestHour,omitempty"`
+}
+
+const DefaultDigestHour = 8
+
+func (p *NotificationPreferences) GetDigestHour() int {
+	if p == nil || p.DigestHour == nil {
+		return DefaultDigestHour
+	}
+	return *p.DigestHour
+}
+
+// Returns how a notification of the given type about the given event (zero if it isn't about an event) is sent
+func (p *NotificationPreferences) Mode(notificationType NotificationType, eventId primitive.ObjectID) NotificationMode {
+	if p == nil {
+		return NotifyInstant
+	}
+	if !eventId.IsZero() && slices.Contains(p.MutedEventIds, eventId) {
+		return NotifyOff
+	}
+	if mode, ok := p.Types[notificationType]; ok {
+		return mode
+	}
+
+	return NotifyInstant
+}
+
+// Notification preferences of an email address that doesn't belong to a user, e.g. a remindee that unsubscribed
+type EmailPreferences struct {
+	Id          primitive.ObjectID      `json:"_id" bson:"_id,omitempty"`
+	Email       string                  `json:"email" bson:"email"`
+	Preferences NotificationPreferences `json:"preferences" bson:"preferences"`
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/services/encryption"
+)
+
+// Calendar credentials are encrypted with the encryption service whenever they are marshalled to BSON and decrypted
+// whenever they are unmarshalled, so the rest of the code only ever sees plaintext.
+//
+// If a value can't be decrypted (e.g. its key was removed from the keyring), DecryptionError is set instead of
+// failing the whole decode, and the original ciphertexts are written back unchanged so that saving the user
+// doesn't destroy credentials that could still be recovered by restoring the key.
+
+// Types with the same fields as the auth structs, but without the BSON hooks
+type oAuth2CalendarAuthFields OAuth2CalendarAuth
+type appleCalendarAuthFields AppleCalendarAuth
+
+func (auth OAuth2CalendarAuth) MarshalBSON() ([]byte, error) {
+	fields := oAuth2CalendarAuthFields(auth)
+
+	if auth.DecryptionError != nil && auth.ciphertexts != nil {
+		fields.AccessToken = auth.ciphertexts.AccessToken
+		fields.RefreshToken = auth.ciphertexts.RefreshToken
+	} else {
+		var err error
+		if fields.AccessToken, err = encryptSecret(auth.AccessToken); err != nil {
+			return nil, err
+		}
+		if fields.RefreshToken, err = encryptSecret(auth.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return bson.Marshal(fields)
+}
+
+func (auth *OAuth2CalendarAuth) UnmarshalBSON(data []byte) error {
+	var fields oAuth2CalendarAuthFields
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	ciphertexts := fields
+
+	// Tokens stored before encryption was added are plaintext
+	accessToken, accessTokenErr := decryptSecret(fields.AccessToken, plaintextFallback)
+	refreshToken, refreshTokenErr := decryptSecret(fields.RefreshToken, plaintextFallback)
+
+	*auth = OAuth2CalendarAuth(fields)
+	auth.ciphertexts = &ciphertexts
+	if err := firstError(accessTokenErr, refreshTokenErr); err != nil {
+		auth.AccessToken = ""
+		auth.RefreshToken = ""
+		auth.DecryptionError = err
+		return nil
+	}
+
+	auth.AccessToken = accessToken
+	auth.RefreshToken = refreshToken
+	return nil
+}
+
+func (auth AppleCalendarAuth) MarshalBSON() ([]byte, error) {
+	fields := appleCalendarAuthFields(auth)
+
+	if auth.DecryptionError != nil && auth.ciphertexts != nil {
+		fields.Password = auth.ciphertexts.Password
+	} else {
+		var err error
+		if fields.Password, err = encryptSecret(auth.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	return bson.Marshal(fields)
+}
+
+func (auth *AppleCalendarAuth) UnmarshalBSON(data []byte) error {
+	var fields appleCalendarAuthFields
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	ciphertexts := fields
+
+	// Passwords stored before envelope encryption was added use the legacy AES-CFB scheme
+	password, err := decryptSecret(fields.Password, encryption.DecryptLegacy)
+
+	*auth = AppleCalendarAuth(fields)
+	auth.ciphertexts = &ciphertexts
+	if err != nil {
+		auth.Password = ""
+		auth.DecryptionError = err
+		return nil
+	}
+
+	auth.Password = password
+	return nil
+}
+
+// Returns whether any of the account's credentials are not encrypted with the current key
+func (account CalendarAccount) NeedsReencryption() bool {
+	if auth := account.OAuth2CalendarAuth; auth != nil {
+		if auth.DecryptionError != nil {
+			return false
+		}
+		return auth.ciphertexts == nil || encryption.NeedsRotation(auth.ciphertexts.AccessToken) || encryption.NeedsRotation(auth.ciphertexts.RefreshToken)
+	}
+	if auth := account.AppleCalendarAuth; auth != nil {
+		if auth.DecryptionError != nil {
+			return false
+		}
+		return auth.ciphertexts == nil || encryption.NeedsRotation(auth.ciphertexts.Password)
+	}
+
+	return false
+}
+
+func encryptSecret(plaintext string) (string, error) {
+	if len(plaintext) == 0 {
+		return "", nil
+	}
+
+	ciphertext, err := encryption.Encrypt(plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt calendar credentials: %w", err)
+	}
+
+	return ciphertext, nil
+}
+
+// Decrypts the given value, using legacyDecrypt for values that were stored before envelope encryption
+func decryptSecret(value string, legacyDecrypt func(string) (string, error)) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+	if !encryption.IsEncrypted(value) {
+		return legacyDecrypt(value)
+	}
+
+	plaintext, err := encryption.Decrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt calendar credentials: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func plaintextFallback(value string) (string, error) {
+	return value, nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -30,6 +30,12 @@ type User struct {
 
 	// Calendar options
 	CalendarOptions *CalendarOptions `json:"calendarOptions" bson:"calendarOptions,omitempty"`
+
+	// Which notification emails the user gets
+	NotificationPreferences *NotificationPreferences `json:"notificationPreferences" bson:"notificationPreferences,omitempty"`
+
+	// When the user's next daily digest is scheduled for, nil if there's no activity waiting to be sent
+	DigestScheduledFor *primitive.DateTime `json:"-" bson:"digestScheduledFor,omitempty"`
 }
 
 // Declare the possible types of TokenOrigin
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of activity that webhooks are notified of
+type WebhookEventType string
+
+const (
+	EventCreatedWebhook     WebhookEventType = "event.created"
+	EventUpdatedWebhook     WebhookEventType = "event.updated"
+	EventScheduledWebhook   WebhookEventType = "event.scheduled"
+	ResponseCreatedWebhook  WebhookEventType = "response.created"
+	ResponseUpdatedWebhook  WebhookEventType = "response.updated"
+	ResponseDeletedWebhook  WebhookEventType = "response.deleted"
+	SignUpChangedWebhook    WebhookEventType = "signup.changed"
+	AttendeeDeclinedWebhook WebhookEventType = "group.attendee_declined"
+)
+
+var WebhookEventTypes = []WebhookEventType{
+	EventCreatedWebhook,
+	EventUpdatedWebhook,
+	EventScheduledWebhook,
+	ResponseCreatedWebhook,
+	ResponseUpdatedWebhook,
+	ResponseDeletedWebhook,
+	SignUpChangedWebhook,
+	AttendeeDeclinedWebhook,
+}
+
+// A url that is posted to when there's activity on one of its owner's events, or on a single event
+type Webhook struct {
+	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	OwnerId primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+
+	// Nil if the webhook is for all of the owner's events
+	EventId *primitive.ObjectID `json:"eventId" bson:"eventId,omitempty"`
+
+	Url string `json:"url" bson:"url"`
+
+	// Signs deliveries, only returned when the webhook is created
+	Secret string `json:"-" bson:"secret"`
+
+	// The types of activity delivered, every type if empty
+	Events []WebhookEventType `json:"events" bson:"events,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Returns whether activity of the given type on the given event is delivered to the webhook
+func (w *Webhook) Matches(eventType WebhookEventType, eventId primitive.ObjectID) bool {
+	if w.EventId != nil && *w.EventId != eventId {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// A post of activity to a webhook, kept in the webhook's delivery log
+type WebhookDelivery struct {
+	Id        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	WebhookId primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	EventId   primitive.ObjectID `json:"eventId" bson:"eventId"`
+	Type      WebhookEventType   `json:"type" bson:"type"`
+
+	// The JSON body that is posted, which is signed as is
+	Body string `json:"body" bson:"body"`
+
+	Status   WebhookDeliveryStatus `json:"status" bson:"status"`
+	Attempts int                   `json:"attempts" bson:"attempts"`
+
+	// Result of the last attempt. The status code is 0 if the request failed before there was a response
+	ResponseStatus int    `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	LastError      string `json:"lastError,omitempty" bson:"lastError,omitempty"`
+
+	// Set if the delivery is a replay of an earlier one
+	ReplayOf *primitive.ObjectID `json:"replayOf,omitempty" bson:"replayOf,omitempty"`
+
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+}
@@ -1,5 +1,20 @@
 package responses
 
+import "schej.it/server/models"
+
 type Error struct {
-	Error interface{} `json:"error" binding:"required"`
+	Error   interface{} `json:"error" binding:"required"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Responses to an event, with availability encoded as bitsets over Grid (base64 in JSON)
+type EncodedResponses struct {
+	Grid      models.SlotGrid            `json:"grid"`
+	Responses map[string]EncodedResponse `json:"responses"`
+}
+
+type EncodedResponse struct {
+	*models.Response
+	Availability []byte `json:"availability"`
+	IfNeeded     []byte `json:"ifNeeded"`
 }
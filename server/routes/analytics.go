@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/slackbot"
 )
@@ -13,7 +15,7 @@ import (
 func InitAnalytics(router *gin.RouterGroup) {
 	authRouter := router.Group("/analytics")
 
-	authRouter.POST("/scanned-poster", scannedPoster)
+	authRouter.POST("/scanned-poster", middleware.RateLimit("analytics", config.Get().RateLimits.Analytics, middleware.ByIP), scannedPoster)
 }
 
 // @Summary Notifies us when poster QR code has been scanned
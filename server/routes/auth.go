@@ -2,17 +2,14 @@
 package routes
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"schej.it/server/db"
-	"schej.it/server/logger"
+	"schej.it/server/errs"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/services/auth"
@@ -51,9 +48,17 @@ func signIn(c *gin.Context) {
 		return
 	}
 
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), payload.CalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), payload.CalendarType)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	user := signInHelper(c, tokens, models.WEB, payload.CalendarType, *payload.TimezoneOffset)
+	user, err := signInHelper(c, tokens, models.WEB, payload.CalendarType, *payload.TimezoneOffset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, user)
 }
@@ -81,7 +86,7 @@ func signInMobile(c *gin.Context) {
 		return
 	}
 
-	signInHelper(
+	_, err := signInHelper(
 		c,
 		auth.TokenResponse{
 			AccessToken:  payload.AccessToken,
@@ -94,12 +99,16 @@ func signInMobile(c *gin.Context) {
 		payload.CalendarType,
 		payload.TimezoneOffset,
 	)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 // Helper function to sign user in with the given parameters from the google oauth route
-func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.TokenOriginType, calendarType models.CalendarType, timezoneOffset int) models.User {
+func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.TokenOriginType, calendarType models.CalendarType, timezoneOffset int) (models.User, error) {
 	// Get access token expire time
 	accessTokenExpireDate := utils.GetAccessTokenExpireDate(token.ExpiresIn)
 
@@ -114,14 +123,20 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 	var email, firstName, lastName, picture string
 	if calendarType == models.GoogleCalendarType {
 		// Get user info from JWT
-		claims := utils.ParseJWT(token.IdToken)
+		claims, err := utils.ParseJWT(token.IdToken)
+		if err != nil {
+			return models.User{}, errs.InvalidCredentials.Wrap(err)
+		}
 		email, _ = claims.GetStr("email")
 		firstName, _ = claims.GetStr("given_name")
 		lastName, _ = claims.GetStr("family_name")
 		picture, _ = claims.GetStr("picture")
 	} else if calendarType == models.OutlookCalendarType {
 		// Get user info from microsoft graph
-		userInfo := microsoftgraph.GetUserInfo(nil, &calendarAuth)
+		userInfo, err := microsoftgraph.GetUserInfo(c.Request.Context(), nil, &calendarAuth)
+		if err != nil {
+			return models.User{}, err
+		}
 		email = userInfo.Email
 		firstName = userInfo.FirstName
 		lastName = userInfo.LastName
@@ -154,11 +169,14 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 	calendarAccountKey := utils.GetCalendarAccountKey(email, calendarType)
 
 	var userId primitive.ObjectID
-	findResult := db.UsersCollection.FindOne(context.Background(), bson.M{"email": email})
+	user, err := db.GetUserByEmail(c.Request.Context(), email)
+	if err != nil {
+		return models.User{}, err
+	}
 	// If user doesn't exist, create a new user
-	if findResult.Err() == mongo.ErrNoDocuments {
+	if user == nil {
 		// Fetch subcalendars
-		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 		if err == nil {
 			calendarAccount.SubCalendars = &subCalendars
 		}
@@ -169,19 +187,14 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		}
 
 		// Create user
-		res, err := db.UsersCollection.InsertOne(context.Background(), userData)
-		if err != nil {
-			logger.StdErr.Panicln(err)
+		if err := db.Users.Insert(c.Request.Context(), &userData); err != nil {
+			return models.User{}, err
 		}
 
-		userId = res.InsertedID.(primitive.ObjectID)
+		userId = userData.Id
 
 		slackbot.SendTextMessage(fmt.Sprintf(":wave: %s %s (%s) has joined schej.it!", firstName, lastName, email))
 	} else {
-		var user models.User
-		if err := findResult.Decode(&user); err != nil {
-			logger.StdErr.Panicln(err)
-		}
 		userId = user.Id
 
 		// If user has custom name, do not override first name and last name
@@ -194,7 +207,7 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		if oldCalendarAccount, ok := user.CalendarAccounts[calendarAccountKey]; ok && oldCalendarAccount.SubCalendars != nil {
 			calendarAccount.SubCalendars = oldCalendarAccount.SubCalendars
 		} else {
-			subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+			subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 			if err == nil {
 				calendarAccount.SubCalendars = &subCalendars
 			}
@@ -205,20 +218,16 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		userData.CalendarAccounts[calendarAccountKey] = calendarAccount
 
 		// Update user if exists
-		_, err := db.UsersCollection.UpdateByID(
-			context.Background(),
-			userId,
-			bson.M{"$set": userData},
-		)
-		if err != nil {
-			logger.StdErr.Panicln(err)
+		userData.Id = userId
+		if err := db.Users.Update(c.Request.Context(), &userData); err != nil {
+			return models.User{}, err
 		}
 	}
 
-	if exists, userId := listmonk.DoesUserExist(email); exists {
-		listmonk.AddUserToListmonk(email, firstName, lastName, picture, userId)
+	if exists, userId := listmonk.DoesUserExist(c.Request.Context(), email); exists {
+		listmonk.AddUserToListmonk(c.Request.Context(), email, firstName, lastName, picture, userId)
 	} else {
-		listmonk.AddUserToListmonk(email, firstName, lastName, picture, nil)
+		listmonk.AddUserToListmonk(c.Request.Context(), email, firstName, lastName, picture, nil)
 	}
 
 	// Set session variables
@@ -227,7 +236,7 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 	session.Save()
 
 	userData.Id = userId
-	return userData
+	return userData, nil
 }
 
 // @Summary Signs user out
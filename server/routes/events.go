@@ -5,37 +5,49 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/gin-gonic/gin/render"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
 	"schej.it/server/db"
+	"schej.it/server/discord_bot"
 	"schej.it/server/errs"
 	"schej.it/server/logger"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/responses"
 	"schej.it/server/services/calendar"
-	"schej.it/server/services/gcloud"
-	"schej.it/server/services/listmonk"
+	"schej.it/server/services/notifications"
+	"schej.it/server/services/reminders"
+	"schej.it/server/services/webhooks"
 	"schej.it/server/slackbot"
 	"schej.it/server/utils"
+	"schej.it/server/validation"
 )
 
 func InitEvents(router *gin.RouterGroup) {
 	eventRouter := router.Group("/events")
+	rateLimits := config.Get().RateLimits
 
-	eventRouter.POST("", createEvent)
+	eventRouter.POST("", middleware.RateLimit("create-event", rateLimits.CreateEvent, middleware.ByUser), createEvent)
 	eventRouter.PUT("/:eventId", editEvent)
 	eventRouter.GET("/:eventId", getEvent)
 	eventRouter.GET("/:eventId/responses", getResponses)
-	eventRouter.POST("/:eventId/response", updateEventResponse)
+	eventRouter.POST("/:eventId/response",
+		middleware.RateLimit("respond", rateLimits.Respond, middleware.ByIP),
+		middleware.RateLimit("respond-to-event", rateLimits.RespondToEvent, middleware.ByEvent),
+		updateEventResponse,
+	)
 	eventRouter.DELETE("/:eventId/response", deleteEventResponse)
 	eventRouter.POST("/:eventId/responded", userResponded)
 	eventRouter.POST("/:eventId/decline", middleware.AuthRequired(), declineInvite)
+	eventRouter.POST("/:eventId/nudge", middleware.AuthRequired(), nudge)
+	eventRouter.POST("/:eventId/schedule", middleware.AuthRequired(), scheduleEvent)
 	eventRouter.GET("/:eventId/calendar-availabilities", middleware.AuthRequired(), getCalendarAvailabilities)
 	eventRouter.DELETE("/:eventId", middleware.AuthRequired(), deleteEvent)
 	eventRouter.POST("/:eventId/duplicate", middleware.AuthRequired(), duplicateEvent)
@@ -45,7 +57,7 @@ func InitEvents(router *gin.RouterGroup) {
 // @Tags events
 // @Accept json
 // @Produce json
-// @Param payload body object{name=string,duration=float32,dates=[]string,type=models.EventType,isSignUpForm=bool,signUpBlocks=[]models.SignUpBlock,notificationsEnabled=bool,blindAvailabilityEnabled=bool,daysOnly=bool,remindees=[]string,sendEmailAfterXResponses=int,when2meetHref=string,attendees=[]string} true "Object containing info about the event to create"
+// @Param payload body object{name=string,duration=float32,dates=[]string,type=models.EventType,isSignUpForm=bool,signUpBlocks=[]models.SignUpBlock,notificationsEnabled=bool,blindAvailabilityEnabled=bool,daysOnly=bool,remindees=[]string,reminderSettings=models.ReminderSettings,sendEmailAfterXResponses=int,when2meetHref=string,attendees=[]string} true "Object containing info about the event to create"
 // @Success 201 {object} object{eventId=string}
 // @Router /events [post]
 func createEvent(c *gin.Context) {
@@ -61,14 +73,15 @@ func createEvent(c *gin.Context) {
 		SignUpBlocks *[]models.SignUpBlock `json:"signUpBlocks"`
 
 		// Only for events (not groups)
-		StartOnMonday            *bool    `json:"startOnMonday"`
-		NotificationsEnabled     *bool    `json:"notificationsEnabled"`
-		BlindAvailabilityEnabled *bool    `json:"blindAvailabilityEnabled"`
-		DaysOnly                 *bool    `json:"daysOnly"`
-		Remindees                []string `json:"remindees"`
-		SendEmailAfterXResponses *int     `json:"sendEmailAfterXResponses"`
-		When2meetHref            *string  `json:"when2meetHref"`
-		CollectEmails            *bool    `json:"collectEmails"`
+		StartOnMonday            *bool                    `json:"startOnMonday"`
+		NotificationsEnabled     *bool                    `json:"notificationsEnabled"`
+		BlindAvailabilityEnabled *bool                    `json:"blindAvailabilityEnabled"`
+		DaysOnly                 *bool                    `json:"daysOnly"`
+		Remindees                []string                 `json:"remindees"`
+		ReminderSettings         *models.ReminderSettings `json:"reminderSettings"`
+		SendEmailAfterXResponses *int                     `json:"sendEmailAfterXResponses"`
+		When2meetHref            *string                  `json:"when2meetHref"`
+		CollectEmails            *bool                    `json:"collectEmails"`
 
 		// Only for availability groups
 		Attendees []string `json:"attendees"`
@@ -77,6 +90,21 @@ func createEvent(c *gin.Context) {
 		fmt.Println(err)
 		return
 	}
+	if err := validation.ValidateEvent(validation.Event{
+		Name:                     payload.Name,
+		Duration:                 payload.Duration,
+		Dates:                    payload.Dates,
+		Type:                     payload.Type,
+		IsSignUpForm:             payload.IsSignUpForm,
+		DaysOnly:                 payload.DaysOnly,
+		Remindees:                payload.Remindees,
+		ReminderSettings:         payload.ReminderSettings,
+		Attendees:                payload.Attendees,
+		SendEmailAfterXResponses: payload.SendEmailAfterXResponses,
+	}); err != nil {
+		c.Error(err)
+		return
+	}
 	session := sessions.Default(c)
 
 	// If user logged in, set owner id to their user id, otherwise set owner id to nil
@@ -85,12 +113,37 @@ func createEvent(c *gin.Context) {
 	var user *models.User
 	var ownerId primitive.ObjectID
 	if signedIn {
-		ownerId = utils.StringToObjectID(userId)
-		user = db.GetUserById(userId)
+		var err error
+		ownerId, err = utils.StringToObjectID(userId)
+		if err != nil {
+			c.Error(errs.NotSignedIn.Wrap(err))
+			return
+		}
+		user, err = db.GetUserById(c.Request.Context(), userId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if user == nil {
+			c.Error(errs.UserDoesNotExist)
+			return
+		}
 	} else {
 		ownerId = primitive.NilObjectID
 	}
 
+	// Check the email caps before anything is sent, counting a reminder for each remindee at every offset
+	rateLimits := config.Get().RateLimits
+	reminderEmails := len(payload.Remindees) * len(payload.ReminderSettings.GetOffsets())
+	if err := reserveEmails(c, "reminder", ownerId, reminderEmails, rateLimits.ReminderEmailsPerDay); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := reserveEmails(c, "invite", ownerId, len(payload.Attendees), rateLimits.InviteEmailsPerDay); err != nil {
+		c.Error(err)
+		return
+	}
+
 	// Construct event object
 	event := models.Event{
 		Id:                       primitive.NewObjectID(),
@@ -105,35 +158,22 @@ func createEvent(c *gin.Context) {
 		BlindAvailabilityEnabled: payload.BlindAvailabilityEnabled,
 		DaysOnly:                 payload.DaysOnly,
 		SendEmailAfterXResponses: payload.SendEmailAfterXResponses,
+		ReminderSettings:         payload.ReminderSettings,
 		When2meetHref:            payload.When2meetHref,
 		CollectEmails:            payload.CollectEmails,
 		Type:                     payload.Type,
-		ResponsesList:            make([]models.EventResponse, 0),
 		SignUpResponses:          make(map[string]*models.SignUpResponse),
 	}
 
-	// Generate short id
-	shortId := db.GenerateShortEventId(event.Id)
-	event.ShortId = &shortId
-
-	// Schedule reminder emails if remindees array is not empty
+	// Add remindees, whose reminder emails are scheduled once the event is inserted
 	if len(payload.Remindees) > 0 {
-		// Determine owner name
-		var ownerName string
-		if signedIn {
-			ownerName = user.FirstName
-		} else {
-			ownerName = "Somebody"
-		}
-
-		// Schedule email reminders for each of the remindees' emails
+		now := primitive.NewDateTimeFromTime(time.Now())
 		remindees := make([]models.Remindee, 0)
 		for _, email := range payload.Remindees {
-			taskIds := gcloud.CreateEmailTask(email, ownerName, payload.Name, event.GetId())
 			remindees = append(remindees, models.Remindee{
 				Email:     email,
-				TaskIds:   taskIds,
 				Responded: utils.FalsePtr(),
+				AddedAt:   &now,
 			})
 		}
 
@@ -166,38 +206,60 @@ func createEvent(c *gin.Context) {
 			attendees = append(attendees, models.Attendee{Email: user.Email, Declined: utils.FalsePtr()})
 		}
 
-		// Add attendees and send email
-		if len(payload.Attendees) > 0 {
-			// Determine owner name
-			var ownerName string
-			if signedIn {
-				ownerName = user.FirstName
-			} else {
-				ownerName = "Somebody"
-			}
-
-			// Add attendees to attendees array and send invite emails
-			availabilityGroupInviteEmailId := 9
-			for _, email := range payload.Attendees {
-				listmonk.SendEmailAddSubscriberIfNotExist(email, availabilityGroupInviteEmailId, bson.M{
-					"ownerName": ownerName,
-					"groupName": event.Name,
-					"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
-				})
-				attendees = append(attendees, models.Attendee{Email: email, Declined: utils.FalsePtr()})
-			}
-
+		// Add attendees, who are sent invite emails once the event is inserted
+		for _, email := range payload.Attendees {
+			attendees = append(attendees, models.Attendee{Email: email, Declined: utils.FalsePtr()})
 		}
 
 		event.Attendees = &attendees
 	}
 
 	// Insert event
-	result, err := db.EventsCollection.InsertOne(context.Background(), event)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.InsertEventWithShortId(c.Request.Context(), &event); err != nil {
+		c.Error(err)
+		return
+	}
+	insertedId := event.Id.Hex()
+
+	// Schedule email reminders for each of the remindees' emails
+	if event.Remindees != nil {
+		for i := range *event.Remindees {
+			remindee := &(*event.Remindees)[i]
+			taskIds, err := reminders.Schedule(c.Request.Context(), &event, user, *remindee)
+			remindee.TaskIds = taskIds
+			if err != nil {
+				// Don't leave behind an event with some of its reminders
+				for _, scheduled := range (*event.Remindees)[:i+1] {
+					reminders.Cancel(c.Request.Context(), scheduled.TaskIds)
+				}
+				if deleteErr := db.Events.Delete(c.Request.Context(), event.Id, event.OwnerId); deleteErr != nil {
+					logger.FromContext(c.Request.Context()).Error("Failed to delete event", "eventId", insertedId, "error", deleteErr)
+				}
+				c.Error(err)
+				return
+			}
+		}
+		if err := db.Events.Update(c.Request.Context(), &event); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// Send invite emails to the group's attendees
+	if payload.Type == models.GROUP && len(payload.Attendees) > 0 {
+		ownerName := "Somebody"
+		if signedIn {
+			ownerName = user.FirstName
+		}
+		for _, email := range payload.Attendees {
+			notifications.Send(c.Request.Context(), email, notifications.GroupInvite{
+				EventId:   event.Id,
+				OwnerName: ownerName,
+				GroupName: event.Name,
+				GroupUrl:  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+			})
+		}
 	}
-	insertedId := result.InsertedID.(primitive.ObjectID).Hex()
 
 	// Send slackbot message
 	var creator string
@@ -207,6 +269,7 @@ func createEvent(c *gin.Context) {
 		creator = "Guest :face_with_open_eyes_and_hand_over_mouth:"
 	}
 	slackbot.SendEventCreatedMessage(insertedId, creator, event)
+	webhooks.Dispatch(c.Request.Context(), &event, models.EventCreatedWebhook, nil)
 
 	c.JSON(http.StatusCreated, gin.H{"eventId": insertedId, "shortId": event.ShortId})
 }
@@ -215,7 +278,7 @@ func createEvent(c *gin.Context) {
 // @Tags events
 // @Produce json
 // @Param eventId path string true "Event ID"
-// @Param payload body object{name=string,description=string,duration=float32,dates=[]string,type=models.EventType,signUpBlocks=[]models.SignUpBlock,notificationsEnabled=bool,blindAvailabilityEnabled=bool,daysOnly=bool,remindees=[]string,sendEmailAfterXResponses=int,attendees=[]string} true "Object containing info about the event to update"
+// @Param payload body object{name=string,description=string,duration=float32,dates=[]string,type=models.EventType,signUpBlocks=[]models.SignUpBlock,notificationsEnabled=bool,blindAvailabilityEnabled=bool,daysOnly=bool,remindees=[]string,reminderSettings=models.ReminderSettings,sendEmailAfterXResponses=int,attendees=[]string} true "Object containing info about the event to update"
 // @Success 200
 // @Router /events/{eventId} [put]
 func editEvent(c *gin.Context) {
@@ -233,13 +296,14 @@ func editEvent(c *gin.Context) {
 		SignUpBlocks *[]models.SignUpBlock `json:"signUpBlocks"`
 
 		// Only for events (not groups)
-		StartOnMonday            *bool    `json:"startOnMonday"`
-		NotificationsEnabled     *bool    `json:"notificationsEnabled"`
-		BlindAvailabilityEnabled *bool    `json:"blindAvailabilityEnabled"`
-		DaysOnly                 *bool    `json:"daysOnly"`
-		Remindees                []string `json:"remindees"`
-		SendEmailAfterXResponses *int     `json:"sendEmailAfterXResponses"`
-		CollectEmails            *bool    `json:"collectEmails"`
+		StartOnMonday            *bool                    `json:"startOnMonday"`
+		NotificationsEnabled     *bool                    `json:"notificationsEnabled"`
+		BlindAvailabilityEnabled *bool                    `json:"blindAvailabilityEnabled"`
+		DaysOnly                 *bool                    `json:"daysOnly"`
+		Remindees                []string                 `json:"remindees"`
+		ReminderSettings         *models.ReminderSettings `json:"reminderSettings"`
+		SendEmailAfterXResponses *int                     `json:"sendEmailAfterXResponses"`
+		CollectEmails            *bool                    `json:"collectEmails"`
 
 		// Only for availability groups
 		Attendees []string `json:"attendees"`
@@ -249,9 +313,13 @@ func editEvent(c *gin.Context) {
 	}
 
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
 		return
 	}
 
@@ -261,7 +329,11 @@ func editEvent(c *gin.Context) {
 	userId, signedIn := userIdInterface.(string)
 	var ownerId primitive.ObjectID
 	if signedIn {
-		ownerId = utils.StringToObjectID(userId)
+		ownerId, err = utils.StringToObjectID(userId)
+		if err != nil {
+			c.Error(errs.NotSignedIn.Wrap(err))
+			return
+		}
 	} else {
 		ownerId = primitive.NilObjectID
 	}
@@ -269,11 +341,28 @@ func editEvent(c *gin.Context) {
 	// If event has an owner id, check if user has permissions to edit event
 	if event.OwnerId != primitive.NilObjectID {
 		if event.OwnerId != ownerId {
-			c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+			c.Error(errs.UserNotEventOwner)
 			return
 		}
 	}
 
+	if err := validation.ValidateEvent(validation.Event{
+		Name:                     payload.Name,
+		Description:              payload.Description,
+		Duration:                 payload.Duration,
+		Dates:                    payload.Dates,
+		Type:                     payload.Type,
+		IsSignUpForm:             event.IsSignUpForm,
+		DaysOnly:                 payload.DaysOnly,
+		Remindees:                payload.Remindees,
+		ReminderSettings:         payload.ReminderSettings,
+		Attendees:                payload.Attendees,
+		SendEmailAfterXResponses: payload.SendEmailAfterXResponses,
+	}); err != nil {
+		c.Error(err)
+		return
+	}
+
 	// Update event
 	event.Name = payload.Name
 	event.Description = payload.Description
@@ -287,41 +376,60 @@ func editEvent(c *gin.Context) {
 	event.SendEmailAfterXResponses = payload.SendEmailAfterXResponses
 	event.CollectEmails = payload.CollectEmails
 	event.Type = payload.Type
+	reminderSettingsChanged := !reflect.DeepEqual(event.ReminderSettings, payload.ReminderSettings)
+	event.ReminderSettings = payload.ReminderSettings
 
 	// Update remindees
 	if event.Type == models.DOW || event.Type == models.SPECIFIC_DATES {
 		origRemindees := utils.Coalesce(event.Remindees)
 		updatedRemindees := make([]models.Remindee, 0)
 		added, removed, kept := utils.FindAddedRemovedKept(payload.Remindees, utils.Map(origRemindees, func(r models.Remindee) string { return r.Email }))
+		reminderEmails := len(added) * len(event.ReminderSettings.GetOffsets())
+		if err := reserveEmails(c, "reminder", event.OwnerId, reminderEmails, config.Get().RateLimits.ReminderEmailsPerDay); err != nil {
+			c.Error(err)
+			return
+		}
 
-		// Determine owner name
-		var ownerName string
-		if event.OwnerId == primitive.NilObjectID {
-			ownerName = "Somebody"
-		} else {
-			owner := db.GetUserById(event.OwnerId.Hex())
-			ownerName = owner.FirstName
+		var owner *models.User
+		if event.OwnerId != primitive.NilObjectID {
+			owner, err = db.GetUserById(c.Request.Context(), event.OwnerId.Hex())
+			if err != nil {
+				c.Error(err)
+				return
+			}
 		}
 
 		for _, keptEmail := range kept {
 			updatedRemindees = append(updatedRemindees, origRemindees[keptEmail.Index])
 		}
 
+		// Reminders that haven't been sent to the kept remindees follow the new settings
+		if reminderSettingsChanged {
+			event.Remindees = &updatedRemindees
+			if err := reminders.Reschedule(c.Request.Context(), event, owner); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+
+		now := primitive.NewDateTimeFromTime(time.Now())
 		for _, addedEmail := range added {
-			// Schedule email tasks
-			taskIds := gcloud.CreateEmailTask(addedEmail.Value, ownerName, event.Name, event.GetId())
-			updatedRemindees = append(updatedRemindees, models.Remindee{
+			// Schedule reminder emails
+			remindee := models.Remindee{
 				Email:     addedEmail.Value,
-				TaskIds:   taskIds,
 				Responded: utils.FalsePtr(),
-			})
+				AddedAt:   &now,
+			}
+			remindee.TaskIds, err = reminders.Schedule(c.Request.Context(), event, owner, remindee)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			updatedRemindees = append(updatedRemindees, remindee)
 		}
 
 		for _, removedEmail := range removed {
-			// Delete email tasks
-			for _, taskId := range origRemindees[removedEmail.Index].TaskIds {
-				gcloud.DeleteEmailTask(taskId)
-			}
+			reminders.Cancel(c.Request.Context(), origRemindees[removedEmail.Index].TaskIds)
 		}
 
 		event.Remindees = &updatedRemindees
@@ -333,11 +441,29 @@ func editEvent(c *gin.Context) {
 		updatedAttendees := make([]models.Attendee, 0)
 		added, removed, kept := utils.FindAddedRemovedKept(payload.Attendees, utils.Map(origAttendees, func(a models.Attendee) string { return a.Email }))
 
+		// Kept attendees are emailed about the added ones
+		inviteEmails := len(added)
+		if len(added) > 0 {
+			inviteEmails += len(kept)
+		}
+		if err := reserveEmails(c, "invite", event.OwnerId, inviteEmails, config.Get().RateLimits.InviteEmailsPerDay); err != nil {
+			c.Error(err)
+			return
+		}
+
 		// Determine owner name
 		var ownerName string
 		var owner *models.User
 		if event.OwnerId != primitive.NilObjectID {
-			owner = db.GetUserById(event.OwnerId.Hex())
+			owner, err = db.GetUserById(c.Request.Context(), event.OwnerId.Hex())
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if owner == nil {
+				c.Error(errs.UserDoesNotExist)
+				return
+			}
 			ownerName = owner.FirstName
 
 			// Keep owner in attendees array
@@ -352,14 +478,15 @@ func editEvent(c *gin.Context) {
 		for _, removedEmail := range removed {
 			// Only delete response if it isn't the owner of the group
 			if removedEmail.Value != utils.Coalesce(owner).Email {
-				removedUser := db.GetUserByEmail(removedEmail.Value)
+				removedUser, err := db.GetUserByEmail(c.Request.Context(), removedEmail.Value)
+				if err != nil {
+					c.Error(err)
+					return
+				}
 				if removedUser != nil {
-					// Remove response from array
-					for i := range event.ResponsesList {
-						if event.ResponsesList[i].UserId == removedUser.Id.Hex() {
-							event.ResponsesList = append(event.ResponsesList[:i], event.ResponsesList[i+1:]...)
-							break
-						}
+					if err := db.Responses.Delete(c.Request.Context(), event.Id, removedUser.Id.Hex()); err != nil {
+						c.Error(err)
+						return
 					}
 				}
 			}
@@ -371,11 +498,11 @@ func editEvent(c *gin.Context) {
 
 		for _, addedEmail := range added {
 			// Send invite email
-			availabilityGroupInviteEmailId := 9
-			listmonk.SendEmailAddSubscriberIfNotExist(addedEmail.Value, availabilityGroupInviteEmailId, bson.M{
-				"ownerName": ownerName,
-				"groupName": event.Name,
-				"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+			notifications.Send(c.Request.Context(), addedEmail.Value, notifications.GroupInvite{
+				EventId:   event.Id,
+				OwnerName: ownerName,
+				GroupName: event.Name,
+				GroupUrl:  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
 			})
 			updatedAttendees = append(updatedAttendees, models.Attendee{
 				Email:    addedEmail.Value,
@@ -386,14 +513,14 @@ func editEvent(c *gin.Context) {
 		// Send group update emails
 		if len(added) > 0 {
 			emails := utils.Map(added, func(a utils.ElementWithIndex[string]) string { return a.Value })
-			addedAttendeeEmailId := 11
 
 			for _, keptEmail := range kept {
-				listmonk.SendEmailAddSubscriberIfNotExist(keptEmail.Value, addedAttendeeEmailId, bson.M{
-					"ownerName": ownerName,
-					"groupName": event.Name,
-					"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
-					"emails":    emails,
+				notifications.Send(c.Request.Context(), keptEmail.Value, notifications.AddedAttendees{
+					EventId:   event.Id,
+					OwnerName: ownerName,
+					GroupName: event.Name,
+					GroupUrl:  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+					Emails:    emails,
 				})
 			}
 		}
@@ -402,19 +529,12 @@ func editEvent(c *gin.Context) {
 	}
 
 	// Update event object
-	_, err := db.EventsCollection.UpdateOne(
-		context.Background(),
-		bson.M{
-			"_id": event.Id,
-		},
-		bson.M{
-			"$set": event,
-		},
-	)
-
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
 	}
+	webhooks.Dispatch(c.Request.Context(), event, models.EventUpdatedWebhook, nil)
+	discord_bot.EventUpdated(c.Request.Context(), event.Id)
 
 	c.Status(http.StatusOK)
 }
@@ -427,10 +547,17 @@ func editEvent(c *gin.Context) {
 // @Router /events/{eventId} [get]
 func getEvent(c *gin.Context) {
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
-
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
+		return
+	}
+	if err := db.PopulateEventResponses(c.Request.Context(), event); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -442,7 +569,11 @@ func getEvent(c *gin.Context) {
 
 	// Populate user fields
 	for userId, response := range responsesMap {
-		user := db.GetUserById(userId)
+		user, err := db.GetUserById(c.Request.Context(), userId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 		if user == nil {
 			if len(response.Name) == 0 {
 				// User was deleted
@@ -469,7 +600,11 @@ func getEvent(c *gin.Context) {
 
 	// Populate sign up form fields
 	for userId, response := range event.SignUpResponses {
-		user := db.GetUserById(userId)
+		user, err := db.GetUserById(c.Request.Context(), userId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
 		if user == nil {
 			if len(response.Name) == 0 {
 				// User was deleted
@@ -493,9 +628,14 @@ func getEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, event)
 }
 
+// Media type that clients can accept to get responses with availability encoded as bitsets over the event's slot grid
+const availabilityBitmapMediaType = "application/vnd.schej.availability-bitmap+json"
+
 // @Summary Gets responses for an event, filtering availability to be within the date ranges
+// @Description If the Accept header prefers application/vnd.schej.availability-bitmap+json, availability and ifNeeded are returned as base64 encoded bitsets over the returned slot grid
 // @Tags events
 // @Produce json
+// @Produce application/vnd.schej.availability-bitmap+json
 // @Param eventId path string true "Event ID"
 // @Param timeMin query string true "Lower bound for start time to filter availability by"
 // @Param timeMax query string true "Upper bound for end time to filter availability by"
@@ -513,9 +653,17 @@ func getResponses(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
+		return
+	}
+	if err := db.PopulateEventResponses(c.Request.Context(), event); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -550,6 +698,26 @@ func getResponses(c *gin.Context) {
 		responsesMap[userId] = response
 	}
 
+	c.Header("Vary", "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, availabilityBitmapMediaType) == availabilityBitmapMediaType {
+		// Times that are no longer on the grid (e.g. the event's dates were edited) can't be displayed, so they're left out
+		grid := event.SlotGrid()
+		encodedResponses := responses.EncodedResponses{Grid: grid, Responses: make(map[string]responses.EncodedResponse)}
+		for userId, response := range responsesMap {
+			availability, _ := grid.Encode(response.Availability)
+			ifNeeded, _ := grid.Encode(response.IfNeeded)
+			encodedResponses.Responses[userId] = responses.EncodedResponse{
+				Response:     response,
+				Availability: availability,
+				IfNeeded:     ifNeeded,
+			}
+		}
+
+		c.Header("Content-Type", availabilityBitmapMediaType)
+		c.Render(http.StatusOK, render.JSON{Data: encodedResponses})
+		return
+	}
+
 	c.JSON(http.StatusOK, responsesMap)
 }
 
@@ -585,15 +753,36 @@ func updateEventResponse(c *gin.Context) {
 	}
 	session := sessions.Default(c)
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
+		return
+	}
+	if err := db.PopulateEventResponses(c.Request.Context(), event); err != nil {
+		c.Error(err)
 		return
 	}
 
 	var userIdString string
 	var userHasResponded bool
+	var webhookType models.WebhookEventType
+	var webhookData interface{}
 	if !utils.Coalesce(event.IsSignUpForm) {
+		if err := validation.ValidateResponse(event, validation.Response{
+			Guest:        *payload.Guest,
+			Name:         payload.Name,
+			Email:        payload.Email,
+			Availability: payload.Availability,
+			IfNeeded:     payload.IfNeeded,
+		}); err != nil {
+			c.Error(err)
+			return
+		}
+
 		// Populate response differently if guest vs signed in user
 		var response models.Response
 		if *payload.Guest {
@@ -608,12 +797,15 @@ func updateEventResponse(c *gin.Context) {
 		} else {
 			userIdInterface := session.Get("userId")
 			if userIdInterface == nil {
-				c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.NotSignedIn})
-				c.Abort()
+				c.Error(errs.NotSignedIn)
 				return
 			}
 			userIdString = userIdInterface.(string)
-			userId := utils.StringToObjectID(userIdString)
+			userId, err := utils.StringToObjectID(userIdString)
+			if err != nil {
+				c.Error(errs.NotSignedIn.Wrap(err))
+				return
+			}
 
 			response = models.Response{
 				UserId:                  userId,
@@ -625,7 +817,11 @@ func updateEventResponse(c *gin.Context) {
 			}
 
 			if event.Type == models.GROUP {
-				user := db.GetUserById(userIdString)
+				user, err := db.GetUserById(c.Request.Context(), userIdString)
+				if err != nil {
+					c.Error(err)
+					return
+				}
 
 				// Set declined to false (in case user declined group in the past)
 				if user != nil {
@@ -687,6 +883,24 @@ func updateEventResponse(c *gin.Context) {
 				Response: &response,
 			})
 		}
+		webhookType = models.ResponseCreatedWebhook
+		if userHasResponded {
+			webhookType = models.ResponseUpdatedWebhook
+		}
+		webhookData = webhooks.Response{
+			UserId:       userIdString,
+			Name:         response.Name,
+			Email:        response.Email,
+			Guest:        *payload.Guest,
+			Availability: response.Availability,
+			IfNeeded:     response.IfNeeded,
+		}
+
+		encodedResponse := response.EncodeAvailability(event.SlotGrid())
+		if err := db.Responses.Upsert(c.Request.Context(), event.Id, userIdString, &encodedResponse); err != nil {
+			c.Error(err)
+			return
+		}
 	} else {
 		var response models.SignUpResponse
 		var userIdString string
@@ -702,15 +916,19 @@ func updateEventResponse(c *gin.Context) {
 		} else {
 			userIdInterface := session.Get("userId")
 			if userIdInterface == nil {
-				c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.NotSignedIn})
-				c.Abort()
+				c.Error(errs.NotSignedIn)
 				return
 			}
 			userIdString = userIdInterface.(string)
+			userId, err := utils.StringToObjectID(userIdString)
+			if err != nil {
+				c.Error(errs.NotSignedIn.Wrap(err))
+				return
+			}
 
 			response = models.SignUpResponse{
 				SignUpBlockIds: payload.SignUpBlockIds,
-				UserId:         utils.StringToObjectID(userIdString),
+				UserId:         userId,
 			}
 		}
 
@@ -722,20 +940,29 @@ func updateEventResponse(c *gin.Context) {
 			event.SignUpResponses = make(map[string]*models.SignUpResponse)
 		}
 		event.SignUpResponses[userIdString] = &response
+
+		webhookType = models.SignUpChangedWebhook
+		webhookData = webhooks.SignUp{
+			UserId:         userIdString,
+			Name:           response.Name,
+			Email:          response.Email,
+			SignUpBlockIds: response.SignUpBlockIds,
+		}
 	}
 
+	// Emails are sent in the background after the response is written, so they mustn't be cancelled with the request
+	backgroundCtx := context.WithoutCancel(c.Request.Context())
+	log := logger.FromContext(backgroundCtx)
+
 	// Send notification emails
 	if (utils.Coalesce(event.NotificationsEnabled) || event.Type == models.GROUP) && !userHasResponded && userIdString != event.OwnerId.Hex() {
 		// Send email asynchronously
-		go func() {
-			// Recover from panics
-			defer func() {
-				if err := recover(); err != nil {
-					logger.StdErr.Println(err)
-				}
-			}()
-
-			creator := db.GetUserById(event.OwnerId.Hex())
+		utils.RunInBackground(func() {
+			creator, err := db.GetUserById(backgroundCtx, event.OwnerId.Hex())
+			if err != nil {
+				log.Error("Failed to get event creator", "error", err)
+				return
+			}
 			if creator == nil {
 				return
 			}
@@ -744,28 +971,36 @@ func updateEventResponse(c *gin.Context) {
 			if *payload.Guest {
 				respondentName = payload.Name
 			} else {
-				respondent := db.GetUserById(userIdString)
+				respondent, err := db.GetUserById(backgroundCtx, userIdString)
+				if err != nil {
+					log.Error("Failed to get respondent", "error", err)
+					return
+				}
+				if respondent == nil {
+					return
+				}
 				respondentName = fmt.Sprintf("%s %s", respondent.FirstName, respondent.LastName)
 			}
 
 			if event.Type == models.GROUP {
-				someoneRespondedEmailId := 13
-				listmonk.SendEmail(creator.Email, someoneRespondedEmailId, bson.M{
-					"groupName":      event.Name,
-					"ownerName":      creator.FirstName,
-					"respondentName": respondentName,
-					"groupUrl":       fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+				notifications.Send(backgroundCtx, creator.Email, notifications.SomeoneRespondedGroup{
+					EventId:        event.Id,
+					GroupName:      event.Name,
+					OwnerName:      creator.FirstName,
+					RespondentName: respondentName,
+					GroupUrl:       fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
 				})
 			} else {
-				someoneRespondedEmailId := 10
-				listmonk.SendEmail(creator.Email, someoneRespondedEmailId, bson.M{
-					"eventName":      event.Name,
-					"ownerName":      creator.FirstName,
-					"respondentName": respondentName,
-					"eventUrl":       fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+				notifications.Send(backgroundCtx, creator.Email, notifications.SomeoneResponded{
+					EventId:        event.Id,
+					EventName:      event.Name,
+					OwnerName:      creator.FirstName,
+					RespondentName: respondentName,
+					EventUrl:       fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+					IsSignUp:       utils.Coalesce(event.IsSignUpForm),
 				})
 			}
-		}()
+		})
 	}
 
 	// Send email after X responses
@@ -775,38 +1010,33 @@ func updateEventResponse(c *gin.Context) {
 		*event.SendEmailAfterXResponses = -1
 
 		// Send email asynchronously
-		go func() {
-			// Recover from panics
-			defer func() {
-				if err := recover(); err != nil {
-					logger.StdErr.Println(err)
-				}
-			}()
-
-			creator := db.GetUserById(event.OwnerId.Hex())
+		utils.RunInBackground(func() {
+			creator, err := db.GetUserById(backgroundCtx, event.OwnerId.Hex())
+			if err != nil {
+				log.Error("Failed to get event creator", "error", err)
+				return
+			}
 			if creator == nil {
 				return
 			}
 
-			sendEmailAfterXResponsesEmailId := 14
-			listmonk.SendEmail(creator.Email, sendEmailAfterXResponsesEmailId, bson.M{
-				"eventName":    event.Name,
-				"ownerName":    creator.FirstName,
-				"eventUrl":     fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
-				"numResponses": len(event.ResponsesList),
+			notifications.Send(backgroundCtx, creator.Email, notifications.ResponsesThreshold{
+				EventId:      event.Id,
+				EventName:    event.Name,
+				OwnerName:    creator.FirstName,
+				EventUrl:     fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+				NumResponses: len(event.ResponsesList),
 			})
-		}()
+		})
 	}
 
 	// Update event in mongodb
-	_, err := db.EventsCollection.UpdateByID(
-		context.Background(),
-		event.Id,
-		bson.M{"$set": event},
-	)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
 	}
+	webhooks.Dispatch(c.Request.Context(), event, webhookType, webhookData)
+	discord_bot.EventUpdated(c.Request.Context(), event.Id)
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -830,9 +1060,13 @@ func deleteEventResponse(c *gin.Context) {
 	}
 	session := sessions.Default(c)
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
 		return
 	}
 
@@ -840,45 +1074,42 @@ func deleteEventResponse(c *gin.Context) {
 		if utils.Coalesce(event.IsSignUpForm) {
 			delete(event.SignUpResponses, payload.Name)
 		} else {
-			// Remove response from array
-			for i := range event.ResponsesList {
-				if event.ResponsesList[i].Response.Name == payload.Name {
-					event.ResponsesList = append(event.ResponsesList[:i], event.ResponsesList[i+1:]...)
-					break
-				}
+			// Guest responses are keyed by the guest's name
+			if err := db.Responses.Delete(c.Request.Context(), event.Id, payload.Name); err != nil {
+				c.Error(err)
+				return
 			}
 		}
 	} else {
 		userIdInterface := session.Get("userId")
 		if userIdInterface == nil {
-			c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.NotSignedIn})
-			c.Abort()
+			c.Error(errs.NotSignedIn)
 			return
 		}
 		userIdString := userIdInterface.(string)
 
 		// Don't allow user to delete availability of other users if they aren't the owner of the event
 		if payload.UserId != userIdString && event.OwnerId.Hex() != userIdString {
-			c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
-			c.Abort()
+			c.Error(errs.UserNotEventOwner)
 			return
 		}
 
 		if utils.Coalesce(event.IsSignUpForm) {
 			delete(event.SignUpResponses, payload.UserId)
 		} else {
-			// Remove response from array
-			for i := range event.ResponsesList {
-				if event.ResponsesList[i].UserId == payload.UserId {
-					event.ResponsesList = append(event.ResponsesList[:i], event.ResponsesList[i+1:]...)
-					break
-				}
+			if err := db.Responses.Delete(c.Request.Context(), event.Id, payload.UserId); err != nil {
+				c.Error(err)
+				return
 			}
 		}
 
 		// If this event is a Group, also make the attendee "leave the group" by setting "declined" to true
 		if event.Type == models.GROUP {
-			user := db.GetUserById(userIdString)
+			user, err := db.GetUserById(c.Request.Context(), userIdString)
+			if err != nil {
+				c.Error(err)
+				return
+			}
 			if user != nil {
 				for i, attendee := range utils.Coalesce(event.Attendees) {
 					if strings.EqualFold(attendee.Email, user.Email) {
@@ -891,17 +1122,28 @@ func deleteEventResponse(c *gin.Context) {
 	}
 
 	// Update responses in mongodb
-	_, err := db.EventsCollection.UpdateByID(
-		context.Background(),
-		event.Id,
-		bson.M{
-			"$set": event,
-		},
-	)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
 	}
 
+	deletedUserId := payload.UserId
+	if *payload.Guest {
+		deletedUserId = payload.Name
+	}
+	if utils.Coalesce(event.IsSignUpForm) {
+		webhooks.Dispatch(c.Request.Context(), event, models.SignUpChangedWebhook, webhooks.SignUp{
+			UserId:         deletedUserId,
+			SignUpBlockIds: []primitive.ObjectID{},
+		})
+	} else {
+		webhooks.Dispatch(c.Request.Context(), event, models.ResponseDeletedWebhook, webhooks.Response{
+			UserId: deletedUserId,
+			Guest:  *payload.Guest,
+		})
+	}
+	discord_bot.EventUpdated(c.Request.Context(), event.Id)
+
 	c.JSON(http.StatusOK, gin.H{})
 }
 
@@ -923,22 +1165,26 @@ func userResponded(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
 		return
 	}
 
 	// Update responded boolean for the given email
 	if event.Remindees == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.RemindeeEmailNotFound})
+		c.Error(errs.RemindeeEmailNotFound)
 		return
 	}
 	index := utils.Find(*event.Remindees, func(r models.Remindee) bool {
 		return r.Email == payload.Email
 	})
 	if index == -1 {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.RemindeeEmailNotFound})
+		c.Error(errs.RemindeeEmailNotFound)
 		return
 	}
 	if *(*event.Remindees)[index].Responded {
@@ -948,15 +1194,23 @@ func userResponded(c *gin.Context) {
 	}
 	(*event.Remindees)[index].Responded = utils.TruePtr()
 
-	// Delete the reminder email tasks
-	for _, taskId := range (*event.Remindees)[index].TaskIds {
-		gcloud.DeleteEmailTask(taskId)
-	}
+	// Cancel the reminder emails that haven't been sent
+	reminders.Cancel(c.Request.Context(), (*event.Remindees)[index].TaskIds)
 
 	// Update event in database
-	db.EventsCollection.UpdateByID(context.Background(), event.Id, bson.M{
-		"$set": event,
-	})
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Owners that get responses in a digest see who marked that they responded
+	if err := notifications.RecordActivity(c.Request.Context(), event.OwnerId, models.DigestItem{
+		EventId:  event.Id,
+		Activity: models.ReminderCompletedActivity,
+		Name:     payload.Email,
+	}); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to record activity for digest", "error", err)
+	}
 
 	// Email owner of event if all remindees have responded
 	everyoneResponded := true
@@ -968,23 +1222,23 @@ func userResponded(c *gin.Context) {
 	}
 	if everyoneResponded {
 		// Get owner
-		owner := db.GetUserById(event.OwnerId.Hex())
-
-		// Get event url
-		var baseUrl string
-		if utils.IsRelease() {
-			baseUrl = "https://schej.it"
-		} else {
-			baseUrl = "http://localhost:8080"
+		owner, err := db.GetUserById(c.Request.Context(), event.OwnerId.Hex())
+		if err != nil {
+			c.Error(err)
+			return
 		}
-		eventUrl := fmt.Sprintf("%s/e/%s", baseUrl, eventId)
 
-		// Send email
-		everyoneRespondedEmailTemplateId := 8
-		listmonk.SendEmail(owner.Email, everyoneRespondedEmailTemplateId, bson.M{
-			"eventName": event.Name,
-			"eventUrl":  eventUrl,
-		})
+		if owner != nil {
+			// Get event url
+			eventUrl := fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), eventId)
+
+			// Send email
+			notifications.Send(c.Request.Context(), owner.Email, notifications.EveryoneResponded{
+				EventId:   event.Id,
+				EventName: event.Name,
+				EventUrl:  eventUrl,
+			})
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
@@ -1000,15 +1254,19 @@ func userResponded(c *gin.Context) {
 func declineInvite(c *gin.Context) {
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventById(eventId)
+	event, err := db.GetEventById(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
 		return
 	}
 
 	// Ensure that event is a group
 	if event.Type != models.GROUP {
-		c.JSON(http.StatusBadRequest, responses.Error{Error: errs.EventNotGroup})
+		c.Error(errs.EventNotGroup)
 		return
 	}
 
@@ -1022,7 +1280,7 @@ func declineInvite(c *gin.Context) {
 	})
 	if index == -1 {
 		// User not in attendees array
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.AttendeeEmailNotFound})
+		c.Error(errs.AttendeeEmailNotFound)
 		return
 	}
 
@@ -1030,16 +1288,130 @@ func declineInvite(c *gin.Context) {
 	(*event.Attendees)[index].Declined = utils.TruePtr()
 
 	// Update event in database
-	_, err := db.EventsCollection.UpdateByID(context.Background(), event.Id, bson.M{
-		"$set": event,
-	})
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
+	}
+	webhooks.Dispatch(c.Request.Context(), event, models.AttendeeDeclinedWebhook, webhooks.Attendee{Email: user.Email})
+
+	// Owners that get responses in a digest see who declined
+	name := strings.TrimSpace(fmt.Sprintf("%s %s", user.FirstName, user.LastName))
+	if len(name) == 0 {
+		name = user.Email
+	}
+	if err := notifications.RecordActivity(c.Request.Context(), event.OwnerId, models.DigestItem{
+		EventId:  event.Id,
+		Activity: models.DeclineActivity,
+		Name:     name,
+	}); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to record activity for digest", "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+// @Summary Email the people who haven't responded to the event
+// @Description Emails the remindees that haven't responded, the group attendees that haven't responded or declined, and if includeUnavailable, the respondents that aren't available for the best time. Each person is nudged about the event at most once per cooldown. People that were nudged within the cooldown are skipped, people that turned off or muted the emails are not sent one, and people whose email failed to send can be nudged again right away
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param payload body object{includeUnavailable=bool} true "Object containing whether to nudge respondents that aren't available for the best time"
+// @Success 200 {object} reminders.NudgeResult
+// @Router /events/{eventId}/nudge [post]
+func nudge(c *gin.Context) {
+	payload := struct {
+		IncludeUnavailable bool `json:"includeUnavailable"`
+	}{}
+	if err := c.Bind(&payload); err != nil {
+		return
+	}
+
+	event, err := db.GetEventByEitherId(c.Request.Context(), c.Param("eventId"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if event == nil {
+		c.Error(errs.EventNotFound)
+		return
+	}
+
+	// Only the owner can nudge
+	owner := utils.GetAuthUser(c)
+	if event.OwnerId != owner.Id {
+		c.Error(errs.UserNotEventOwner)
+		return
+	}
+
+	if err := db.PopulateEventResponses(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
+	}
+	result, err := reminders.NudgeAll(c.Request.Context(), event, owner, payload.IncludeUnavailable)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// @Summary Set the time the event is scheduled for
+// @Description Only the owner can schedule the event. Scheduling it again replaces the scheduled time
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param payload body object{startDate=primitive.DateTime,endDate=primitive.DateTime,calendarEventId=string} true "Object containing the scheduled time, and the id of the calendar event that was created for it, if any"
+// @Success 200 {object} models.CalendarEvent
+// @Router /events/{eventId}/schedule [post]
+func scheduleEvent(c *gin.Context) {
+	payload := struct {
+		StartDate       *primitive.DateTime `json:"startDate" binding:"required"`
+		EndDate         *primitive.DateTime `json:"endDate" binding:"required"`
+		CalendarEventId string              `json:"calendarEventId"`
+	}{}
+	if err := c.Bind(&payload); err != nil {
+		return
+	}
+	if err := validation.ValidateSchedule(*payload.StartDate, *payload.EndDate); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event, err := db.GetEventByEitherId(c.Request.Context(), c.Param("eventId"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if event == nil {
+		c.Error(errs.EventNotFound)
+		return
+	}
+
+	// Only the owner can schedule
+	if event.OwnerId != utils.GetAuthUser(c).Id {
+		c.Error(errs.UserNotEventOwner)
+		return
+	}
+
+	event.ScheduledEvent = &models.CalendarEvent{
+		Summary:   event.Name,
+		StartDate: *payload.StartDate,
+		EndDate:   *payload.EndDate,
+	}
+	event.CalendarEventId = payload.CalendarEventId
+	if err := db.Events.Update(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
+	}
+	webhooks.Dispatch(c.Request.Context(), event, models.EventScheduledWebhook, nil)
+	discord_bot.EventScheduled(c.Request.Context(), event.Id)
+
+	c.JSON(http.StatusOK, event.ScheduledEvent)
+}
+
 // @Summary Return a map mapping user id to their calendar events that they have enabled for the given time range
 // @Tags events
 // @Accept json
@@ -1061,15 +1433,23 @@ func getCalendarAvailabilities(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventById(eventId)
+	event, err := db.GetEventById(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
-		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		c.Error(errs.EventNotFound)
 		return
 	}
 
 	// Ensure that event is a group
 	if event.Type != models.GROUP {
-		c.JSON(http.StatusBadRequest, responses.Error{Error: errs.EventNotGroup})
+		c.Error(errs.EventNotGroup)
+		return
+	}
+	if err := db.PopulateEventResponses(c.Request.Context(), event); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -1082,7 +1462,11 @@ func getCalendarAvailabilities(c *gin.Context) {
 
 	for _, eventResponse := range event.ResponsesList {
 		if utils.Coalesce(eventResponse.Response.UseCalendarAvailability) {
-			user := db.GetUserById(eventResponse.UserId)
+			user, err := db.GetUserById(c.Request.Context(), eventResponse.UserId)
+			if err != nil {
+				c.Error(err)
+				return
+			}
 			if user != nil {
 				numCalendarEventsRequests++
 
@@ -1094,14 +1478,23 @@ func getCalendarAvailabilities(c *gin.Context) {
 
 				// Fetch calendar events
 				go func(userId string) {
-					// Recover from panics
+					// Recover from panics, still reporting back so the request doesn't hang
 					defer func() {
 						if err := recover(); err != nil {
-							logger.StdErr.Println(err)
+							logger.FromContext(c.Request.Context()).Error("Panic while fetching calendar events", "userId", userId, "panic", err)
+							calendarEventsChan <- struct {
+								UserId string
+								Events map[string]calendar.CalendarEventsWithError
+							}{UserId: userId}
 						}
 					}()
 
-					calendarEvents, _ := calendar.GetUsersCalendarEvents(user, utils.ArrayToSet(enabledAccounts), payload.TimeMin, payload.TimeMax)
+					calendarEvents, editedCalendarAccounts := calendar.GetUsersCalendarEvents(c.Request.Context(), user, utils.ArrayToSet(enabledAccounts), payload.TimeMin, payload.TimeMax)
+					if editedCalendarAccounts {
+						if err := db.Users.Update(c.Request.Context(), user); err != nil {
+							logger.FromContext(c.Request.Context()).Error("Failed to update calendar accounts", "userId", userId, "error", err)
+						}
+					}
 					calendarEventsChan <- struct {
 						UserId string
 						Events map[string]calendar.CalendarEventsWithError
@@ -1180,12 +1573,9 @@ func deleteEvent(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	_, err = db.EventsCollection.DeleteOne(context.Background(), bson.M{
-		"_id":     objectId,
-		"ownerId": user.Id,
-	})
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Events.Delete(c.Request.Context(), objectId, user.Id); err != nil {
+		c.Error(err)
+		return
 	}
 
 	c.Status(http.StatusOK)
@@ -1212,7 +1602,11 @@ func duplicateEvent(c *gin.Context) {
 	user := userInterface.(*models.User)
 
 	// Get event
-	event := db.GetEventByEitherId(eventId)
+	event, err := db.GetEventByEitherId(c.Request.Context(), eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if event == nil {
 		c.Status(http.StatusBadRequest)
 		return
@@ -1225,24 +1619,44 @@ func duplicateEvent(c *gin.Context) {
 	}
 
 	// Update event
+	originalEventId := event.Id
 	event.Id = primitive.NewObjectID()
 	event.Name = payload.EventName
-	if !*payload.CopyAvailability {
-		event.ResponsesList = make([]models.EventResponse, 0)
+
+	// Insert new event
+	if err := db.InsertEventWithShortId(c.Request.Context(), event); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Copy responses to the new event
+	if *payload.CopyAvailability {
+		eventResponses, err := db.Responses.GetByEvent(c.Request.Context(), originalEventId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for _, eventResponse := range eventResponses {
+			if err := db.Responses.Upsert(c.Request.Context(), event.Id, eventResponse.UserId, eventResponse.Response); err != nil {
+				c.Error(err)
+				return
+			}
+		}
 	}
 
-	// Generate short id
-	shortId := db.GenerateShortEventId(event.Id)
-	event.ShortId = &shortId
+	insertedId := event.Id.Hex()
+	c.JSON(http.StatusCreated, gin.H{"eventId": insertedId, "shortId": *event.ShortId})
+}
 
-	// Insert new event
-	result, err := db.EventsCollection.InsertOne(context.Background(), event)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+// Counts n invite or reminder emails against the daily cap of the event's owner, or of the client's ip for events
+// without an owner. Returns errs.EmailLimitExceeded without counting the emails if they would exceed the cap
+func reserveEmails(c *gin.Context, kind string, ownerId primitive.ObjectID, n int, limit int) error {
+	sender := "ip:" + c.ClientIP()
+	if ownerId != primitive.NilObjectID {
+		sender = "user:" + ownerId.Hex()
 	}
 
-	insertedId := result.InsertedID.(primitive.ObjectID).Hex()
-	c.JSON(http.StatusCreated, gin.H{"eventId": insertedId, "shortId": shortId})
+	return notifications.ReserveEmails(c.Request.Context(), kind, sender, n, limit)
 }
 
 // Helper function to find a response by userId
@@ -0,0 +1,395 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+	"schej.it/server/services/notifications"
+	"schej.it/server/services/reminders"
+	"schej.it/server/utils"
+)
+
+// Resets the repositories to empty in-memory ones
+func setupTestDb() {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+	db.InitMemory("")
+}
+
+// Returns a router with the event and user routes. If userId is not empty, every request is made as that user
+func newTestRouter(userId string) *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("test"))))
+	router.Use(func(c *gin.Context) {
+		if len(userId) > 0 {
+			sessions.Default(c).Set("userId", userId)
+		}
+	})
+	router.Use(middleware.Errors())
+
+	apiRouter := router.Group("/api")
+	InitEvents(apiRouter)
+	InitUser(apiRouter)
+
+	return router
+}
+
+// Sends a request to the router and decodes the JSON response into response, if not nil
+func doRequest(t *testing.T, router *gin.Engine, method string, path string, body interface{}, response interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if response != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("%s %s: failed to decode response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+
+	return w.Code
+}
+
+func TestGuestEventResponses(t *testing.T) {
+	setupTestDb()
+	router := newTestRouter("")
+
+	date := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	var created struct {
+		EventId string `json:"eventId"`
+		ShortId string `json:"shortId"`
+	}
+	code := doRequest(t, router, http.MethodPost, "/api/events", gin.H{
+		"name":     "Team sync",
+		"duration": 1,
+		"dates":    []time.Time{date},
+		"type":     models.SPECIFIC_DATES,
+	}, &created)
+	if code != http.StatusCreated {
+		t.Fatalf("createEvent returned %d", code)
+	}
+
+	availability := []time.Time{date, date.Add(15 * time.Minute)}
+	code = doRequest(t, router, http.MethodPost, "/api/events/"+created.ShortId+"/response", gin.H{
+		"guest":        true,
+		"name":         "Alice",
+		"availability": availability,
+		"ifNeeded":     []time.Time{},
+	}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("updateEventResponse returned %d", code)
+	}
+
+	var invalid struct {
+		Error   string `json:"error"`
+		Details []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"details"`
+	}
+	code = doRequest(t, router, http.MethodPost, "/api/events/"+created.ShortId+"/response", gin.H{
+		"guest":        true,
+		"name":         "Bob",
+		"availability": []time.Time{date.Add(time.Hour)},
+	}, &invalid)
+	if code != http.StatusBadRequest || invalid.Error != "validation-failed" || len(invalid.Details) != 1 || invalid.Details[0].Field != "availability[0]" {
+		t.Errorf("expected a validation error for availability outside the event, got %d %+v", code, invalid)
+	}
+
+	var event struct {
+		Name      string                     `json:"name"`
+		Responses map[string]models.Response `json:"responses"`
+	}
+	if code := doRequest(t, router, http.MethodGet, "/api/events/"+created.EventId, nil, &event); code != http.StatusOK {
+		t.Fatalf("getEvent returned %d", code)
+	}
+	if event.Name != "Team sync" {
+		t.Errorf("expected event name %q, got %q", "Team sync", event.Name)
+	}
+	if _, ok := event.Responses["Alice"]; !ok || len(event.Responses) != 1 {
+		t.Errorf("expected a single response from Alice, got %v", event.Responses)
+	}
+
+	var responses map[string]models.Response
+	path := "/api/events/" + created.ShortId + "/responses?timeMin=" + date.Format(time.RFC3339) + "&timeMax=" + date.Add(time.Hour).Format(time.RFC3339)
+	if code := doRequest(t, router, http.MethodGet, path, nil, &responses); code != http.StatusOK {
+		t.Fatalf("getResponses returned %d", code)
+	}
+	if len(responses["Alice"].Availability) != len(availability) {
+		t.Errorf("expected %d available times, got %v", len(availability), responses["Alice"].Availability)
+	}
+
+	// Clients that accept the bitmap format get availability as a bitset over the event's slot grid
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", availabilityBitmapMediaType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var encodedResponses struct {
+		Grid      models.SlotGrid `json:"grid"`
+		Responses map[string]struct {
+			Availability []byte `json:"availability"`
+		} `json:"responses"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &encodedResponses); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Type") != availabilityBitmapMediaType {
+		t.Errorf("expected Content-Type %q, got %q", availabilityBitmapMediaType, w.Header().Get("Content-Type"))
+	}
+	if bits := encodedResponses.Responses["Alice"].Availability; encodedResponses.Grid.SlotsPerDay != 4 || len(bits) != 1 || bits[0] != 0b11 {
+		t.Errorf("unexpected bitmap response %s", w.Body.String())
+	}
+
+	if code := doRequest(t, router, http.MethodGet, "/api/events/"+primitive.NewObjectID().Hex(), nil, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing event, got %d", code)
+	}
+}
+
+func TestGetUserEvents(t *testing.T) {
+	setupTestDb()
+
+	user := models.User{FirstName: "Bob", Email: "bob@example.com"}
+	if err := db.Users.Insert(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	router := newTestRouter(user.Id.Hex())
+
+	owned := models.Event{Name: "Owned", OwnerId: user.Id, Type: models.SPECIFIC_DATES}
+	other := models.Event{Name: "Other", OwnerId: primitive.NewObjectID(), Type: models.SPECIFIC_DATES}
+	for _, event := range []*models.Event{&owned, &other} {
+		if err := db.Events.Insert(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var events struct {
+		Events       []models.Event `json:"events"`
+		JoinedEvents []models.Event `json:"joinedEvents"`
+	}
+	if code := doRequest(t, router, http.MethodGet, "/api/user/events", nil, &events); code != http.StatusOK {
+		t.Fatalf("getEvents returned %d", code)
+	}
+	if len(events.Events) != 1 || events.Events[0].Name != "Owned" || len(events.JoinedEvents) != 0 {
+		t.Errorf("expected only the owned event, got %+v", events)
+	}
+
+	// Responding to an event makes it a joined event, and responses are returned without availability
+	response := models.Response{UserId: user.Id, Availability: []primitive.DateTime{primitive.NewDateTimeFromTime(time.Now())}}
+	if err := db.Responses.Upsert(context.Background(), other.Id, user.Id.Hex(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if code := doRequest(t, router, http.MethodGet, "/api/user/events", nil, &events); code != http.StatusOK {
+		t.Fatalf("getEvents returned %d", code)
+	}
+	if len(events.JoinedEvents) != 1 || events.JoinedEvents[0].Name != "Other" {
+		t.Fatalf("expected the responded event to be joined, got %+v", events.JoinedEvents)
+	}
+	if r, ok := events.JoinedEvents[0].ResponsesMap[user.Id.Hex()]; !ok || r != nil {
+		t.Errorf("expected a response key without availability, got %v", events.JoinedEvents[0].ResponsesMap)
+	}
+}
+
+func TestNudge(t *testing.T) {
+	setupTestDb()
+	mailbox := t.TempDir()
+	t.Setenv("EMAIL_BACKEND", "file")
+	t.Setenv("EMAIL_MAILBOX_PATH", mailbox)
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	owner := models.User{FirstName: "Jane", Email: "jane@example.com"}
+	db.Users.Insert(ctx, &owner)
+	day := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	duration := float32(1)
+	event := models.Event{
+		Name:     "Lunch",
+		OwnerId:  owner.Id,
+		Type:     models.SPECIFIC_DATES,
+		Dates:    []primitive.DateTime{primitive.NewDateTimeFromTime(day)},
+		Duration: &duration,
+		Remindees: &[]models.Remindee{
+			{Email: "ann@example.com", Responded: utils.FalsePtr()},
+			{Email: "bob@example.com", Responded: utils.TruePtr()},
+		},
+	}
+	db.Events.Insert(ctx, &event)
+	available := []primitive.DateTime{primitive.NewDateTimeFromTime(day)}
+	db.Responses.Upsert(ctx, event.Id, "Bob", &models.Response{Name: "Bob", Email: "bob@example.com", Availability: available})
+	db.Responses.Upsert(ctx, event.Id, "Dan", &models.Response{Name: "Dan", Email: "dan@example.com", Availability: available})
+	db.Responses.Upsert(ctx, event.Id, "Carol", &models.Response{Name: "Carol", Email: "carol@example.com", Availability: []primitive.DateTime{}})
+
+	var result reminders.NudgeResult
+	path := "/api/events/" + event.Id.Hex() + "/nudge"
+	other := models.User{FirstName: "Eve", Email: "eve@example.com"}
+	db.Users.Insert(ctx, &other)
+	if code := doRequest(t, newTestRouter(other.Id.Hex()), http.MethodPost, path, gin.H{}, nil); code != http.StatusForbidden {
+		t.Errorf("expected only the owner to nudge, got %d", code)
+	}
+
+	router := newTestRouter(owner.Id.Hex())
+	if code := doRequest(t, router, http.MethodPost, path, gin.H{"includeUnavailable": true}, &result); code != http.StatusOK {
+		t.Fatalf("nudge returned %d", code)
+	}
+	expected := []reminders.NudgeRecipient{{Email: "ann@example.com", Reason: reminders.NotRespondedNudge}, {Email: "carol@example.com", Reason: reminders.NotAvailableNudge}}
+	if !reflect.DeepEqual(result.Nudged, expected) || len(result.Skipped) != 0 {
+		t.Errorf("expected %v to be nudged, got %+v", expected, result)
+	}
+	if files, _ := os.ReadDir(mailbox); len(files) != 2 {
+		t.Errorf("expected two nudge emails, got %v", files)
+	}
+
+	// Nudging again within the cooldown skips everyone
+	if code := doRequest(t, router, http.MethodPost, path, gin.H{"includeUnavailable": true}, &result); code != http.StatusOK {
+		t.Fatalf("nudge returned %d", code)
+	}
+	if len(result.Nudged) != 0 || !reflect.DeepEqual(result.Skipped, expected) {
+		t.Errorf("expected everyone to be skipped, got %+v", result)
+	}
+
+	// People that muted the event aren't nudged, and can be nudged again once they unmute it
+	notifications.Unsubscribe{Email: "finn@example.com", EventId: event.Id}.Apply(ctx)
+	*event.Remindees = append(*event.Remindees, models.Remindee{Email: "finn@example.com", Responded: utils.FalsePtr()})
+	db.Events.Update(ctx, &event)
+	for i := 0; i < 2; i++ {
+		if code := doRequest(t, router, http.MethodPost, path, gin.H{}, &result); code != http.StatusOK {
+			t.Fatalf("nudge returned %d", code)
+		}
+		muted := []reminders.NudgeRecipient{{Email: "finn@example.com", Reason: reminders.NotRespondedNudge}}
+		if len(result.Nudged) != 0 || !reflect.DeepEqual(result.NotSent, muted) {
+			t.Errorf("expected the muted remindee not to be sent a nudge, got %+v", result)
+		}
+	}
+	// People whose nudge fails to send are reported, and can be nudged again right away
+	*event.Remindees = append(*event.Remindees, models.Remindee{Email: "gus@example.com", Responded: utils.FalsePtr()})
+	db.Events.Update(ctx, &event)
+	os.RemoveAll(mailbox)
+	os.WriteFile(mailbox, nil, 0644)
+	for i := 0; i < 2; i++ {
+		if code := doRequest(t, router, http.MethodPost, path, gin.H{}, &result); code != http.StatusOK {
+			t.Fatalf("nudge returned %d", code)
+		}
+		failed := []reminders.NudgeRecipient{{Email: "gus@example.com", Reason: reminders.NotRespondedNudge}}
+		if len(result.Nudged) != 0 || !reflect.DeepEqual(result.Failed, failed) {
+			t.Errorf("expected the remindee's nudge to fail, got %+v", result)
+		}
+	}
+}
+
+func TestCreateEventReminders(t *testing.T) {
+	setupTestDb()
+	t.Setenv("EMAIL_BACKEND", "file")
+	t.Setenv("EMAIL_MAILBOX_PATH", t.TempDir())
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	closeJobs := jobs.Init()
+	defer closeJobs()
+	ctx := context.Background()
+
+	owner := models.User{FirstName: "Jane", Email: "jane@example.com"}
+	db.Users.Insert(ctx, &owner)
+	router := newTestRouter(owner.Id.Hex())
+	payload := gin.H{
+		"name":      "Lunch",
+		"duration":  1,
+		"dates":     []time.Time{time.Now().Truncate(time.Hour).Add(72 * time.Hour)},
+		"type":      models.SPECIFIC_DATES,
+		"remindees": []string{"ann@example.com"},
+	}
+
+	// Reminders are scheduled for the inserted event, with its short id
+	var created struct {
+		ShortId string `json:"shortId"`
+	}
+	if code := doRequest(t, router, http.MethodPost, "/api/events", payload, &created); code != http.StatusCreated {
+		t.Fatalf("createEvent returned %d", code)
+	}
+	event, _ := db.GetEventByShortId(ctx, created.ShortId)
+	if event == nil || len((*event.Remindees)[0].TaskIds) == 0 {
+		t.Fatalf("expected the event's reminders to be scheduled, got %+v", event)
+	}
+
+	// Events whose reminders can't be scheduled aren't kept
+	t.Setenv("EMAIL_BACKEND", "none")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if code := doRequest(t, router, http.MethodPost, "/api/events", payload, nil); code == http.StatusCreated {
+		t.Fatalf("expected createEvent to fail without reminder emails")
+	}
+	if events, _ := db.Events.GetByUser(ctx, owner.Id, owner.Email); len(events) != 1 {
+		t.Errorf("expected only the first event to be kept, got %d", len(events))
+	}
+	// Every reminder a remindee will be sent counts against the owner's cap
+	t.Setenv("REMINDER_EMAILS_PER_DAY", "2")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	other := models.User{FirstName: "Joe", Email: "joe@example.com"}
+	db.Users.Insert(ctx, &other)
+	if code := doRequest(t, newTestRouter(other.Id.Hex()), http.MethodPost, "/api/events", payload, nil); code != http.StatusTooManyRequests {
+		t.Errorf("expected the reminder email cap to be exceeded, got %d", code)
+	}
+}
+
+func TestScheduleEvent(t *testing.T) {
+	setupTestDb()
+	ctx := context.Background()
+
+	owner := models.User{FirstName: "Jane", Email: "jane@example.com"}
+	other := models.User{FirstName: "Eve", Email: "eve@example.com"}
+	db.Users.Insert(ctx, &owner)
+	db.Users.Insert(ctx, &other)
+	event := models.Event{Name: "Lunch", OwnerId: owner.Id, Type: models.SPECIFIC_DATES}
+	db.Events.Insert(ctx, &event)
+
+	path := "/api/events/" + event.Id.Hex() + "/schedule"
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	payload := gin.H{"startDate": start, "endDate": start.Add(time.Hour), "calendarEventId": "gcal1"}
+	if code := doRequest(t, newTestRouter(other.Id.Hex()), http.MethodPost, path, payload, nil); code != http.StatusForbidden {
+		t.Errorf("expected only the owner to schedule, got %d", code)
+	}
+
+	router := newTestRouter(owner.Id.Hex())
+	if code := doRequest(t, router, http.MethodPost, path, gin.H{"startDate": start, "endDate": start}, nil); code != http.StatusBadRequest {
+		t.Errorf("expected the end date to be after the start date, got %d", code)
+	}
+	if code := doRequest(t, router, http.MethodPost, path, payload, nil); code != http.StatusOK {
+		t.Fatalf("scheduleEvent returned %d", code)
+	}
+	scheduled, _ := db.Events.GetById(ctx, event.Id)
+	if scheduled.ScheduledEvent == nil || !scheduled.ScheduledEvent.StartDate.Time().Equal(start) || scheduled.CalendarEventId != "gcal1" {
+		t.Errorf("expected the event to be scheduled, got %+v", scheduled)
+	}
+}
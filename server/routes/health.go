@@ -0,0 +1,104 @@
+/* The health and metrics routes are used by the load balancer, deploy scripts, and monitoring, and are not part of the api */
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/services/jobs"
+	"schej.it/server/services/listmonk"
+)
+
+// Set once the server starts shutting down, so that it's taken out of the load balancer while it drains
+var shuttingDown atomic.Bool
+
+const readinessCheckTimeout = 5 * time.Second
+
+func InitHealth(router *gin.RouterGroup) {
+	router.GET("/healthz", healthz)
+	router.GET("/readyz", readyz)
+	router.GET("/metrics", metricsAuth(), gin.WrapH(promhttp.Handler()))
+}
+
+// Requires the metrics token as a bearer token, if one is configured
+func metricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Get().Server.MetricsToken
+		if len(token) > 0 && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Marks the server as not ready, called when the server starts shutting down
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Returns 200 as long as the process is up
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Returns 200 if the server can serve requests, i.e. it isn't shutting down and its dependencies are reachable,
+// and 503 with the failing checks otherwise
+func readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"server": func(ctx context.Context) error {
+			if shuttingDown.Load() {
+				return errors.New("shutting down")
+			}
+			return nil
+		},
+		"database": db.Ping,
+		"listmonk": listmonk.Ping,
+		"tasks":    jobs.Ping,
+	}
+
+	// Run the checks concurrently so that one slow dependency doesn't time out the others
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]string)
+	ready := true
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			results[name] = result
+			if result != "ok" {
+				ready = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": results})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": results})
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/middleware"
+)
+
+func TestHealth(t *testing.T) {
+	setupTestDb()
+	router := gin.New()
+	InitHealth(router.Group("/"))
+
+	if code := doRequest(t, router, http.MethodGet, "/healthz", nil, nil); code != http.StatusOK {
+		t.Fatalf("expected healthz to return 200, got %d", code)
+	}
+
+	// The job scheduler isn't started in tests, so the server isn't ready
+	var response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if code := doRequest(t, router, http.MethodGet, "/readyz", nil, &response); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected readyz to return 503, got %d", code)
+	}
+	if response.Checks["database"] != "ok" || response.Checks["server"] != "ok" || response.Checks["tasks"] == "ok" {
+		t.Errorf("unexpected checks %v", response.Checks)
+	}
+
+	SetShuttingDown()
+	defer shuttingDown.Store(false)
+	doRequest(t, router, http.MethodGet, "/readyz", nil, &response)
+	if response.Checks["server"] == "ok" {
+		t.Errorf("expected the server check to fail while shutting down, got %v", response.Checks)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	setupTestDb()
+	t.Setenv("METRICS_TOKEN", "token")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	InitHealth(router.Group("/"))
+
+	if code := doRequest(t, router, http.MethodGet, "/metrics", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected metrics without the token to return 401, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected metrics with the token to return 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `schej_http_request_duration_seconds_count{method="GET",route="/metrics",status="401"} 1`) {
+		t.Errorf("expected the unauthorized request to be recorded, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,42 @@
+/* The /jobs group contains the route that Google Cloud Tasks calls to run background jobs */
+package routes
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/config"
+	"schej.it/server/services/jobs"
+)
+
+func InitJobs(router *gin.RouterGroup) {
+	jobsRouter := router.Group("/jobs")
+
+	jobsRouter.POST("/run", runJob)
+}
+
+// Runs a job scheduled on Cloud Tasks. Cloud Tasks retries the job if this doesn't return 2xx
+func runJob(c *gin.Context) {
+	secret := config.Get().Tasks.Secret
+	if len(secret) == 0 || subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+secret)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	payload := struct {
+		Type    string `json:"type" binding:"required"`
+		Payload bson.M `json:"payload"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+
+	if err := jobs.Run(c.Request.Context(), payload.Type, payload.Payload); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,70 @@
+/* The /notifications group contains the routes that the unsubscribe links in emails open */
+package routes
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+	"schej.it/server/services/notifications"
+)
+
+func InitNotifications(router *gin.RouterGroup) {
+	notificationsRouter := router.Group("/notifications")
+
+	notificationsRouter.GET("/unsubscribe", getUnsubscribe)
+	notificationsRouter.POST("/unsubscribe", unsubscribe)
+}
+
+// @Summary Shows the page that asks to confirm an unsubscribe link
+// @Tags notifications
+// @Produce html
+// @Param token query string true "Signed token of the unsubscribe link"
+// @Success 200
+// @Router /notifications/unsubscribe [get]
+func getUnsubscribe(c *gin.Context) {
+	u, err := notifications.ParseToken(c.Query("token"))
+	if err != nil {
+		c.Error(errs.InvalidUnsubscribeLink.Wrap(err))
+		return
+	}
+
+	renderUnsubscribePage(c, u, false)
+}
+
+// @Summary Turns off the type of notification or mutes the event of an unsubscribe link
+// @Description Called by the unsubscribe page, and by mail clients' one-click unsubscribe buttons (RFC 8058)
+// @Tags notifications
+// @Produce html
+// @Param token query string true "Signed token of the unsubscribe link, can also be a form field"
+// @Success 200
+// @Router /notifications/unsubscribe [post]
+func unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if len(token) == 0 {
+		token = c.PostForm("token")
+	}
+	u, err := notifications.ParseToken(token)
+	if err != nil {
+		c.Error(errs.InvalidUnsubscribeLink.Wrap(err))
+		return
+	}
+
+	if err := u.Apply(c.Request.Context()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	renderUnsubscribePage(c, u, true)
+}
+
+func renderUnsubscribePage(c *gin.Context, u notifications.Unsubscribe, done bool) {
+	var page bytes.Buffer
+	if err := notifications.RenderUnsubscribePage(&page, u, done); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
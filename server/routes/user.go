@@ -2,7 +2,7 @@
 package routes
 
 import (
-	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -10,10 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"schej.it/server/db"
 	"schej.it/server/errs"
-	"schej.it/server/logger"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/responses"
@@ -22,6 +20,7 @@ import (
 	"schej.it/server/services/contacts"
 	"schej.it/server/services/microsoftgraph"
 	"schej.it/server/utils"
+	"schej.it/server/validation"
 )
 
 func InitUser(router *gin.RouterGroup) {
@@ -31,6 +30,7 @@ func InitUser(router *gin.RouterGroup) {
 	userRouter.GET("/profile", getProfile)
 	userRouter.PATCH("/name", updateName)
 	userRouter.PATCH("/calendar-options", updateCalendarOptions)
+	userRouter.PATCH("/notification-preferences", updateNotificationPreferences)
 	userRouter.GET("/events", getEvents)
 	userRouter.GET("/calendars", getCalendars)
 	userRouter.POST("/add-google-calendar-account", addGoogleCalendarAccount)
@@ -52,7 +52,10 @@ func getProfile(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	db.UpdateDailyUserLog(user)
+	if err := db.UpdateDailyUserLog(c.Request.Context(), user); err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, user)
 }
@@ -75,11 +78,10 @@ func updateName(c *gin.Context) {
 
 	authUser := utils.GetAuthUser(c)
 
-	_, err := db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.M{
-		"$set": bson.M{"firstName": payload.FirstName, "lastName": payload.LastName, "hasCustomName": true},
-	})
+	err := db.Users.SetFields(c.Request.Context(), authUser.Id, bson.M{"firstName": payload.FirstName, "lastName": payload.LastName, "hasCustomName": true})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
@@ -127,16 +129,64 @@ func updateCalendarOptions(c *gin.Context) {
 	}
 
 	// Update database
-	_, err := db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.M{
-		"$set": bson.M{"calendarOptions": authUser.CalendarOptions},
-	})
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Users.SetFields(c.Request.Context(), authUser.Id, bson.M{"calendarOptions": authUser.CalendarOptions}); err != nil {
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+// @Summary Updates the user's notification preferences
+// @Description Sets the mode (instant, digest, or off) of each given type of notification, and replaces the muted events and the local hour the daily digest is sent at if given
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param payload body object{types=map[string]string,mutedEventIds=[]string,digestHour=int} true "Object containing the updated preferences"
+// @Success 200 {object} models.NotificationPreferences
+// @Router /user/notification-preferences [patch]
+func updateNotificationPreferences(c *gin.Context) {
+	payload := struct {
+		Types         map[models.NotificationType]models.NotificationMode `json:"types"`
+		MutedEventIds *[]primitive.ObjectID                               `json:"mutedEventIds"`
+		DigestHour    *int                                                `json:"digestHour"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+	if err := validation.ValidateNotificationPreferences(payload.Types, payload.DigestHour); err != nil {
+		c.Error(err)
+		return
+	}
+
+	authUser := utils.GetAuthUser(c)
+
+	prefs := authUser.NotificationPreferences
+	if prefs == nil {
+		prefs = &models.NotificationPreferences{}
+	}
+	if len(payload.Types) > 0 && prefs.Types == nil {
+		prefs.Types = make(map[models.NotificationType]models.NotificationMode)
+	}
+	for notificationType, mode := range payload.Types {
+		prefs.Types[notificationType] = mode
+	}
+	if payload.MutedEventIds != nil {
+		prefs.MutedEventIds = *payload.MutedEventIds
+	}
+	if payload.DigestHour != nil {
+		prefs.DigestHour = payload.DigestHour
+	}
+
+	// Update database
+	if err := db.Users.SetFields(c.Request.Context(), authUser.Id, bson.M{"notificationPreferences": prefs}); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
 // @Summary Gets all the user's events
 // @Description Returns an array containing all the user's events
 // @Tags user
@@ -148,36 +198,29 @@ func getEvents(c *gin.Context) {
 	userId := user.Id
 
 	// Get the events associated with the current user
-	events := make([]models.Event, 0)
-	opts := options.Find().SetSort(bson.M{"_id": -1})
-
-	cursor, err := db.EventsCollection.Find(context.Background(), bson.M{
-		"$or": bson.A{
-			bson.M{"ownerId": userId},
-			bson.M{"responses.userId": userId.Hex()},
-			bson.M{"attendees": bson.M{"email": user.Email, "declined": false}},
-		},
-	}, opts)
+	events, err := db.Events.GetByUser(c.Request.Context(), userId, user.Email)
 	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	if err := cursor.All(context.Background(), &events); err != nil {
-		logger.StdErr.Panicln(err)
+		c.Error(err)
+		return
 	}
 
 	response := make(map[string][]models.Event)
 	response["events"] = make([]models.Event, 0)       // The events the user created
 	response["joinedEvents"] = make([]models.Event, 0) // The events the user has responded to
 
-	// Convert events to old format for backward compatibility
-	for i := range events {
-		utils.ConvertEventToOldFormat(&events[i])
+	// Only fetch who responded to each event, not their availability, so we don't send too much data when fetching all events
+	eventIds := utils.Map(events, func(event models.Event) primitive.ObjectID { return event.Id })
+	respondentIds, err := db.Responses.GetUserIdsByEvents(c.Request.Context(), eventIds)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	for _, event := range events {
-		// Get rid of responses so we don't send too much data when fetching all events
-		for id := range event.ResponsesMap {
-			event.ResponsesMap[id] = nil
+		// Convert responses to old format for backward compatibility
+		event.ResponsesMap = make(map[string]*models.Response)
+		for _, respondentId := range respondentIds[event.Id] {
+			event.ResponsesMap[respondentId] = nil
 		}
 
 		// Filter into events user created and responded to
@@ -215,19 +258,23 @@ func getCalendars(c *gin.Context) {
 	if len(payload.Accounts) == 0 {
 		accounts = make([]string, 0)
 	} else {
-		accounts = utils.ParseArrayQueryParam(payload.Accounts)
+		var err error
+		accounts, err = utils.ParseArrayQueryParam(payload.Accounts)
+		if err != nil {
+			c.Error(errs.InvalidRequest.Wrap(err))
+			return
+		}
 	}
 	accountsSet := utils.ArrayToSet(accounts)
 	user := utils.GetAuthUser(c)
 
-	calendarEvents, editedCalendarAccounts := calendar.GetUsersCalendarEvents(user, accountsSet, payload.TimeMin, payload.TimeMax)
+	calendarEvents, editedCalendarAccounts := calendar.GetUsersCalendarEvents(c.Request.Context(), user, accountsSet, payload.TimeMin, payload.TimeMax)
 
 	if editedCalendarAccounts {
-		db.UsersCollection.FindOneAndUpdate(
-			context.Background(),
-			bson.M{"_id": user.Id},
-			bson.M{"$set": user},
-		)
+		if err := db.Users.Update(c.Request.Context(), user); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, calendarEvents)
@@ -250,10 +297,18 @@ func addGoogleCalendarAccount(c *gin.Context) {
 	}
 
 	// Get tokens
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), models.GoogleCalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), models.GoogleCalendarType)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	// Get user info from JWT
-	claims := utils.ParseJWT(tokens.IdToken)
+	claims, err := utils.ParseJWT(tokens.IdToken)
+	if err != nil {
+		c.Error(errs.InvalidCredentials.Wrap(err))
+		return
+	}
 	email, _ := claims.GetStr("email")
 	picture, _ := claims.GetStr("picture")
 
@@ -266,12 +321,16 @@ func addGoogleCalendarAccount(c *gin.Context) {
 		RefreshToken:          tokens.RefreshToken,
 	}
 
-	addCalendarAccount(c, addCalendarAccountArgs{
+	err = addCalendarAccount(c, addCalendarAccountArgs{
 		calendarType:       models.GoogleCalendarType,
 		oAuth2CalendarAuth: calendarAuth,
 		email:              email,
 		picture:            picture,
 	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -292,32 +351,32 @@ func addAppleCalendarAccount(c *gin.Context) {
 		return
 	}
 
-	encryptedPassword, err := utils.Encrypt(payload.Password)
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
+	// The password is encrypted when the user is saved to the database
 	auth := &models.AppleCalendarAuth{
 		Email:    payload.Email,
-		Password: encryptedPassword,
+		Password: payload.Password,
 	}
 
 	// Check if the provided credentials are valid
 	calendarProvider := calendar.AppleCalendar{
 		AppleCalendarAuth: *auth,
 	}
-	_, err = calendarProvider.GetCalendarList()
+	_, err := calendarProvider.GetCalendarList(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.InvalidCredentials})
+		c.Error(errs.InvalidCredentials.Wrap(err))
 		return
 	}
 
-	addCalendarAccount(c, addCalendarAccountArgs{
+	err = addCalendarAccount(c, addCalendarAccountArgs{
 		calendarType:      models.AppleCalendarType,
 		appleCalendarAuth: auth,
 		email:             payload.Email,
 		picture:           "",
 	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -342,7 +401,11 @@ func addOutlookCalendarAccount(c *gin.Context) {
 	authUser := utils.GetAuthUser(c)
 
 	// Get tokens
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), models.OutlookCalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), models.OutlookCalendarType)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	// Get access token expire time
 	accessTokenExpireDate := utils.GetAccessTokenExpireDate(tokens.ExpiresIn)
@@ -356,14 +419,22 @@ func addOutlookCalendarAccount(c *gin.Context) {
 	}
 
 	// Get user info
-	userInfo := microsoftgraph.GetUserInfo(authUser, calendarAuth)
+	userInfo, err := microsoftgraph.GetUserInfo(c.Request.Context(), authUser, calendarAuth)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	addCalendarAccount(c, addCalendarAccountArgs{
+	err = addCalendarAccount(c, addCalendarAccountArgs{
 		calendarType:       models.OutlookCalendarType,
 		oAuth2CalendarAuth: calendarAuth,
 		email:              userInfo.Email,
 		picture:            "",
 	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -377,7 +448,7 @@ type addCalendarAccountArgs struct {
 	picture            string
 }
 
-func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) {
+func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) error {
 	// Get auth user
 	authUser := utils.GetAuthUser(c)
 
@@ -403,7 +474,7 @@ func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) {
 	if oldCalendarAccount, ok := authUser.CalendarAccounts[calendarAccountKey]; ok && oldCalendarAccount.SubCalendars != nil {
 		calendarAccount.SubCalendars = oldCalendarAccount.SubCalendars
 	} else {
-		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 		if err == nil {
 			calendarAccount.SubCalendars = &subCalendars
 		}
@@ -413,11 +484,7 @@ func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) {
 	authUser.CalendarAccounts[calendarAccountKey] = calendarAccount
 
 	// Perform mongo update
-	db.UsersCollection.FindOneAndUpdate(
-		context.Background(),
-		bson.M{"_id": authUser.Id},
-		bson.M{"$set": authUser},
-	)
+	return db.Users.Update(c.Request.Context(), authUser)
 }
 
 // @Summary Removes an existing calendar account
@@ -439,17 +506,10 @@ func removeCalendarAccount(c *gin.Context) {
 	calendarAccountKey := utils.GetCalendarAccountKey(payload.Email, payload.CalendarType)
 
 	authUser := utils.GetAuthUser(c)
-	db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.A{
-		bson.M{"$set": bson.M{
-			"calendarAccounts": bson.M{
-				"$setField": bson.M{
-					"field": calendarAccountKey,
-					"input": "$$ROOT.calendarAccounts",
-					"value": "$$REMOVE",
-				},
-			},
-		}},
-	})
+	if err := db.Users.RemoveCalendarAccount(c.Request.Context(), authUser.Id, calendarAccountKey); err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -468,7 +528,6 @@ func toggleCalendar(c *gin.Context) {
 		Enabled      *bool               `json:"enabled" binding:"required"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Panicln(err)
 		return
 	}
 
@@ -479,13 +538,8 @@ func toggleCalendar(c *gin.Context) {
 		account.Enabled = payload.Enabled
 		authUser.CalendarAccounts[calendarAccountKey] = account
 
-		_, err := db.UsersCollection.UpdateOne(context.Background(), bson.M{
-			"_id": authUser.Id,
-		}, bson.M{
-			"$set": authUser,
-		})
-		if err != nil {
-			logger.StdErr.Panicln(err)
+		if err := db.Users.Update(c.Request.Context(), authUser); err != nil {
+			c.Error(err)
 			return
 		}
 	}
@@ -508,7 +562,6 @@ func toggleSubCalendar(c *gin.Context) {
 		Enabled       *bool               `json:"enabled" binding:"required"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Panicln(err)
 		return
 	}
 
@@ -521,13 +574,8 @@ func toggleSubCalendar(c *gin.Context) {
 			(*account.SubCalendars)[payload.SubCalendarId] = subCalendar
 			authUser.CalendarAccounts[calendarAccountKey] = account
 
-			_, err := db.UsersCollection.UpdateOne(context.Background(), bson.M{
-				"_id": authUser.Id,
-			}, bson.M{
-				"$set": authUser,
-			})
-			if err != nil {
-				logger.StdErr.Panicln(err)
+			if err := db.Users.Update(c.Request.Context(), authUser); err != nil {
+				c.Error(err)
 				return
 			}
 		}
@@ -554,9 +602,16 @@ func searchContacts(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	contacts, googleError := contacts.SearchContacts(user, payload.Query)
-	if googleError != nil {
-		c.JSON(googleError.Code, responses.Error{Error: *googleError})
+	contacts, err := contacts.SearchContacts(c.Request.Context(), user, payload.Query)
+	if err != nil {
+		// Google errors are returned as is, so the frontend can ask for the contacts permission
+		var googleError *errs.GoogleAPIError
+		if errors.As(err, &googleError) {
+			c.JSON(googleError.Code, responses.Error{Error: *googleError})
+			return
+		}
+
+		c.Error(err)
 		return
 	}
 
@@ -572,9 +627,9 @@ func deleteUser(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	_, err := db.UsersCollection.DeleteOne(context.Background(), bson.M{"_id": user.Id})
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := db.Users.Delete(c.Request.Context(), user.Id); err != nil {
+		c.Error(err)
+		return
 	}
 
 	// Delete session
@@ -2,22 +2,18 @@
 package routes
 
 import (
-	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
 	"schej.it/server/db"
-	"schej.it/server/logger"
-	"schej.it/server/models"
-	"schej.it/server/utils"
+	"schej.it/server/middleware"
 )
 
 func InitUsers(router *gin.RouterGroup) {
 	userRouter := router.Group("/users")
-	userRouter.GET("", searchUsers)
+	userRouter.GET("", middleware.RateLimit("search-users", config.Get().RateLimits.SearchUsers, middleware.ByUser), searchUsers)
 	userRouter.GET("/:userId", getUser)
 }
 
@@ -34,48 +30,13 @@ func searchUsers(c *gin.Context) {
 		Query *string `form:"query" binding:"required"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Panicln(err)
 		return
 	}
 
-	query := *payload.Query
-	queryTermsRegex := make([]primitive.Regex, 0)
-
-	for _, s := range strings.Split(query, " ") {
-		r := primitive.Regex{Pattern: utils.EscapeRegExp(s), Options: "i"}
-		queryTermsRegex = append(queryTermsRegex, r)
-	}
-
-	users := make([]models.User, 0)
-
-	cursor, err := db.UsersCollection.Find(context.Background(), bson.M{
-		"$expr": bson.M{
-			"$reduce": bson.M{
-				"input":        queryTermsRegex,
-				"initialValue": true,
-				"in": bson.M{
-					"$and": bson.A{
-						"$$value",
-						bson.M{
-							"$regexMatch": bson.M{
-								"input": bson.M{
-									"$concat": bson.A{
-										"$firstName", " ", "$lastName", " ", "$email",
-									},
-								},
-								"regex": "$$this",
-							},
-						},
-					},
-				},
-			},
-		},
-	})
+	users, err := db.Users.Search(c.Request.Context(), strings.Split(*payload.Query, " "))
 	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	if err := cursor.All(context.Background(), &users); err != nil {
-		logger.StdErr.Panicln(err)
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, users)
@@ -89,8 +50,11 @@ func searchUsers(c *gin.Context) {
 // @Router /users/{userId} [get]
 func getUser(c *gin.Context) {
 	userId := c.Param("userId")
-	user := db.GetUserById(userId)
-
+	user, err := db.GetUserById(c.Request.Context(), userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if user == nil {
 		c.JSON(http.StatusNotFound, gin.H{})
 		return
@@ -0,0 +1,231 @@
+/* The /webhooks group contains the routes to manage the current user's webhooks and their delivery logs */
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/services/webhooks"
+	"schej.it/server/utils"
+	"schej.it/server/validation"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+func InitWebhooks(router *gin.RouterGroup) {
+	webhookRouter := router.Group("/webhooks")
+	webhookRouter.Use(middleware.AuthRequired())
+
+	webhookRouter.GET("", getWebhooks)
+	webhookRouter.POST("", createWebhook)
+	webhookRouter.DELETE("/:webhookId", deleteWebhook)
+	webhookRouter.GET("/:webhookId/deliveries", getWebhookDeliveries)
+	webhookRouter.POST("/:webhookId/deliveries/:deliveryId/replay", replayWebhookDelivery)
+}
+
+// @Summary Gets the user's webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} []models.Webhook
+// @Router /webhooks [get]
+func getWebhooks(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	hooks, err := db.Webhooks.GetByOwner(c.Request.Context(), user.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// @Summary Registers a webhook on one of the user's events, or on all of them
+// @Description Returns the webhook with the secret its deliveries are signed with, which isn't returned again
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param payload body object{url=string,eventId=string,events=[]string} true "Object containing the url, the event id or nothing for all events, and the types of activity to deliver or nothing for all types"
+// @Success 201 {object} object{webhook=models.Webhook,secret=string}
+// @Router /webhooks [post]
+func createWebhook(c *gin.Context) {
+	payload := struct {
+		Url     string                    `json:"url"`
+		EventId string                    `json:"eventId"`
+		Events  []models.WebhookEventType `json:"events"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	existing, err := db.Webhooks.GetByOwner(c.Request.Context(), user.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := validation.ValidateWebhook(payload.Url, payload.Events, len(existing)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	webhook := models.Webhook{
+		OwnerId:   user.Id,
+		Url:       payload.Url,
+		Events:    payload.Events,
+		CreatedAt: time.Now(),
+	}
+
+	// Webhooks can only be registered on the user's own events
+	if len(payload.EventId) > 0 {
+		event, err := db.GetEventByEitherId(c.Request.Context(), payload.EventId)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if event == nil {
+			c.Error(errs.EventNotFound)
+			return
+		}
+		if event.OwnerId != user.Id {
+			c.Error(errs.UserNotEventOwner)
+			return
+		}
+		webhook.EventId = &event.Id
+	}
+
+	webhook.Secret, err = webhooks.NewSecret()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := db.Webhooks.Insert(c.Request.Context(), &webhook); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"webhook": webhook, "secret": webhook.Secret})
+}
+
+// @Summary Deletes a webhook and its delivery log
+// @Tags webhooks
+// @Param webhookId path string true "Webhook ID"
+// @Success 200
+// @Router /webhooks/{webhookId} [delete]
+func deleteWebhook(c *gin.Context) {
+	webhook := getOwnedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	if err := db.Webhooks.Delete(c.Request.Context(), webhook.Id, webhook.OwnerId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Gets the webhook's most recent deliveries
+// @Tags webhooks
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Param limit query int false "Number of deliveries to return, defaults to 50"
+// @Success 200 {object} []models.WebhookDelivery
+// @Router /webhooks/{webhookId}/deliveries [get]
+func getWebhookDeliveries(c *gin.Context) {
+	webhook := getOwnedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if s := c.Query("limit"); len(s) > 0 {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxDeliveriesLimit {
+			var e validation.Errors
+			e.Add("limit", validation.OutOfRange, "Limit must be between 1 and %d", maxDeliveriesLimit)
+			c.Error(e.Err())
+			return
+		}
+	}
+
+	deliveries, err := db.WebhookDeliveries.GetByWebhook(c.Request.Context(), webhook.Id, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// @Summary Posts a delivery to its webhook again
+// @Description Creates a new delivery with the same body, which is signed and retried like any other delivery
+// @Tags webhooks
+// @Produce json
+// @Param webhookId path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Router /webhooks/{webhookId}/deliveries/{deliveryId}/replay [post]
+func replayWebhookDelivery(c *gin.Context) {
+	webhook := getOwnedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	deliveryId, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		c.Error(errs.DeliveryNotFound)
+		return
+	}
+	delivery, err := db.WebhookDeliveries.GetById(c.Request.Context(), deliveryId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if delivery == nil || delivery.WebhookId != webhook.Id {
+		c.Error(errs.DeliveryNotFound)
+		return
+	}
+
+	replay, err := webhooks.Replay(c.Request.Context(), delivery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, replay)
+}
+
+// Returns the webhook in the path if the current user owns it, otherwise sets the error and returns nil
+func getOwnedWebhook(c *gin.Context) *models.Webhook {
+	webhookId, err := primitive.ObjectIDFromHex(c.Param("webhookId"))
+	if err != nil {
+		c.Error(errs.WebhookNotFound)
+		return nil
+	}
+
+	webhook, err := db.Webhooks.GetById(c.Request.Context(), webhookId)
+	if err != nil {
+		c.Error(err)
+		return nil
+	}
+	// Other users' webhooks aren't found, so that their ids aren't revealed
+	if webhook == nil || webhook.OwnerId != utils.GetAuthUser(c).Id {
+		c.Error(errs.WebhookNotFound)
+		return nil
+	}
+
+	return webhook
+}
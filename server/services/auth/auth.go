@@ -7,18 +7,22 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
 	"schej.it/server/db"
+	"schej.it/server/errs"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
 	"schej.it/server/models"
 	"schej.it/server/utils"
 )
 
-// Returns access, refresh, and id tokens from the auth code
-func GetTokensFromAuthCode(code string, scope string, origin string, calendarType models.CalendarType) TokenResponse {
+// Returns access, refresh, and id tokens from the auth code. Returns errs.InvalidCredentials wrapping a *TokenError
+// if the token endpoint rejected the auth code
+func GetTokensFromAuthCode(ctx context.Context, code string, scope string, origin string, calendarType models.CalendarType) (TokenResponse, error) {
 	clientId, clientSecret := getCredentialsFromCalendarType(calendarType)
 	tokenEndpoint := getTokenEndpointFromCalendarType(calendarType)
 
@@ -32,27 +36,29 @@ func GetTokensFromAuthCode(code string, scope string, origin string, calendarTyp
 		"redirect_uri":  {redirectUri},
 		"grant_type":    {"authorization_code"},
 	}
-	resp, err := http.PostForm(
-		tokenEndpoint,
-		values,
-	)
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Auth())
+	defer cancel()
+
+	resp, err := postForm(ctx, tokenEndpoint, values)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return TokenResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	var res TokenResponse
-
-	json.NewDecoder(resp.Body).Decode(&res)
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return TokenResponse{}, err
+	}
 	if len(res.Error) > 0 {
-		data, _ := json.MarshalIndent(res, "", "  ")
-		logger.StdErr.Panicln(string(data))
+		return res, errs.InvalidCredentials.Wrap(&TokenError{Code: res.Error, Description: res.ErrorDescription})
 	}
 
-	return res
+	return res, nil
 }
 
-func RefreshAccessToken(accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType) AccessTokenResponse {
+// Exchanges the account's refresh token for a new access token. Returns a *TokenError if the token endpoint
+// rejected the refresh token
+func RefreshAccessToken(ctx context.Context, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType) (AccessTokenResponse, error) {
 	clientId, clientSecret := getCredentialsFromCalendarType(calendarType)
 	tokenEndpoint := getTokenEndpointFromCalendarType(calendarType)
 	values := url.Values{
@@ -63,44 +69,50 @@ func RefreshAccessToken(accountAuth *models.OAuth2CalendarAuth, calendarType mod
 		"grant_type":    {"refresh_token"},
 	}
 
-	resp, err := http.PostForm(
-		tokenEndpoint,
-		values,
-	)
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Auth())
+	defer cancel()
+
+	resp, err := postForm(ctx, tokenEndpoint, values)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return AccessTokenResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	var res AccessTokenResponse
-	json.NewDecoder(resp.Body).Decode(&res)
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, err
+	}
+
+	if len(res.Error) > 0 {
+		return res, &TokenError{Code: res.Error, Description: res.ErrorDescription}
+	}
 
-	return res
+	return res, nil
 }
 
 type RefreshAccessTokenData struct {
 	TokenResponse AccessTokenResponse
 	Email         string
 	CalendarType  models.CalendarType
-	Error         *interface{}
+	Error         error
 }
 
-func RefreshAccessTokenAsync(email string, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType, c chan RefreshAccessTokenData) {
+func RefreshAccessTokenAsync(ctx context.Context, email string, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType, c chan RefreshAccessTokenData) {
 	// Recover from panics
 	defer func() {
 		if err := recover(); err != nil {
-			c <- RefreshAccessTokenData{Error: &err}
+			c <- RefreshAccessTokenData{Email: email, CalendarType: calendarType, Error: fmt.Errorf("%v", err)}
 		}
 	}()
 
-	tokenResponse := RefreshAccessToken(accountAuth, calendarType)
+	tokenResponse, err := RefreshAccessToken(ctx, accountAuth, calendarType)
 
-	c <- RefreshAccessTokenData{tokenResponse, email, calendarType, nil}
+	c <- RefreshAccessTokenData{tokenResponse, email, calendarType, err}
 }
 
 // If access token has expired, get a new token for the primary account as well as all other calendar accounts, update the user object, and save it to the database
 // `accounts` specifies for which accounts to refresh access tokens. If `accounts` is nil or empty, then update tokens for all accounts
-func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
+func RefreshUserTokenIfNecessary(ctx context.Context, u *models.User, accounts models.Set[string]) {
 	refreshTokenChan := make(chan RefreshAccessTokenData)
 	numAccountsToUpdate := 0
 
@@ -114,7 +126,7 @@ func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
 
 			if _, ok := accounts[account.Email]; ok || updateAllAccounts {
 				if time.Now().After(accountAuth.AccessTokenExpireDate.Time()) && len(accountAuth.RefreshToken) > 0 {
-					go RefreshAccessTokenAsync(account.Email, accountAuth, account.CalendarType, refreshTokenChan)
+					go RefreshAccessTokenAsync(ctx, account.Email, accountAuth, account.CalendarType, refreshTokenChan)
 					numAccountsToUpdate++
 				}
 			}
@@ -124,29 +136,42 @@ func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
 	// Update access tokens as responses are received
 	for i := 0; i < numAccountsToUpdate; i++ {
 		res := <-refreshTokenChan
+		calendarAccountKey := utils.GetCalendarAccountKey(res.Email, res.CalendarType)
+		metrics.TokenRefreshes.WithLabelValues(string(res.CalendarType), metrics.Result(res.Error)).Inc()
 
 		if res.Error != nil {
+			logger.StdErr.Printf("Failed to refresh access token for %s: %v\n", calendarAccountKey, res.Error)
+			UpdateCalendarAccountHealth(ctx, u, calendarAccountKey, res.Error)
 			continue
 		}
 
 		accessTokenExpireDate := utils.GetAccessTokenExpireDate(res.TokenResponse.ExpiresIn)
 
-		calendarAccountKey := utils.GetCalendarAccountKey(res.Email, res.CalendarType)
 		if calendarAccount, ok := u.CalendarAccounts[calendarAccountKey]; ok {
 			calendarAccount.OAuth2CalendarAuth.AccessToken = res.TokenResponse.AccessToken
 			calendarAccount.OAuth2CalendarAuth.AccessTokenExpireDate = primitive.NewDateTimeFromTime(accessTokenExpireDate)
 			u.CalendarAccounts[calendarAccountKey] = calendarAccount
 		}
+		UpdateCalendarAccountHealth(ctx, u, calendarAccountKey, nil)
 	}
 
 	// Update user object if accounts were updated
 	if numAccountsToUpdate > 0 {
-		db.UsersCollection.FindOneAndUpdate(
-			context.Background(),
-			bson.M{"_id": u.Id},
-			bson.M{"$set": u},
-		)
+		if err := db.Users.Update(ctx, u); err != nil {
+			logger.FromContext(ctx).Error("Failed to save refreshed access tokens", "userId", u.Id.Hex(), "error", err)
+		}
+	}
+}
+
+// Posts the url encoded form to the token endpoint
+func postForm(ctx context.Context, endpoint string, values url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return http.DefaultClient.Do(req)
 }
 
 func getCredentialsFromCalendarType(calendarType models.CalendarType) (string, string) {
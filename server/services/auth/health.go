@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/notifications"
+	"schej.it/server/utils"
+)
+
+// Returns the health status that the given error puts a calendar account in
+func GetCalendarAccountStatus(err error) models.CalendarAccountStatus {
+	if err == nil {
+		return models.CalendarAccountOk
+	}
+
+	var tokenError *TokenError
+	if errors.As(err, &tokenError) && tokenError.NeedsReauth() {
+		return models.CalendarAccountNeedsReauth
+	}
+	if errors.Is(err, errs.ErrCalendarUnauthorized) {
+		return models.CalendarAccountNeedsReauth
+	}
+
+	return models.CalendarAccountFailing
+}
+
+// Updates the health of the calendar account with the given key based on the result of the last call made
+// with its credentials. Emails the user a reconnect link the first time the account needs reauthentication.
+// Returns whether the account was changed, the caller is responsible for saving the user to the database
+func UpdateCalendarAccountHealth(ctx context.Context, u *models.User, calendarAccountKey string, err error) bool {
+	account, ok := u.CalendarAccounts[calendarAccountKey]
+	if !ok {
+		return false
+	}
+
+	status := GetCalendarAccountStatus(err)
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+
+	health := utils.Coalesce(account.Health)
+	if account.Health != nil && health.Status == status && health.LastError == lastError {
+		return false
+	}
+
+	health.Status = status
+	health.LastError = lastError
+	health.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	switch status {
+	case models.CalendarAccountOk:
+		health.ReauthEmailSentAt = nil
+	case models.CalendarAccountNeedsReauth:
+		if health.ReauthEmailSentAt == nil && sendReconnectEmail(ctx, u, account) {
+			sentAt := primitive.NewDateTimeFromTime(time.Now())
+			health.ReauthEmailSentAt = &sentAt
+		}
+	}
+
+	account.Health = &health
+	u.CalendarAccounts[calendarAccountKey] = account
+
+	return true
+}
+
+// Emails the user asking them to reconnect the given calendar account. Returns whether the email was sent
+func sendReconnectEmail(ctx context.Context, u *models.User, account models.CalendarAccount) bool {
+	msg := notifications.ReconnectCalendar{
+		FirstName:     u.FirstName,
+		CalendarEmail: account.Email,
+		CalendarType:  string(account.CalendarType),
+		ReconnectUrl:  fmt.Sprintf("%s/settings", utils.GetBaseUrl()),
+	}
+	if !notifications.Configured(msg) {
+		logger.StdErr.Println("Not sending reconnect calendar email, it isn't configured")
+		return false
+	}
+
+	// Send email asynchronously, after the request that noticed the account needs reauthentication may have finished
+	ctx = context.WithoutCancel(ctx)
+	utils.RunInBackground(func() {
+		notifications.Send(ctx, u.Email, msg)
+	})
+
+	return true
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+)
+
+func TestGetCalendarAccountStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status models.CalendarAccountStatus
+	}{
+		{nil, models.CalendarAccountOk},
+		{&TokenError{Code: "invalid_grant"}, models.CalendarAccountNeedsReauth},
+		{&TokenError{Code: "invalid_client"}, models.CalendarAccountFailing},
+		{&errs.GoogleAPIError{Code: 401}, models.CalendarAccountNeedsReauth},
+		{&errs.GoogleAPIError{Code: 500}, models.CalendarAccountFailing},
+		{fmt.Errorf("outlook: %w", errs.ErrCalendarUnauthorized), models.CalendarAccountNeedsReauth},
+		{errors.New("connection reset"), models.CalendarAccountFailing},
+	}
+
+	for _, test := range tests {
+		if status := GetCalendarAccountStatus(test.err); status != test.status {
+			t.Errorf("GetCalendarAccountStatus(%v) = %s, expected %s", test.err, status, test.status)
+		}
+	}
+}
+
+func TestUpdateCalendarAccountHealth(t *testing.T) {
+	logger.Init(io.Discard)
+	t.Setenv("LISTMONK_RECONNECT_CALENDAR_EMAIL_ID", "")
+
+	key := "test@gmail.com_google"
+	user := &models.User{CalendarAccounts: map[string]models.CalendarAccount{
+		key: {CalendarType: models.GoogleCalendarType, Email: "test@gmail.com"},
+	}}
+
+	if !UpdateCalendarAccountHealth(context.Background(), user, key, nil) {
+		t.Fatal("expected first health check to change the account")
+	}
+	if UpdateCalendarAccountHealth(context.Background(), user, key, nil) {
+		t.Error("expected unchanged health to not change the account")
+	}
+
+	if !UpdateCalendarAccountHealth(context.Background(), user, key, &TokenError{Code: "invalid_grant", Description: "Token has been expired or revoked."}) {
+		t.Fatal("expected revoked token to change the account")
+	}
+	health := user.CalendarAccounts[key].Health
+	if health.Status != models.CalendarAccountNeedsReauth || len(health.LastError) == 0 {
+		t.Errorf("unexpected health after revoked token: %+v", health)
+	}
+
+	if UpdateCalendarAccountHealth(context.Background(), user, "missing_google", nil) {
+		t.Error("expected missing account to not be changed")
+	}
+}
@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"go.mongodb.org/mongo-driver/bson"
+	"fmt"
 )
 
 type TokenResponse struct {
@@ -16,9 +16,25 @@ type TokenResponse struct {
 }
 
 type AccessTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in"`
-	Scope       string `json:"scope"`
-	TokenType   string `json:"token_type"`
-	Error       bson.M `json:"error"`
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Scope            string `json:"scope"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// TokenError is returned when the oauth token endpoint rejects a refresh token
+type TokenError struct {
+	Code        string
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("token endpoint returned %s: %s", e.Code, e.Description)
+}
+
+// Returns whether the user has to sign in again to fix this error, i.e. the refresh token was revoked or expired
+func (e *TokenError) NeedsReauth() bool {
+	return e.Code == "invalid_grant" || e.Code == "interaction_required"
 }
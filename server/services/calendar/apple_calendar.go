@@ -3,6 +3,7 @@ package calendar
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/jonyTF/go-webdav"
 	"github.com/jonyTF/go-webdav/caldav"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
 	"schej.it/server/models"
 	"schej.it/server/utils"
 )
@@ -18,25 +20,25 @@ type AppleCalendar struct {
 	models.AppleCalendarAuth
 }
 
-func (calendar *AppleCalendar) GetCalendarList() (map[string]models.SubCalendar, error) {
+func (calendar *AppleCalendar) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
 	webdavClient, caldavClient, err := calendar.getClients()
 	if err != nil {
 		return nil, err
 	}
 
-	principal, err := webdavClient.FindCurrentUserPrincipal(context.Background())
+	principal, err := webdavClient.FindCurrentUserPrincipal(ctx)
 	if err != nil {
-		return nil, err
+		return nil, wrapAppleError(err)
 	}
 
-	calendarHomeSet, err := caldavClient.FindCalendarHomeSet(context.Background(), principal)
+	calendarHomeSet, err := caldavClient.FindCalendarHomeSet(ctx, principal)
 	if err != nil {
-		return nil, err
+		return nil, wrapAppleError(err)
 	}
 
-	calendars, err := caldavClient.FindCalendars(context.Background(), calendarHomeSet)
+	calendars, err := caldavClient.FindCalendars(ctx, calendarHomeSet)
 	if err != nil {
-		return nil, err
+		return nil, wrapAppleError(err)
 	}
 
 	// Only include calendars that support VEVENT
@@ -56,14 +58,14 @@ func (calendar *AppleCalendar) GetCalendarList() (map[string]models.SubCalendar,
 	return filteredCalendars, nil
 }
 
-func (calendar *AppleCalendar) GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+func (calendar *AppleCalendar) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
 	_, caldavClient, err := calendar.getClients()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get events
-	events, err := caldavClient.QueryCalendar(context.Background(), calendarId, &caldav.CalendarQuery{
+	events, err := caldavClient.QueryCalendar(ctx, calendarId, &caldav.CalendarQuery{
 		CompRequest: caldav.CalendarCompRequest{
 			Name: "VCALENDAR",
 			Comps: []caldav.CalendarCompRequest{{
@@ -91,7 +93,7 @@ func (calendar *AppleCalendar) GetCalendarEvents(calendarId string, timeMin time
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapAppleError(err)
 	}
 
 	var filteredEvents []models.CalendarEvent
@@ -125,12 +127,7 @@ func (calendar *AppleCalendar) GetCalendarEvents(calendarId string, timeMin time
 }
 
 func (calendar *AppleCalendar) getClients() (*webdav.Client, *caldav.Client, error) {
-	decryptedPassword, err := utils.Decrypt(calendar.Password)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	httpClient := webdav.HTTPClientWithBasicAuth(nil, calendar.Email, decryptedPassword)
+	httpClient := webdav.HTTPClientWithBasicAuth(nil, calendar.Email, calendar.Password)
 
 	webdavClient, err := webdav.NewClient(httpClient, "https://caldav.icloud.com")
 	if err != nil {
@@ -145,6 +142,16 @@ func (calendar *AppleCalendar) getClients() (*webdav.Client, *caldav.Client, err
 	return webdavClient, caldavClient, nil
 }
 
+// Wraps errors caused by iCloud rejecting the app password with errs.ErrCalendarUnauthorized.
+// The webdav library's HTTPError type is internal, so we have to match on its message
+func wrapAppleError(err error) error {
+	if strings.HasPrefix(err.Error(), fmt.Sprintf("%d ", http.StatusUnauthorized)) {
+		return fmt.Errorf("%w: %v", errs.ErrCalendarUnauthorized, err)
+	}
+
+	return err
+}
+
 func parseTimeWithTZ(prop *ical.Prop) (time.Time, error) {
 	timeStr := prop.Value
 	tzID := prop.Params.Get("TZID")
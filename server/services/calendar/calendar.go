@@ -1,6 +1,7 @@
 package calendar
 
 import (
+	"context"
 	"time"
 
 	"schej.it/server/models"
@@ -15,7 +16,7 @@ type GetCalendarListData struct {
 }
 
 // Calls GetCalendarList but broadcasts the result to channel
-func GetCalendarListAsync(calendarAccountKey string, calendarProvider *CalendarProvider, c chan GetCalendarListData) {
+func GetCalendarListAsync(ctx context.Context, calendarAccountKey string, calendarProvider *CalendarProvider, c chan GetCalendarListData) {
 	// Recover from panics
 	defer func() {
 		if err := recover(); err != nil {
@@ -23,7 +24,7 @@ func GetCalendarListAsync(calendarAccountKey string, calendarProvider *CalendarP
 		}
 	}()
 
-	calendarList, err := (*calendarProvider).GetCalendarList()
+	calendarList, err := (*calendarProvider).GetCalendarList(ctx)
 
 	c <- GetCalendarListData{CalendarList: calendarList, CalendarAccountKey: calendarAccountKey, Error: err}
 }
@@ -35,7 +36,7 @@ type GetCalendarEventsData struct {
 }
 
 // Get the user's list of calendar events for the given calendar
-func GetCalendarEventsAsync(calendarAccountKey string, calendarProvider *CalendarProvider, calendarId string, timeMin time.Time, timeMax time.Time, c chan GetCalendarEventsData) {
+func GetCalendarEventsAsync(ctx context.Context, calendarAccountKey string, calendarProvider *CalendarProvider, calendarId string, timeMin time.Time, timeMax time.Time, c chan GetCalendarEventsData) {
 	// Recover from panics
 	defer func() {
 		if err := recover(); err != nil {
@@ -43,7 +44,7 @@ func GetCalendarEventsAsync(calendarAccountKey string, calendarProvider *Calenda
 		}
 	}()
 
-	calendarEvents, err := (*calendarProvider).GetCalendarEvents(calendarId, timeMin, timeMax)
+	calendarEvents, err := (*calendarProvider).GetCalendarEvents(ctx, calendarId, timeMin, timeMax)
 
 	c <- GetCalendarEventsData{CalendarEvents: calendarEvents, CalendarAccountKey: calendarAccountKey, Error: err}
 }
@@ -54,8 +55,8 @@ type CalendarEventsWithError struct {
 }
 
 // Returns a map mapping email to the calendar events associated with that email, and an error if there was an error fetching events for that email
-func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], timeMin time.Time, timeMax time.Time) (map[string]CalendarEventsWithError, bool) {
-	auth.RefreshUserTokenIfNecessary(user, accounts)
+func GetUsersCalendarEvents(ctx context.Context, user *models.User, accounts models.Set[string], timeMin time.Time, timeMax time.Time) (map[string]CalendarEventsWithError, bool) {
+	auth.RefreshUserTokenIfNecessary(ctx, user, accounts)
 
 	returnAllAccounts := len(accounts) == 0
 	editedCalendarAccounts := false
@@ -73,7 +74,16 @@ func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], time
 
 		// Get secondary account calendars
 		if _, ok := accounts[calendarAccountKey]; ok || returnAllAccounts {
-			go GetCalendarListAsync(calendarAccountKey, &calendarProvider, calendarListChan)
+			// Don't call the provider if we couldn't decrypt the account's credentials
+			if err := account.DecryptionError(); err != nil {
+				calendarEventsMap[calendarAccountKey] = CalendarEventsWithError{
+					CalendarEvents: make([]models.CalendarEvent, 0),
+					Error:          err,
+				}
+				continue
+			}
+
+			go GetCalendarListAsync(ctx, calendarAccountKey, &calendarProvider, calendarListChan)
 			numCalendarListRequests++
 
 			calendarEventsMap[calendarAccountKey] = CalendarEventsWithError{
@@ -129,7 +139,7 @@ func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], time
 		user.CalendarAccounts[calendarListData.CalendarAccountKey] = account
 
 		for id := range *account.SubCalendars {
-			go GetCalendarEventsAsync(calendarListData.CalendarAccountKey, &calendarProvider, id, timeMin, timeMax, calendarEventsChan)
+			go GetCalendarEventsAsync(ctx, calendarListData.CalendarAccountKey, &calendarProvider, id, timeMin, timeMax, calendarEventsChan)
 			numCalendarEventsRequests++
 		}
 	}
@@ -153,5 +163,12 @@ func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], time
 		}
 	}
 
+	// Update the health of each account based on whether its events could be fetched
+	for calendarAccountKey, events := range calendarEventsMap {
+		if auth.UpdateCalendarAccountHealth(ctx, user, calendarAccountKey, events.Error) {
+			editedCalendarAccounts = true
+		}
+	}
+
 	return calendarEventsMap, editedCalendarAccounts
 }
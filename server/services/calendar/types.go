@@ -1,17 +1,30 @@
 package calendar
 
 import (
+	"context"
 	"time"
 
+	"schej.it/server/config"
+	"schej.it/server/metrics"
 	"schej.it/server/models"
 )
 
 type CalendarProvider interface {
-	GetCalendarList() (map[string]models.SubCalendar, error)
-	GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error)
+	GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error)
+	GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error)
 }
 
+// Returns the provider for the calendar account, which records metrics for each request it makes and gives up on
+// requests that take longer than the calendar timeout
 func GetCalendarProvider(calendarAccount models.CalendarAccount) CalendarProvider {
+	provider := getCalendarProvider(calendarAccount)
+	if provider == nil {
+		return nil
+	}
+	return instrumentedCalendarProvider{provider, calendarAccount.CalendarType}
+}
+
+func getCalendarProvider(calendarAccount models.CalendarAccount) CalendarProvider {
 	switch calendarAccount.CalendarType {
 	case models.GoogleCalendarType:
 		return &GoogleCalendar{
@@ -28,3 +41,33 @@ func GetCalendarProvider(calendarAccount models.CalendarAccount) CalendarProvide
 	}
 	return nil
 }
+
+// Bounds each request made by the wrapped provider by the calendar timeout, and records its latency and result
+type instrumentedCalendarProvider struct {
+	provider     CalendarProvider
+	calendarType models.CalendarType
+}
+
+func (p instrumentedCalendarProvider) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Calendar())
+	defer cancel()
+
+	start := time.Now()
+	calendarList, err := p.provider.GetCalendarList(ctx)
+	p.observe("list", start, err)
+	return calendarList, err
+}
+
+func (p instrumentedCalendarProvider) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Calendar())
+	defer cancel()
+
+	start := time.Now()
+	calendarEvents, err := p.provider.GetCalendarEvents(ctx, calendarId, timeMin, timeMax)
+	p.observe("events", start, err)
+	return calendarEvents, err
+}
+
+func (p instrumentedCalendarProvider) observe(operation string, start time.Time, err error) {
+	metrics.CalendarRequestDuration.WithLabelValues(string(p.calendarType), operation, metrics.Result(err)).Observe(time.Since(start).Seconds())
+}
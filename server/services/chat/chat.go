@@ -0,0 +1,121 @@
+/* Platform-neutral parts of the chat bots' /schej commands, e.g. creating events and linking schej accounts */
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/webhooks"
+	"schej.it/server/utils"
+	"schej.it/server/validation"
+)
+
+var ErrTooManyEvents = errors.New("too many events created")
+
+// Returns the schej user that the chat user linked, or nil if they haven't linked one
+func GetLinkedUser(ctx context.Context, platform models.ChatPlatform, teamId string, userId string) (*models.User, error) {
+	account, err := db.ChatAccounts.Get(ctx, platform, teamId, userId)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	return db.Users.GetById(ctx, account.UserId)
+}
+
+// Creates the parsed event, owned by the user. Returns ErrTooManyEvents if the user has hit the create event rate
+// limit, and validation errors if the event is invalid
+func CreateEvent(ctx context.Context, user *models.User, parsed NewEvent) (*models.Event, error) {
+	// Events created from chat count against the same limit as the ones created on the website
+	limit := config.Get().RateLimits.CreateEvent
+	if limit.Requests > 0 {
+		count, _, err := db.RateLimits.Increment(ctx, "request:create-event:user:"+user.Id.Hex(), 1, limit.Window())
+		if err != nil {
+			logger.FromContext(ctx).Error("Failed to count request", "limit", "create-event", "error", err)
+		} else if count > limit.Requests {
+			return nil, ErrTooManyEvents
+		}
+	}
+
+	duration := float32(parsed.End-parsed.Start) / 60
+	dates := make([]primitive.DateTime, 0)
+	for _, day := range parsed.Days {
+		dates = append(dates, primitive.NewDateTimeFromTime(day.Add(time.Duration(parsed.Start)*time.Minute)))
+	}
+	if err := validation.ValidateEvent(validation.Event{
+		Name:     parsed.Name,
+		Duration: &duration,
+		Dates:    dates,
+		Type:     models.SPECIFIC_DATES,
+	}); err != nil {
+		return nil, err
+	}
+
+	event := models.Event{
+		Id:              primitive.NewObjectID(),
+		OwnerId:         user.Id,
+		Name:            parsed.Name,
+		Duration:        &duration,
+		Dates:           dates,
+		Type:            models.SPECIFIC_DATES,
+		SignUpResponses: make(map[string]*models.SignUpResponse),
+	}
+	if err := db.InsertEventWithShortId(ctx, &event); err != nil {
+		return nil, err
+	}
+
+	webhooks.Dispatch(ctx, &event, models.EventCreatedWebhook, nil)
+
+	return &event, nil
+}
+
+// Returns the event with the id, with its responses populated, or nil if there isn't one. The id can also be the
+// event's link, e.g. https://schej.it/e/2aB3c
+func GetEvent(ctx context.Context, id string) (*models.Event, error) {
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+
+	event, err := db.GetEventByEitherId(ctx, id)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if err := db.PopulateEventResponses(ctx, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// Returns the number of people that responded to the event
+func NumResponses(event *models.Event) int {
+	if utils.Coalesce(event.IsSignUpForm) {
+		return len(event.SignUpResponses)
+	}
+	return len(event.ResponsesList)
+}
+
+func EventUrl(event *models.Event) string {
+	if event.Type == models.GROUP {
+		return fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId())
+	}
+	return fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId())
+}
+
+// Returns the user's timezone. The offset is in minutes behind UTC, like javascript's Date.getTimezoneOffset
+func UserZone(user *models.User) *time.Location {
+	return time.FixedZone("", -user.TimezoneOffset*60)
+}
+
+func Plural(n int, singular string, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/models"
+)
+
+// The link pages are the same for every platform, so each bot registers these handlers for its own platform
+
+// @Summary Shows the page that asks to confirm linking a Slack or Discord account to the signed in user
+// @Tags chat
+// @Produce html
+// @Param token query string true "Signed token of the link that /schej link replied with"
+// @Success 200
+// @Router /slackbot/link [get]
+// @Router /discord/link [get]
+func GetLinkHandler(platform models.ChatPlatform) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l, err := ParseLinkToken(platform, c.Query("token"))
+		if err != nil {
+			c.Error(errs.InvalidChatLink.Wrap(err))
+			return
+		}
+
+		user, err := signedInUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		renderLinkPage(c, l, user, false)
+	}
+}
+
+// @Summary Links a Slack or Discord account to the signed in user, so that their /schej commands act as the user
+// @Tags chat
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param token formData string true "Signed token of the link that /schej link replied with"
+// @Success 200
+// @Router /slackbot/link [post]
+// @Router /discord/link [post]
+func LinkHandler(platform models.ChatPlatform) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !sameOrigin(c) {
+			c.Error(errs.CrossOriginRequest)
+			return
+		}
+
+		l, err := ParseLinkToken(platform, c.PostForm("token"))
+		if err != nil {
+			c.Error(errs.InvalidChatLink.Wrap(err))
+			return
+		}
+
+		user, err := signedInUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if user == nil {
+			renderLinkPage(c, l, nil, false)
+			return
+		}
+
+		if err := l.Apply(c.Request.Context(), user); err != nil {
+			c.Error(err)
+			return
+		}
+
+		renderLinkPage(c, l, user, true)
+	}
+}
+
+// Returns whether the request was sent from a page of this server or of an allowed origin. Otherwise another site
+// could post the link form with its own token for whoever is signed in, and act as them from its chat account
+func sameOrigin(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if len(origin) == 0 {
+		// Some browsers only send the Referer
+		referer, err := url.Parse(c.GetHeader("Referer"))
+		if err != nil || len(referer.Host) == 0 {
+			return false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == c.Request.Host || slices.Contains(config.Get().Server.AllowedOrigins, origin)
+}
+
+// Returns the user that is signed in, or nil if nobody is
+func signedInUser(c *gin.Context) (*models.User, error) {
+	userId, ok := sessions.Default(c).Get("userId").(string)
+	if !ok {
+		return nil, nil
+	}
+	return db.GetUserById(c.Request.Context(), userId)
+}
+
+func renderLinkPage(c *gin.Context, l LinkRequest, user *models.User, done bool) {
+	var page bytes.Buffer
+	if err := RenderLinkPage(&page, l, user, done); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+// Chat users link their schej account by following a link that the bot replies with, while signed in to schej.
+// The link is signed and expires, so that it only links the chat user it was sent to
+
+const linkExpiry = time.Hour
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Asks to confirm before linking, so that opening someone else's link doesn't link their chat account to yours
+var linkPage = template.Must(template.ParseFS(templateFiles, "templates/link.html"))
+
+var ErrInvalidLinkToken = errors.New("invalid chat link token")
+
+var platformNames = map[models.ChatPlatform]string{
+	models.SlackPlatform:   "Slack",
+	models.DiscordPlatform: "Discord",
+}
+
+// Paths of the routes that link each platform's accounts, after /api
+var linkPaths = map[models.ChatPlatform]string{
+	models.SlackPlatform:   "/slackbot/link",
+	models.DiscordPlatform: "/discord/link",
+}
+
+// The chat user that a link token was signed for
+type LinkRequest struct {
+	Platform  models.ChatPlatform
+	TeamId    string
+	UserId    string
+	ExpiresAt time.Time
+}
+
+// Returns the link request of the chat user, which expires in an hour
+func NewLinkRequest(platform models.ChatPlatform, teamId string, userId string) LinkRequest {
+	return LinkRequest{Platform: platform, TeamId: teamId, UserId: userId, ExpiresAt: time.Now().Add(linkExpiry)}
+}
+
+// Returns the signed token of the link
+func (l LinkRequest) Token() string {
+	payload := strings.Join([]string{string(l.Platform), l.TeamId, l.UserId, strconv.FormatInt(l.ExpiresAt.Unix(), 10)}, "\n")
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(signLink(payload))
+}
+
+// Returns the url of the page that links the chat user to the schej account that is signed in
+func (l LinkRequest) Url() string {
+	return fmt.Sprintf("%s/api%s?token=%s", config.Get().Server.BaseUrl, linkPaths[l.Platform], url.QueryEscape(l.Token()))
+}
+
+// Links the chat user to the schej user
+func (l LinkRequest) Apply(ctx context.Context, user *models.User) error {
+	return db.ChatAccounts.Upsert(ctx, &models.ChatAccount{
+		Platform:   l.Platform,
+		TeamId:     l.TeamId,
+		ExternalId: l.UserId,
+		UserId:     user.Id,
+		LinkedAt:   time.Now(),
+	})
+}
+
+// Returns the link that the token was signed for, if it's for the platform and hasn't expired
+func ParseLinkToken(platform models.ChatPlatform, token string) (LinkRequest, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return LinkRequest{}, ErrInvalidLinkToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return LinkRequest{}, ErrInvalidLinkToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signLink(string(payload))) {
+		return LinkRequest{}, ErrInvalidLinkToken
+	}
+
+	parts := strings.Split(string(payload), "\n")
+	if len(parts) != 4 || parts[0] != string(platform) {
+		return LinkRequest{}, ErrInvalidLinkToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expiresAt, 0)) {
+		return LinkRequest{}, ErrInvalidLinkToken
+	}
+
+	return LinkRequest{Platform: platform, TeamId: parts[1], UserId: parts[2], ExpiresAt: time.Unix(expiresAt, 0)}, nil
+}
+
+func signLink(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte("chat-link:"+config.Get().Server.SessionSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Renders the page that asks the signed in user to confirm the link, or that it's done. user is nil if nobody is
+// signed in
+func RenderLinkPage(w io.Writer, l LinkRequest, user *models.User, done bool) error {
+	data := map[string]interface{}{
+		"Done":     done,
+		"Platform": platformNames[l.Platform],
+		"Token":    l.Token(),
+		"BaseUrl":  config.Get().Server.BaseUrl,
+		"Email":    "",
+	}
+	if user != nil {
+		data["Email"] = user.Email
+	}
+
+	return linkPage.Execute(w, data)
+}
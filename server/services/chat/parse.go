@@ -0,0 +1,201 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The event that a chat command creates
+type NewEvent struct {
+	Name string
+
+	// Midnight of each day of the event, in the creator's timezone
+	Days []time.Time
+
+	// Minutes after midnight that the event starts and ends on each day
+	Start int
+	End   int
+}
+
+// Returns when the event is, e.g. "Mon, Oct 19 - Fri, Oct 23, 9:00 AM - 5:00 PM"
+func (e NewEvent) When() string {
+	first, last := e.Days[0], e.Days[len(e.Days)-1]
+	days := first.Format("Mon, Jan 2")
+	if len(e.Days) > 1 {
+		days += " - " + last.Format("Mon, Jan 2")
+	}
+	start := first.Add(time.Duration(e.Start) * time.Minute)
+	end := first.Add(time.Duration(e.End) * time.Minute)
+	return fmt.Sprintf("%s, %s - %s", days, start.Format("3:04 PM"), end.Format("3:04 PM"))
+}
+
+// Events are 9am-5pm if the command doesn't have a time range
+const (
+	defaultStart = 9 * 60
+	defaultEnd   = 17 * 60
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// e.g. "9-5", "9am-5pm", "9:30-17:00"
+var timeRangeRegex = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?-(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+// Parses the arguments of a command that creates an event, i.e. the event's name followed by its days and an
+// optional time range, e.g. `"Standup" next week 9-5`. Days can be "today", "tomorrow", "this week" or "next week"
+// (their weekdays), weekdays like "mon" or "mon-fri" (the next one, including today), and dates like "10/19" or
+// "2026-10-19". Relative days are relative to now, in now's location
+func ParseNewEvent(args []string, now time.Time) (NewEvent, error) {
+	if len(args) == 0 || len(strings.TrimSpace(args[0])) == 0 {
+		return NewEvent{}, fmt.Errorf("the event needs a name")
+	}
+	e := NewEvent{Name: strings.TrimSpace(args[0]), Start: defaultStart, End: defaultEnd}
+
+	terms := make([]string, 0)
+	for _, arg := range args[1:] {
+		for _, term := range strings.FieldsFunc(strings.ToLower(arg), func(r rune) bool { return r == ' ' || r == ',' }) {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) > 0 && timeRangeRegex.MatchString(terms[len(terms)-1]) {
+		var err error
+		if e.Start, e.End, err = parseTimeRange(terms[len(terms)-1]); err != nil {
+			return NewEvent{}, err
+		}
+		terms = terms[:len(terms)-1]
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make(map[time.Time]bool)
+	for i := 0; i < len(terms); i++ {
+		term := terms[i]
+		switch {
+		case term == "today":
+			days[today] = true
+		case term == "tomorrow":
+			days[today.AddDate(0, 0, 1)] = true
+		case (term == "this" || term == "next") && i+1 < len(terms) && terms[i+1] == "week":
+			// Weeks start on Monday
+			monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+			if term == "next" {
+				monday = monday.AddDate(0, 0, 7)
+			}
+			for d := 0; d < 5; d++ {
+				if day := monday.AddDate(0, 0, d); !day.Before(today) {
+					days[day] = true
+				}
+			}
+			i++
+		default:
+			parsed, err := parseDays(term, today)
+			if err != nil {
+				return NewEvent{}, err
+			}
+			for _, day := range parsed {
+				days[day] = true
+			}
+		}
+	}
+	if len(days) == 0 {
+		return NewEvent{}, fmt.Errorf("the event needs at least one day that hasn't passed, e.g. \"next week\" or \"mon-fri\"")
+	}
+
+	for day := range days {
+		e.Days = append(e.Days, day)
+	}
+	sort.Slice(e.Days, func(i, j int) bool { return e.Days[i].Before(e.Days[j]) })
+
+	return e, nil
+}
+
+// Parses a weekday, a range of weekdays, or a date
+func parseDays(term string, today time.Time) ([]time.Time, error) {
+	from, to, isRange := strings.Cut(term, "-")
+	fromWeekday, fromOk := weekdays[from]
+	toWeekday, toOk := weekdays[to]
+	if isRange && fromOk && toOk {
+		// The range starts on the next fromWeekday, e.g. "fri-mon" is the next weekend
+		days := []time.Time{nextWeekday(today, fromWeekday)}
+		for days[len(days)-1].Weekday() != toWeekday {
+			days = append(days, days[len(days)-1].AddDate(0, 0, 1))
+		}
+		return days, nil
+	}
+	if weekday, ok := weekdays[term]; ok {
+		return []time.Time{nextWeekday(today, weekday)}, nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", term, today.Location()); err == nil {
+		if date.Before(today) {
+			return nil, fmt.Errorf("%s has already passed", term)
+		}
+		return []time.Time{date}, nil
+	}
+	if date, err := time.ParseInLocation("1/2", term, today.Location()); err == nil {
+		// Dates without a year are the next time that date comes around
+		date = time.Date(today.Year(), date.Month(), date.Day(), 0, 0, 0, 0, today.Location())
+		if date.Before(today) {
+			date = date.AddDate(1, 0, 0)
+		}
+		return []time.Time{date}, nil
+	}
+
+	return nil, fmt.Errorf("%q isn't a day, use e.g. \"tomorrow\", \"next week\", \"mon-fri\" or \"10/19\"", term)
+}
+
+// Returns the first day on or after today that is the weekday
+func nextWeekday(today time.Time, weekday time.Weekday) time.Time {
+	return today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+7)%7)
+}
+
+// Parses a time range like "9-5" into minutes after midnight. Times without am or pm are in the morning unless
+// that would put the end before the start, so "9-5" is 9am-5pm
+func parseTimeRange(term string) (int, int, error) {
+	m := timeRangeRegex.FindStringSubmatch(term)
+	startHour, _ := strconv.Atoi(m[1])
+	startMinute, _ := strconv.Atoi(m[2])
+	endHour, _ := strconv.Atoi(m[4])
+	endMinute, _ := strconv.Atoi(m[5])
+	startMeridiem, endMeridiem := m[3], m[6]
+	if len(startMeridiem) == 0 && len(endMeridiem) > 0 && to24Hour(startHour, endMeridiem) < to24Hour(endHour, endMeridiem) {
+		startMeridiem = endMeridiem
+	}
+
+	start := to24Hour(startHour, startMeridiem)*60 + startMinute
+	end := to24Hour(endHour, endMeridiem)*60 + endMinute
+	if len(endMeridiem) == 0 && end <= start && endHour < 12 {
+		end += 12 * 60
+	}
+
+	switch {
+	case startHour > 24 || endHour > 24 || startMinute >= 60 || endMinute >= 60 || end > 24*60:
+		return 0, 0, fmt.Errorf("%q isn't a valid time range", term)
+	case startMinute%15 != 0 || endMinute%15 != 0:
+		return 0, 0, fmt.Errorf("times must be on the hour or 15, 30 or 45 minutes past it")
+	case end <= start:
+		return 0, 0, fmt.Errorf("the event must end after it starts")
+	}
+	return start, end, nil
+}
+
+func to24Hour(hour int, meridiem string) int {
+	switch {
+	case meridiem == "am" && hour == 12:
+		return 0
+	case meridiem == "pm" && hour < 12:
+		return hour + 12
+	default:
+		return hour
+	}
+}
@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNewEvent(t *testing.T) {
+	// A Sunday
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(now.Year(), month, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		args  []string
+		days  []time.Time
+		start int
+		end   int
+	}{
+		{[]string{"Standup", "next", "week", "9-5"}, []time.Time{day(10, 19), day(10, 20), day(10, 21), day(10, 22), day(10, 23)}, 9 * 60, 17 * 60},
+		{[]string{"Lunch", "tomorrow", "12-1:30pm"}, []time.Time{day(10, 19)}, 12 * 60, 13*60 + 30},
+		{[]string{"Trip", "fri-mon"}, []time.Time{day(10, 23), day(10, 24), day(10, 25), day(10, 26)}, defaultStart, defaultEnd},
+		{[]string{"Dinner", "today, wed", "6pm-9pm"}, []time.Time{day(10, 18), day(10, 21)}, 18 * 60, 21 * 60},
+		{[]string{"Kickoff", "10/1", "2026-10-20"}, []time.Time{day(10, 20), time.Date(2027, 10, 1, 0, 0, 0, 0, time.UTC)}, defaultStart, defaultEnd},
+	}
+	for _, test := range tests {
+		e, err := ParseNewEvent(test.args, now)
+		if err != nil {
+			t.Errorf("%v: %v", test.args, err)
+			continue
+		}
+		if e.Name != test.args[0] || len(e.Days) != len(test.days) || e.Start != test.start || e.End != test.end {
+			t.Errorf("%v: got %+v", test.args, e)
+			continue
+		}
+		for i := range e.Days {
+			if !e.Days[i].Equal(test.days[i]) {
+				t.Errorf("%v: expected days %v, got %v", test.args, test.days, e.Days)
+				break
+			}
+		}
+	}
+
+	for _, args := range [][]string{{"Standup"}, {"Standup", "this", "week"}, {"Standup", "someday"}, {"Standup", "mon", "9:10-10"}, {"Standup", "mon", "5pm-9am"}} {
+		if _, err := ParseNewEvent(args, now); err == nil {
+			t.Errorf("expected %v to be invalid", args)
+		}
+	}
+}
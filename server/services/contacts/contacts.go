@@ -1,18 +1,21 @@
 package contacts
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 
+	"schej.it/server/config"
 	"schej.it/server/errs"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 	"schej.it/server/services"
 	"schej.it/server/utils"
 )
 
-func SearchContacts(user *models.User, query string) ([]models.User, *errs.GoogleAPIError) {
+// Returns the user's contacts and directory people matching the query. Returns a *errs.GoogleAPIError if google
+// rejected the request, e.g. because contacts access wasn't granted
+func SearchContacts(ctx context.Context, user *models.User, query string) ([]models.User, error) {
 	type Person struct {
 		Names []struct {
 			FamilyName string `json:"familyName"`
@@ -29,14 +32,21 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 	// Set calendar auth to the user's primary google calendar account
 	calendarAuth := user.CalendarAccounts[utils.GetCalendarAccountKey(user.Email, models.GoogleCalendarType)].OAuth2CalendarAuth
 
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Contacts())
+	defer cancel()
+
 	// Search contacts
-	response := services.CallApi(
+	response, err := services.CallApi(
+		ctx,
 		user,
 		calendarAuth,
 		"GET",
 		fmt.Sprintf("https://people.googleapis.com/v1/people:searchContacts?query=%s&pageSize=10&readMask=names,emailAddresses,photos", url.QueryEscape(query)),
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 	defer response.Body.Close()
 
 	// Parse response
@@ -47,7 +57,7 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 		Error *errs.GoogleAPIError `json:"error"`
 	}{}
 	if err := json.NewDecoder(response.Body).Decode(&contactsData); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
 	directoryData := struct {
@@ -56,18 +66,22 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 	}{}
 	if len(query) > 0 {
 		// Search Directory
-		response = services.CallApi(
+		response, err := services.CallApi(
+			ctx,
 			user,
 			calendarAuth,
 			"GET",
 			fmt.Sprintf("https://people.googleapis.com/v1/people:searchDirectoryPeople?query=%s&pageSize=10&readMask=names,emailAddresses,photos&sources=DIRECTORY_SOURCE_TYPE_DOMAIN_PROFILE", url.QueryEscape(query)),
 			nil,
 		)
+		if err != nil {
+			return nil, err
+		}
 		defer response.Body.Close()
 
 		// Parse response
 		if err := json.NewDecoder(response.Body).Decode(&directoryData); err != nil {
-			logger.StdErr.Panicln(err)
+			return nil, err
 		}
 	}
 
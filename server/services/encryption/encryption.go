@@ -0,0 +1,293 @@
+// Package encryption implements envelope encryption for secrets stored in the database.
+//
+// Every value is encrypted with its own random data key using AES-GCM, and the data key is
+// wrapped with a versioned key encryption key. Rotating keys only requires re-wrapping data keys,
+// and ciphertexts record the id of the key that wrapped them so old keys keep working until
+// every value has been re-encrypted.
+//
+// Ciphertext format: enc:v1:<keyId>:<base64 wrapped data key>:<base64 encrypted value>
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	prefix      = "enc"
+	version     = "v1"
+	dataKeySize = 32
+)
+
+var (
+	ErrNoKeys           = errors.New("no encryption keys configured")
+	ErrUnknownKey       = errors.New("ciphertext was encrypted with an unknown key")
+	ErrMalformed        = errors.New("ciphertext is malformed")
+	ErrDecryptionFailed = errors.New("failed to decrypt ciphertext")
+)
+
+// Keyring holds the key encryption keys, mapped from key id to key, and the id of the key used to encrypt new values
+type Keyring struct {
+	keys         map[string][]byte
+	currentKeyId string
+}
+
+// Returns a new keyring. currentKeyId must be one of the ids in keys
+func NewKeyring(keys map[string][]byte, currentKeyId string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if _, ok := keys[currentKeyId]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", currentKeyId)
+	}
+	for id, key := range keys {
+		if strings.Contains(id, ":") || len(id) == 0 {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+	}
+
+	return &Keyring{keys: keys, currentKeyId: currentKeyId}, nil
+}
+
+// Loads the keyring from the environment.
+//
+// ENCRYPTION_KEYS is a comma separated list of `id:base64key` pairs and ENCRYPTION_KEY_ID is the id of the key
+// to encrypt new values with (defaults to the first key in the list). If ENCRYPTION_KEYS is not set, the legacy
+// ENCRYPTION_KEY is used as key "0" so existing deployments keep working without any configuration changes
+func LoadKeyringFromEnv() (*Keyring, error) {
+	keys := make(map[string][]byte)
+	currentKeyId := os.Getenv("ENCRYPTION_KEY_ID")
+
+	if keysString := os.Getenv("ENCRYPTION_KEYS"); len(keysString) > 0 {
+		for i, pair := range strings.Split(keysString, ",") {
+			id, encodedKey, found := strings.Cut(strings.TrimSpace(pair), ":")
+			if !found {
+				return nil, fmt.Errorf("ENCRYPTION_KEYS entry %d is not in the form id:base64key", i)
+			}
+			key, err := base64.StdEncoding.DecodeString(encodedKey)
+			if err != nil {
+				return nil, fmt.Errorf("ENCRYPTION_KEYS entry %q: %w", id, err)
+			}
+			keys[id] = key
+
+			if len(currentKeyId) == 0 {
+				currentKeyId = id
+			}
+		}
+	} else if legacyKey := os.Getenv("ENCRYPTION_KEY"); len(legacyKey) > 0 {
+		keys["0"] = []byte(legacyKey)
+		if len(currentKeyId) == 0 {
+			currentKeyId = "0"
+		}
+	}
+
+	return NewKeyring(keys, currentKeyId)
+}
+
+// Returns the id of the key that new values are encrypted with
+func (k *Keyring) CurrentKeyId() string {
+	return k.currentKeyId
+}
+
+// Encrypts the given plaintext with a new data key wrapped by the current key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrappedDataKey, err := seal(k.keys[k.currentKeyId], dataKey, []byte(k.currentKeyId))
+	if err != nil {
+		return "", err
+	}
+	encryptedValue, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		prefix,
+		version,
+		k.currentKeyId,
+		base64.StdEncoding.EncodeToString(wrappedDataKey),
+		base64.StdEncoding.EncodeToString(encryptedValue),
+	}, ":"), nil
+}
+
+// Decrypts a ciphertext returned by Encrypt, using whichever key in the keyring it was wrapped with
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	keyId, wrappedDataKey, encryptedValue, err := parse(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	key, ok := k.keys[keyId]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyId)
+	}
+
+	dataKey, err := open(key, wrappedDataKey, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, encryptedValue, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Returns whether the given value should be re-encrypted, i.e. it is not encrypted with the current key
+func (k *Keyring) NeedsRotation(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	keyId, _, _, err := parse(value)
+	return err != nil || keyId != k.currentKeyId
+}
+
+// Returns whether the given value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix+":")
+}
+
+// Splits a ciphertext into its key id, wrapped data key, and encrypted value
+func parse(ciphertext string) (string, []byte, []byte, error) {
+	parts := strings.Split(ciphertext, ":")
+	if len(parts) != 5 || parts[0] != prefix || parts[1] != version {
+		return "", nil, nil, ErrMalformed
+	}
+
+	wrappedDataKey, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	encryptedValue, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	return parts[2], wrappedDataKey, encryptedValue, nil
+}
+
+// Encrypts plaintext with AES-GCM, returning the nonce followed by the sealed data
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypts data returned by seal
+func open(key []byte, data []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// The keyring loaded from the environment, initialized on first use since .env is loaded after package init
+var (
+	defaultKeyring    *Keyring
+	defaultKeyringErr error
+	loadKeyringOnce   sync.Once
+)
+
+func getDefaultKeyring() (*Keyring, error) {
+	loadKeyringOnce.Do(func() {
+		defaultKeyring, defaultKeyringErr = LoadKeyringFromEnv()
+	})
+
+	return defaultKeyring, defaultKeyringErr
+}
+
+// Encrypts the given plaintext with the keyring loaded from the environment
+func Encrypt(plaintext string) (string, error) {
+	keyring, err := getDefaultKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	return keyring.Encrypt(plaintext)
+}
+
+// Decrypts the given ciphertext with the keyring loaded from the environment
+func Decrypt(ciphertext string) (string, error) {
+	keyring, err := getDefaultKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	return keyring.Decrypt(ciphertext)
+}
+
+// Returns whether the given value is not encrypted with the current key of the keyring loaded from the environment
+func NeedsRotation(value string) bool {
+	keyring, err := getDefaultKeyring()
+	if err != nil {
+		return false
+	}
+
+	return keyring.NeedsRotation(value)
+}
+
+// Decrypts a value encrypted by the old AES-CFB scheme, which used ENCRYPTION_KEY directly and had no authentication
+func DecryptLegacy(text string) (string, error) {
+	block, err := aes.NewCipher([]byte(os.Getenv("ENCRYPTION_KEY")))
+	if err != nil {
+		return "", err
+	}
+	cipherText, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	if len(cipherText) < aes.BlockSize {
+		return "", errors.New("ciphertext too short")
+	}
+	iv := cipherText[:aes.BlockSize]
+	cipherText = cipherText[aes.BlockSize:]
+	cfb := cipher.NewCFBDecrypter(block, iv)
+	plainText := make([]byte, len(cipherText))
+	cfb.XORKeyStream(plainText, cipherText)
+	return string(plainText), nil
+}
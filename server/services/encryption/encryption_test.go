@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyring, err := NewKeyring(map[string][]byte{"1": bytes.Repeat([]byte{1}, 32)}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := keyring.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(ciphertext) || strings.Contains(ciphertext, "refresh-token") {
+		t.Fatalf("unexpected ciphertext %q", ciphertext)
+	}
+
+	plaintext, err := keyring.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "refresh-token" {
+		t.Errorf("Decrypt() = %q, expected %q", plaintext, "refresh-token")
+	}
+
+	// Tampering with the ciphertext should fail authentication
+	parts := strings.Split(ciphertext, ":")
+	encryptedValue, _ := base64.StdEncoding.DecodeString(parts[4])
+	encryptedValue[len(encryptedValue)-1] ^= 1
+	parts[4] = base64.StdEncoding.EncodeToString(encryptedValue)
+	if _, err := keyring.Decrypt(strings.Join(parts, ":")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for tampered ciphertext, got %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	oldKeyring, _ := NewKeyring(map[string][]byte{"1": oldKey}, "1")
+	ciphertext, err := oldKeyring.Encrypt("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotatedKeyring, _ := NewKeyring(map[string][]byte{"1": oldKey, "2": newKey}, "2")
+	if !rotatedKeyring.NeedsRotation(ciphertext) {
+		t.Error("expected value encrypted with the old key to need rotation")
+	}
+	plaintext, err := rotatedKeyring.Decrypt(ciphertext)
+	if err != nil || plaintext != "password" {
+		t.Fatalf("Decrypt() with rotated keyring = %q, %v", plaintext, err)
+	}
+
+	reencrypted, _ := rotatedKeyring.Encrypt(plaintext)
+	if rotatedKeyring.NeedsRotation(reencrypted) {
+		t.Error("expected re-encrypted value to not need rotation")
+	}
+
+	// Once the old key is removed, old ciphertexts can't be decrypted
+	newKeyring, _ := NewKeyring(map[string][]byte{"2": newKey}, "2")
+	if _, err := newKeyring.Decrypt(ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestLoadKeyringFromEnv(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	t.Setenv("ENCRYPTION_KEYS", "2:"+key2+", 1:"+key1)
+	t.Setenv("ENCRYPTION_KEY_ID", "")
+	keyring, err := LoadKeyringFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.CurrentKeyId() != "2" {
+		t.Errorf("CurrentKeyId() = %q, expected the first key", keyring.CurrentKeyId())
+	}
+
+	t.Setenv("ENCRYPTION_KEYS", "")
+	t.Setenv("ENCRYPTION_KEY", strings.Repeat("k", 32))
+	keyring, err = LoadKeyringFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.CurrentKeyId() != "0" {
+		t.Errorf("CurrentKeyId() = %q, expected the legacy key", keyring.CurrentKeyId())
+	}
+
+	t.Setenv("ENCRYPTION_KEY", "")
+	if _, err := LoadKeyringFromEnv(); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys, got %v", err)
+	}
+}
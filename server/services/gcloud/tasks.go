@@ -2,11 +2,9 @@ package gcloud
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2beta3"
@@ -14,125 +12,77 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"google.golang.org/api/option"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"schej.it/server/logger"
-	"schej.it/server/services/listmonk"
-	"schej.it/server/utils"
+	"schej.it/server/config"
 )
 
-var TasksClient *cloudtasks.Client
-
-func InitTasks() func() {
-	ctx := context.Background()
-
-	var err error
-	credsFile := os.Getenv("SERVICE_ACCOUNT_KEY_PATH")
+// Schedules jobs as Google Cloud Tasks tasks, which post the job back to the server's run url when they're due.
+// Implements jobs.Queue
+type Queue struct {
+	client *cloudtasks.Client
+}
 
-	TasksClient, err = cloudtasks.NewClient(ctx, option.WithCredentialsFile(credsFile))
+func NewQueue(ctx context.Context) (*Queue, error) {
+	client, err := cloudtasks.NewClient(ctx, option.WithCredentialsFile(config.Get().Tasks.ServiceAccountKeyPath))
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	// Return function to close client
-	return func() {
-		TasksClient.Close()
-	}
+	return &Queue{client: client}, nil
 }
 
-func CreateEmailTask(email string, ownerName string, eventName string, eventId string) []string {
-	// Get listmonk url env vars
-	listmonkUrl := os.Getenv("LISTMONK_URL")
-	listmonkUsername := os.Getenv("LISTMONK_USERNAME")
-	listmonkPassword := os.Getenv("LISTMONK_PASSWORD")
-	basicAuthString := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", listmonkUsername, listmonkPassword)))
+func (q *Queue) Close() error {
+	return q.client.Close()
+}
 
-	// Find if subscriber exists in listmonk
-	subscriberExists, _ := listmonk.DoesUserExist(email)
+// Returns whether the id is the name of a Cloud Tasks task rather than the id of a local job
+func IsTaskName(id string) bool {
+	return strings.HasPrefix(id, "projects/")
+}
 
-	// If subscriber doesn't exist, add subscriber to listmonk
-	if !subscriberExists {
-		listmonk.AddUserToListmonk(email, "", "", "", nil)
-	}
+// Creates a task that posts {type, payload} to the run url at runAt, and returns the task's name
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload bson.M, runAt time.Time) (string, error) {
+	cfg := config.Get()
 
-	// Get email template ids
-	initialEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_INITIAL_EMAIL_REMINDER_ID"))
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	secondEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_SECOND_EMAIL_REMINDER_ID"))
+	body, err := json.Marshal(bson.M{"type": jobType, "payload": payload})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return "", err
 	}
-	finalEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_FINAL_EMAIL_REMINDER_ID"))
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
-	// Create map of emails to iterate through
-	tasksToCreate := make(map[int]*timestamppb.Timestamp)
-	tasksToCreate[initialEmailReminderId] = timestamppb.Now()
-	tasksToCreate[secondEmailReminderId] = timestamppb.New(time.Now().Add(24 * time.Hour))
-	tasksToCreate[finalEmailReminderId] = timestamppb.New(time.Now().Add(3 * 24 * time.Hour))
 
-	// Construct URLs
-	baseUrl := utils.GetBaseUrl()
-	eventUrl := fmt.Sprintf("%s/e/%s", baseUrl, eventId)
-	finishedUrl := fmt.Sprintf("%s/e/%s/responded?email=%s", baseUrl, eventId, email)
-
-	taskIds := make([]string, 0)
-
-	for templateId, scheduleTime := range tasksToCreate {
-		// Create JSON object
-		body, err := json.Marshal(bson.M{
-			"subscriber_email": email,
-			"template_id":      templateId,
-			"data": bson.M{
-				"ownerName":   ownerName,
-				"eventName":   eventName,
-				"eventUrl":    eventUrl,
-				"finishedUrl": finishedUrl,
-			},
-			"content_type": "html",
-		})
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
+	runUrl := cfg.Tasks.RunUrl
+	if len(runUrl) == 0 {
+		runUrl = cfg.Server.BaseUrl + "/api/jobs/run"
+	}
 
-		// Create task
-		task, err := TasksClient.CreateTask(context.Background(), &cloudtaskspb.CreateTaskRequest{
-			Parent: "projects/schej-it/locations/us-central1/queues/SendReminderEmail",
-			Task: &cloudtaskspb.Task{
-				ScheduleTime: scheduleTime,
-				PayloadType: &cloudtaskspb.Task_HttpRequest{
-					HttpRequest: &cloudtaskspb.HttpRequest{
-						Url:        fmt.Sprintf("%s/api/tx", listmonkUrl),
-						HttpMethod: cloudtaskspb.HttpMethod_POST,
-						Headers: map[string]string{
-							"Authorization": fmt.Sprintf("Basic %s", basicAuthString),
-							"Content-Type":  "application/json",
-						},
-						Body: body,
+	task, err := q.client.CreateTask(ctx, &cloudtaskspb.CreateTaskRequest{
+		Parent: cfg.Tasks.ReminderQueuePath(),
+		Task: &cloudtaskspb.Task{
+			ScheduleTime: timestamppb.New(runAt),
+			PayloadType: &cloudtaskspb.Task_HttpRequest{
+				HttpRequest: &cloudtaskspb.HttpRequest{
+					Url:        runUrl,
+					HttpMethod: cloudtaskspb.HttpMethod_POST,
+					Headers: map[string]string{
+						"Authorization": fmt.Sprintf("Bearer %s", cfg.Tasks.Secret),
+						"Content-Type":  "application/json",
 					},
+					Body: body,
 				},
 			},
-		})
-
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
-
-		taskIds = append(taskIds, task.Name)
+		},
+	})
+	if err != nil {
+		return "", err
 	}
 
-	return taskIds
+	return task.Name, nil
 }
 
-func DeleteEmailTask(taskId string) {
-	err := TasksClient.DeleteTask(context.Background(), &cloudtaskspb.DeleteTaskRequest{
-		Name: taskId,
+func (q *Queue) Cancel(ctx context.Context, taskName string) error {
+	return q.client.DeleteTask(ctx, &cloudtaskspb.DeleteTaskRequest{
+		Name: taskName,
 	})
+}
 
-	if err != nil {
-		// logger.StdErr.Println(err)
-		return
-	}
+func (q *Queue) Ping(ctx context.Context) error {
+	return nil
 }
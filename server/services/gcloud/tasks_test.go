@@ -1,6 +1,7 @@
 package gcloud
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -8,30 +9,54 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
 	"schej.it/server/logger"
 )
 
-func TestCreateEmailTask(t *testing.T) {
-	// Init logfile
-	logFile, err := os.OpenFile("logs.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func TestEnqueue(t *testing.T) {
+	queue := initTestQueue(t)
+	defer queue.Close()
+
+	taskName, err := queue.Enqueue(context.Background(), "test", bson.M{"email": "schej.team@gmail.com"}, time.Now().Add(time.Hour))
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
+	if !IsTaskName(taskName) {
+		t.Errorf("expected a task name, got %q", taskName)
+	}
+	if err := queue.Cancel(context.Background(), taskName); err != nil {
+		t.Error(err)
+	}
+}
 
-	// Init logger
-	logger.Init(logFile)
+func TestCancel(t *testing.T) {
+	queue := initTestQueue(t)
+	defer queue.Close()
 
-	// Load .env file
-	err = godotenv.Load("../../.env")
+	// Should fail
+	fmt.Println("Delete task that doesn't exist...")
+	if err := queue.Cancel(context.Background(), "id_that_doesn't_exist"); err == nil {
+		t.Error("expected deleting a task that doesn't exist to fail")
+	}
+
+	// Should succeed
+	fmt.Println("Creating task...")
+	taskName, err := queue.Enqueue(context.Background(), "test", bson.M{}, time.Now().Add(time.Hour))
 	if err != nil {
-		logger.StdErr.Panicln("Error loading .env file")
+		t.Fatal(err)
 	}
+	fmt.Println("Task created")
 
-	InitTasks()
-	CreateEmailTask("schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161")
+	time.Sleep(10 * time.Second)
+	fmt.Println("Deleting task with name: ", taskName)
+	if err := queue.Cancel(context.Background(), taskName); err != nil {
+		t.Error(err)
+	}
+	fmt.Println("Done.")
 }
 
-func TestDeleteEmailTask(t *testing.T) {
+// Creates a queue with the credentials in .env
+func initTestQueue(t *testing.T) *Queue {
 	// Init logfile
 	logFile, err := os.OpenFile("logs.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -47,24 +72,9 @@ func TestDeleteEmailTask(t *testing.T) {
 		logger.StdErr.Panicln("Error loading .env file")
 	}
 
-	InitTasks()
-
-	// Should fail
-	fmt.Println("Delete email task that doesn't exist...")
-	DeleteEmailTask("id_that_doesn't_exist")
-	fmt.Println("Should have thrown an error ^")
-
-	// Should succeed
-	fmt.Println("Creating email task...")
-	taskIds := CreateEmailTask("schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161")
-	fmt.Println("Email task created")
-
-	time.Sleep(10 * time.Second)
-	for _, taskId := range taskIds {
-		fmt.Println("Deleting email task with taskId: ", taskId)
-		DeleteEmailTask(taskId)
-		fmt.Println("Deleted email task with taskId: ", taskId)
+	queue, err := NewQueue(context.Background())
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	fmt.Println("Done.")
+	return queue
 }
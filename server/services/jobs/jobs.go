@@ -0,0 +1,147 @@
+/* Background jobs that run later or outside of a request, e.g. reminder emails */
+package jobs
+
+// Jobs are scheduled with Enqueue on the configured backend, either the local scheduler (jobs stored in the
+// storage backend and run by every server) or Google Cloud Tasks (which posts each job back to /api/jobs/run).
+// Either way, a job is run by the handler registered for its type with Register.
+//
+// Job ids are opaque strings that can be stored (e.g. models.Remindee.TaskIds) and passed to Cancel. Ids of Cloud
+// Tasks tasks are canceled on Cloud Tasks even if the local scheduler is used, so that switching backends doesn't
+// orphan scheduled jobs.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/config"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/services/gcloud"
+)
+
+// Runs a job with the payload it was scheduled with. Returning an error retries the job later
+type Handler func(ctx context.Context, payload bson.M) error
+
+// A backend that jobs are scheduled on
+type Queue interface {
+	// Schedules a job to run at runAt and returns its id
+	Enqueue(ctx context.Context, jobType string, payload bson.M, runAt time.Time) (string, error)
+
+	// Cancels the job with the given id if it hasn't run yet
+	Cancel(ctx context.Context, id string) error
+
+	// Returns an error if jobs can't be scheduled or run
+	Ping(ctx context.Context) error
+}
+
+var (
+	handlers = make(map[string]Handler)
+	queue    Queue
+
+	// Used to cancel Cloud Tasks tasks, nil if there are no Cloud Tasks credentials
+	cloudTasks *gcloud.Queue
+)
+
+// Sets the handler of the job type. Must be called before Init, e.g. from the init function of the package that
+// defines the job
+func Register(jobType string, handler Handler) {
+	if _, ok := handlers[jobType]; ok {
+		panic(fmt.Sprintf("job type %s is registered twice", jobType))
+	}
+	handlers[jobType] = handler
+}
+
+// Sets up the backend from the config and starts running jobs. Returns a function that stops running jobs, which
+// waits for the running job to finish
+func Init() func() {
+	cfg := config.Get().Tasks
+
+	if cfg.Backend == "cloudtasks" || len(cfg.ServiceAccountKeyPath) > 0 {
+		var err error
+		cloudTasks, err = gcloud.NewQueue(context.Background())
+		if err != nil {
+			logger.StdErr.Panicln(err)
+		}
+	}
+
+	if cfg.Backend == "cloudtasks" {
+		queue = cloudTasks
+		return func() { cloudTasks.Close() }
+	}
+
+	local := newLocalQueue(cfg)
+	local.start()
+	queue = local
+	return func() {
+		local.stop()
+		if cloudTasks != nil {
+			cloudTasks.Close()
+		}
+	}
+}
+
+// Schedules a job of the given type to run at runAt, or as soon as possible if runAt has passed, and returns its id
+func Enqueue(ctx context.Context, jobType string, payload bson.M, runAt time.Time) (string, error) {
+	if queue == nil {
+		return "", errors.New("jobs.Init hasn't been called")
+	}
+	if _, ok := handlers[jobType]; !ok {
+		return "", fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	return queue.Enqueue(ctx, jobType, payload, runAt)
+}
+
+// Cancels the job with the given id if it hasn't run yet
+func Cancel(ctx context.Context, id string) error {
+	if gcloud.IsTaskName(id) {
+		if cloudTasks == nil {
+			return fmt.Errorf("can't cancel Cloud Tasks task %s without a service account key", id)
+		}
+		return cloudTasks.Cancel(ctx, id)
+	}
+
+	if queue == nil {
+		return errors.New("jobs.Init hasn't been called")
+	}
+	return queue.Cancel(ctx, id)
+}
+
+// Returns an error if jobs can't be scheduled or run
+func Ping(ctx context.Context) error {
+	if queue == nil {
+		return errors.New("jobs.Init hasn't been called")
+	}
+	return queue.Ping(ctx)
+}
+
+// Runs the handler of the job type with the payload. Panics in the handler are returned as errors
+func Run(ctx context.Context, jobType string, payload bson.M) (err error) {
+	handler, ok := handlers[jobType]
+	if !ok {
+		return fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s job: %v\n%s", jobType, r, debug.Stack())
+		}
+		metrics.JobsRun.WithLabelValues(jobType, metrics.Result(err)).Inc()
+	}()
+
+	return handler(ctx, payload)
+}
+
+// Decodes the payload into v, which is usually a struct with bson tags. Payloads go through BSON or JSON before
+// they're run, so handlers shouldn't type assert their fields
+func Decode(payload bson.M, v interface{}) error {
+	data, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
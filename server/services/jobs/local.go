@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+)
+
+// Failed jobs are retried after 30s, 1m, 2m, ... up to an hour
+const (
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Runs the jobs in db.Jobs. Every server runs a local queue, and a job is leased to one of them at a time
+type localQueue struct {
+	// Identifies this server in job leases
+	worker string
+
+	pollInterval time.Duration
+	lease        time.Duration
+	maxAttempts  int
+
+	// Wakes up the scheduler when a job that is already due is enqueued
+	wake    chan struct{}
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newLocalQueue(cfg config.Tasks) *localQueue {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return &localQueue{
+		worker:       fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix)),
+		pollInterval: cfg.PollInterval(),
+		lease:        cfg.Lease(),
+		maxAttempts:  cfg.MaxAttempts,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+}
+
+func (q *localQueue) Enqueue(ctx context.Context, jobType string, payload bson.M, runAt time.Time) (string, error) {
+	job := models.Job{
+		Type:        jobType,
+		Payload:     payload,
+		Status:      models.JobPending,
+		RunAt:       runAt,
+		MaxAttempts: q.maxAttempts,
+		CreatedAt:   time.Now(),
+	}
+	if err := db.Jobs.Insert(ctx, &job); err != nil {
+		return "", err
+	}
+
+	if !runAt.After(time.Now()) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return job.Id.Hex(), nil
+}
+
+func (q *localQueue) Cancel(ctx context.Context, id string) error {
+	jobId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid job id %q: %w", id, err)
+	}
+
+	return db.Jobs.Cancel(ctx, jobId)
+}
+
+func (q *localQueue) Ping(ctx context.Context) error {
+	select {
+	case <-q.done:
+		return errors.New("the scheduler is stopped")
+	default:
+		return nil
+	}
+}
+
+// Starts running due jobs in the background
+func (q *localQueue) start() {
+	q.stopped.Add(1)
+	go func() {
+		defer q.stopped.Done()
+
+		ticker := time.NewTicker(q.pollInterval)
+		defer ticker.Stop()
+		for {
+			q.runDue()
+
+			select {
+			case <-q.done:
+				return
+			case <-ticker.C:
+			case <-q.wake:
+			}
+		}
+	}()
+}
+
+// Stops running jobs, waiting for the running job to finish
+func (q *localQueue) stop() {
+	close(q.done)
+	q.stopped.Wait()
+}
+
+// Runs jobs one at a time until none are due or the queue is stopped
+func (q *localQueue) runDue() {
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+
+		now := time.Now()
+		job, err := db.Jobs.Lease(context.Background(), q.worker, now, now.Add(q.lease))
+		if err != nil {
+			logger.Logger.Error("Failed to lease job", "error", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		q.run(job)
+	}
+}
+
+// Runs the job and records the result. The job may run for as long as its lease, after which another server
+// would run it again
+func (q *localQueue) run(job *models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.lease)
+	defer cancel()
+	l := logger.Logger.With("jobId", job.Id.Hex(), "jobType", job.Type, "attempt", job.Attempts)
+
+	err := Run(ctx, job.Type, job.Payload)
+	if err == nil {
+		if err := db.Jobs.Complete(context.Background(), job.Id, q.worker); err != nil {
+			l.Error("Failed to complete job", "error", err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if job.Attempts < job.MaxAttempts {
+		t := time.Now().Add(backoff(job.Attempts))
+		retryAt = &t
+		l.Warn("Job failed, retrying", "error", err, "retryAt", t)
+	} else {
+		l.Error("Job failed", "error", err)
+	}
+	if err := db.Jobs.Fail(context.Background(), job.Id, q.worker, err.Error(), retryAt); err != nil {
+		l.Error("Failed to record job failure", "error", err)
+	}
+}
+
+// Returns how long to wait before retrying a job that has failed the given number of times
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
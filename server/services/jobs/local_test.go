@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+)
+
+func TestLocalQueue(t *testing.T) {
+	ctx := context.Background()
+	logger.Init(io.Discard)
+	db.InitMemory("")
+
+	var ran []string
+	handlers = map[string]Handler{
+		"greet": func(ctx context.Context, payload bson.M) error {
+			var p struct {
+				Name string `bson:"name"`
+			}
+			if err := Decode(payload, &p); err != nil {
+				return err
+			}
+			ran = append(ran, p.Name)
+			return nil
+		},
+		"fail": func(ctx context.Context, payload bson.M) error {
+			return errors.New("always fails")
+		},
+	}
+	q := newLocalQueue(config.Tasks{PollIntervalSeconds: 1, LeaseSeconds: 60, MaxAttempts: 2})
+
+	now := time.Now()
+	later, _ := q.Enqueue(ctx, "greet", bson.M{"name": "later"}, now.Add(time.Hour))
+	q.Enqueue(ctx, "greet", bson.M{"name": "second"}, now.Add(-time.Minute))
+	q.Enqueue(ctx, "greet", bson.M{"name": "first"}, now.Add(-time.Hour))
+	canceled, _ := q.Enqueue(ctx, "greet", bson.M{"name": "canceled"}, now.Add(-time.Second))
+	failing, _ := q.Enqueue(ctx, "fail", nil, now.Add(-time.Second))
+	if err := q.Cancel(ctx, canceled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Due jobs run in order, and jobs that aren't due or are canceled don't run
+	q.runDue()
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Errorf("expected first and second to run, got %v", ran)
+	}
+	if job := getJob(t, later); job.Status != models.JobPending || job.Attempts != 0 {
+		t.Errorf("expected the later job to be pending, got %+v", job)
+	}
+	if job := getJob(t, canceled); job.Status != models.JobCanceled {
+		t.Errorf("expected the canceled job to be canceled, got %+v", job)
+	}
+
+	// Failed jobs are retried after a backoff, until they run out of attempts
+	job := getJob(t, failing)
+	if job.Status != models.JobPending || job.Attempts != 1 || job.LastError != "always fails" || time.Until(job.RunAt) < 29*time.Second {
+		t.Errorf("expected the failed job to be retried later, got %+v", job)
+	}
+	leased, err := db.Jobs.Lease(ctx, q.worker, job.RunAt, job.RunAt.Add(time.Minute))
+	if err != nil || leased == nil || leased.Id != job.Id {
+		t.Fatalf("expected to lease the failed job once it's due, got %+v, %v", leased, err)
+	}
+	if other, _ := db.Jobs.Lease(ctx, "other", job.RunAt, job.RunAt.Add(time.Minute)); other != nil {
+		t.Errorf("expected a leased job not to be leased again, got %+v", other)
+	}
+	q.run(leased)
+	if job := getJob(t, failing); job.Status != models.JobFailed || job.FinishedAt == nil {
+		t.Errorf("expected the job to fail after its last attempt, got %+v", job)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, d := range expected {
+		if got := backoff(i + 1); got != d {
+			t.Errorf("backoff(%d) = %v, expected %v", i+1, got, d)
+		}
+	}
+	if got := backoff(20); got != time.Hour {
+		t.Errorf("expected backoff to be capped at an hour, got %v", got)
+	}
+}
+
+func getJob(t *testing.T, id string) *models.Job {
+	t.Helper()
+	jobId, _ := primitive.ObjectIDFromHex(id)
+	job, err := db.Jobs.GetById(context.Background(), jobId)
+	if err != nil || job == nil {
+		t.Fatalf("failed to get job %s: %v", id, err)
+	}
+	return job
+}
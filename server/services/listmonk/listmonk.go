@@ -2,31 +2,30 @@ package listmonk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/config"
 	"schej.it/server/logger"
 )
 
 // Adds the given user to the Listmonk contact list
 // If subscriberId is not nil, then UPDATE the user instead of adding user
-func AddUserToListmonk(email string, firstName string, lastName string, picture string, subscriberId *int) {
-	if os.Getenv("LISTMONK_ENABLED") == "false" {
+func AddUserToListmonk(ctx context.Context, email string, firstName string, lastName string, picture string, subscriberId *int) {
+	listmonkConfig := config.Get().Listmonk
+	if !listmonkConfig.Enabled {
 		return
 	}
 
-	url := os.Getenv("LISTMONK_URL")
-	username := os.Getenv("LISTMONK_USERNAME")
-	password := os.Getenv("LISTMONK_PASSWORD")
-	listIdString := os.Getenv("LISTMONK_LIST_ID")
-
-	listId, err := strconv.Atoi(listIdString)
-	if err != nil {
-		logger.StdErr.Println(err)
+	url := listmonkConfig.Url
+	username := listmonkConfig.Username
+	password := listmonkConfig.Password
+	listId := listmonkConfig.ListId
+	if listId == 0 {
+		logger.StdErr.Println("Not adding user to listmonk, the listmonk list id is not configured")
 		return
 	}
 
@@ -45,13 +44,16 @@ func AddUserToListmonk(email string, firstName string, lastName string, picture
 	})
 	bodyBuffer := bytes.NewBuffer(body)
 
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Email())
+	defer cancel()
+
 	var req *http.Request
 	if subscriberId != nil {
 		// Existing subscriber
-		req, _ = http.NewRequest("PUT", fmt.Sprintf("%s/api/subscribers/%d", url, *subscriberId), bodyBuffer)
+		req, _ = http.NewRequestWithContext(ctx, "PUT", fmt.Sprintf("%s/api/subscribers/%d", url, *subscriberId), bodyBuffer)
 	} else {
 		// New subscriber
-		req, _ = http.NewRequest("POST", fmt.Sprintf("%s/api/subscribers", url), bodyBuffer)
+		req, _ = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/subscribers", url), bodyBuffer)
 	}
 	req.SetBasicAuth(username, password)
 	req.Header.Set("Content-Type", "application/json")
@@ -66,16 +68,20 @@ func AddUserToListmonk(email string, firstName string, lastName string, picture
 
 // Check if the user is already in listmonk
 // Returns a bool representing whether the subscriber exists and the id of the subscriber if it does exist
-func DoesUserExist(email string) (bool, *int) {
-	if os.Getenv("LISTMONK_ENABLED") == "false" {
+func DoesUserExist(ctx context.Context, email string) (bool, *int) {
+	listmonkConfig := config.Get().Listmonk
+	if !listmonkConfig.Enabled {
 		return false, nil
 	}
 
-	url := os.Getenv("LISTMONK_URL")
-	username := os.Getenv("LISTMONK_USERNAME")
-	password := os.Getenv("LISTMONK_PASSWORD")
+	url := listmonkConfig.Url
+	username := listmonkConfig.Username
+	password := listmonkConfig.Password
 
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/subscribers?query=subscribers.email='%s'", url, email), nil)
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Email())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/subscribers?query=subscribers.email='%s'", url, email), nil)
 	req.SetBasicAuth(username, password)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -106,51 +112,98 @@ func DoesUserExist(email string) (bool, *int) {
 	}
 }
 
-// Send a transactional email using the specified template and data
-func SendEmail(email string, templateId int, data bson.M) {
-	if os.Getenv("LISTMONK_ENABLED") == "false" {
-		return
+// Send a transactional email using the specified template, data, and extra email headers. Errors are logged, and
+// returned for callers that retry
+func SendEmail(ctx context.Context, email string, templateId int, data bson.M, headers map[string]string) error {
+	listmonkConfig := config.Get().Listmonk
+	if !listmonkConfig.Enabled {
+		return nil
+	}
+	if templateId == 0 {
+		logger.StdErr.Println("Not sending email, its listmonk template id is not configured")
+		return nil
 	}
 
-	// Get listmonk url env vars
-	listmonkUrl := os.Getenv("LISTMONK_URL")
-	listmonkUsername := os.Getenv("LISTMONK_USERNAME")
-	listmonkPassword := os.Getenv("LISTMONK_PASSWORD")
+	// Get listmonk url config
+	listmonkUrl := listmonkConfig.Url
+	listmonkUsername := listmonkConfig.Username
+	listmonkPassword := listmonkConfig.Password
 
-	// Construct body
+	// Construct body, listmonk takes headers as a list of single header objects
+	headerList := bson.A{}
+	for name, value := range headers {
+		headerList = append(headerList, bson.M{name: value})
+	}
 	body, err := json.Marshal(bson.M{
 		"subscriber_email": email,
 		"template_id":      templateId,
 		"data":             data,
+		"headers":          headerList,
 		"content_type":     "html",
 	})
 	if err != nil {
 		logger.StdErr.Println(err)
-		return
+		return err
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Email())
+	defer cancel()
+
 	// Construct request
-	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/tx", listmonkUrl), bytes.NewBuffer(body))
+	req, _ := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/tx", listmonkUrl), bytes.NewBuffer(body))
 	req.SetBasicAuth(listmonkUsername, listmonkPassword)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Execute request
 	response, err := http.DefaultClient.Do(req)
+	if err == nil {
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			err = fmt.Errorf("listmonk returned %s when sending template %d", response.Status, templateId)
+		}
+	}
 	if err != nil {
 		logger.StdErr.Println(err)
 	}
-	defer response.Body.Close()
+
+	return err
 }
 
-// Send a transactional email using the specified template and data. Adds subscriber if they don't exist
-func SendEmailAddSubscriberIfNotExist(email string, templateId int, data bson.M) {
-	if os.Getenv("LISTMONK_ENABLED") == "false" {
-		return
+// Send a transactional email using the specified template, data, and headers. Adds subscriber if they don't exist
+func SendEmailAddSubscriberIfNotExist(ctx context.Context, email string, templateId int, data bson.M, headers map[string]string) error {
+	if !config.Get().Listmonk.Enabled {
+		return nil
 	}
 
-	if exists, _ := DoesUserExist(email); !exists {
-		AddUserToListmonk(email, "", "", "", nil)
+	if exists, _ := DoesUserExist(ctx, email); !exists {
+		AddUserToListmonk(ctx, email, "", "", "", nil)
+	}
+
+	return SendEmail(ctx, email, templateId, data, headers)
+}
+
+// Returns an error if listmonk is enabled and configured but can't be reached
+func Ping(ctx context.Context) error {
+	listmonkConfig := config.Get().Listmonk
+	if !listmonkConfig.Enabled || len(listmonkConfig.Url) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/health", listmonkConfig.Url), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(listmonkConfig.Username, listmonkConfig.Password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("listmonk health check returned %s", resp.Status)
 	}
 
-	SendEmail(email, templateId, data)
+	return nil
 }
@@ -1,6 +1,7 @@
 package listmonk
 
 import (
+	"context"
 	"log"
 	"os"
 	"testing"
@@ -26,8 +27,8 @@ func TestSendEmail(t *testing.T) {
 		logger.StdErr.Panicln("Error loading .env file")
 	}
 
-	SendEmail("schej.team@gmail.com", 8, bson.M{
+	SendEmail(context.Background(), "schej.team@gmail.com", 8, bson.M{
 		"eventName": "casablanca",
 		"eventUrl":  "http://localhost:8080/e/65e636bb760d3ea2e113e161",
-	})
+	}, nil)
 }
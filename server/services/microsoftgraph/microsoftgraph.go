@@ -1,9 +1,10 @@
 package microsoftgraph
 
 import (
+	"context"
 	"encoding/json"
 
-	"schej.it/server/logger"
+	"schej.it/server/config"
 	"schej.it/server/models"
 	"schej.it/server/services"
 )
@@ -14,14 +15,21 @@ type UserInfo struct {
 	Email     string `json:"mail"`
 }
 
-func GetUserInfo(user *models.User, calendarAuth *models.OAuth2CalendarAuth) UserInfo {
-	response := services.CallApi(
+func GetUserInfo(ctx context.Context, user *models.User, calendarAuth *models.OAuth2CalendarAuth) (UserInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Get().Timeouts.Auth())
+	defer cancel()
+
+	response, err := services.CallApi(
+		ctx,
 		user,
 		calendarAuth,
 		"GET",
 		"https://graph.microsoft.com/v1.0/me?$select=givenName,surname,mail",
 		nil,
 	)
+	if err != nil {
+		return UserInfo{}, err
+	}
 	defer response.Body.Close()
 
 	userResponse := struct {
@@ -31,12 +39,12 @@ func GetUserInfo(user *models.User, calendarAuth *models.OAuth2CalendarAuth) Use
 	}{}
 
 	if err := json.NewDecoder(response.Body).Decode(&userResponse); err != nil {
-		logger.StdErr.Panicln(err)
+		return UserInfo{}, err
 	}
 
 	return UserInfo{
 		FirstName: userResponse.GivenName,
 		LastName:  userResponse.Surname,
 		Email:     userResponse.Mail,
-	}
+	}, nil
 }
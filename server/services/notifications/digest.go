@@ -0,0 +1,220 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+	"schej.it/server/utils"
+)
+
+// Owners that get some types of notifications in a digest get one email a day at their digest hour, with the
+// activity on their events since the last one. Each activity is stored as a digest item, and the first item after
+// a digest schedules a send digest job for the next digest hour
+
+const sendDigestJob = "send-digest"
+
+// How long after its scheduled time a digest that hasn't been sent is considered lost, e.g. because scheduling its
+// job failed, so that the next activity schedules a new one
+const digestStaleAfter = time.Hour
+
+func init() {
+	jobs.Register(sendDigestJob, sendDigest)
+}
+
+// Messages that are batched into the recipient's daily digest when they get the message's type in a digest
+type digestible interface {
+	digestItem() models.DigestItem
+}
+
+// Records activity on an owner's event for their daily digest, if they get its type of notification in a digest.
+// Used for activity that doesn't have an email of its own, e.g. declines
+func RecordActivity(ctx context.Context, ownerId primitive.ObjectID, item models.DigestItem) error {
+	owner, err := db.Users.GetById(ctx, ownerId)
+	if err != nil || owner == nil {
+		return err
+	}
+	if owner.NotificationPreferences.Mode(item.Activity.NotificationType(), item.EventId) != models.NotifyDigest {
+		return nil
+	}
+
+	return addToDigest(ctx, owner, item)
+}
+
+// Adds the message to its recipient's digest. Only users can get notifications in a digest, since the digest hour is
+// set in their preferences
+func queueDigestItem(ctx context.Context, to string, msg digestible) error {
+	user, err := db.Users.GetByEmail(ctx, to)
+	if err != nil || user == nil {
+		return err
+	}
+
+	return addToDigest(ctx, user, msg.digestItem())
+}
+
+// Stores the item and schedules the user's next digest if it isn't already
+func addToDigest(ctx context.Context, user *models.User, item models.DigestItem) error {
+	item.UserId = user.Id
+	item.CreatedAt = time.Now()
+	if err := db.DigestItems.Insert(ctx, &item); err != nil {
+		return err
+	}
+
+	return scheduleDigest(ctx, user)
+}
+
+func scheduleDigest(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	at := nextDigestTime(user, now)
+	claimed, err := db.Users.ClaimDigest(ctx, user.Id, at, now.Add(-digestStaleAfter))
+	if err != nil || !claimed {
+		return err
+	}
+
+	if _, err := jobs.Enqueue(ctx, sendDigestJob, bson.M{"userId": user.Id.Hex()}, at); err != nil {
+		// Let the next activity try again
+		db.Users.SetFields(ctx, user.Id, bson.M{"digestScheduledFor": nil})
+		return err
+	}
+
+	return nil
+}
+
+// Returns the next time it's the user's digest hour in their timezone
+func nextDigestTime(user *models.User, now time.Time) time.Time {
+	// The timezone offset is in minutes behind UTC, like javascript's Date.getTimezoneOffset
+	zone := time.FixedZone("", -user.TimezoneOffset*60)
+	local := now.In(zone)
+
+	next := time.Date(local.Year(), local.Month(), local.Day(), user.NotificationPreferences.GetDigestHour(), 0, 0, 0, zone)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+func sendDigest(ctx context.Context, payload bson.M) error {
+	var p struct {
+		UserId string `bson:"userId"`
+	}
+	if err := jobs.Decode(payload, &p); err != nil {
+		return err
+	}
+	userId, err := primitive.ObjectIDFromHex(p.UserId)
+	if err != nil {
+		return err
+	}
+
+	user, err := db.Users.GetById(ctx, userId)
+	if err != nil || user == nil {
+		return err
+	}
+	items, err := db.DigestItems.GetByUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if len(items) > 0 {
+		digest, err := buildDigest(ctx, user, items)
+		if err != nil {
+			return err
+		}
+		if len(digest.Events) > 0 {
+			if _, err := Send(ctx, user.Email, digest); err != nil {
+				return err
+			}
+		}
+
+		itemIds := utils.Map(items, func(item models.DigestItem) primitive.ObjectID { return item.Id })
+		if err := db.DigestItems.Delete(ctx, itemIds); err != nil {
+			return err
+		}
+	}
+
+	// Activity recorded while this digest was being sent goes in the next one
+	if err := db.Users.SetFields(ctx, userId, bson.M{"digestScheduledFor": nil}); err != nil {
+		return err
+	}
+	remaining, err := db.DigestItems.GetByUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		return scheduleDigest(ctx, user)
+	}
+
+	return nil
+}
+
+// Groups the items by event, in the order of each event's first activity. Events that were deleted are left out
+func buildDigest(ctx context.Context, user *models.User, items []models.DigestItem) (Digest, error) {
+	digest := Digest{FirstName: user.FirstName, Events: make([]DigestEvent, 0)}
+	zone := time.FixedZone("", -user.TimezoneOffset*60)
+
+	itemsByEvent := make(map[primitive.ObjectID][]models.DigestItem)
+	eventIds := make([]primitive.ObjectID, 0)
+	for _, item := range items {
+		if _, ok := itemsByEvent[item.EventId]; !ok {
+			eventIds = append(eventIds, item.EventId)
+		}
+		itemsByEvent[item.EventId] = append(itemsByEvent[item.EventId], item)
+	}
+
+	for _, eventId := range eventIds {
+		event, err := db.Events.GetById(ctx, eventId)
+		if err != nil {
+			return Digest{}, err
+		}
+		if event == nil {
+			continue
+		}
+		if err := db.PopulateEventResponses(ctx, event); err != nil {
+			return Digest{}, err
+		}
+
+		digestEvent := DigestEvent{
+			Name:         event.Name,
+			Url:          eventUrl(event),
+			Activity:     utils.Map(itemsByEvent[eventId], describeActivity),
+			NumResponses: len(event.ResponsesList),
+		}
+		if event.Type != models.GROUP && !utils.Coalesce(event.IsSignUpForm) {
+			if bestTime, available, ok := event.BestTime(); ok {
+				digestEvent.BestTime = fmt.Sprintf("%s (%d of %d available)", event.FormatTime(bestTime.Time().In(zone)), available, len(event.ResponsesList))
+			}
+		}
+		digest.Events = append(digest.Events, digestEvent)
+	}
+
+	return digest, nil
+}
+
+func eventUrl(event *models.Event) string {
+	if event.Type == models.GROUP {
+		return fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId())
+	}
+	return fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId())
+}
+
+func describeActivity(item models.DigestItem) string {
+	switch item.Activity {
+	case models.SignUpActivity:
+		return fmt.Sprintf("%s signed up", item.Name)
+	case models.DeclineActivity:
+		return fmt.Sprintf("%s declined", item.Name)
+	case models.ReminderCompletedActivity:
+		return fmt.Sprintf("%s marked that they responded", item.Name)
+	case models.EveryoneRespondedActivity:
+		return "Everyone you sent reminders to responded"
+	case models.ResponsesThresholdActivity:
+		return fmt.Sprintf("Reached %d responses", item.NumResponses)
+	default:
+		return fmt.Sprintf("%s responded", item.Name)
+	}
+}
@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/config"
+	"schej.it/server/services/listmonk"
+)
+
+// Sends messages with their listmonk transactional templates, which are rendered by listmonk with the message's
+// fields, unsubscribeUrl, and muteUrl as data
+type listmonkSender struct{}
+
+func (listmonkSender) send(ctx context.Context, e email) error {
+	encoded, err := json.Marshal(e.Message)
+	if err != nil {
+		return err
+	}
+	var data bson.M
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return err
+	}
+
+	data["unsubscribeUrl"] = e.UnsubscribeUrl
+	data["muteUrl"] = e.MuteUrl
+
+	return listmonk.SendEmailAddSubscriberIfNotExist(ctx, e.To, listmonkTemplateId(e.Message), data, unsubscribeHeaders(e))
+}
+
+// Returns the id of the message's listmonk template, 0 if it isn't configured
+func listmonkTemplateId(msg Message) int {
+	templates := config.Get().Listmonk.Templates
+	switch msg.Template() {
+	case GroupInvite{}.Template():
+		return templates.AvailabilityGroupInvite
+	case AddedAttendees{}.Template():
+		return templates.AddedAttendee
+	case SomeoneResponded{}.Template():
+		return templates.SomeoneResponded
+	case SomeoneRespondedGroup{}.Template():
+		return templates.SomeoneRespondedGroup
+	case EveryoneResponded{}.Template():
+		return templates.EveryoneResponded
+	case ResponsesThreshold{}.Template():
+		return templates.ResponsesThreshold
+	case Reminder{Stage: InitialReminder}.Template():
+		return templates.InitialReminder
+	case Reminder{Stage: SecondReminder}.Template():
+		return templates.SecondReminder
+	case Reminder{Stage: FinalReminder}.Template():
+		return templates.FinalReminder
+	case ReconnectCalendar{}.Template():
+		return templates.ReconnectCalendar
+	case Digest{}.Template():
+		return templates.Digest
+	case Nudge{}.Template():
+		return templates.Nudge
+	}
+
+	return 0
+}
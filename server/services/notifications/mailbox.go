@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"schej.it/server/config"
+	"schej.it/server/logger"
+)
+
+// Renders messages and writes each one to a .eml file in the mailbox directory instead of sending it, for
+// development
+type fileSender struct {
+	cfg config.Email
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+func (s fileSender) send(ctx context.Context, e email) error {
+	m, err := newMessage(s.cfg.From, e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.cfg.MailboxPath, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%s.eml", time.Now().Format("20060102-150405.000000"), e.Message.Template(), unsafeFileChars.ReplaceAllString(e.To, "_"))
+	path := filepath.Join(s.cfg.MailboxPath, name)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := m.WriteTo(file); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("Wrote email to mailbox", "to", e.To, "subject", e.Message.Subject(), "path", path)
+	return nil
+}
@@ -0,0 +1,223 @@
+package notifications
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Invites an attendee to an availability group
+type GroupInvite struct {
+	EventId   primitive.ObjectID `json:"-"`
+	OwnerName string             `json:"ownerName"`
+	GroupName string             `json:"groupName"`
+	GroupUrl  string             `json:"groupUrl"`
+}
+
+func (GroupInvite) Template() string              { return "group-invite" }
+func (GroupInvite) Type() models.NotificationType { return models.GroupInviteNotification }
+func (m GroupInvite) Event() primitive.ObjectID   { return m.EventId }
+func (m GroupInvite) Subject() string {
+	return fmt.Sprintf("%s invited you to %s", m.OwnerName, m.GroupName)
+}
+
+// Tells the attendees of an availability group that people were added to it
+type AddedAttendees struct {
+	EventId   primitive.ObjectID `json:"-"`
+	OwnerName string             `json:"ownerName"`
+	GroupName string             `json:"groupName"`
+	GroupUrl  string             `json:"groupUrl"`
+	Emails    []string           `json:"emails"`
+}
+
+func (AddedAttendees) Template() string              { return "added-attendees" }
+func (AddedAttendees) Type() models.NotificationType { return models.GroupInviteNotification }
+func (m AddedAttendees) Event() primitive.ObjectID   { return m.EventId }
+func (m AddedAttendees) Subject() string {
+	return fmt.Sprintf("New people were added to %s", m.GroupName)
+}
+
+// Tells the owner of an event that someone responded
+type SomeoneResponded struct {
+	EventId        primitive.ObjectID `json:"-"`
+	IsSignUp       bool               `json:"-"`
+	OwnerName      string             `json:"ownerName"`
+	EventName      string             `json:"eventName"`
+	EventUrl       string             `json:"eventUrl"`
+	RespondentName string             `json:"respondentName"`
+}
+
+func (SomeoneResponded) Template() string              { return "someone-responded" }
+func (SomeoneResponded) Type() models.NotificationType { return models.ResponseNotification }
+func (m SomeoneResponded) Event() primitive.ObjectID   { return m.EventId }
+func (m SomeoneResponded) digestItem() models.DigestItem {
+	activity := models.ResponseActivity
+	if m.IsSignUp {
+		activity = models.SignUpActivity
+	}
+	return models.DigestItem{EventId: m.EventId, Activity: activity, Name: m.RespondentName}
+}
+func (m SomeoneResponded) Subject() string {
+	return fmt.Sprintf("%s responded to %s", m.RespondentName, m.EventName)
+}
+
+// Tells the owner of an availability group that someone added their availability
+type SomeoneRespondedGroup struct {
+	EventId        primitive.ObjectID `json:"-"`
+	OwnerName      string             `json:"ownerName"`
+	GroupName      string             `json:"groupName"`
+	GroupUrl       string             `json:"groupUrl"`
+	RespondentName string             `json:"respondentName"`
+}
+
+func (SomeoneRespondedGroup) Template() string              { return "someone-responded-group" }
+func (SomeoneRespondedGroup) Type() models.NotificationType { return models.ResponseNotification }
+func (m SomeoneRespondedGroup) Event() primitive.ObjectID   { return m.EventId }
+func (m SomeoneRespondedGroup) digestItem() models.DigestItem {
+	return models.DigestItem{EventId: m.EventId, Activity: models.ResponseActivity, Name: m.RespondentName}
+}
+func (m SomeoneRespondedGroup) Subject() string {
+	return fmt.Sprintf("%s added their availability to %s", m.RespondentName, m.GroupName)
+}
+
+// Tells the owner of an event that all of its remindees responded
+type EveryoneResponded struct {
+	EventId   primitive.ObjectID `json:"-"`
+	EventName string             `json:"eventName"`
+	EventUrl  string             `json:"eventUrl"`
+}
+
+func (EveryoneResponded) Template() string              { return "everyone-responded" }
+func (EveryoneResponded) Type() models.NotificationType { return models.EveryoneRespondedNotification }
+func (m EveryoneResponded) Event() primitive.ObjectID   { return m.EventId }
+func (m EveryoneResponded) digestItem() models.DigestItem {
+	return models.DigestItem{EventId: m.EventId, Activity: models.EveryoneRespondedActivity}
+}
+func (m EveryoneResponded) Subject() string {
+	return fmt.Sprintf("Everyone responded to %s", m.EventName)
+}
+
+// Tells the owner of an event that it reached their sendEmailAfterXResponses
+type ResponsesThreshold struct {
+	EventId      primitive.ObjectID `json:"-"`
+	OwnerName    string             `json:"ownerName"`
+	EventName    string             `json:"eventName"`
+	EventUrl     string             `json:"eventUrl"`
+	NumResponses int                `json:"numResponses"`
+}
+
+func (ResponsesThreshold) Template() string { return "responses-threshold" }
+func (ResponsesThreshold) Type() models.NotificationType {
+	return models.ResponsesThresholdNotification
+}
+func (m ResponsesThreshold) Event() primitive.ObjectID { return m.EventId }
+func (m ResponsesThreshold) digestItem() models.DigestItem {
+	return models.DigestItem{EventId: m.EventId, Activity: models.ResponsesThresholdActivity, NumResponses: m.NumResponses}
+}
+func (m ResponsesThreshold) Subject() string {
+	return fmt.Sprintf("%s has %d responses", m.EventName, m.NumResponses)
+}
+
+// Which of the reminders sent to a remindee a reminder is
+type ReminderStage string
+
+const (
+	InitialReminder ReminderStage = "initial"
+	SecondReminder  ReminderStage = "second"
+	FinalReminder   ReminderStage = "final"
+)
+
+// Reminds a remindee to respond to an event
+type Reminder struct {
+	EventId     primitive.ObjectID `json:"-"`
+	Stage       ReminderStage      `json:"-"`
+	OwnerName   string             `json:"ownerName"`
+	EventName   string             `json:"eventName"`
+	EventUrl    string             `json:"eventUrl"`
+	FinishedUrl string             `json:"finishedUrl"`
+
+	// The owner's message and the response deadline, empty if the event doesn't have them
+	Message  string `json:"message"`
+	Deadline string `json:"deadline"`
+}
+
+func (m Reminder) Template() string            { return fmt.Sprintf("%s-reminder", m.Stage) }
+func (Reminder) Type() models.NotificationType { return models.ReminderNotification }
+func (m Reminder) Event() primitive.ObjectID   { return m.EventId }
+func (m Reminder) Subject() string {
+	if m.Stage == FinalReminder {
+		return fmt.Sprintf("Last reminder: %s is waiting for your availability", m.OwnerName)
+	}
+	return fmt.Sprintf("%s wants your availability for %s", m.OwnerName, m.EventName)
+}
+
+// An owner's manual reminder to someone who hasn't responded, or whose availability doesn't include the best time
+type Nudge struct {
+	EventId   primitive.ObjectID `json:"-"`
+	OwnerName string             `json:"ownerName"`
+	EventName string             `json:"eventName"`
+	EventUrl  string             `json:"eventUrl"`
+	IsGroup   bool               `json:"isGroup"`
+
+	// The best time so far if the recipient isn't available for it, otherwise empty
+	BestTime string `json:"bestTime"`
+
+	// Where remindees say they've already responded, empty for everyone else
+	FinishedUrl string `json:"finishedUrl"`
+}
+
+func (Nudge) Template() string              { return "nudge" }
+func (Nudge) Type() models.NotificationType { return models.ReminderNotification }
+func (m Nudge) Event() primitive.ObjectID   { return m.EventId }
+func (m Nudge) Subject() string {
+	if len(m.BestTime) > 0 {
+		return fmt.Sprintf("Can you make %s for %s?", m.BestTime, m.EventName)
+	}
+	return fmt.Sprintf("%s is waiting for your availability for %s", m.OwnerName, m.EventName)
+}
+
+// Asks a user to reconnect a calendar account that needs to be reauthenticated
+type ReconnectCalendar struct {
+	FirstName     string `json:"firstName"`
+	CalendarEmail string `json:"calendarEmail"`
+	CalendarType  string `json:"calendarType"`
+	ReconnectUrl  string `json:"reconnectUrl"`
+}
+
+func (ReconnectCalendar) Template() string              { return "reconnect-calendar" }
+func (ReconnectCalendar) Type() models.NotificationType { return models.CalendarNotification }
+func (ReconnectCalendar) Event() primitive.ObjectID     { return primitive.NilObjectID }
+func (m ReconnectCalendar) Subject() string {
+	return fmt.Sprintf("Reconnect your calendar %s", m.CalendarEmail)
+}
+
+// The daily digest of the activity on a user's events, for the types of notifications they get in a digest
+type Digest struct {
+	FirstName string        `json:"firstName"`
+	Events    []DigestEvent `json:"events"`
+}
+
+type DigestEvent struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+
+	// What happened since the last digest, e.g. "Bob responded"
+	Activity []string `json:"activity"`
+
+	NumResponses int `json:"numResponses"`
+
+	// The time the most respondents are available, e.g. "Tue, Mar 4 at 2:00 PM (5 of 6 available)". Empty for
+	// groups, sign up forms, and events without availability
+	BestTime string `json:"bestTime"`
+}
+
+func (Digest) Template() string              { return "digest" }
+func (Digest) Type() models.NotificationType { return models.DigestNotification }
+func (Digest) Event() primitive.ObjectID     { return primitive.NilObjectID }
+func (m Digest) Subject() string {
+	if len(m.Events) == 1 {
+		return fmt.Sprintf("Your daily schej digest: %s", m.Events[0].Name)
+	}
+	return fmt.Sprintf("Your daily schej digest: %d events", len(m.Events))
+}
@@ -0,0 +1,150 @@
+/* Notification emails, delivered by the backend in config.Email to the recipients whose preferences allow them */
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/models"
+)
+
+// A notification email. Each notification is a struct whose fields are the data its template is rendered with
+type Message interface {
+	// Name of the message's template in templates/, and of its listmonk template id
+	Template() string
+	Subject() string
+
+	// The preference that controls whether the recipient gets the message
+	Type() models.NotificationType
+
+	// The event the message is about, which the recipient can mute. Zero if it isn't about an event
+	Event() primitive.ObjectID
+}
+
+// A message to a recipient, with the links the recipient can follow to stop getting it
+type email struct {
+	To             string
+	Message        Message
+	UnsubscribeUrl string
+	MuteUrl        string // Empty if the message isn't about an event
+}
+
+// Delivers rendered or listmonk templated emails
+type sender interface {
+	send(ctx context.Context, e email) error
+}
+
+// Sends the message to the email address, unless the recipient turned off its type or muted its event, or adds it
+// to their daily digest if they get its type in a digest. Returns whether the message was sent or added to a digest.
+// Errors are logged, and returned for callers that retry. Messages that the backend isn't configured to send are
+// skipped
+func Send(ctx context.Context, to string, msg Message) (bool, error) {
+	log := logger.FromContext(ctx).With("template", msg.Template())
+	prefs, err := GetPreferences(ctx, to)
+	if err != nil {
+		log.Error("Failed to get notification preferences", "error", err)
+		return false, err
+	}
+	switch prefs.Mode(msg.Type(), msg.Event()) {
+	case models.NotifyOff:
+		log.Debug("Not sending email, the recipient turned it off")
+		return false, nil
+	case models.NotifyDigest:
+		if d, ok := msg.(digestible); ok {
+			if err := queueDigestItem(ctx, to, d); err != nil {
+				log.Error("Failed to add to digest", "error", err)
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	if !Configured(msg) {
+		log.Debug("Not sending email, it isn't configured")
+		return false, nil
+	}
+
+	e := email{
+		To:             to,
+		Message:        msg,
+		UnsubscribeUrl: Unsubscribe{Email: to, Type: msg.Type()}.Url(),
+	}
+	if eventId := msg.Event(); !eventId.IsZero() {
+		e.MuteUrl = Unsubscribe{Email: to, EventId: eventId}.Url()
+	}
+
+	err = backend().send(ctx, e)
+	metrics.EmailsSent.WithLabelValues(msg.Template(), metrics.Result(err)).Inc()
+	if err != nil {
+		log.Error("Failed to send email", "error", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Counts n more emails of the kind (e.g. "reminder") against the sender's daily limit, which is a user or an ip like
+// "user:<id>". Returns errs.EmailLimitExceeded without counting them if they would go over the limit. A limit of 0
+// is disabled
+func ReserveEmails(ctx context.Context, kind string, sender string, n int, limit int) error {
+	if n == 0 || limit <= 0 {
+		return nil
+	}
+
+	key := "emails:" + kind + ":" + sender
+	count, resetAt, err := db.RateLimits.Increment(ctx, key, n, 24*time.Hour)
+	if err != nil {
+		// Like request rate limits, emails are let through if they can't be counted
+		logger.FromContext(ctx).Error("Failed to count emails", "kind", kind, "error", err)
+		return nil
+	}
+	if count > limit {
+		ReleaseEmails(ctx, kind, sender, n, limit)
+		metrics.RateLimited.WithLabelValues(kind + "-emails").Inc()
+		return errs.EmailLimitExceeded.WithDetails(map[string]interface{}{"limit": limit, "resetAt": resetAt})
+	}
+
+	return nil
+}
+
+// Uncounts n emails that were reserved with ReserveEmails but not sent
+func ReleaseEmails(ctx context.Context, kind string, sender string, n int, limit int) {
+	if n == 0 || limit <= 0 {
+		return
+	}
+
+	if _, _, err := db.RateLimits.Increment(ctx, "emails:"+kind+":"+sender, -n, 24*time.Hour); err != nil {
+		logger.FromContext(ctx).Error("Failed to uncount emails", "kind", kind, "error", err)
+	}
+}
+
+// Returns whether the configured backend can send the message
+func Configured(msg Message) bool {
+	cfg := config.Get()
+	switch cfg.Email.Backend {
+	case "listmonk":
+		return cfg.Listmonk.Enabled && listmonkTemplateId(msg) != 0
+	case "none":
+		return false
+	default:
+		return true
+	}
+}
+
+func backend() sender {
+	cfg := config.Get().Email
+	switch cfg.Backend {
+	case "smtp":
+		return smtpSender{cfg}
+	case "file":
+		return fileSender{cfg}
+	default:
+		return listmonkSender{}
+	}
+}
@@ -0,0 +1,235 @@
+package notifications
+
+import (
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+)
+
+var messages = []Message{
+	GroupInvite{OwnerName: "Jane", GroupName: "Team sync", GroupUrl: "https://schej.it/g/1"},
+	AddedAttendees{OwnerName: "Jane", GroupName: "Team sync", GroupUrl: "https://schej.it/g/1", Emails: []string{"a@example.com", "b@example.com"}},
+	SomeoneResponded{OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", RespondentName: "Bob Smith"},
+	SomeoneRespondedGroup{OwnerName: "Jane", GroupName: "Team sync", GroupUrl: "https://schej.it/g/1", RespondentName: "Bob Smith"},
+	EveryoneResponded{EventName: "Lunch", EventUrl: "https://schej.it/e/1"},
+	ResponsesThreshold{OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", NumResponses: 5},
+	Reminder{Stage: InitialReminder, OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", FinishedUrl: "https://schej.it/e/1/responded"},
+	Reminder{Stage: SecondReminder, OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", FinishedUrl: "https://schej.it/e/1/responded"},
+	Reminder{Stage: FinalReminder, OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", FinishedUrl: "https://schej.it/e/1/responded"},
+	ReconnectCalendar{FirstName: "Jane", CalendarEmail: "jane@example.com", CalendarType: "google", ReconnectUrl: "https://schej.it/settings"},
+	Nudge{OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", BestTime: "Mon, Oct 19 at 9:30 AM"},
+	Digest{FirstName: "Jane", Events: []DigestEvent{{Name: "Lunch", Url: "https://schej.it/e/1", Activity: []string{"Bob responded"}, NumResponses: 1}}},
+}
+
+func TestRender(t *testing.T) {
+	templateNames := make(map[string]bool)
+	for _, msg := range messages {
+		templateNames[msg.Template()] = true
+
+		body, err := render(email{To: "jane@example.com", Message: msg, UnsubscribeUrl: "https://schej.it/unsubscribe"})
+		if err != nil {
+			t.Errorf("%s: %v", msg.Template(), err)
+			continue
+		}
+		if !strings.Contains(body, "schej.it/") || len(msg.Subject()) == 0 {
+			t.Errorf("%s: expected a subject and a body with a link, got %q, %q", msg.Template(), msg.Subject(), body)
+		}
+	}
+
+	// Every template belongs to a message
+	for name := range templates {
+		if !templateNames[name] {
+			t.Errorf("template %s isn't used by any message", name)
+		}
+	}
+
+	// Data is escaped
+	body, _ := render(email{Message: EveryoneResponded{EventName: "<script>alert(1)</script>", EventUrl: "javascript:alert(1)"}})
+	if strings.Contains(body, "<script>") || strings.Contains(body, `href="javascript:`) {
+		t.Errorf("expected data to be escaped, got %q", body)
+	}
+}
+
+func TestListmonkTemplateId(t *testing.T) {
+	t.Setenv("LISTMONK_INITIAL_EMAIL_REMINDER_ID", "1")
+	t.Setenv("LISTMONK_SECOND_EMAIL_REMINDER_ID", "2")
+	t.Setenv("LISTMONK_FINAL_EMAIL_REMINDER_ID", "3")
+	t.Setenv("LISTMONK_RECONNECT_CALENDAR_EMAIL_ID", "4")
+	t.Setenv("LISTMONK_DIGEST_EMAIL_ID", "5")
+	t.Setenv("LISTMONK_NUDGE_EMAIL_ID", "6")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[int]string)
+	for _, msg := range messages {
+		id := listmonkTemplateId(msg)
+		if id == 0 {
+			t.Errorf("%s: expected a listmonk template id", msg.Template())
+		} else if other, ok := ids[id]; ok {
+			t.Errorf("%s and %s have the same listmonk template id %d", msg.Template(), other, id)
+		}
+		ids[id] = msg.Template()
+	}
+}
+
+func TestMailbox(t *testing.T) {
+	dir := initMailbox(t)
+
+	msg := SomeoneResponded{EventId: primitive.NewObjectID(), OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", RespondentName: "Bob Smith"}
+	if _, err := Send(context.Background(), "jane@example.com", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-someone-responded-jane@example.com.eml") {
+		t.Fatalf("expected one email in the mailbox, got %v", files)
+	}
+	contents, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	for _, expected := range []string{"To: jane@example.com", "Subject: Bob Smith responded to Lunch", "List-Unsubscribe: <", "Mute this event"} {
+		if !strings.Contains(string(contents), expected) {
+			t.Errorf("expected the email to contain %q, got %s", expected, contents)
+		}
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	dir := initMailbox(t)
+	ctx := context.Background()
+	user := models.User{Email: "owner@example.com"}
+	db.Users.Insert(ctx, &user)
+
+	eventId, mutedEventId := primitive.NewObjectID(), primitive.NewObjectID()
+	responded := func(eventId primitive.ObjectID) Message {
+		return SomeoneResponded{EventId: eventId, OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1", RespondentName: "Bob"}
+	}
+	reminder := Reminder{EventId: eventId, Stage: InitialReminder, OwnerName: "Jane", EventName: "Lunch", EventUrl: "https://schej.it/e/1"}
+
+	// Following links from emails to a user and to an email address without a user
+	for _, link := range []Unsubscribe{
+		{Email: user.Email, EventId: mutedEventId},
+		{Email: "Remindee@example.com", Type: models.ReminderNotification},
+	} {
+		u, err := ParseToken(link.Token())
+		if err != nil || u != link {
+			t.Fatalf("expected the token to parse to %+v, got %+v, %v", link, u, err)
+		}
+		if err := u.Apply(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload, _, _ := strings.Cut(Unsubscribe{Email: "other@example.com", Type: models.ReminderNotification}.Token(), ".")
+	_, signature, _ := strings.Cut(Unsubscribe{Email: user.Email, Type: models.ReminderNotification}.Token(), ".")
+	if _, err := ParseToken(payload + "." + signature); err == nil {
+		t.Error("expected a tampered token to be rejected")
+	}
+
+	mutedSent, _ := Send(ctx, user.Email, responded(mutedEventId))
+	unsubscribedSent, _ := Send(ctx, "remindee@example.com", reminder)
+	if files, _ := os.ReadDir(dir); len(files) != 0 || mutedSent || unsubscribedSent {
+		t.Errorf("expected muted and unsubscribed emails not to be sent, got %v", files)
+	}
+
+	respondedSent, _ := Send(ctx, user.Email, responded(eventId))
+	inviteSent, _ := Send(ctx, "remindee@example.com", GroupInvite{EventId: eventId, OwnerName: "Jane", GroupName: "Team", GroupUrl: "https://schej.it/g/1"})
+	if files, _ := os.ReadDir(dir); len(files) != 2 || !respondedSent || !inviteSent {
+		t.Errorf("expected other emails to be sent, got %v", files)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	dir := initMailbox(t)
+	closeJobs := jobs.Init()
+	defer closeJobs()
+	ctx := context.Background()
+
+	hour := 17
+	owner := models.User{Email: "owner@example.com", FirstName: "Jane", NotificationPreferences: &models.NotificationPreferences{
+		Types:      map[models.NotificationType]models.NotificationMode{models.ResponseNotification: models.NotifyDigest},
+		DigestHour: &hour,
+	}}
+	db.Users.Insert(ctx, &owner)
+	day := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	duration := float32(1)
+	event := models.Event{OwnerId: owner.Id, Name: "Lunch", Type: models.SPECIFIC_DATES, Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(day)}, Duration: &duration}
+	db.Events.Insert(ctx, &event)
+	db.Responses.Upsert(ctx, event.Id, "bob", &models.Response{Name: "Bob", Availability: []primitive.DateTime{primitive.NewDateTimeFromTime(day.Add(30 * time.Minute))}})
+
+	// Responses are added to the digest instead of being sent, and declines are recorded for it
+	if _, err := Send(ctx, owner.Email, SomeoneResponded{EventId: event.Id, EventName: "Lunch", RespondentName: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordActivity(ctx, owner.Id, models.DigestItem{EventId: event.Id, Activity: models.DeclineActivity, Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("expected digested emails not to be sent, got %v", files)
+	}
+	items, _ := db.DigestItems.GetByUser(ctx, owner.Id)
+	user, _ := db.Users.GetById(ctx, owner.Id)
+	if len(items) != 2 || user.DigestScheduledFor == nil || user.DigestScheduledFor.Time().UTC().Hour() != hour {
+		t.Fatalf("expected two digest items and a digest at %d:00, got %+v, %v", hour, items, user.DigestScheduledFor)
+	}
+
+	// The digest sends everything at once and starts over
+	if err := sendDigest(ctx, bson.M{"userId": owner.Id.Hex()}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.Contains(files[0].Name(), "-digest-") {
+		t.Fatalf("expected one digest in the mailbox, got %v", files)
+	}
+	file, _ := os.Open(filepath.Join(dir, files[0].Name()))
+	defer file.Close()
+	contents, _ := io.ReadAll(quotedprintable.NewReader(file))
+	for _, expected := range []string{"Subject: Your daily schej digest: Lunch", "Bob responded", "Alice declined", "Mon, Oct 19 at 9:30 AM (1 of 1 available)"} {
+		if !strings.Contains(string(contents), expected) {
+			t.Errorf("expected the digest to contain %q, got %s", expected, contents)
+		}
+	}
+	items, _ = db.DigestItems.GetByUser(ctx, owner.Id)
+	user, _ = db.Users.GetById(ctx, owner.Id)
+	if len(items) != 0 || user.DigestScheduledFor != nil {
+		t.Errorf("expected the digest to be cleared, got %+v, %v", items, user.DigestScheduledFor)
+	}
+}
+
+func TestNextDigestTime(t *testing.T) {
+	hour := 8
+	// 7 hours behind UTC
+	user := &models.User{TimezoneOffset: 420, NotificationPreferences: &models.NotificationPreferences{DigestHour: &hour}}
+	now := time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)
+	if next := nextDigestTime(user, now); !next.Equal(time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the digest later today, got %v", next)
+	}
+	if next := nextDigestTime(user, now.Add(time.Hour)); !next.Equal(time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the digest tomorrow, got %v", next)
+	}
+}
+
+// Sends emails to a temporary mailbox directory, with the memory storage backend
+func initMailbox(t *testing.T) string {
+	logger.Init(io.Discard)
+	db.InitMemory("")
+	dir := filepath.Join(t.TempDir(), "mailbox")
+	t.Setenv("EMAIL_BACKEND", "file")
+	t.Setenv("EMAIL_MAILBOX_PATH", dir)
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
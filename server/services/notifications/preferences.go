@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+// What following an unsubscribe link in an email does: mutes the event if EventId is set, and otherwise turns off
+// the type of notification. Links are signed, so that following one is enough to prove it's the recipient's
+type Unsubscribe struct {
+	Email   string
+	Type    models.NotificationType
+	EventId primitive.ObjectID
+}
+
+var errInvalidToken = errors.New("invalid unsubscribe token")
+
+// Returns the signed token of the unsubscribe link
+func (u Unsubscribe) Token() string {
+	eventId := ""
+	if !u.EventId.IsZero() {
+		eventId = u.EventId.Hex()
+	}
+	payload := strings.Join([]string{u.Email, string(u.Type), eventId}, "\n")
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(sign(payload))
+}
+
+// Returns the url of the page that confirms the unsubscribe
+func (u Unsubscribe) Url() string {
+	return fmt.Sprintf("%s/api/notifications/unsubscribe?token=%s", config.Get().Server.BaseUrl, url.QueryEscape(u.Token()))
+}
+
+// Returns the unsubscribe link that the token was signed for
+func ParseToken(token string) (Unsubscribe, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Unsubscribe{}, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Unsubscribe{}, errInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, sign(string(payload))) {
+		return Unsubscribe{}, errInvalidToken
+	}
+
+	parts := strings.Split(string(payload), "\n")
+	if len(parts) != 3 {
+		return Unsubscribe{}, errInvalidToken
+	}
+	u := Unsubscribe{Email: parts[0], Type: models.NotificationType(parts[1])}
+	if len(parts[2]) > 0 {
+		if u.EventId, err = primitive.ObjectIDFromHex(parts[2]); err != nil {
+			return Unsubscribe{}, errInvalidToken
+		}
+	}
+
+	return u, nil
+}
+
+func sign(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+config.Get().Server.SessionSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Mutes the event or turns off the type of notification for the link's email address
+func (u Unsubscribe) Apply(ctx context.Context) error {
+	return UpdatePreferences(ctx, u.Email, func(prefs *models.NotificationPreferences) {
+		if !u.EventId.IsZero() {
+			if !slices.Contains(prefs.MutedEventIds, u.EventId) {
+				prefs.MutedEventIds = append(prefs.MutedEventIds, u.EventId)
+			}
+			return
+		}
+
+		if prefs.Types == nil {
+			prefs.Types = make(map[models.NotificationType]models.NotificationMode)
+		}
+		prefs.Types[u.Type] = models.NotifyOff
+	})
+}
+
+// Returns the notification preferences of the user with the email address, or of the email address if it isn't a
+// user's. Returns nil if they haven't been set
+func GetPreferences(ctx context.Context, email string) (*models.NotificationPreferences, error) {
+	user, err := db.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user.NotificationPreferences, nil
+	}
+
+	emailPrefs, err := db.EmailPreferences.GetByEmail(ctx, strings.ToLower(email))
+	if err != nil || emailPrefs == nil {
+		return nil, err
+	}
+
+	return &emailPrefs.Preferences, nil
+}
+
+// Changes the notification preferences of the user with the email address, or of the email address if it isn't a
+// user's
+func UpdatePreferences(ctx context.Context, email string, updateFunc func(prefs *models.NotificationPreferences)) error {
+	user, err := db.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		if user.NotificationPreferences == nil {
+			user.NotificationPreferences = &models.NotificationPreferences{}
+		}
+		updateFunc(user.NotificationPreferences)
+		return db.Users.SetFields(ctx, user.Id, bson.M{"notificationPreferences": user.NotificationPreferences})
+	}
+
+	emailPrefs, err := db.EmailPreferences.GetByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		return err
+	}
+	if emailPrefs == nil {
+		emailPrefs = &models.EmailPreferences{Email: strings.ToLower(email)}
+	}
+	updateFunc(&emailPrefs.Preferences)
+	return db.EmailPreferences.Upsert(ctx, emailPrefs)
+}
@@ -0,0 +1,49 @@
+package notifications
+
+import (
+	"context"
+
+	"gopkg.in/gomail.v2"
+	"schej.it/server/config"
+)
+
+// Renders messages with the templates in templates/ and sends them with an SMTP server
+type smtpSender struct {
+	cfg config.Email
+}
+
+func (s smtpSender) send(ctx context.Context, e email) error {
+	m, err := newMessage(s.cfg.From, e)
+	if err != nil {
+		return err
+	}
+
+	dialer := gomail.NewDialer(s.cfg.SmtpHost, s.cfg.SmtpPort, s.cfg.SmtpUsername, s.cfg.SmtpPassword)
+	return dialer.DialAndSend(m)
+}
+
+func newMessage(from string, e email) (*gomail.Message, error) {
+	body, err := render(e)
+	if err != nil {
+		return nil, err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", e.To)
+	m.SetHeader("Subject", e.Message.Subject())
+	for name, value := range unsubscribeHeaders(e) {
+		m.SetHeader(name, value)
+	}
+	m.SetBody("text/html", body)
+
+	return m, nil
+}
+
+// Headers that let mail clients show an unsubscribe button, which posts to the unsubscribe url (RFC 8058)
+func unsubscribeHeaders(e email) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + e.UnsubscribeUrl + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
@@ -0,0 +1,81 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"strings"
+
+	"schej.it/server/config"
+	"schej.it/server/models"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Email bodies by template name. Each template defines "content", which is rendered with the message inside
+// templates/layout.html
+var templates = parseTemplates()
+
+func parseTemplates() map[string]*template.Template {
+	names, err := fs.Glob(templateFiles, "templates/*.html")
+	if err != nil {
+		panic(err)
+	}
+
+	parsed := make(map[string]*template.Template)
+	for _, name := range names {
+		if name == "templates/layout.html" {
+			continue
+		}
+		t := template.Must(template.ParseFS(templateFiles, "templates/layout.html", name))
+		parsed[strings.TrimSuffix(strings.TrimPrefix(name, "templates/"), ".html")] = t
+	}
+
+	return parsed
+}
+
+// Renders the email's html body
+func render(e email) (string, error) {
+	t, ok := templates[e.Message.Template()]
+	if !ok {
+		return "", fmt.Errorf("no email template named %q", e.Message.Template())
+	}
+
+	var body bytes.Buffer
+	if err := t.ExecuteTemplate(&body, "layout", e); err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+// The page unsubscribe links open. It asks to confirm before unsubscribing, so that link scanners that open every
+// link in an email don't unsubscribe anyone
+var unsubscribePage = template.Must(template.ParseFS(templateFiles, "templates/pages/unsubscribe.html"))
+
+// Descriptions of each type of notification, as in "You won't get any more <description> emails"
+var typeDescriptions = map[models.NotificationType]string{
+	models.GroupInviteNotification:        "availability group invite",
+	models.ResponseNotification:           "new response",
+	models.ResponsesThresholdNotification: "response count",
+	models.EveryoneRespondedNotification:  "everyone responded",
+	models.ReminderNotification:           "reminder",
+	models.CalendarNotification:           "calendar connection",
+	models.DigestNotification:             "daily digest",
+}
+
+// Renders the unsubscribe page of the link, confirming that it was followed if done
+func RenderUnsubscribePage(w io.Writer, u Unsubscribe, done bool) error {
+	return unsubscribePage.Execute(w, map[string]interface{}{
+		"Done":        done,
+		"Muted":       !u.EventId.IsZero(),
+		"Description": typeDescriptions[u.Type],
+		"Email":       u.Email,
+		"Token":       u.Token(),
+		"SettingsUrl": fmt.Sprintf("%s/settings", config.Get().Server.BaseUrl),
+	})
+}
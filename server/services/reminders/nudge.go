@@ -0,0 +1,244 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/notifications"
+	"schej.it/server/utils"
+)
+
+// Owners can nudge the people who haven't responded to their event yet, on top of the automatic reminders. Each
+// person is nudged about an event at most once per config.RateLimits.NudgeCooldownHours
+
+// Why someone is nudged
+type NudgeReason string
+
+const (
+	// A remindee or group attendee that hasn't responded
+	NotRespondedNudge NudgeReason = "notResponded"
+
+	// A respondent whose availability doesn't include the best time
+	NotAvailableNudge NudgeReason = "notAvailable"
+)
+
+type NudgeRecipient struct {
+	Email  string      `json:"email"`
+	Reason NudgeReason `json:"reason"`
+}
+
+// Who NudgeAll nudged, and who it didn't nudge and why
+type NudgeResult struct {
+	Nudged []NudgeRecipient `json:"nudged"`
+
+	// Nudged within the cooldown
+	Skipped []NudgeRecipient `json:"skipped"`
+
+	// Turned off or muted the email, or emails aren't configured
+	NotSent []NudgeRecipient `json:"notSent"`
+
+	// The email couldn't be sent, they can be nudged again right away
+	Failed []NudgeRecipient `json:"failed"`
+}
+
+// Returns the remindees that haven't responded, the group attendees that haven't responded or declined, and if
+// includeUnavailable, the respondents that aren't available for the best time. The event's responses must be
+// populated. The owner isn't nudged
+func NudgeRecipients(ctx context.Context, event *models.Event, owner *models.User, includeUnavailable bool) ([]NudgeRecipient, error) {
+	recipients := make([]NudgeRecipient, 0)
+	seen := make(map[string]bool)
+	if owner != nil {
+		seen[strings.ToLower(owner.Email)] = true
+	}
+	add := func(email string, reason NudgeReason) {
+		if len(email) == 0 || seen[strings.ToLower(email)] {
+			return
+		}
+		seen[strings.ToLower(email)] = true
+		recipients = append(recipients, NudgeRecipient{email, reason})
+	}
+
+	// Respondents without an email, e.g. guests of events that don't collect emails, can't be nudged
+	respondentEmails := make(map[string]string)
+	for _, eventResponse := range event.ResponsesList {
+		email, err := respondentEmail(ctx, eventResponse)
+		if err != nil {
+			return nil, err
+		}
+		respondentEmails[eventResponse.UserId] = email
+	}
+
+	for _, remindee := range utils.Coalesce(event.Remindees) {
+		if !utils.Coalesce(remindee.Responded) {
+			add(remindee.Email, NotRespondedNudge)
+		}
+	}
+	for _, attendee := range utils.Coalesce(event.Attendees) {
+		responded := slices.ContainsFunc(event.ResponsesList, func(r models.EventResponse) bool {
+			return strings.EqualFold(respondentEmails[r.UserId], attendee.Email)
+		})
+		if !responded && !utils.Coalesce(attendee.Declined) {
+			add(attendee.Email, NotRespondedNudge)
+		}
+	}
+
+	if includeUnavailable && event.Type != models.GROUP && !utils.Coalesce(event.IsSignUpForm) {
+		if bestTime, _, ok := event.BestTime(); ok {
+			for _, eventResponse := range event.ResponsesList {
+				response := eventResponse.Response
+				if response == nil || slices.Contains(response.Availability, bestTime) || slices.Contains(response.IfNeeded, bestTime) {
+					continue
+				}
+				add(respondentEmails[eventResponse.UserId], NotAvailableNudge)
+			}
+		}
+	}
+
+	return recipients, nil
+}
+
+// Returns the email of a user's response, or the email a guest left with their response
+func respondentEmail(ctx context.Context, eventResponse models.EventResponse) (string, error) {
+	// Guest responses are keyed by the guest's name
+	if userId, err := primitive.ObjectIDFromHex(eventResponse.UserId); err == nil {
+		user, err := db.Users.GetById(ctx, userId)
+		if err != nil {
+			return "", err
+		}
+		if user != nil {
+			return user.Email, nil
+		}
+	}
+
+	if eventResponse.Response == nil {
+		return "", nil
+	}
+	return eventResponse.Response.Email, nil
+}
+
+// Starts the cooldown of the recipients that haven't been nudged about the event within the cooldown. Returns the
+// recipients that can be nudged and the ones that were nudged too recently
+func claimNudges(ctx context.Context, eventId primitive.ObjectID, recipients []NudgeRecipient) ([]NudgeRecipient, []NudgeRecipient) {
+	cooldown := config.Get().RateLimits.NudgeCooldown()
+	if cooldown <= 0 {
+		return recipients, make([]NudgeRecipient, 0)
+	}
+
+	claimed, coolingDown := make([]NudgeRecipient, 0), make([]NudgeRecipient, 0)
+	for _, recipient := range recipients {
+		count, _, err := db.RateLimits.Increment(ctx, nudgeKey(eventId, recipient.Email), 1, cooldown)
+		if err != nil {
+			// Like rate limits, nudges are let through if they can't be counted
+			logger.FromContext(ctx).Error("Failed to count nudge", "error", err)
+		}
+		if err == nil && count > 1 {
+			coolingDown = append(coolingDown, recipient)
+		} else {
+			claimed = append(claimed, recipient)
+		}
+	}
+
+	return claimed, coolingDown
+}
+
+// Ends the cooldown of recipients that were claimed but not nudged
+func releaseNudges(ctx context.Context, eventId primitive.ObjectID, recipients []NudgeRecipient) {
+	cooldown := config.Get().RateLimits.NudgeCooldown()
+	if cooldown <= 0 {
+		return
+	}
+
+	for _, recipient := range recipients {
+		if _, _, err := db.RateLimits.Increment(ctx, nudgeKey(eventId, recipient.Email), -1, cooldown); err != nil {
+			logger.FromContext(ctx).Error("Failed to uncount nudge", "error", err)
+		}
+	}
+}
+
+func nudgeKey(eventId primitive.ObjectID, email string) string {
+	return fmt.Sprintf("nudge:%s:%s", eventId.Hex(), strings.ToLower(email))
+}
+
+// Emails the recipient about the owner's event, and returns whether the email was sent. It isn't if the recipient
+// turned off or muted the emails, or they aren't configured. The owner is nil for events without one
+func Nudge(ctx context.Context, event *models.Event, owner *models.User, recipient NudgeRecipient) (bool, error) {
+	msg := notifications.Nudge{
+		EventId:   event.Id,
+		OwnerName: "Somebody",
+		EventName: event.Name,
+		EventUrl:  fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+		IsGroup:   event.Type == models.GROUP,
+	}
+	if owner != nil {
+		msg.OwnerName = owner.FirstName
+	}
+	if msg.IsGroup {
+		msg.EventUrl = fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId())
+	}
+
+	switch {
+	case recipient.Reason == NotAvailableNudge:
+		bestTime, _, ok := event.BestTime()
+		if !ok {
+			return false, nil
+		}
+		zone, err := remindeeZone(ctx, recipient.Email, owner)
+		if err != nil {
+			return false, err
+		}
+		msg.BestTime = event.FormatTime(bestTime.Time().In(zone))
+	case !msg.IsGroup:
+		msg.FinishedUrl = fmt.Sprintf("%s/e/%s/responded?email=%s", utils.GetBaseUrl(), event.GetId(), recipient.Email)
+	}
+
+	return notifications.Send(ctx, recipient.Email, msg)
+}
+
+// Nudges the people who haven't responded to the owner's event, see NudgeRecipients. People that were nudged
+// recently are skipped, and the rest count against the owner's reminder emails. Only the people that were nudged
+// start a cooldown and count against the owner's emails. The event's responses must be populated
+func NudgeAll(ctx context.Context, event *models.Event, owner *models.User, includeUnavailable bool) (*NudgeResult, error) {
+	recipients, err := NudgeRecipients(ctx, event, owner, includeUnavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := config.Get().RateLimits.ReminderEmailsPerDay
+	sender := "user:" + owner.Id.Hex()
+	claimed, skipped := claimNudges(ctx, event.Id, recipients)
+	if err := notifications.ReserveEmails(ctx, "reminder", sender, len(claimed), limit); err != nil {
+		releaseNudges(ctx, event.Id, claimed)
+		return nil, err
+	}
+
+	result := &NudgeResult{
+		Nudged:  make([]NudgeRecipient, 0),
+		Skipped: skipped,
+		NotSent: make([]NudgeRecipient, 0),
+		Failed:  make([]NudgeRecipient, 0),
+	}
+	for _, recipient := range claimed {
+		sent, err := Nudge(ctx, event, owner, recipient)
+		switch {
+		case err != nil:
+			logger.FromContext(ctx).Error("Failed to nudge", "eventId", event.Id.Hex(), "error", err)
+			result.Failed = append(result.Failed, recipient)
+		case !sent:
+			result.NotSent = append(result.NotSent, recipient)
+		default:
+			result.Nudged = append(result.Nudged, recipient)
+		}
+	}
+	unsent := append(slices.Clone(result.Failed), result.NotSent...)
+	releaseNudges(ctx, event.Id, unsent)
+	notifications.ReleaseEmails(ctx, "reminder", sender, len(unsent), limit)
+
+	return result, nil
+}
@@ -0,0 +1,236 @@
+/* Reminder emails to an event's remindees, until they respond */
+package reminders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+	"schej.it/server/services/notifications"
+	"schej.it/server/utils"
+)
+
+const sendReminderJob = "send-reminder-email"
+
+func init() {
+	jobs.Register(sendReminderJob, sendReminder)
+}
+
+// Payload of a send reminder email job
+type reminder struct {
+	Email       string                      `bson:"email"`
+	EventId     string                      `bson:"eventId"`
+	Stage       notifications.ReminderStage `bson:"stage"`
+	OwnerName   string                      `bson:"ownerName"`
+	EventName   string                      `bson:"eventName"`
+	EventUrl    string                      `bson:"eventUrl"`
+	FinishedUrl string                      `bson:"finishedUrl"`
+	Message     string                      `bson:"message"`
+	Deadline    string                      `bson:"deadline"`
+}
+
+// A reminder email to be scheduled
+type scheduledReminder struct {
+	stage notifications.ReminderStage
+	runAt time.Time
+}
+
+// Schedules the reminder emails for a remindee that was just added to the event, and returns the ids of the
+// scheduled jobs. Reminders whose time has already passed are replaced by one sent right away. On error, the jobs
+// scheduled before the error are still returned so that they can be canceled. The owner is nil for events without one
+func Schedule(ctx context.Context, event *models.Event, owner *models.User, remindee models.Remindee) ([]string, error) {
+	return schedule(ctx, event, owner, remindee, true)
+}
+
+// Replaces the pending reminder emails of the remindees that haven't responded, after the event's reminder settings
+// changed. Reminders whose time has passed under the new settings aren't sent. Updates the task ids of the remindees
+// in the event, which the caller saves
+func Reschedule(ctx context.Context, event *models.Event, owner *models.User) error {
+	for i, remindee := range utils.Coalesce(event.Remindees) {
+		if utils.Coalesce(remindee.Responded) {
+			continue
+		}
+
+		Cancel(ctx, remindee.TaskIds)
+		taskIds, err := schedule(ctx, event, owner, remindee, false)
+		(*event.Remindees)[i].TaskIds = taskIds
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func schedule(ctx context.Context, event *models.Event, owner *models.User, remindee models.Remindee, catchUp bool) ([]string, error) {
+	for _, stage := range []notifications.ReminderStage{notifications.InitialReminder, notifications.SecondReminder, notifications.FinalReminder} {
+		if !notifications.Configured(notifications.Reminder{Stage: stage}) {
+			return nil, errors.New("reminder emails are not configured")
+		}
+	}
+
+	zone, err := remindeeZone(ctx, remindee.Email, owner)
+	if err != nil {
+		return nil, err
+	}
+	addedAt := event.Id.Timestamp()
+	if remindee.AddedAt != nil {
+		addedAt = remindee.AddedAt.Time()
+	}
+	settings := utils.Coalesce(event.ReminderSettings)
+
+	// Construct URLs
+	baseUrl := utils.GetBaseUrl()
+	eventUrl := fmt.Sprintf("%s/e/%s", baseUrl, event.GetId())
+	finishedUrl := fmt.Sprintf("%s/e/%s/responded?email=%s", baseUrl, event.GetId(), remindee.Email)
+
+	ownerName := "Somebody"
+	if owner != nil {
+		ownerName = owner.FirstName
+	}
+	var deadline string
+	if settings.Deadline != nil {
+		deadline = settings.Deadline.Time().In(zone).Format("Mon, Jan 2 at 3:04 PM")
+	}
+
+	taskIds := make([]string, 0)
+	for _, r := range reminderTimes(event.ReminderSettings, addedAt, time.Now(), zone, catchUp) {
+		taskId, err := jobs.Enqueue(ctx, sendReminderJob, bson.M{
+			"email":       remindee.Email,
+			"eventId":     event.Id.Hex(),
+			"stage":       r.stage,
+			"ownerName":   ownerName,
+			"eventName":   event.Name,
+			"eventUrl":    eventUrl,
+			"finishedUrl": finishedUrl,
+			"message":     settings.Message,
+			"deadline":    deadline,
+		}, r.runAt)
+		if err != nil {
+			return taskIds, err
+		}
+		taskIds = append(taskIds, taskId)
+	}
+
+	return taskIds, nil
+}
+
+// Returns when each reminder is sent, oldest first. Reminders whose time has passed are skipped, unless catchUp, in
+// which case the first of them is sent now as the initial reminder. No reminders are sent after the deadline
+func reminderTimes(settings *models.ReminderSettings, addedAt time.Time, now time.Time, zone *time.Location, catchUp bool) []scheduledReminder {
+	s := utils.Coalesce(settings)
+	if s.Deadline != nil && !s.Deadline.Time().After(now) {
+		return nil
+	}
+
+	times := make([]time.Time, 0)
+	for _, offset := range settings.GetOffsets() {
+		d := time.Duration(offset * float64(time.Hour))
+		if s.Deadline != nil {
+			times = append(times, s.Deadline.Time().Add(-d))
+		} else {
+			times = append(times, addedAt.Add(d))
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	reminders := make([]scheduledReminder, 0)
+	caughtUp := false
+	for i, runAt := range times {
+		stage := notifications.SecondReminder
+		if i == 0 {
+			stage = notifications.InitialReminder
+		} else if i == len(times)-1 {
+			stage = notifications.FinalReminder
+		}
+
+		if runAt.Before(now) {
+			if !catchUp || caughtUp {
+				continue
+			}
+			runAt, stage = now, notifications.InitialReminder
+			caughtUp = true
+		}
+
+		runAt = avoidQuietHours(runAt, s.QuietHours, zone)
+		// Reminders pushed to the end of the same quiet hours are only sent once
+		if len(reminders) > 0 && !runAt.After(reminders[len(reminders)-1].runAt) {
+			continue
+		}
+		reminders = append(reminders, scheduledReminder{stage, runAt})
+	}
+
+	return reminders
+}
+
+// Returns when the quiet hours that t is in end, or t if it isn't in quiet hours
+func avoidQuietHours(t time.Time, quietHours *models.QuietHours, zone *time.Location) time.Time {
+	local := t.In(zone)
+	if !quietHours.Contains(local.Hour()) {
+		return t
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), quietHours.End, 0, 0, 0, zone)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// Returns the timezone of the remindee's account, or of the owner's if the remindee doesn't have one. UTC if neither
+// does
+func remindeeZone(ctx context.Context, email string, owner *models.User) (*time.Location, error) {
+	user, err := db.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = owner
+	}
+	if user == nil {
+		return time.UTC, nil
+	}
+
+	// The timezone offset is in minutes behind UTC, like javascript's Date.getTimezoneOffset
+	return time.FixedZone("", -user.TimezoneOffset*60), nil
+}
+
+// Cancels the remindee's reminder emails that haven't been sent yet. Failures are logged, since the emails of a
+// remindee that responded or was removed only need to be canceled on a best effort basis
+func Cancel(ctx context.Context, taskIds []string) {
+	for _, taskId := range taskIds {
+		if err := jobs.Cancel(ctx, taskId); err != nil {
+			logger.FromContext(ctx).Error("Failed to cancel reminder email", "taskId", taskId, "error", err)
+		}
+	}
+}
+
+func sendReminder(ctx context.Context, payload bson.M) error {
+	var r reminder
+	if err := jobs.Decode(payload, &r); err != nil {
+		return err
+	}
+
+	// Reminders scheduled before the event id was stored can't be muted
+	eventId, _ := primitive.ObjectIDFromHex(r.EventId)
+
+	_, err := notifications.Send(ctx, r.Email, notifications.Reminder{
+		EventId:     eventId,
+		Stage:       r.Stage,
+		OwnerName:   r.OwnerName,
+		EventName:   r.EventName,
+		EventUrl:    r.EventUrl,
+		FinishedUrl: r.FinishedUrl,
+		Message:     r.Message,
+		Deadline:    r.Deadline,
+	})
+	return err
+}
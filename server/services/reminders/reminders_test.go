@@ -0,0 +1,61 @@
+package reminders
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+	"schej.it/server/services/notifications"
+)
+
+func TestReminderTimes(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	deadline := primitive.NewDateTimeFromTime(now.Add(30 * time.Hour))
+	// 7 hours behind UTC, so quiet hours from 10 PM to 7 AM are 5 AM to 2 PM UTC
+	zone := time.FixedZone("", -7*60*60)
+	quietHours := &models.QuietHours{Start: 22, End: 7}
+
+	tests := []struct {
+		name     string
+		settings *models.ReminderSettings
+		addedAt  time.Time
+		catchUp  bool
+		expected []scheduledReminder
+	}{
+		{"default", nil, now, true, []scheduledReminder{
+			{notifications.InitialReminder, now},
+			{notifications.SecondReminder, now.Add(24 * time.Hour)},
+			{notifications.FinalReminder, now.Add(72 * time.Hour)},
+		}},
+		{"before deadline, catching up", &models.ReminderSettings{Offsets: []float64{48, 36, 2}, Deadline: &deadline}, now, true, []scheduledReminder{
+			{notifications.InitialReminder, now},
+			{notifications.FinalReminder, now.Add(28 * time.Hour)},
+		}},
+		{"rescheduled before deadline", &models.ReminderSettings{Offsets: []float64{48, 24, 2}, Deadline: &deadline}, now, false, []scheduledReminder{
+			{notifications.SecondReminder, now.Add(6 * time.Hour)},
+			{notifications.FinalReminder, now.Add(28 * time.Hour)},
+		}},
+		{"quiet hours", &models.ReminderSettings{Offsets: []float64{0, 20, 22}, QuietHours: quietHours}, now, true, []scheduledReminder{
+			{notifications.InitialReminder, now.Add(2 * time.Hour)},
+			{notifications.SecondReminder, now.Add(26 * time.Hour)},
+		}},
+	}
+	for _, test := range tests {
+		got := reminderTimes(test.settings, test.addedAt, now, zone, test.catchUp)
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i].stage != test.expected[i].stage || !got[i].runAt.Equal(test.expected[i].runAt) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+				break
+			}
+		}
+	}
+
+	if got := reminderTimes(&models.ReminderSettings{Deadline: &deadline}, now, deadline.Time(), zone, true); len(got) != 0 {
+		t.Errorf("expected no reminders after the deadline, got %v", got)
+	}
+}
@@ -2,21 +2,22 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 	"schej.it/server/services/auth"
 )
 
 // Calls the given url with the given method using the user's OAuth 2 access token.
-// Set user to nil if refreshing the token is not necessary
-func CallApi(user *models.User, calendarAuth *models.OAuth2CalendarAuth, method string, url string, body *bson.M) *http.Response {
+// Set user to nil if refreshing the token is not necessary. The request is cancelled with ctx, so callers that set a
+// deadline must not cancel it until they've read the response body
+func CallApi(ctx context.Context, user *models.User, calendarAuth *models.OAuth2CalendarAuth, method string, url string, body *bson.M) (*http.Response, error) {
 	if user != nil {
-		auth.RefreshUserTokenIfNecessary(user, nil)
+		auth.RefreshUserTokenIfNecessary(ctx, user, nil)
 	}
 
 	// Format body as a buffer if not nil
@@ -30,18 +31,17 @@ func CallApi(user *models.User, calendarAuth *models.OAuth2CalendarAuth, method
 
 	// Construct request
 	var req *http.Request
+	var err error
 	if bodyBuffer != nil {
-		req, _ = http.NewRequest(method, url, bodyBuffer)
+		req, err = http.NewRequestWithContext(ctx, method, url, bodyBuffer)
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", calendarAuth.AccessToken))
-
-	// Execute request
-	response, err := http.DefaultClient.Do(req)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", calendarAuth.AccessToken))
 
-	return response
+	// Execute request
+	return http.DefaultClient.Do(req)
 }
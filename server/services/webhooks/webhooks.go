@@ -0,0 +1,321 @@
+/* Outbound webhooks, posted when there's activity on an owner's events */
+package webhooks
+
+// Each activity is stored as a delivery in the webhook's delivery log and posted by a deliver webhook job, which is
+// retried with the jobs backoff until it succeeds or runs out of attempts. The body is signed with the webhook's
+// secret: the X-Schej-Signature header is "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">", so
+// that receivers can check both the sender and the age of a delivery.
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+	"schej.it/server/utils"
+)
+
+const deliverWebhookJob = "deliver-webhook"
+
+const (
+	SignatureHeader = "X-Schej-Signature"
+	EventHeader     = "X-Schej-Event"
+	DeliveryHeader  = "X-Schej-Delivery"
+)
+
+var client = newClient()
+
+// Returns the client that posts deliveries. Webhook urls are user-given, so it only connects to public addresses,
+// checked when dialing so that hosts can't resolve to a private address after the webhook is created, and doesn't
+// follow redirects
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !utils.IsPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func init() {
+	jobs.Register(deliverWebhookJob, deliver)
+}
+
+// The body posted to webhooks
+type body struct {
+	Id        string                  `json:"id"`
+	Type      models.WebhookEventType `json:"type"`
+	CreatedAt time.Time               `json:"createdAt"`
+	Event     Event                   `json:"event"`
+	Data      interface{}             `json:"data,omitempty"`
+}
+
+// The event that the activity is on
+type Event struct {
+	Id             string                `json:"id"`
+	ShortId        string                `json:"shortId,omitempty"`
+	Name           string                `json:"name"`
+	Type           models.EventType      `json:"type"`
+	Url            string                `json:"url"`
+	ScheduledEvent *models.CalendarEvent `json:"scheduledEvent,omitempty"`
+}
+
+// Data of response activity. Availability is omitted for deleted responses
+type Response struct {
+	UserId       string               `json:"userId"`
+	Name         string               `json:"name"`
+	Email        string               `json:"email,omitempty"`
+	Guest        bool                 `json:"guest"`
+	Availability []primitive.DateTime `json:"availability,omitempty"`
+	IfNeeded     []primitive.DateTime `json:"ifNeeded,omitempty"`
+}
+
+// Data of sign up activity, with the blocks the respondent is signed up for after the change
+type SignUp struct {
+	UserId         string               `json:"userId"`
+	Name           string               `json:"name"`
+	Email          string               `json:"email,omitempty"`
+	SignUpBlockIds []primitive.ObjectID `json:"signUpBlockIds"`
+}
+
+// Data of a group attendee declining
+type Attendee struct {
+	Email string `json:"email"`
+}
+
+// Posts the activity on the event to the owner's webhooks that are subscribed to it, in the background. Events
+// without an owner don't have webhooks
+func Dispatch(ctx context.Context, event *models.Event, eventType models.WebhookEventType, data interface{}) {
+	if event.OwnerId.IsZero() {
+		return
+	}
+
+	// The event is encoded now, since the caller can change it after this returns
+	b := body{
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Event:     eventData(event),
+		Data:      data,
+	}
+	ownerId, eventId := event.OwnerId, event.Id
+	ctx = context.WithoutCancel(ctx)
+	utils.RunInBackground(func() {
+		if err := dispatch(ctx, ownerId, eventId, b); err != nil {
+			logger.FromContext(ctx).Error("Failed to dispatch webhooks", "type", eventType, "error", err)
+		}
+	})
+}
+
+func dispatch(ctx context.Context, ownerId primitive.ObjectID, eventId primitive.ObjectID, b body) error {
+	webhooks, err := db.Webhooks.GetByOwner(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Matches(b.Type, eventId) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			Id:        primitive.NewObjectID(),
+			WebhookId: webhook.Id,
+			EventId:   eventId,
+			Type:      b.Type,
+			Status:    models.DeliveryPending,
+			CreatedAt: b.CreatedAt,
+		}
+		b.Id = delivery.Id.Hex()
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		delivery.Body = string(data)
+
+		if err := schedule(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Posts the delivery again, as a new delivery with the same body
+func Replay(ctx context.Context, delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	replay := models.WebhookDelivery{
+		WebhookId: delivery.WebhookId,
+		EventId:   delivery.EventId,
+		Type:      delivery.Type,
+		Body:      delivery.Body,
+		Status:    models.DeliveryPending,
+		ReplayOf:  &delivery.Id,
+		CreatedAt: time.Now(),
+	}
+	if err := schedule(ctx, &replay); err != nil {
+		return nil, err
+	}
+
+	return &replay, nil
+}
+
+// Stores the delivery and schedules its first attempt
+func schedule(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := db.WebhookDeliveries.Insert(ctx, delivery); err != nil {
+		return err
+	}
+
+	_, err := jobs.Enqueue(ctx, deliverWebhookJob, bson.M{"deliveryId": delivery.Id.Hex()}, time.Now())
+	return err
+}
+
+func deliver(ctx context.Context, payload bson.M) error {
+	var p struct {
+		DeliveryId string `bson:"deliveryId"`
+	}
+	if err := jobs.Decode(payload, &p); err != nil {
+		return err
+	}
+	deliveryId, err := primitive.ObjectIDFromHex(p.DeliveryId)
+	if err != nil {
+		return err
+	}
+
+	// Deliveries are deleted with their webhook
+	delivery, err := db.WebhookDeliveries.GetById(ctx, deliveryId)
+	if err != nil || delivery == nil || delivery.Status != models.DeliveryPending {
+		return err
+	}
+	webhook, err := db.Webhooks.GetById(ctx, delivery.WebhookId)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	delivery.Attempts++
+	status, err := post(ctx, webhook, delivery)
+	metrics.WebhookDeliveries.WithLabelValues(string(delivery.Type), metrics.Result(err)).Inc()
+	delivery.ResponseStatus = status
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return db.WebhookDeliveries.Update(ctx, delivery)
+	}
+
+	// The job is retried with a backoff until the delivery runs out of attempts
+	delivery.LastError = err.Error()
+	retry := delivery.Attempts < config.Get().Tasks.MaxAttempts
+	if !retry {
+		delivery.Status = models.DeliveryFailed
+	}
+	if updateErr := db.WebhookDeliveries.Update(ctx, delivery); updateErr != nil {
+		return updateErr
+	}
+	if retry {
+		return err
+	}
+
+	logger.FromContext(ctx).Warn("Webhook delivery failed", "webhookId", webhook.Id.Hex(), "deliveryId", delivery.Id.Hex(), "error", err)
+	return nil
+}
+
+// Posts the delivery and returns the response's status code, which is 0 if there wasn't a response. Statuses other
+// than 2xx are errors
+func post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader([]byte(delivery.Body)))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "schej-webhooks")
+	req.Header.Set(EventHeader, string(delivery.Type))
+	req.Header.Set(DeliveryHeader, delivery.Id.Hex())
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(webhook.Secret, timestamp, []byte(delivery.Body))))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Returns the hex encoded signature of a body posted at the unix timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Returns a random secret for a new webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func eventData(event *models.Event) Event {
+	path := "e"
+	if event.Type == models.GROUP {
+		path = "g"
+	}
+
+	return Event{
+		Id:             event.Id.Hex(),
+		ShortId:        utils.Coalesce(event.ShortId),
+		Name:           event.Name,
+		Type:           event.Type,
+		Url:            fmt.Sprintf("%s/%s/%s", utils.GetBaseUrl(), path, event.GetId()),
+		ScheduledEvent: event.ScheduledEvent,
+	}
+}
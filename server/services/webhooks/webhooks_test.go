@@ -0,0 +1,116 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/jobs"
+)
+
+func TestDeliver(t *testing.T) {
+	logger.Init(io.Discard)
+	db.InitMemory("")
+	t.Setenv("TASKS_MAX_ATTEMPTS", "2")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	closeJobs := jobs.Init()
+	defer closeJobs()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	status := http.StatusOK
+	var signature, received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		data, _ := io.ReadAll(r.Body)
+		signature, received = r.Header.Get(SignatureHeader), string(data)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// The test server is on a loopback address, which the webhook client refuses to connect to
+	if status, err := post(ctx, &models.Webhook{Url: server.URL}, &models.WebhookDelivery{}); status != 0 || err == nil {
+		t.Fatalf("expected a loopback webhook to be refused, got %d", status)
+	}
+	defaultClient := client
+	client = &http.Client{Timeout: 10 * time.Second}
+	defer func() { client = defaultClient }()
+
+	event := models.Event{Name: "Lunch", OwnerId: primitive.NewObjectID(), Type: models.SPECIFIC_DATES}
+	db.Events.Insert(ctx, &event)
+	webhook := models.Webhook{OwnerId: event.OwnerId, Url: server.URL, Secret: "whsec_test", Events: []models.WebhookEventType{models.ResponseCreatedWebhook}}
+	db.Webhooks.Insert(ctx, &webhook)
+
+	// Only the types the webhook is subscribed to are delivered
+	for _, eventType := range []models.WebhookEventType{models.EventUpdatedWebhook, models.ResponseCreatedWebhook} {
+		if err := dispatch(ctx, event.OwnerId, event.Id, body{Type: eventType, CreatedAt: time.Now(), Event: eventData(&event)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries := waitForDeliveries(t, webhook, func(d models.WebhookDelivery) bool { return d.Status != models.DeliveryPending })
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliverySucceeded || deliveries[0].ResponseStatus != http.StatusOK {
+		t.Fatalf("expected one successful delivery, got %+v", deliveries)
+	}
+	delivered := deliveries[0]
+
+	// The signature covers the timestamp and the body
+	mu.Lock()
+	var timestamp int64
+	var mac string
+	fmt.Sscanf(signature, "t=%d,v1=%s", &timestamp, &mac)
+	if received != delivered.Body || mac != Sign(webhook.Secret, timestamp, []byte(received)) {
+		t.Errorf("expected the body %s to be signed, got %q", received, signature)
+	}
+	status = http.StatusInternalServerError
+	mu.Unlock()
+
+	// Replays are new deliveries that are retried until they run out of attempts
+	replay, err := Replay(ctx, &delivered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries = waitForDeliveries(t, webhook, func(d models.WebhookDelivery) bool { return d.Id != replay.Id || d.Attempts > 0 })
+	if len(deliveries) != 2 || deliveries[0].Id != replay.Id || deliveries[0].Status != models.DeliveryPending || deliveries[0].Body != delivered.Body {
+		t.Fatalf("expected the replay to be retried, got %+v", deliveries)
+	}
+	if err := deliver(ctx, bson.M{"deliveryId": replay.Id.Hex()}); err != nil {
+		t.Fatal(err)
+	}
+	replay, _ = db.WebhookDeliveries.GetById(ctx, replay.Id)
+	if replay.Status != models.DeliveryFailed || replay.Attempts != 2 || replay.ResponseStatus != http.StatusInternalServerError || *replay.ReplayOf != delivered.Id {
+		t.Errorf("expected the replay to fail after two attempts, got %+v", replay)
+	}
+}
+
+// Waits until done returns true for every delivery of the webhook and returns them
+func waitForDeliveries(t *testing.T, webhook models.Webhook, done func(models.WebhookDelivery) bool) []models.WebhookDelivery {
+	t.Helper()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		deliveries, err := db.WebhookDeliveries.GetByWebhook(context.Background(), webhook.Id, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finished := true
+		for _, d := range deliveries {
+			finished = finished && done(d)
+		}
+		if finished || time.Since(start) > 5*time.Second {
+			return deliveries
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"schej.it/server/logger"
@@ -19,18 +18,6 @@ type Response struct {
 	Blocks       []bson.M `json:"blocks,omitempty"`
 }
 
-type Command struct {
-	Name        string
-	Description string
-	Usage       string
-	Execute     func(args []string, webhookUrl string)
-}
-
-var CommandMap = map[string]Command{
-	activeUsers.Name: activeUsers,
-	numUsers.Name:    numUsers,
-}
-
 func SendRawMessage(message *Response, webhookUrl string) {
 	bodyBytes, _ := json.Marshal(message)
 	bodyBuffer := bytes.NewBuffer(bodyBytes)
@@ -49,35 +36,3 @@ func SendRawMessage(message *Response, webhookUrl string) {
 	}
 	defer resp.Body.Close()
 }
-
-// Returns an array containing the separate messages to send when a
-// single message is too long (>2000 characters).
-// Each message in the array will be surrounded by surroundString (e.g. "```")
-func splitLongMessage(message string, surroundString string) []string {
-
-	charLimit := 2000
-	charLimit -= len(surroundString) * 2
-	messageArray := make([]string, 0)
-
-	// Go through message and keep substringing it in 2000 character chunks,
-	// and add the chunks to the messageArray
-	for len(message) > charLimit {
-		// Split at the last newline before the character limit, or the character limit
-		// if newline was not found
-		splitIndex := strings.LastIndex(message[:charLimit], "\n")
-		if splitIndex == -1 {
-			splitIndex = charLimit
-		}
-
-		messageArray = append(messageArray, surroundString+message[:splitIndex]+surroundString)
-		if message[splitIndex:splitIndex+1] == "\n" {
-			splitIndex++
-		}
-		message = message[splitIndex:]
-	}
-	if len(message) > 0 {
-		messageArray = append(messageArray, surroundString+message+surroundString)
-	}
-
-	return messageArray
-}
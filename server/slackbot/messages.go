@@ -12,14 +12,14 @@ import (
 func SendEventCreatedMessage(insertedId string, creator string, event models.Event) {
 	eventInfoText := fmt.Sprintf(
 		"*Name*: %s\n"+
-			"*Event url*: https://schej.it/e/%s\n"+
-			"*Short url*: https://schej.it/e/%s\n"+
+			"*Event url*: %s/e/%s\n"+
+			"*Short url*: %s/e/%s\n"+
 			"*Creator*: %s\n"+
 			"*Num days*: %v\n"+
 			"*Type*: %s\n",
 		event.Name,
-		insertedId,
-		utils.Coalesce(event.ShortId),
+		utils.GetBaseUrl(), insertedId,
+		utils.GetBaseUrl(), utils.Coalesce(event.ShortId),
 		creator,
 		len(event.Dates),
 		event.Type,
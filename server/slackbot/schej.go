@@ -0,0 +1,272 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
+	"schej.it/server/services/reminders"
+	"schej.it/server/slackbot/commands"
+	"schej.it/server/utils"
+	"schej.it/server/validation"
+)
+
+// The /schej command lets Slack users create and follow up on events without leaving Slack, e.g.
+// `/schej new "Standup" next week 9-5`. Its subcommands act as the schej account that the Slack user linked
+
+// A subcommand of /schej
+type schejCommand struct {
+	Name        string
+	Usage       string
+	Description string
+
+	// Whether the Slack user has to link a schej account before running the command
+	NeedsAccount bool
+
+	Execute func(ctx context.Context, r schejRequest) *commands.Response
+}
+
+type schejRequest struct {
+	// The Slack workspace and user that sent the command
+	TeamId string
+	UserId string
+
+	// Arguments after the subcommand's name
+	Args []string
+
+	// The linked schej account, nil if the command doesn't need one
+	User *models.User
+}
+
+var schejCommands = []schejCommand{newCommand, statusCommand, remindCommand, linkCommand, unlinkCommand}
+
+// Runs the /schej subcommand and returns the message to reply with
+func runSchejCommand(ctx context.Context, name string, r schejRequest) *commands.Response {
+	var command *schejCommand
+	for i := range schejCommands {
+		if schejCommands[i].Name == name {
+			command = &schejCommands[i]
+		}
+	}
+	if command == nil {
+		return schejHelp()
+	}
+
+	if command.NeedsAccount {
+		var err error
+		if r.User, err = chat.GetLinkedUser(ctx, models.SlackPlatform, r.TeamId, r.UserId); err != nil {
+			return commandFailed(ctx, err)
+		}
+		if r.User == nil {
+			return linkPrompt(r, "Link your schej account first, so that schej knows who you are.")
+		}
+	}
+
+	return command.Execute(ctx, r)
+}
+
+func schejHelp() *commands.Response {
+	text := "*Usage*\n"
+	for _, command := range schejCommands {
+		text += fmt.Sprintf("`%s`: %s\n", command.Usage, command.Description)
+	}
+	return &commands.Response{ResponseType: "ephemeral", Text: text, Blocks: []bson.M{section(text)}}
+}
+
+const newUsage = `/schej new "NAME" DAYS [TIMES]`
+
+var newCommand = schejCommand{
+	Name:         "new",
+	Usage:        newUsage,
+	Description:  `Creates an event, e.g. /schej new "Standup" next week 9-5. DAYS can be today, tomorrow, this week, next week, weekdays like mon-fri, or dates like 10/19. TIMES defaults to 9-5`,
+	NeedsAccount: true,
+	Execute: func(ctx context.Context, r schejRequest) *commands.Response {
+		zone := chat.UserZone(r.User)
+		parsed, err := chat.ParseNewEvent(r.Args, time.Now().In(zone))
+		if err != nil {
+			return ephemeral(fmt.Sprintf("Couldn't create the event: %v.\nUsage: `%s`", err, newUsage))
+		}
+
+		event, err := chat.CreateEvent(ctx, r.User, parsed)
+		if errors.Is(err, chat.ErrTooManyEvents) {
+			return ephemeral("You've created too many events recently, try again later.")
+		} else if err != nil {
+			return validationFailed(ctx, err)
+		}
+		SendEventCreatedMessage(event.Id.Hex(), fmt.Sprintf("%s %s (%s) via Slack", r.User.FirstName, r.User.LastName, r.User.Email), *event)
+
+		text := fmt.Sprintf("<@%s> created *%s* for %s. Add your availability so we can find a time!", r.UserId, event.Name, parsed.When())
+		return &commands.Response{ResponseType: "in_channel", Text: text, Blocks: []bson.M{
+			section(text),
+			button("Add availability", chat.EventUrl(event)),
+		}}
+	},
+}
+
+var statusCommand = schejCommand{
+	Name:         "status",
+	Usage:        "/schej status ID",
+	Description:  "Shows how many people responded to the event and its best times. ID is the event's id or the end of its link",
+	NeedsAccount: true,
+	Execute: func(ctx context.Context, r schejRequest) *commands.Response {
+		event, response := getEvent(ctx, r.Args)
+		if event == nil {
+			return response
+		}
+
+		numResponses := chat.NumResponses(event)
+		text := fmt.Sprintf("*%s* has %d %s.", event.Name, numResponses, chat.Plural(numResponses, "response", "responses"))
+
+		best := event.BestTimes(3)
+		if len(best) > 0 {
+			text += "\n*Best times*"
+			zone := chat.UserZone(r.User)
+			for _, slot := range best {
+				text += fmt.Sprintf("\n• %s (%d of %d available)", event.FormatTime(slot.Time.Time().In(zone)), slot.Count, len(event.ResponsesList))
+			}
+		} else if event.Type != models.GROUP && !utils.Coalesce(event.IsSignUpForm) {
+			text += "\nNobody is available for any of its times yet."
+		}
+
+		return &commands.Response{ResponseType: "ephemeral", Text: text, Blocks: []bson.M{
+			section(text),
+			button("Open event", chat.EventUrl(event)),
+		}}
+	},
+}
+
+var remindCommand = schejCommand{
+	Name:         "remind",
+	Usage:        "/schej remind ID",
+	Description:  "Emails the people who haven't responded to your event yet, except the ones that were reminded recently",
+	NeedsAccount: true,
+	Execute: func(ctx context.Context, r schejRequest) *commands.Response {
+		event, response := getEvent(ctx, r.Args)
+		if event == nil {
+			return response
+		}
+		if event.OwnerId != r.User.Id {
+			return ephemeral("Only the event's owner can send reminders.")
+		}
+
+		result, err := reminders.NudgeAll(ctx, event, r.User, false)
+		if errors.Is(err, errs.EmailLimitExceeded) {
+			return ephemeral("You've sent too many reminder emails today, try again tomorrow.")
+		} else if err != nil {
+			return commandFailed(ctx, err)
+		}
+
+		var text string
+		if len(result.Nudged) == 0 && len(result.Skipped) == 0 && len(result.NotSent) == 0 && len(result.Failed) == 0 {
+			text = fmt.Sprintf("Everyone has responded to *%s*, there's nobody to remind.", event.Name)
+		} else {
+			text = fmt.Sprintf("Reminded %d %s about *%s*.", len(result.Nudged), chat.Plural(len(result.Nudged), "person", "people"), event.Name)
+			for _, recipient := range result.Nudged {
+				text += "\n• " + recipient.Email
+			}
+			if len(result.Skipped) > 0 {
+				text += fmt.Sprintf("\nSkipped %d %s who %s reminded recently.", len(result.Skipped), chat.Plural(len(result.Skipped), "person", "people"), chat.Plural(len(result.Skipped), "was", "were"))
+			}
+			if len(result.NotSent) > 0 {
+				text += fmt.Sprintf("\nDidn't email %d %s who turned off reminder emails.", len(result.NotSent), chat.Plural(len(result.NotSent), "person", "people"))
+			}
+			if len(result.Failed) > 0 {
+				text += fmt.Sprintf("\nCouldn't email %d %s, try reminding them again later.", len(result.Failed), chat.Plural(len(result.Failed), "person", "people"))
+			}
+		}
+		return ephemeral(text)
+	},
+}
+
+var linkCommand = schejCommand{
+	Name:        "link",
+	Usage:       "/schej link",
+	Description: "Links your schej account, so that your commands act as you",
+	Execute: func(ctx context.Context, r schejRequest) *commands.Response {
+		return linkPrompt(r, "Open this link while you're signed in to schej to link your account.")
+	},
+}
+
+var unlinkCommand = schejCommand{
+	Name:        "unlink",
+	Usage:       "/schej unlink",
+	Description: "Unlinks your schej account",
+	Execute: func(ctx context.Context, r schejRequest) *commands.Response {
+		if err := db.ChatAccounts.Delete(ctx, models.SlackPlatform, r.TeamId, r.UserId); err != nil {
+			return commandFailed(ctx, err)
+		}
+		return ephemeral("Your schej account is unlinked.")
+	},
+}
+
+// Returns the event with the id in the first argument, with its responses populated, or nil and the message to
+// reply with if there isn't one
+func getEvent(ctx context.Context, args []string) (*models.Event, *commands.Response) {
+	if len(args) == 0 {
+		return nil, ephemeral("Which event? Add its id or the end of its link, e.g. `/schej status 2aB3c`.")
+	}
+
+	event, err := chat.GetEvent(ctx, args[0])
+	if err != nil {
+		return nil, commandFailed(ctx, err)
+	}
+	if event == nil {
+		return nil, ephemeral(fmt.Sprintf("There's no event with the id %s.", args[0]))
+	}
+
+	return event, nil
+}
+
+// Returns the message asking the Slack user to link their schej account
+func linkPrompt(r schejRequest, text string) *commands.Response {
+	l := chat.NewLinkRequest(models.SlackPlatform, r.TeamId, r.UserId)
+	return &commands.Response{ResponseType: "ephemeral", Text: text, Blocks: []bson.M{
+		section(text),
+		button("Link schej account", l.Url()),
+	}}
+}
+
+func validationFailed(ctx context.Context, err error) *commands.Response {
+	var apiErr *errs.Error
+	if !errors.As(err, &apiErr) {
+		return commandFailed(ctx, err)
+	}
+	details, ok := apiErr.Details.(validation.Errors)
+	if !ok {
+		return commandFailed(ctx, err)
+	}
+
+	text := "Couldn't create the event:"
+	for _, detail := range details {
+		text += "\n• " + detail.Message
+	}
+	return ephemeral(text)
+}
+
+func commandFailed(ctx context.Context, err error) *commands.Response {
+	logger.FromContext(ctx).Error("Slack command failed", "error", err)
+	return ephemeral("Something went wrong, please try again.")
+}
+
+func ephemeral(text string) *commands.Response {
+	return &commands.Response{ResponseType: "ephemeral", Text: text}
+}
+
+func section(text string) bson.M {
+	return bson.M{"type": "section", "text": bson.M{"type": "mrkdwn", "text": text}}
+}
+
+func button(text string, url string) bson.M {
+	return bson.M{"type": "actions", "elements": bson.A{bson.M{
+		"type": "button",
+		"text": bson.M{"type": "plain_text", "text": text},
+		"url":  url,
+	}}}
+}
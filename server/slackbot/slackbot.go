@@ -1,13 +1,17 @@
 package slackbot
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"schej.it/server/chatcommands"
+	"schej.it/server/models"
+	"schej.it/server/services/chat"
 	"schej.it/server/slackbot/commands"
 	"schej.it/server/utils"
 )
@@ -32,10 +36,14 @@ func SendMessage(message *commands.Response) {
 func InitSlackbot(router *gin.RouterGroup) {
 	slackbotRouter := router.Group("/slackbot")
 
-	slackbotRouter.POST("", execCommand)
+	slackbotRouter.POST("", verifySignature, execCommand)
+	slackbotRouter.GET("/link", chat.GetLinkHandler(models.SlackPlatform))
+	slackbotRouter.POST("/link", chat.LinkHandler(models.SlackPlatform))
 }
 
-// @Summary Gets the number of signed up users
+// @Summary Runs a slash command: the admin commands, or /schej for users
+// @Description Requests must be signed by Slack with SLACK_SIGNING_SECRET. The admin commands only run in SLACK_ADMIN_TEAM_ID,
+// @Description and in SLACK_ADMIN_CHANNEL_ID if it's set. Replies are posted to the response url
 // @Tags slackbot
 // @Accept x-www-form-urlencoded
 // @Produce json
@@ -46,6 +54,9 @@ func execCommand(c *gin.Context) {
 		Command     string `form:"command" binding:"required"`
 		Text        string `form:"text"`
 		ResponseUrl string `form:"response_url" binding:"required"`
+		TeamId      string `form:"team_id"`
+		ChannelId   string `form:"channel_id"`
+		UserId      string `form:"user_id"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
 		return
@@ -57,29 +68,51 @@ func execCommand(c *gin.Context) {
 		return
 	}
 
-	args := make([]string, 0)
-	r := regexp.MustCompile(`([^\s"']+)|"([^"]*)"|'([^']*)'`)
-	matches := r.FindAllStringSubmatch(decodedText, -1)
-	for _, match := range matches {
-		for _, el := range match[1:] {
-			if len(el) > 0 {
-				args = append(args, el)
-			}
+	args := chatcommands.SplitArgs(decodedText)
+
+	if payload.Command == "/schej" {
+		r := schejRequest{TeamId: payload.TeamId, UserId: payload.UserId}
+		name := ""
+		if len(args) > 0 {
+			name, r.Args = strings.ToLower(args[0]), args[1:]
 		}
-	}
+		ctx := context.WithoutCancel(c.Request.Context())
+		utils.RunInBackground(func() { commands.SendRawMessage(runSchejCommand(ctx, name, r), payload.ResponseUrl) })
 
-	command, ok := commands.CommandMap[payload.Command]
-	if !ok {
-		c.JSON(http.StatusOK, commands.Response{ResponseType: "ephemeral", Text: fmt.Sprintf("Command does not exist: %s", payload.Command)})
+		c.Status(http.StatusOK)
 		return
 	}
 
-	if len(args) > 0 && args[0] == "help" {
-		c.JSON(http.StatusOK, commands.Response{ResponseType: "ephemeral", Text: command.Description})
+	// The app is installed in other workspaces for /schej, so the admin commands are only shown to be there in the admin
+	// workspace
+	command := chatcommands.Find(strings.TrimPrefix(payload.Command, "/"))
+	if command == nil || !isAdminChannel(payload.TeamId, payload.ChannelId) {
+		c.JSON(http.StatusOK, commands.Response{ResponseType: "ephemeral", Text: fmt.Sprintf("Command does not exist: %s", payload.Command)})
 		return
 	}
 
-	go command.Execute(args, payload.ResponseUrl)
+	ctx := context.WithoutCancel(c.Request.Context())
+	utils.RunInBackground(func() {
+		message := chatcommands.Run(ctx, command, "/", args)
+		responseType := "in_channel"
+		if message.Ephemeral {
+			responseType = "ephemeral"
+		}
+		for _, slackMessage := range message.Slack() {
+			commands.SendRawMessage(&commands.Response{ResponseType: responseType, Text: slackMessage.Text, Blocks: slackMessage.Blocks}, payload.ResponseUrl)
+		}
+	})
 
 	c.Status(http.StatusOK)
 }
+
+// Returns whether the admin commands can be run in the channel: channels of SLACK_ADMIN_TEAM_ID, or only
+// SLACK_ADMIN_CHANNEL_ID if it's set. The admin commands are disabled if SLACK_ADMIN_TEAM_ID isn't set
+func isAdminChannel(teamId string, channelId string) bool {
+	adminTeamId := os.Getenv("SLACK_ADMIN_TEAM_ID")
+	if len(adminTeamId) == 0 || teamId != adminTeamId {
+		return false
+	}
+	adminChannelId := os.Getenv("SLACK_ADMIN_CHANNEL_ID")
+	return len(adminChannelId) == 0 || channelId == adminChannelId
+}
@@ -0,0 +1,163 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/config"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/slackbot/commands"
+)
+
+func TestSchej(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+	db.InitMemory("")
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("SLACK_ADMIN_TEAM_ID", "TADMIN")
+	t.Setenv("EMAIL_BACKEND", "none")
+	if _, err := config.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	user := models.User{FirstName: "Jane", Email: "jane@example.com", TimezoneOffset: 240}
+	db.Users.Insert(ctx, &user)
+
+	replies := make(chan commands.Response, 1)
+	responseServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reply commands.Response
+		json.NewDecoder(r.Body).Decode(&reply)
+		replies <- reply
+	}))
+	defer responseServer.Close()
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("test"))))
+	router.Use(func(c *gin.Context) { sessions.Default(c).Set("userId", user.Id.Hex()) })
+	router.Use(middleware.Errors())
+	InitSlackbot(router.Group("/api"))
+
+	// Sends a signed slash command from the team's channel C1
+	command := func(name string, text string, teamId string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := url.Values{"command": {name}, "text": {text}, "team_id": {teamId}, "channel_id": {"C1"}, "user_id": {"U1"}, "response_url": {responseServer.URL}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/api/slackbot", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		timestamp := time.Now().Unix()
+		req.Header.Set(timestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(signatureHeader, sign("secret", timestamp, []byte(body)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s returned %d", name, text, w.Code)
+		}
+		return w
+	}
+	// Returns the reply posted to the response url
+	nextReply := func() commands.Response {
+		t.Helper()
+		select {
+		case reply := <-replies:
+			return reply
+		case <-time.After(5 * time.Second):
+			t.Fatalf("command didn't reply")
+			return commands.Response{}
+		}
+	}
+	schej := func(text string) commands.Response {
+		t.Helper()
+		command("/schej", text, "T1")
+		return nextReply()
+	}
+
+	// Unsigned requests are rejected
+	req := httptest.NewRequest(http.MethodPost, "/api/slackbot", strings.NewReader("command=/num_users"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unsigned request to be rejected, got %d", w.Code)
+	}
+
+	// Admin commands only run in the admin workspace
+	if w := command("/num_users", "", "T1"); !strings.Contains(w.Body.String(), "Command does not exist") {
+		t.Errorf("expected /num_users to be hidden outside the admin workspace, got %s", w.Body.String())
+	}
+	command("/num_users", "", "TADMIN")
+	if reply := nextReply(); !strings.Contains(blocksJson(t, reply), "signed up users: 1") {
+		t.Errorf("unexpected /num_users reply %+v", reply)
+	}
+
+	// Commands need a linked account, which is linked by following the link in the reply while signed in
+	reply := schej(`new "Standup" next week 9-5`)
+	token := regexp.MustCompile(`token=([^"&]+)`).FindStringSubmatch(blocksJson(t, reply))
+	if token == nil {
+		t.Fatalf("expected a link prompt, got %+v", reply)
+	}
+	linkToken, _ := url.QueryUnescape(token[1])
+	postLink := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/slackbot/link", strings.NewReader(url.Values{"token": {linkToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Other sites can't post the link form for the signed in user
+	if w := postLink("https://evil.example"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected a cross origin link to be rejected, got %d", w.Code)
+	}
+	if account, _ := db.ChatAccounts.Get(ctx, models.SlackPlatform, "T1", "U1"); account != nil {
+		t.Fatalf("expected the cross origin link not to link the Slack user")
+	}
+
+	w = postLink("http://example.com")
+	if account, _ := db.ChatAccounts.Get(ctx, models.SlackPlatform, "T1", "U1"); w.Code != http.StatusOK || account == nil || account.UserId != user.Id {
+		t.Fatalf("expected the Slack user to be linked, got %d %+v", w.Code, account)
+	}
+
+	reply = schej(`new “Standup” next week 9-5`)
+	events, _ := db.Events.GetByUser(ctx, user.Id, user.Email)
+	if len(events) != 1 || events[0].Name != "Standup" || len(events[0].Dates) != 5 || *events[0].Duration != 8 || reply.ResponseType != "in_channel" {
+		t.Fatalf("expected a 5 day event, got %+v and reply %+v", events, reply)
+	}
+	// 9am in the user's timezone, which is 4 hours behind UTC
+	if hour := events[0].Dates[0].Time().UTC().Hour(); hour != 13 {
+		t.Errorf("expected the event to start at 13:00 UTC, got %d:00", hour)
+	}
+
+	reply = schej("status " + *events[0].ShortId)
+	if !strings.Contains(reply.Text, "*Standup* has 0 responses") {
+		t.Errorf("unexpected status reply %q", reply.Text)
+	}
+
+	reply = schej("remind " + *events[0].ShortId)
+	if !strings.Contains(reply.Text, "there's nobody to remind") {
+		t.Errorf("unexpected remind reply %q", reply.Text)
+	}
+}
+
+func blocksJson(t *testing.T, reply commands.Response) string {
+	data, err := json.Marshal(reply.Blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
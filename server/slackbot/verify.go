@@ -0,0 +1,70 @@
+package slackbot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+)
+
+// Slack signs every request with the app's signing secret: https://api.slack.com/authentication/verifying-requests-from-slack
+const (
+	signatureHeader = "X-Slack-Signature"
+	timestampHeader = "X-Slack-Request-Timestamp"
+
+	// Requests older than this are rejected, so that a signed request can't be replayed later
+	maxRequestAge = 5 * time.Minute
+)
+
+// Returns the app's signing secret, slash commands are disabled if it isn't set
+func signingSecret() string {
+	return os.Getenv("SLACK_SIGNING_SECRET")
+}
+
+// Rejects requests that weren't signed by Slack. Every request is rejected if there's no signing secret, since
+// there's no way to tell who sent them
+func verifySignature(c *gin.Context) {
+	secret := signingSecret()
+	if len(secret) == 0 {
+		c.Error(errs.InvalidSlackSignature)
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(errs.InvalidRequest.Wrap(err))
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	timestamp, err := strconv.ParseInt(c.GetHeader(timestampHeader), 10, 64)
+	age := time.Since(time.Unix(timestamp, 0))
+	if err != nil || age > maxRequestAge || age < -maxRequestAge {
+		c.Error(errs.InvalidSlackSignature)
+		c.Abort()
+		return
+	}
+
+	if !hmac.Equal([]byte(c.GetHeader(signatureHeader)), []byte(sign(secret, timestamp, body))) {
+		c.Error(errs.InvalidSlackSignature)
+		c.Abort()
+		return
+	}
+}
+
+// Returns the signature of a request body sent at the unix timestamp
+func sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(timestamp, 10) + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"schej.it/server/logger"
+)
+
+// Work that outlives the request that started it (e.g. sending emails), which the server waits for before
+// shutting down
+var backgroundTasks sync.WaitGroup
+
+// Runs f in a new goroutine that is waited for on shutdown. Panics in f are logged instead of crashing the server
+func RunInBackground(f func()) {
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+
+		// Recover from panics
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Logger.Error("Panic in background task", "panic", err, "stack", string(debug.Stack()))
+			}
+		}()
+
+		f()
+	}()
+}
+
+// Waits for every function started with RunInBackground to return. Returns ctx.Err() if ctx is done first
+func WaitForBackgroundTasks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		backgroundTasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -8,33 +8,9 @@ import (
 	"os"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"gopkg.in/gomail.v2"
 	"schej.it/server/logger"
 )
 
-// Send email to the given email
-func SendEmail(toEmail string, subject string, body string, contentType string) {
-	if contentType == "" {
-		contentType = "text/plain"
-	}
-
-	appPassword := os.Getenv("GMAIL_APP_PASSWORD")
-	fromEmail := os.Getenv("SCHEJ_EMAIL_ADDRESS")
-
-	m := gomail.NewMessage()
-	m.SetHeader("From", fromEmail)
-	m.SetHeader("To", toEmail)
-	m.SetHeader("Subject", subject)
-	m.SetBody(contentType, body)
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, fromEmail, appPassword)
-
-	// Send the email to Bob, Cora and Dan.
-	if err := d.DialAndSend(m); err != nil {
-		logger.StdErr.Println(err)
-	}
-}
-
 func AddUserToMailchimp(email string, firstName string, lastName string) {
 	// Adds the given user to the default mailchimp audience
 	apiKey := os.Getenv("MAILCHIMP_API_KEY")
@@ -1,23 +1,33 @@
 package utils
 
 import (
+	"net"
 	"net/url"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"schej.it/server/logger"
 )
 
-func ParseArrayQueryParam(s string) []string {
+func ParseArrayQueryParam(s string) ([]string, error) {
 	decoded, err := url.QueryUnescape(s)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 	arr := strings.Split(decoded, ",")
-	return arr
+	return arr, nil
 }
 
 // Returns origin of the given request (i.e. http://localhost:8080 or http://localhost:3002 or https://schej.it)
 func GetOrigin(c *gin.Context) string {
 	return c.Request.Header.Get("Origin")
 }
+
+// Returns whether the ip is a public address, i.e. not loopback, private, link-local, multicast or unspecified.
+// Requests to user-given urls, e.g. webhooks, must only go to public addresses
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// Carrier-grade NAT addresses, which aren't reachable from the internet either
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
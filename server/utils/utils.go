@@ -2,12 +2,7 @@ package utils
 
 import (
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +13,7 @@ import (
 	"github.com/brianvoe/sjwt"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/config"
 	"schej.it/server/logger"
 	"schej.it/server/models"
 )
@@ -37,22 +33,12 @@ func PrintJson(s interface{}) {
 	fmt.Println(string(data))
 }
 
-func ParseJWT(jwt string) sjwt.Claims {
-	claims, err := sjwt.Parse(jwt)
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
-	return claims
+func ParseJWT(jwt string) (sjwt.Claims, error) {
+	return sjwt.Parse(jwt)
 }
 
-func StringToObjectID(s string) primitive.ObjectID {
-	objectID, err := primitive.ObjectIDFromHex(s)
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
-	return objectID
+func StringToObjectID(s string) (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(s)
 }
 
 // Returns the currently signed in user
@@ -113,15 +99,9 @@ func PrintHttpResponse(resp *http.Response) {
 	resp.Body = io.NopCloser(bytes.NewBuffer(body))
 }
 
-// Returns the correct base url, based on whether we're on dev or prod
+// Returns the url of the frontend, which defaults to https://schej.it on prod and http://localhost:8080 on dev
 func GetBaseUrl() string {
-	var baseUrl string
-	if IsRelease() {
-		baseUrl = "https://schej.it"
-	} else {
-		baseUrl = "http://localhost:8080"
-	}
-	return baseUrl
+	return config.Get().Server.BaseUrl
 }
 
 // Returns the value of the first non nil pointer in `args`.
@@ -162,53 +142,6 @@ func GetPrimaryAccountKey(user *models.User) string {
 	return *user.PrimaryAccountKey
 }
 
-func Encode(b []byte) string {
-	return base64.StdEncoding.EncodeToString(b)
-}
-
-func Decode(s string) []byte {
-	data, err := base64.StdEncoding.DecodeString(s)
-	if err != nil {
-		panic(err)
-	}
-	return data
-}
-
-// Encrypts the given text using the given secret
-func Encrypt(text string) (string, error) {
-	block, err := aes.NewCipher([]byte(os.Getenv("ENCRYPTION_KEY")))
-	if err != nil {
-		return "", err
-	}
-	plainText := []byte(text)
-	cipherText := make([]byte, aes.BlockSize+len(plainText))
-	iv := cipherText[:aes.BlockSize]
-	if _, err := rand.Read(iv); err != nil {
-		return "", err
-	}
-	cfb := cipher.NewCFBEncrypter(block, iv)
-	cfb.XORKeyStream(cipherText[aes.BlockSize:], plainText)
-	return Encode(cipherText), nil
-}
-
-// Decrypts the given text using the given secret
-func Decrypt(text string) (string, error) {
-	block, err := aes.NewCipher([]byte(os.Getenv("ENCRYPTION_KEY")))
-	if err != nil {
-		return "", err
-	}
-	cipherText := Decode(text)
-	if len(cipherText) < aes.BlockSize {
-		return "", errors.New("ciphertext too short")
-	}
-	iv := cipherText[:aes.BlockSize]
-	cipherText = cipherText[aes.BlockSize:]
-	cfb := cipher.NewCFBDecrypter(block, iv)
-	plainText := make([]byte, len(cipherText))
-	cfb.XORKeyStream(plainText, cipherText)
-	return string(plainText), nil
-}
-
 // ConvertEventToOldFormat converts an event's responses from ResponsesList to ResponsesMap format
 // for backward compatibility with older code
 func ConvertEventToOldFormat(event *models.Event) {
@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Limits on event payloads
+const (
+	MaxNameLength        = 200
+	MaxDescriptionLength = 5000
+	MaxDates             = 366
+	MaxDurationHours     = 24
+	MaxRemindees         = 100
+	MaxAttendees         = 100
+
+	MaxReminders             = 5
+	MaxReminderOffsetHours   = 30 * 24
+	MaxReminderMessageLength = 1000
+)
+
+// The fields of a create or edit event payload
+type Event struct {
+	Name                     string
+	Description              *string
+	Duration                 *float32
+	Dates                    []primitive.DateTime
+	Type                     models.EventType
+	IsSignUpForm             *bool
+	DaysOnly                 *bool
+	Remindees                []string
+	ReminderSettings         *models.ReminderSettings
+	Attendees                []string
+	SendEmailAfterXResponses *int
+}
+
+// Returns errs.ValidationFailed describing every invalid field of the event, or nil if it's valid
+func ValidateEvent(e Event) error {
+	var errors Errors
+
+	if len(strings.TrimSpace(e.Name)) == 0 {
+		errors.Add("name", Required, "Name is required")
+	} else if utf8.RuneCountInString(e.Name) > MaxNameLength {
+		errors.Add("name", TooLong, "Name must be at most %d characters", MaxNameLength)
+	}
+	if e.Description != nil && utf8.RuneCountInString(*e.Description) > MaxDescriptionLength {
+		errors.Add("description", TooLong, "Description must be at most %d characters", MaxDescriptionLength)
+	}
+
+	// Days only events don't have times, so their duration is ignored
+	if e.Duration == nil {
+		errors.Add("duration", Required, "Duration is required")
+	} else if duration := float64(*e.Duration); duration < 0 || duration > MaxDurationHours {
+		errors.Add("duration", OutOfRange, "Duration must be between 0 and %d hours", MaxDurationHours)
+	} else if slots := duration * float64(time.Hour/models.SlotDuration); !utils.Coalesce(e.DaysOnly) && slots != math.Round(slots) {
+		errors.Add("duration", NotOnGrid, "Duration must be a multiple of %v", models.SlotDuration)
+	}
+
+	errors.checkDates(e.Dates, e.Type)
+
+	switch e.Type {
+	case models.SPECIFIC_DATES, models.DOW:
+		if len(e.Attendees) > 0 {
+			errors.Add("attendees", NotAllowed, "Only availability groups have attendees")
+		}
+	case models.GROUP:
+		if len(e.Remindees) > 0 {
+			errors.Add("remindees", NotAllowed, "Availability groups can't have remindees")
+		}
+		if utils.Coalesce(e.IsSignUpForm) {
+			errors.Add("isSignUpForm", NotAllowed, "Availability groups can't be sign up forms")
+		}
+	default:
+		errors.Add("type", Invalid, "Type must be one of %q, %q, or %q", models.SPECIFIC_DATES, models.DOW, models.GROUP)
+	}
+	if utils.Coalesce(e.DaysOnly) && e.Type != models.SPECIFIC_DATES {
+		errors.Add("daysOnly", NotAllowed, "Only events on specific dates can be days only")
+	}
+
+	errors.checkEmails("remindees", e.Remindees, MaxRemindees)
+	if e.ReminderSettings != nil {
+		if e.Type == models.GROUP {
+			errors.Add("reminderSettings", NotAllowed, "Availability groups can't have remindees")
+		} else {
+			errors.checkReminderSettings(e.ReminderSettings)
+		}
+	}
+	errors.checkEmails("attendees", e.Attendees, MaxAttendees)
+
+	// -1 means the email has already been sent or is disabled
+	if e.SendEmailAfterXResponses != nil && *e.SendEmailAfterXResponses < -1 {
+		errors.Add("sendEmailAfterXResponses", OutOfRange, "Must be -1 or a number of responses")
+	}
+
+	return errors.Err()
+}
+
+// Checks that there are dates, that each one starts a slot, and that days of the week events span a single week
+func (e *Errors) checkDates(dates []primitive.DateTime, eventType models.EventType) {
+	if len(dates) == 0 {
+		e.Add("dates", Required, "At least one date is required")
+		return
+	}
+	if len(dates) > MaxDates {
+		e.Add("dates", TooMany, "At most %d dates are allowed", MaxDates)
+		return
+	}
+
+	seen := make(map[primitive.DateTime]bool)
+	first, last := dates[0].Time(), dates[0].Time()
+	for i, date := range dates {
+		field := fmt.Sprintf("dates[%d]", i)
+		t := date.Time()
+		if !t.Truncate(models.SlotDuration).Equal(t) {
+			e.Add(field, NotOnGrid, "Dates must start on a multiple of %v", models.SlotDuration)
+		} else if seen[date] {
+			e.Add(field, Duplicate, "Dates must be unique")
+		}
+		seen[date] = true
+
+		if t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+
+	if eventType == models.DOW && last.Sub(first) >= 7*24*time.Hour {
+		e.Add("dates", OutOfRange, "Days of the week must be within a single week")
+	}
+}
+
+// Checks the reminder offsets, message, and quiet hours
+func (e *Errors) checkReminderSettings(s *models.ReminderSettings) {
+	if len(s.Offsets) > MaxReminders {
+		e.Add("reminderSettings.offsets", TooMany, "At most %d reminders are allowed", MaxReminders)
+	}
+	for i, offset := range s.Offsets {
+		if offset < 0 || offset > MaxReminderOffsetHours {
+			e.Add(fmt.Sprintf("reminderSettings.offsets[%d]", i), OutOfRange, "Reminders must be between 0 and %d hours apart from the deadline or the remindee being added", MaxReminderOffsetHours)
+		}
+	}
+	if utf8.RuneCountInString(s.Message) > MaxReminderMessageLength {
+		e.Add("reminderSettings.message", TooLong, "Message must be at most %d characters", MaxReminderMessageLength)
+	}
+	if q := s.QuietHours; q != nil {
+		if q.Start < 0 || q.Start > 23 || q.End < 0 || q.End > 23 {
+			e.Add("reminderSettings.quietHours", OutOfRange, "Quiet hours must start and end between 0 and 23")
+		} else if q.Start == q.End {
+			e.Add("reminderSettings.quietHours", Invalid, "Quiet hours must start and end at different hours")
+		}
+	}
+}
+
+// The fields of an update response payload that are checked against the event
+type Response struct {
+	Guest        bool
+	Name         string
+	Email        string
+	Availability []primitive.DateTime
+	IfNeeded     []primitive.DateTime
+}
+
+// Returns errs.ValidationFailed describing every invalid field of the response to the event, or nil if it's valid
+func ValidateResponse(event *models.Event, r Response) error {
+	var errors Errors
+
+	if r.Guest {
+		if len(strings.TrimSpace(r.Name)) == 0 {
+			errors.Add("name", Required, "Name is required")
+		} else if utf8.RuneCountInString(r.Name) > MaxNameLength {
+			errors.Add("name", TooLong, "Name must be at most %d characters", MaxNameLength)
+		}
+	}
+	if len(r.Email) > 0 && !IsEmail(r.Email) {
+		errors.Add("email", InvalidEmail, "%q is not a valid email address", r.Email)
+	}
+
+	// Availability groups are filled in from calendars and manual availability, which aren't limited to the grid
+	if event.Type != models.GROUP {
+		onGrid := make(map[primitive.DateTime]bool)
+		for _, t := range event.SlotGrid().Times() {
+			onGrid[t] = true
+		}
+		errors.checkOnGrid("availability", r.Availability, onGrid)
+		errors.checkOnGrid("ifNeeded", r.IfNeeded, onGrid)
+	}
+
+	return errors.Err()
+}
+
+// Adds an error for the first time that isn't the start of a slot of the event, there can be hundreds
+func (e *Errors) checkOnGrid(field string, times []primitive.DateTime, onGrid map[primitive.DateTime]bool) {
+	for i, t := range times {
+		if !onGrid[t] {
+			e.Add(fmt.Sprintf("%s[%d]", field, i), NotOnGrid, "%s is not one of the event's time slots", t.Time().UTC().Format(time.RFC3339))
+			return
+		}
+	}
+}
+
+// Checks the time an event is scheduled for
+func ValidateSchedule(start primitive.DateTime, end primitive.DateTime) error {
+	var errors Errors
+
+	if !end.Time().After(start.Time()) {
+		errors.Add("endDate", OutOfRange, "End date must be after the start date")
+	}
+
+	return errors.Err()
+}
@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
+	"schej.it/server/models"
+)
+
+func TestValidateEvent(t *testing.T) {
+	date := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	dates := func(times ...time.Time) []primitive.DateTime {
+		result := make([]primitive.DateTime, len(times))
+		for i, t := range times {
+			result[i] = primitive.NewDateTimeFromTime(t)
+		}
+		return result
+	}
+	duration := func(hours float32) *float32 { return &hours }
+	yes := true
+
+	tests := []struct {
+		name   string
+		event  Event
+		fields []string
+	}{
+		{"valid", Event{Name: "Team sync", Duration: duration(1.5), Dates: dates(date), Type: models.SPECIFIC_DATES, Remindees: []string{"ada@example.com"}}, nil},
+		{"days only", Event{Name: "Offsite", Duration: duration(0), Dates: dates(date.Truncate(24 * time.Hour)), Type: models.SPECIFIC_DATES, DaysOnly: &yes}, nil},
+		{"group", Event{Name: "Team", Duration: duration(0), Dates: dates(date), Type: models.GROUP, Attendees: []string{"ada@example.com"}}, nil},
+		{"missing name", Event{Name: "  ", Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES}, []string{"name"}},
+		{"long name", Event{Name: strings.Repeat("a", MaxNameLength+1), Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES}, []string{"name"}},
+		{"unknown type", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date), Type: "weekly"}, []string{"type"}},
+		{"duration too long", Event{Name: "Team sync", Duration: duration(25), Dates: dates(date), Type: models.SPECIFIC_DATES}, []string{"duration"}},
+		{"duration off grid", Event{Name: "Team sync", Duration: duration(1.1), Dates: dates(date), Type: models.SPECIFIC_DATES}, []string{"duration"}},
+		{"no dates", Event{Name: "Team sync", Duration: duration(1), Type: models.SPECIFIC_DATES}, []string{"dates"}},
+		{"dates off grid and duplicated", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date, date.Add(7*time.Minute), date), Type: models.SPECIFIC_DATES}, []string{"dates[1]", "dates[2]"}},
+		{"dow over a week", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date, date.AddDate(0, 0, 7)), Type: models.DOW}, []string{"dates"}},
+		{"days only dow", Event{Name: "Team sync", Duration: duration(0), Dates: dates(date), Type: models.DOW, DaysOnly: &yes}, []string{"daysOnly"}},
+		{"sign up group", Event{Name: "Team", Duration: duration(0), Dates: dates(date), Type: models.GROUP, IsSignUpForm: &yes}, []string{"isSignUpForm"}},
+		{"attendees on event", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES, Attendees: []string{"ada@example.com"}}, []string{"attendees"}},
+		{"bad remindees", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES, Remindees: []string{"ada@example.com", "Ada <ada@example.com>", "ADA@example.com"}}, []string{"remindees[1]", "remindees[2]"}},
+		{"too many remindees", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES, Remindees: make([]string, MaxRemindees+1)}, []string{"remindees"}},
+		{"bad reminder settings", Event{Name: "Team sync", Duration: duration(1), Dates: dates(date), Type: models.SPECIFIC_DATES, ReminderSettings: &models.ReminderSettings{Offsets: []float64{24, -1}, QuietHours: &models.QuietHours{Start: 22, End: 22}}}, []string{"reminderSettings.offsets[1]", "reminderSettings.quietHours"}},
+	}
+	for _, test := range tests {
+		err := ValidateEvent(test.event)
+		if fields := fieldsOf(t, err); !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: expected errors for %v, got %v", test.name, test.fields, err)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	date := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	duration := float32(1)
+	event := &models.Event{Duration: &duration, Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(date)}, Type: models.SPECIFIC_DATES}
+
+	onGrid := []primitive.DateTime{primitive.NewDateTimeFromTime(date), primitive.NewDateTimeFromTime(date.Add(45 * time.Minute))}
+	if err := ValidateResponse(event, Response{Guest: true, Name: "Alice", Availability: onGrid}); err != nil {
+		t.Errorf("expected a valid response, got %v", err)
+	}
+
+	offGrid := append(onGrid, primitive.NewDateTimeFromTime(date.Add(time.Hour)))
+	err := ValidateResponse(event, Response{Guest: true, Email: "alice", Availability: offGrid, IfNeeded: offGrid[2:]})
+	if fields := fieldsOf(t, err); !reflect.DeepEqual(fields, []string{"name", "email", "availability[2]", "ifNeeded[0]"}) {
+		t.Errorf("unexpected errors %v", err)
+	}
+}
+
+// Returns the fields of the validation errors in err
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *errs.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, errs.ValidationFailed) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range apiErr.Details.(Errors) {
+		fields = append(fields, fieldErr.Field)
+	}
+	return fields
+}
@@ -0,0 +1,29 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+
+	"schej.it/server/models"
+)
+
+// Checks the modes and digest hour of a notification preferences update
+func ValidateNotificationPreferences(types map[models.NotificationType]models.NotificationMode, digestHour *int) error {
+	var e Errors
+	if digestHour != nil && (*digestHour < 0 || *digestHour > 23) {
+		e.Add("digestHour", OutOfRange, "Digest hour must be between 0 and 23")
+	}
+	for notificationType, mode := range types {
+		field := fmt.Sprintf("types.%s", notificationType)
+		switch {
+		case !slices.Contains(models.NotificationTypes, notificationType):
+			e.Add(field, Invalid, "%q is not a type of notification", notificationType)
+		case mode != models.NotifyInstant && mode != models.NotifyDigest && mode != models.NotifyOff:
+			e.Add(field, Invalid, "Mode must be instant, digest, or off")
+		case mode == models.NotifyDigest && !notificationType.SupportsDigest():
+			e.Add(field, NotAllowed, "%s notifications can't be sent in a digest", notificationType)
+		}
+	}
+
+	return e.Err()
+}
@@ -0,0 +1,76 @@
+/* Validation of request payloads beyond what binding tags can express */
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"schej.it/server/errs"
+)
+
+// A problem with a single field of a payload, which the frontend shows next to that field
+type FieldError struct {
+	// Path of the field in the JSON payload, e.g. "dates" or "remindees[2]"
+	Field string `json:"field"`
+
+	// Machine readable reason, e.g. "invalid-email"
+	Code string `json:"code"`
+
+	Message string `json:"message"`
+}
+
+// Field error codes
+const (
+	Required     = "required"
+	Invalid      = "invalid"
+	InvalidEmail = "invalid-email"
+	TooLong      = "too-long"
+	TooMany      = "too-many"
+	OutOfRange   = "out-of-range"
+	Duplicate    = "duplicate"
+	NotOnGrid    = "not-on-grid"
+	NotAllowed   = "not-allowed"
+)
+
+// Collects the field errors of a payload
+type Errors []FieldError
+
+func (e *Errors) Add(field string, code string, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Returns errs.ValidationFailed with the field errors as its details, or nil if there are none
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return errs.ValidationFailed.WithDetails(e)
+}
+
+// Returns whether s is a bare email address, e.g. "ada@example.com" but not "Ada <ada@example.com>"
+func IsEmail(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+// Adds an error for each invalid or duplicate email in the list, and one if the list has more than max emails
+func (e *Errors) checkEmails(field string, emails []string, max int) {
+	if len(emails) > max {
+		e.Add(field, TooMany, "At most %d emails are allowed", max)
+		return
+	}
+
+	// Emails are compared case insensitively everywhere else
+	seen := make(map[string]bool)
+	for i, email := range emails {
+		itemField := fmt.Sprintf("%s[%d]", field, i)
+		key := strings.ToLower(email)
+		if !IsEmail(email) {
+			e.Add(itemField, InvalidEmail, "%q is not a valid email address", email)
+		} else if seen[key] {
+			e.Add(itemField, Duplicate, "%s is listed more than once", email)
+		}
+		seen[key] = true
+	}
+}
@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Limits on webhooks
+const (
+	MaxWebhooks      = 20
+	MaxWebhookUrlLen = 2000
+)
+
+// Checks a new webhook, given how many webhooks its owner already has
+func ValidateWebhook(webhookUrl string, events []models.WebhookEventType, numWebhooks int) error {
+	var e Errors
+
+	if len(webhookUrl) == 0 {
+		e.Add("url", Required, "Url is required")
+	} else if u, err := url.Parse(webhookUrl); err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Hostname()) == 0 {
+		e.Add("url", Invalid, "Url must be an http or https url")
+	} else if u.Scheme != "https" && utils.IsRelease() {
+		e.Add("url", Invalid, "Url must be an https url")
+	} else if len(webhookUrl) > MaxWebhookUrlLen {
+		e.Add("url", TooLong, "Url must be at most %d characters", MaxWebhookUrlLen)
+	} else if !isPublicHost(u.Hostname()) {
+		e.Add("url", Invalid, "Url must be on a public host")
+	}
+	if numWebhooks >= MaxWebhooks {
+		e.Add("url", TooMany, "At most %d webhooks are allowed", MaxWebhooks)
+	}
+
+	for i, eventType := range events {
+		if !slices.Contains(models.WebhookEventTypes, eventType) {
+			e.Add(fmt.Sprintf("events[%d]", i), Invalid, "%q is not a type of webhook event", eventType)
+		}
+	}
+
+	return e.Err()
+}
+
+// Returns whether every address of the host is public. Hosts that can't be resolved aren't, since webhooks to them
+// can't be delivered. The address is checked again when delivering, in case the host's addresses change
+func isPublicHost(host string) bool {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = net.LookupIP(host); err != nil || len(ips) == 0 {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if !utils.IsPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"testing"
+)
+
+func TestValidateWebhookUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		release bool
+		valid   bool
+	}{
+		{url: "https://93.184.216.34/hook", release: true, valid: true},
+		{url: "http://93.184.216.34/hook", valid: true},
+		{url: "http://93.184.216.34/hook", release: true},
+		{url: "ftp://93.184.216.34/hook"},
+		{url: "http://localhost:8080/hook"},
+		{url: "http://127.0.0.1/hook"},
+		{url: "http://10.0.0.5/hook"},
+		{url: "http://169.254.169.254/latest/meta-data"},
+		{url: "http://0.0.0.0/hook"},
+		{url: "http://[::1]/hook"},
+		{url: "http://[fe80::1]/hook"},
+	}
+	for _, test := range tests {
+		mode := "debug"
+		if test.release {
+			mode = "release"
+		}
+		t.Setenv("GIN_MODE", mode)
+
+		if err := ValidateWebhook(test.url, nil, 0); (err == nil) != test.valid {
+			t.Errorf("ValidateWebhook(%q) in %s mode = %v, want valid %v", test.url, mode, err, test.valid)
+		}
+	}
+}